@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/service/apikeysvc"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	apikeyCreateCmd.Flags().String("user", "", "id of the user the key acts as")
+	apikeyCreateCmd.Flags().String("name", "", "name telling what the key is for")
+	apikeyCreateCmd.Flags().StringSlice("scope", nil, "scopes of the key, admin and/or user")
+	apikeyCreateCmd.Flags().Duration("expires-in", 0, "lifetime of the key, zero never expires")
+	_ = apikeyCreateCmd.MarkFlagRequired("user")
+	_ = apikeyCreateCmd.MarkFlagRequired("name")
+	_ = apikeyCreateCmd.MarkFlagRequired("scope")
+
+	apikeyCmd.AddCommand(apikeyCreateCmd, apikeyListCmd, apikeyRevokeCmd)
+	rootCmd.AddCommand(apikeyCmd)
+}
+
+var apikeyCmd = &cobra.Command{
+	Use:   "apikey",
+	Short: "Manage the API keys of batch jobs",
+}
+
+var apikeyCreateCmd = &cobra.Command{
+	Use:   "create",
+	Args:  cobra.NoArgs,
+	Short: "Create an API key, it is only printed once",
+	Run: func(cmd *cobra.Command, args []string) {
+		flags := cmd.Flags()
+		userID, _ := flags.GetString("user")
+		name, _ := flags.GetString("name")
+		scopes, err := flags.GetStringSlice("scope")
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+		expiresIn, err := flags.GetDuration("expires-in")
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		data := &domain.APIKey{
+			Name:   name,
+			UserID: userID,
+			Scopes: scopes,
+		}
+		if expiresIn > 0 {
+			expiresAt := time.Now().Add(expiresIn)
+			data.ExpiresAt = &expiresAt
+		}
+
+		res, err := initAPIKeySvc().CreateAPIKey(data)
+		if err != nil {
+			log.Fatalf("error create api key: %v", err)
+		}
+
+		log.Printf("created api key %s for user %s\n", res.ID, res.UserID)
+		fmt.Println(res.Key)
+	},
+}
+
+var apikeyListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "List API keys, revoked and expired ones included",
+	Run: func(cmd *cobra.Command, args []string) {
+		keys, err := initAPIKeySvc().ListAPIKeys()
+		if err != nil {
+			log.Fatalf("error list api keys: %v", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tUSER\tSCOPES\tEXPIRES\tLAST USED\tREVOKED")
+		for _, k := range keys {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				k.ID, k.Name, k.Prefix, k.UserID, strings.Join(k.Scopes, ","),
+				formatTime(k.ExpiresAt), formatTime(k.LastUsedAt), formatTime(k.RevokedAt))
+		}
+
+		if err = w.Flush(); err != nil {
+			log.Fatalf("err: %v", err)
+		}
+	},
+}
+
+var apikeyRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Revoke an API key, it stops working right away",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := initAPIKeySvc().RevokeAPIKey(args[0])
+		if err != nil {
+			log.Fatalf("error revoke api key: %v", err)
+		}
+
+		log.Printf("revoked api key %s\n", args[0])
+	},
+}
+
+// initAPIKeySvc works on the configured storage directly, so keys can be
+// managed before any administrator can sign in.
+func initAPIKeySvc() *apikeysvc.Service {
+	cfg := initConfig()
+	if cfg.Storage.Driver == storageDriverMemory {
+		log.Fatal("memory storage is not shared with the http process, api keys can not be managed")
+	}
+
+	repo, err := initRepository(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("error init repository: %v", err)
+	}
+
+	return apikeysvc.New(repo)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/SawitProRecruitment/UserService/lib/blocklist"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	blocklistBuildCmd.Flags().String("format", string(blocklist.FormatPlain), "source list format, plain passwords or sha1 hex digests")
+	blocklistBuildCmd.Flags().Float64("fp-rate", blocklist.DefaultFalsePositiveRate, "false positive rate of the bloom filter")
+	blocklistCmd.AddCommand(blocklistBuildCmd)
+	rootCmd.AddCommand(blocklistCmd)
+}
+
+var blocklistCmd = &cobra.Command{
+	Use:   "blocklist",
+	Short: "Manage the breached password blocklist",
+}
+
+var blocklistBuildCmd = &cobra.Command{
+	Use:   "build <source> <index>",
+	Args:  cobra.ExactArgs(2),
+	Short: "Build blocklist index from a plain password or SHA-1 hash list",
+	Run: func(cmd *cobra.Command, args []string) {
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		fpRate, err := cmd.Flags().GetFloat64("fp-rate")
+		if err != nil {
+			log.Fatalf("err: %v", err)
+		}
+
+		src, err := os.Open(args[0])
+		if err != nil {
+			log.Fatalf("error open source: %v", err)
+		}
+		defer src.Close()
+
+		dst, err := os.Create(args[1])
+		if err != nil {
+			log.Fatalf("error create index: %v", err)
+		}
+
+		total, err := blocklist.Build(src, dst, blocklist.SourceFormat(format), fpRate)
+		if err != nil {
+			_ = dst.Close()
+			log.Fatalf("error build index: %v", err)
+		}
+
+		if err = dst.Close(); err != nil {
+			log.Fatalf("error write index: %v", err)
+		}
+
+		log.Printf("indexed %d entries into %s\n", total, args[1])
+	},
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/SawitProRecruitment/UserService/core/service/apikeysvc"
+	"github.com/SawitProRecruitment/UserService/generated/userpb"
+	sawitgrpc "github.com/SawitProRecruitment/UserService/handler/grpc"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	rootCmd.AddCommand(grpcCmd)
+}
+
+var grpcCmd = &cobra.Command{
+	Use:   "grpc",
+	Short: "Run the gRPC Server",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := initConfig()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := initLogger()
+
+		repo, err := initRepository(ctx, cfg)
+		if err != nil {
+			log.Fatalf("error init repository: %v", err)
+		}
+
+		userRepo, userCache := initUserCache(cfg.UserCache, repo)
+		if userCache != nil {
+			go reportCacheStats(ctx, logger, userCache, cfg.UserCache.StatsInterval)
+		}
+
+		phoneParser, err := initPhoneParser(cfg.Phone)
+		if err != nil {
+			log.Fatalf("error init phone parser: %v", err)
+		}
+
+		libLocker := locker.New(cfg.AES.SecretKey)
+		apiKeySvc := apikeysvc.New(repo)
+		authSvc, err := initAuthSvc(cfg, logger, phoneParser, libLocker, apiKeySvc, userRepo)
+		if err != nil {
+			log.Fatalf("error init auth service: %v", err)
+		}
+
+		userSvc, err := initUserSvc(cfg.Password, phoneParser, userRepo)
+		if err != nil {
+			log.Fatalf("error init user service: %v", err)
+		}
+
+		proxies, err := parseTrustedProxies(cfg.GRPC.TrustedProxies)
+		if err != nil {
+			log.Fatalf("error parse trusted proxies: %v", err)
+		}
+
+		// gRPC handler based on proto/user.proto
+		handler := sawitgrpc.NewHandler(logger, userSvc, authSvc, proxies)
+		s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+			handler.UnaryRequestID,
+			handler.UnaryLogging,
+			handler.UnaryError,
+		))
+		userpb.RegisterUserServiceServer(s, handler)
+
+		lis, err := net.Listen("tcp", cfg.GRPC.Address)
+		if err != nil {
+			log.Fatalf("error listen: %v", err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			s.GracefulStop()
+		}()
+
+		logger.Info(fmt.Sprintf("running at %s", lis.Addr()))
+		if err = s.Serve(lis); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
@@ -4,10 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
-	"github.com/SawitProRecruitment/UserService/core/service/usersvc"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/apikeysvc"
+	"github.com/SawitProRecruitment/UserService/core/service/idempotencysvc"
 	sawithttp "github.com/SawitProRecruitment/UserService/handler/http"
 	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/repository/cache"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 )
 
@@ -23,22 +28,57 @@ var httpCmd = &cobra.Command{
 		ctx := context.Background()
 		logger := initLogger()
 
-		repo, err := initPostgres(ctx, cfg.DB)
+		repo, err := initRepository(ctx, cfg)
 		if err != nil {
-			log.Fatalf("error init postgres repo: %v", err)
+			log.Fatalf("error init repository: %v", err)
+		}
+
+		userRepo, userCache := initUserCache(cfg.UserCache, repo)
+		if userCache != nil {
+			go reportCacheStats(ctx, logger, userCache, cfg.UserCache.StatsInterval)
+		}
+
+		phoneParser, err := initPhoneParser(cfg.Phone)
+		if err != nil {
+			log.Fatalf("error init phone parser: %v", err)
 		}
 
 		libLocker := locker.New(cfg.AES.SecretKey)
-		authSvc, err := initAuthSvc(cfg.Auth, repo)
+		apiKeySvc := apikeysvc.New(repo)
+		authSvc, err := initAuthSvc(cfg, logger, phoneParser, libLocker, apiKeySvc, userRepo)
 		if err != nil {
 			log.Fatalf("error init auth service: %v", err)
 		}
 
-		userSvc := usersvc.New(repo)
+		userSvc, err := initUserSvc(cfg.Password, phoneParser, userRepo)
+		if err != nil {
+			log.Fatalf("error init user service: %v", err)
+		}
+
+		webhookSvc, err := initWebhookSvc(cfg.Webhook, libLocker, repo)
+		if err != nil {
+			log.Fatalf("error init webhook service: %v", err)
+		}
+
+		oauthSvc := initOAuthSvc(cfg.OAuth, repo, authSvc, userSvc)
+
+		go prune(ctx, logger, "login history events", authSvc.PruneLoginHistory, cfg.LoginHistory.PruneInterval)
+		go prune(ctx, logger, "mfa challenges", authSvc.PruneMFAChallenges, cfg.Auth.MFAPruneInterval)
+		go prune(ctx, logger, "oauth authorization codes", oauthSvc.PruneCodes, cfg.OAuth.PruneInterval)
+
+		// a zero ttl disables Idempotency-Key support
+		var idempotencySvc port.IdempotencyService
+		if cfg.Idempotency.TTL > 0 {
+			idempotencySvc = idempotencysvc.New(idempotencysvc.ServiceOpts{TTL: cfg.Idempotency.TTL}, repo)
+			go prune(ctx, logger, "idempotency keys", idempotencySvc.Prune, cfg.Idempotency.PruneInterval)
+		}
 
 		// HTTP handler based on api.yml
-		handler := sawithttp.NewHandler(logger, libLocker, userSvc, authSvc)
-		s := initServer(cfg.Server, handler)
+		handler := sawithttp.NewHandler(logger, libLocker, userSvc, authSvc, webhookSvc, oauthSvc, apiKeySvc, cfg.Server.ProblemTypeBase)
+		s, err := initServer(cfg.Server, handler, idempotencySvc)
+		if err != nil {
+			log.Fatalf("error init http server: %v", err)
+		}
 
 		// running http server
 		lock := make(chan error)
@@ -54,3 +94,58 @@ var httpCmd = &cobra.Command{
 		}
 	},
 }
+
+// prune deletes expired rows with fn every interval until ctx is done, a
+// zero interval disables it.
+func prune(ctx context.Context, logger *logrus.Logger, name string, fn func() (int64, error), interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := fn()
+		if err != nil {
+			logger.WithError(err).Error("error prune " + name)
+		} else if n > 0 {
+			logger.Info(fmt.Sprintf("pruned %d %s", n, name))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportCacheStats logs the counters of the user cache every interval until
+// ctx is done, a zero interval disables it.
+func reportCacheStats(ctx context.Context, logger *logrus.Logger, c *cache.Repository, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		stats := c.Stats()
+		logger.WithFields(logrus.Fields{
+			"hits":            stats.Hits,
+			"misses":          stats.Misses,
+			"external_hits":   stats.ExternalHits,
+			"loads":           stats.Loads,
+			"invalidations":   stats.Invalidations,
+			"external_errors": stats.ExternalErrors,
+		}).Info("user cache stats")
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/outboxsvc"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/publisher/file"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(outboxRelayCmd)
+}
+
+var outboxRelayCmd = &cobra.Command{
+	Use:   "outbox-relay",
+	Short: "Publish domain events from the outbox and deliver webhooks",
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg := initConfig()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := initLogger()
+
+		if cfg.Storage.Driver == storageDriverMemory {
+			logger.Warn("memory storage is not shared with the http process, the relay sees no events")
+		}
+
+		repo, err := initRepository(ctx, cfg)
+		if err != nil {
+			log.Fatalf("error init repository: %v", err)
+		}
+
+		publisher, err := file.Open(cfg.Outbox.PublisherPath)
+		if err != nil {
+			log.Fatalf("error init publisher: %v", err)
+		}
+		defer publisher.Close()
+
+		// events are queued for the webhook subscriptions as they are
+		// published, the deliveries are sent by their own loop so a slow
+		// receiver does not hold up the relay
+		webhookSvc, err := initWebhookSvc(cfg.Webhook, locker.New(cfg.AES.SecretKey), repo)
+		if err != nil {
+			log.Fatalf("error init webhook service: %v", err)
+		}
+
+		outboxSvc := outboxsvc.New(outboxsvc.ServiceOpts{
+			BatchSize:   cfg.Outbox.BatchSize,
+			Lease:       cfg.Outbox.Lease,
+			Retention:   time.Duration(cfg.Outbox.RetentionDays) * 24 * time.Hour,
+			MaxAttempts: cfg.Outbox.MaxAttempts,
+			BackoffBase: cfg.Outbox.BackoffBase,
+			BackoffMax:  cfg.Outbox.BackoffMax,
+		}, repo, publishers{publisher, webhookSvc})
+
+		go prune(ctx, logger, "published outbox events", outboxSvc.Prune, cfg.Outbox.PruneInterval)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			relay(ctx, logger, "webhook deliveries", webhookSvc.Deliver, cfg.Webhook.BatchSize, cfg.Webhook.PollInterval)
+		}()
+
+		logger.Info("relaying outbox events")
+		relay(ctx, logger, "outbox events", outboxSvc.Relay, cfg.Outbox.BatchSize, cfg.Outbox.PollInterval)
+		<-done
+		logger.Info("outbox relay stopped")
+	},
+}
+
+// relay processes batches with fn until ctx is done. A full batch means
+// more are likely waiting, so the next one is processed right away,
+// otherwise it waits for pollInterval.
+func relay(ctx context.Context, logger *logrus.Logger, name string, fn func() (int, error), batchSize int, pollInterval time.Duration) {
+	for {
+		n, err := fn()
+		if err != nil {
+			logger.WithError(err).Error("error relay " + name)
+		}
+		if n > 0 {
+			logger.Info(fmt.Sprintf("relayed %d %s", n, name))
+		}
+
+		if err == nil && n > 0 && n >= batchSize {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// publishers publishes every event to each publisher in turn. An event is
+// published again when a later publisher fails, which is fine as events are
+// delivered at least once.
+type publishers []port.EventPublisher
+
+func (p publishers) Publish(event *domain.Event) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
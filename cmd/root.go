@@ -3,17 +3,30 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
 	"github.com/SawitProRecruitment/UserService/core/port"
 	"github.com/SawitProRecruitment/UserService/core/service/authsvc"
+	"github.com/SawitProRecruitment/UserService/core/service/oauthsvc"
+	"github.com/SawitProRecruitment/UserService/core/service/usersvc"
+	"github.com/SawitProRecruitment/UserService/core/service/webhooksvc"
 	"github.com/SawitProRecruitment/UserService/generated"
 	sawithttp "github.com/SawitProRecruitment/UserService/handler/http"
+	"github.com/SawitProRecruitment/UserService/lib/blocklist"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/lib/phone"
+	"github.com/SawitProRecruitment/UserService/repository/cache"
+	"github.com/SawitProRecruitment/UserService/repository/memory"
 	"github.com/SawitProRecruitment/UserService/repository/postgres"
+	"github.com/SawitProRecruitment/UserService/repository/sqlite"
 	"github.com/fsnotify/fsnotify"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -24,11 +37,23 @@ import (
 )
 
 type Config struct {
-	Env    string       `json:"env"`
-	Auth   AuthConfig   `json:"auth"`
-	Server ServerConfig `json:"http"`
-	DB     PsqlConfig   `json:"postgresql"`
-	AES    AESConfig    `json:"aes"`
+	Env      string         `json:"env"`
+	Auth     AuthConfig     `json:"auth"`
+	Server   ServerConfig   `json:"http"`
+	GRPC     GRPCConfig     `json:"grpc"`
+	Storage  StorageConfig  `json:"storage"`
+	DB       PsqlConfig     `json:"postgresql"`
+	SQLite   SqliteConfig   `json:"sqlite"`
+	AES      AESConfig      `json:"aes"`
+	Password PasswordConfig `json:"password"`
+	Phone    PhoneConfig    `json:"phone"`
+
+	LoginHistory LoginHistoryConfig `json:"loginHistory"`
+	Idempotency  IdempotencyConfig  `json:"idempotency"`
+	UserCache    UserCacheConfig    `json:"userCache"`
+	Outbox       OutboxConfig       `json:"outbox"`
+	Webhook      WebhookConfig      `json:"webhook"`
+	OAuth        OAuthConfig        `json:"oauth"`
 }
 
 type ServerConfig struct {
@@ -37,6 +62,31 @@ type ServerConfig struct {
 	ReadTimeout       time.Duration `json:"readTimeout"`
 	WriteTimeout      time.Duration `json:"writeTimeout"`
 	ReadHeaderTimeout time.Duration `json:"readHeaderTimeout"`
+	ValidateResponses bool          `json:"validateResponses"`
+	// ProblemTypeBase prefixes error codes into the type of problem
+	// documents, about:blank is used when empty
+	ProblemTypeBase string `json:"problemTypeBase"`
+	// TrustedProxies are the CIDRs of the proxies whose X-Forwarded-For is
+	// believed, the peer address is the client ip when empty
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+type GRPCConfig struct {
+	Address string `json:"address"`
+	// TrustedProxies are the CIDRs of the proxies whose x-forwarded-for is
+	// believed, the peer address is the client ip when empty
+	TrustedProxies []string `json:"trustedProxies"`
+}
+
+const (
+	storageDriverPostgres = "postgres"
+	storageDriverSqlite   = "sqlite"
+	storageDriverMemory   = "memory"
+)
+
+type StorageConfig struct {
+	// Driver is postgres, sqlite or memory, memory loses all data on restart
+	Driver string `json:"driver"`
 }
 
 type PsqlConfig struct {
@@ -51,16 +101,101 @@ type PsqlConfig struct {
 	MaxIdleTime time.Duration `json:"maxIdleTime"`
 }
 
+type SqliteConfig struct {
+	Path        string `json:"path"`
+	MaxOpenConn int    `json:"maxOpenConn"`
+}
+
 type AuthConfig struct {
 	TokenPrivateKeyPath string        `json:"privateKeyPath"`
 	TokenPublicKeyPath  string        `json:"publicKeyPath"`
 	TokenExpDuration    time.Duration `json:"tokenExpDuration"`
+	// IntrospectionClients are the services allowed to introspect tokens
+	IntrospectionClients []ClientConfig `json:"introspectionClients"`
+	// MFAPruneInterval is how often the attempt counts of expired MFA
+	// challenge tokens are deleted
+	MFAPruneInterval time.Duration `json:"mfaPruneInterval"`
+}
+
+type ClientConfig struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
 }
 
 type AESConfig struct {
 	SecretKey string `json:"secretKey"`
 }
 
+// PasswordConfig is the password policy, the pointer fields are nil when
+// unset and fall back to the limits passwords had before the policy was
+// configurable.
+type PasswordConfig struct {
+	MinLength        *int   `json:"minLength"`
+	MaxLength        *int   `json:"maxLength"`
+	RequireUpper     *bool  `json:"requireUpper"`
+	RequireLower     bool   `json:"requireLower"`
+	RequireNumber    *bool  `json:"requireNumber"`
+	RequireSymbol    *bool  `json:"requireSymbol"`
+	MaxRepeatedChars int    `json:"maxRepeatedChars"`
+	DisallowUserInfo bool   `json:"disallowUserInfo"`
+	HistoryDepth     int    `json:"historyDepth"`
+	BlocklistPath    string `json:"blocklistPath"`
+	MaxAgeDays       int    `json:"maxAgeDays"`
+}
+
+type LoginHistoryConfig struct {
+	RetentionDays int           `json:"retentionDays"`
+	PruneInterval time.Duration `json:"pruneInterval"`
+}
+
+type IdempotencyConfig struct {
+	TTL           time.Duration `json:"ttl"`
+	PruneInterval time.Duration `json:"pruneInterval"`
+}
+
+type UserCacheConfig struct {
+	Size          int           `json:"size"`
+	TTL           time.Duration `json:"ttl"`
+	StatsInterval time.Duration `json:"statsInterval"`
+}
+
+type OutboxConfig struct {
+	BatchSize     int           `json:"batchSize"`
+	PollInterval  time.Duration `json:"pollInterval"`
+	Lease         time.Duration `json:"lease"`
+	RetentionDays int           `json:"retentionDays"`
+	PruneInterval time.Duration `json:"pruneInterval"`
+	MaxAttempts   int           `json:"maxAttempts"`
+	BackoffBase   time.Duration `json:"backoffBase"`
+	BackoffMax    time.Duration `json:"backoffMax"`
+	// PublisherPath is the file events are appended to, stdout when empty
+	PublisherPath string `json:"publisherPath"`
+}
+
+type WebhookConfig struct {
+	BatchSize    int           `json:"batchSize"`
+	PollInterval time.Duration `json:"pollInterval"`
+	Lease        time.Duration `json:"lease"`
+	MaxAttempts  int           `json:"maxAttempts"`
+	BackoffBase  time.Duration `json:"backoffBase"`
+	BackoffMax   time.Duration `json:"backoffMax"`
+	// Timeout bounds a single delivery attempt, Lease has to be longer
+	// than BatchSize attempts
+	Timeout time.Duration `json:"timeout"`
+}
+
+type OAuthConfig struct {
+	// Issuer is the public base url of the API, the prefix path included
+	Issuer        string        `json:"issuer"`
+	CodeTTL       time.Duration `json:"codeTTL"`
+	PruneInterval time.Duration `json:"pruneInterval"`
+}
+
+type PhoneConfig struct {
+	DefaultCountryCode  string   `json:"defaultCountryCode"`
+	AllowedCountryCodes []string `json:"allowedCountryCodes"`
+}
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -118,6 +253,29 @@ func initLogger() *logrus.Logger {
 	return logger
 }
 
+// repository is what the services need from a storage driver.
+type repository interface {
+	port.UserRepo
+	port.IdempotencyRepo
+	port.OutboxRepo
+	port.WebhookRepo
+	port.OAuthRepo
+	port.APIKeyRepo
+}
+
+func initRepository(ctx context.Context, cfg Config) (repository, error) {
+	switch cfg.Storage.Driver {
+	case "", storageDriverPostgres:
+		return initPostgres(ctx, cfg.DB)
+	case storageDriverSqlite:
+		return initSqlite(ctx, cfg.SQLite)
+	case storageDriverMemory:
+		return memory.New(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
 func initPostgres(ctx context.Context, cfg PsqlConfig) (*postgres.Repository, error) {
 	opts := postgres.NewRepoOptions{
 		Username:    cfg.Username,
@@ -139,10 +297,69 @@ func initPostgres(ctx context.Context, cfg PsqlConfig) (*postgres.Repository, er
 	return repo, nil
 }
 
-func initServer(cfg ServerConfig, handler *sawithttp.Handler) *http.Server {
+func initSqlite(ctx context.Context, cfg SqliteConfig) (*sqlite.Repository, error) {
+	opts := sqlite.NewRepoOptions{
+		Path:        cfg.Path,
+		MaxOpenConn: cfg.MaxOpenConn,
+	}
+
+	return sqlite.New(ctx, opts)
+}
+
+// initUserCache wraps repo with the user cache, a zero size disables it.
+func initUserCache(cfg UserCacheConfig, repo port.UserRepo) (port.UserRepo, *cache.Repository) {
+	if cfg.Size <= 0 {
+		return repo, nil
+	}
+
+	cached := cache.New(repo, cache.Options{
+		Size: cfg.Size,
+		TTL:  cfg.TTL,
+	})
+
+	return cached, cached
+}
+
+func initServer(cfg ServerConfig, handler *sawithttp.Handler, idempotencySvc port.IdempotencyService) (*http.Server, error) {
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := handler.MiddlewareAuth(spec, cfg.PrefixPath)
+	if err != nil {
+		return nil, err
+	}
+
+	proxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
 	e := echo.New()
+	e.IPExtractor = echo.ExtractIPDirect()
+	if len(proxies) > 0 {
+		opts := []echo.TrustOption{
+			echo.TrustLoopback(false),
+			echo.TrustLinkLocal(false),
+			echo.TrustPrivateNet(false),
+		}
+		for _, proxy := range proxies {
+			opts = append(opts, echo.TrustIPRange(proxy))
+		}
+		e.IPExtractor = echo.ExtractIPFromXFFHeader(opts...)
+	}
+	e.Use(handler.MiddlewareRequestID)
 	e.Use(handler.MiddlewareLogging)
+	if cfg.ValidateResponses {
+		e.Use(handler.MiddlewareResponseValidation(spec, cfg.PrefixPath))
+	}
 	e.Use(handler.MiddlewareError)
+	e.Use(auth)
+	if idempotencySvc != nil {
+		e.Use(handler.MiddlewareIdempotency(idempotencySvc))
+	}
+	e.Use(handler.MiddlewareRequestValidation(spec, cfg.PrefixPath))
 
 	generated.RegisterHandlersWithBaseURL(e, handler, cfg.PrefixPath)
 	s := &http.Server{
@@ -153,15 +370,159 @@ func initServer(cfg ServerConfig, handler *sawithttp.Handler) *http.Server {
 		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
 	}
 
-	return s
+	return s, nil
+}
+
+// parseTrustedProxies parses the CIDRs of trusted proxies, a single address
+// is a range of its own.
+func parseTrustedProxies(cidrs []string) ([]*net.IPNet, error) {
+	proxies := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", cidr)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			cidr = fmt.Sprintf("%s/%d", cidr, bits)
+		}
+
+		_, proxy, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+
+		proxies = append(proxies, proxy)
+	}
+
+	return proxies, nil
 }
 
-func initAuthSvc(cfg AuthConfig, repo port.UserRepo) (*authsvc.Service, error) {
+func initPhoneParser(cfg PhoneConfig) (*phone.Parser, error) {
+	opts := phone.Options{
+		DefaultCountryCode:  cfg.DefaultCountryCode,
+		AllowedCountryCodes: cfg.AllowedCountryCodes,
+	}
+
+	return phone.New(opts)
+}
+
+func initAuthSvc(cfg Config, logger *logrus.Logger, phoneParser *phone.Parser, libLocker *locker.Locker, apiKeys port.APIKeyService, repo port.UserRepo) (*authsvc.Service, error) {
 	opts := authsvc.ServiceOpts{
-		PrvKeyPath:       cfg.TokenPrivateKeyPath,
-		PubKeyPath:       cfg.TokenPublicKeyPath,
-		TokenExpDuration: cfg.TokenExpDuration,
+		PrvKeyPath:       cfg.Auth.TokenPrivateKeyPath,
+		PubKeyPath:       cfg.Auth.TokenPublicKeyPath,
+		TokenExpDuration: cfg.Auth.TokenExpDuration,
+		PasswordMaxAge:   time.Duration(cfg.Password.MaxAgeDays) * 24 * time.Hour,
+		PhoneParser:      phoneParser,
+		Locker:           libLocker,
+		APIKeys:          apiKeys,
+		Logger:           logger,
+
+		LoginHistoryRetention: time.Duration(cfg.LoginHistory.RetentionDays) * 24 * time.Hour,
+		IntrospectionClients:  make(map[string]string, len(cfg.Auth.IntrospectionClients)),
+	}
+	for _, client := range cfg.Auth.IntrospectionClients {
+		opts.IntrospectionClients[client.ID] = client.Secret
 	}
 
 	return authsvc.New(opts, repo)
 }
+
+func initUserSvc(cfg PasswordConfig, phoneParser *phone.Parser, repo port.UserRepo) (*usersvc.Service, error) {
+	policy, err := initPasswordPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := usersvc.ServiceOpts{
+		PhoneParser:    phoneParser,
+		PasswordPolicy: policy,
+	}
+
+	if cfg.BlocklistPath != "" {
+		list, err := blocklist.Load(cfg.BlocklistPath)
+		if err != nil {
+			return nil, err
+		}
+		opts.Blocklist = list
+	}
+
+	return usersvc.New(opts, repo), nil
+}
+
+// initPasswordPolicy fills the unset fields of cfg with the length limits
+// and character classes passwords always needed, so a missing password block
+// does not weaken the policy.
+func initPasswordPolicy(cfg PasswordConfig) (domain.PasswordPolicy, error) {
+	policy := domain.PasswordPolicy{
+		MinLength:        cons.MinLengthPass,
+		MaxLength:        cons.MaxLengthPass,
+		RequireUpper:     true,
+		RequireLower:     cfg.RequireLower,
+		RequireNumber:    true,
+		RequireSymbol:    true,
+		MaxRepeatedChars: cfg.MaxRepeatedChars,
+		DisallowUserInfo: cfg.DisallowUserInfo,
+		HistoryDepth:     cfg.HistoryDepth,
+	}
+	if cfg.MinLength != nil {
+		policy.MinLength = *cfg.MinLength
+	}
+	if cfg.MaxLength != nil {
+		policy.MaxLength = *cfg.MaxLength
+	}
+	if cfg.RequireUpper != nil {
+		policy.RequireUpper = *cfg.RequireUpper
+	}
+	if cfg.RequireNumber != nil {
+		policy.RequireNumber = *cfg.RequireNumber
+	}
+	if cfg.RequireSymbol != nil {
+		policy.RequireSymbol = *cfg.RequireSymbol
+	}
+
+	if policy.MinLength <= 0 {
+		return domain.PasswordPolicy{}, fmt.Errorf("password min length has to be at least 1, got %d", policy.MinLength)
+	}
+	if policy.MaxLength > cons.MaxBytesPass {
+		return domain.PasswordPolicy{}, fmt.Errorf("password max length %d is over the %d bytes bcrypt hashes", policy.MaxLength, cons.MaxBytesPass)
+	}
+	if policy.MaxLength > 0 && policy.MaxLength < policy.MinLength {
+		return domain.PasswordPolicy{}, fmt.Errorf("password max length %d is below the min length %d", policy.MaxLength, policy.MinLength)
+	}
+
+	return policy, nil
+}
+
+func initWebhookSvc(cfg WebhookConfig, libLocker *locker.Locker, repo port.WebhookRepo) (*webhooksvc.Service, error) {
+	// a lease running out while the batch is still being sent lets another
+	// relay claim and send the same deliveries again
+	if batchTime := time.Duration(cfg.BatchSize) * cfg.Timeout; cfg.Lease <= batchTime {
+		return nil, fmt.Errorf("webhook lease %s has to outlast a batch of %d deliveries timing out after %s each, %s", cfg.Lease, cfg.BatchSize, cfg.Timeout, batchTime)
+	}
+
+	opts := webhooksvc.ServiceOpts{
+		Locker:      libLocker,
+		Client:      &http.Client{Timeout: cfg.Timeout},
+		BatchSize:   cfg.BatchSize,
+		Lease:       cfg.Lease,
+		MaxAttempts: cfg.MaxAttempts,
+		BackoffBase: cfg.BackoffBase,
+		BackoffMax:  cfg.BackoffMax,
+	}
+
+	return webhooksvc.New(opts, repo), nil
+}
+
+func initOAuthSvc(cfg OAuthConfig, repo port.OAuthRepo, authSvc port.AuthService, userSvc port.UserService) *oauthsvc.Service {
+	opts := oauthsvc.ServiceOpts{
+		Issuer:  cfg.Issuer,
+		CodeTTL: cfg.CodeTTL,
+	}
+
+	return oauthsvc.New(opts, repo, authSvc, userSvc)
+}
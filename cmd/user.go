@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"log"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/service/usersvc"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	for _, c := range []*cobra.Command{userPromoteCmd, userDemoteCmd} {
+		c.Flags().String("id", "", "id of the user")
+		_ = c.MarkFlagRequired("id")
+	}
+
+	userCmd.AddCommand(userPromoteCmd, userDemoteCmd)
+	rootCmd.AddCommand(userCmd)
+}
+
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "Manage the roles of users",
+}
+
+var userPromoteCmd = &cobra.Command{
+	Use:   "promote",
+	Args:  cobra.NoArgs,
+	Short: "Make a user an administrator, it is how the first one is made",
+	Run: func(cmd *cobra.Command, args []string) {
+		setUserRole(cmd, cons.RoleAdmin)
+	},
+}
+
+var userDemoteCmd = &cobra.Command{
+	Use:   "demote",
+	Args:  cobra.NoArgs,
+	Short: "Take the administrator role away from a user",
+	Run: func(cmd *cobra.Command, args []string) {
+		setUserRole(cmd, cons.RoleUser)
+	},
+}
+
+// setUserRole works on the configured storage directly like the apikey
+// commands, the user is signed out everywhere so the new role applies on
+// the next sign in.
+func setUserRole(cmd *cobra.Command, role string) {
+	id, _ := cmd.Flags().GetString("id")
+
+	cfg := initConfig()
+	if cfg.Storage.Driver == storageDriverMemory {
+		log.Fatal("memory storage is not shared with the http process, roles can not be managed")
+	}
+
+	repo, err := initRepository(context.Background(), cfg)
+	if err != nil {
+		log.Fatalf("error init repository: %v", err)
+	}
+
+	err = usersvc.New(usersvc.ServiceOpts{}, repo).SetRole(domain.Actor{}, id, role)
+	if err != nil {
+		log.Fatalf("error set role: %v", err)
+	}
+
+	log.Printf("user %s is now %s\n", id, role)
+}
@@ -1,30 +1,148 @@
 package cons
 
-import "errors"
+import (
+	"fmt"
+	"net/http"
+)
 
+// Err* are the errors reported to API callers, ErrInvalidRequest and
+// ErrInternal are the ones of malformed requests and unexpected failures.
 var (
-	ErrInvalidNameLength     = errors.New("error invalid name length")
-	ErrInvalidPhoneLength    = errors.New("error invalid phone length")
-	ErrInvalidPhonePrefix    = errors.New("error invalid phone prefix")
-	ErrInvalidPasswordLength = errors.New("error invalid password length")
-	ErrInvalidPasswordFormat = errors.New("error password must have capital, number and symbol")
-	ErrInvalidAuthorized     = errors.New("user not authorize for this action")
-	ErrInvalidToken          = errors.New("error invalid token")
-	ErrDataConflict          = errors.New("error data conflict")
-
-	ErrJWTFormat     = errors.New("invalid token format")
-	ErrJWTSign       = errors.New("invalid signature")
-	ErrJWTSignMethod = errors.New("invalid signing method")
-	ErrJWTExpired    = errors.New("invalid token expired")
+	ErrInvalidRequest = NewError(http.StatusBadRequest, StatusCode(http.StatusBadRequest), "", "error invalid request")
+	ErrInternal       = NewError(http.StatusInternalServerError, StatusCode(http.StatusInternalServerError), "", "internal server error")
+
+	ErrInvalidNameLength     = NewError(http.StatusUnprocessableEntity, "invalid_name_length", "full_name", "error invalid name length")
+	ErrInvalidPhoneLength    = NewError(http.StatusUnprocessableEntity, "invalid_phone_length", "phone_number", "error invalid phone length")
+	ErrInvalidPhonePrefix    = NewError(http.StatusUnprocessableEntity, "invalid_phone_prefix", "phone_number", "error invalid phone prefix")
+	ErrInvalidPhoneFormat    = NewError(http.StatusUnprocessableEntity, "invalid_phone_format", "phone_number", "error invalid phone format")
+	ErrInvalidPasswordLength = NewError(http.StatusUnprocessableEntity, "invalid_password_length", "password", "error invalid password length")
+	ErrInvalidPasswordFormat = NewError(http.StatusUnprocessableEntity, "invalid_password_format", "password", "error invalid password format")
+	ErrInvalidAuthorized     = NewError(http.StatusForbidden, "not_authorized", "", "user not authorize for this action")
+	ErrInvalidToken          = NewError(http.StatusUnauthorized, "invalid_token", "", "error invalid token")
+	ErrDataConflict          = NewError(http.StatusConflict, "data_conflict", "", "error data conflict")
+	ErrVersionMismatch       = NewError(http.StatusPreconditionFailed, "version_mismatch", "", "error user was modified since it was last read")
+	ErrIdempotencyKeyReused  = NewError(http.StatusUnprocessableEntity, "idempotency_key_reused", "", "error idempotency key was used with a different request")
+	ErrIdempotencyInProgress = NewError(http.StatusConflict, "idempotency_in_progress", "", "error request with the same idempotency key is in progress")
+	ErrPasswordNotMatch      = NewError(http.StatusUnprocessableEntity, "password_not_match", "old_password", "error old password does not match")
+	ErrLoginNotMatch         = NewError(http.StatusUnauthorized, "login_not_match", "", "phone and password do not match")
+	ErrPasswordBreached      = NewError(http.StatusUnprocessableEntity, "password_breached", "password", "error password is known to be compromised")
+	ErrPasswordReused        = NewError(http.StatusUnprocessableEntity, "password_reused", "password", "error password was used recently")
+	ErrPasswordChangeNeeded  = NewError(http.StatusForbidden, "password_change_required", "", "error password change required")
+	ErrTokenScope            = NewError(http.StatusForbidden, "token_scope", "", "error token scope not allowed")
+	ErrUserNotFound          = NewError(http.StatusNotFound, "user_not_found", "", "error user not found")
+	ErrMFAAlreadyEnabled     = NewError(http.StatusConflict, "mfa_already_enabled", "", "error two factor authentication already enabled")
+	ErrMFANotEnrolled        = NewError(http.StatusConflict, "mfa_not_enrolled", "", "error two factor authentication not enrolled")
+	ErrInvalidMFACode        = NewError(http.StatusUnprocessableEntity, "invalid_mfa_code", "code", "error invalid two factor authentication code")
+	ErrMFAAttemptsExceeded   = NewError(http.StatusUnauthorized, "mfa_attempts_exceeded", "", "error too many two factor authentication attempts, login again")
+	ErrSessionNotFound       = NewError(http.StatusNotFound, "session_not_found", "", "error session not found")
+	ErrSessionRevoked        = NewError(http.StatusUnauthorized, "session_revoked", "", "error session revoked or expired")
+	ErrWebhookNotFound       = NewError(http.StatusNotFound, "webhook_not_found", "", "error webhook subscription not found")
+	ErrInvalidWebhookURL     = NewError(http.StatusUnprocessableEntity, "invalid_webhook_url", "url", "error invalid webhook url, must be an absolute http or https url")
+	ErrInvalidWebhookEvent   = NewError(http.StatusUnprocessableEntity, "invalid_webhook_event", "event_types", "error invalid webhook event type")
+	ErrInvalidClient         = NewError(http.StatusUnauthorized, "invalid_client", "", "error invalid client credentials")
+	ErrOAuthClientNotFound   = NewError(http.StatusNotFound, "oauth_client_not_found", "", "error oauth client not found")
+	ErrInvalidOAuthClient    = NewError(http.StatusUnprocessableEntity, "invalid_oauth_client", "", "error invalid oauth client, name and redirect uris are required")
+	ErrInvalidRedirectURI    = NewError(http.StatusUnprocessableEntity, "invalid_redirect_uri", "", "error invalid redirect uri")
+	ErrInvalidOAuthRequest   = NewError(http.StatusBadRequest, "invalid_oauth_request", "", "error invalid authorization request")
+	ErrInvalidScope          = NewError(http.StatusUnprocessableEntity, "invalid_scope", "", "error invalid scope")
+	ErrInvalidGrant          = NewError(http.StatusBadRequest, "invalid_grant", "", "error invalid, expired or used authorization code")
+	ErrUnsupportedGrantType  = NewError(http.StatusBadRequest, "unsupported_grant_type", "", "error unsupported grant type")
+	ErrAPIKeyNotFound        = NewError(http.StatusNotFound, "api_key_not_found", "", "error api key not found")
+	ErrInvalidAPIKey         = NewError(http.StatusUnprocessableEntity, "invalid_api_key", "", "error invalid api key, name and scopes are required")
+	ErrInvalidAPIKeyScope    = NewError(http.StatusUnprocessableEntity, "invalid_api_key_scope", "scopes", "error invalid api key scope")
+	ErrInvalidTimeRange      = NewError(http.StatusUnprocessableEntity, "invalid_time_range", "to", "error time range end is before its start")
+	ErrInvalidResponse       = NewError(http.StatusInternalServerError, "invalid_response", "", "error response does not match api.yml")
+	ErrAPIKeyRevoked         = NewError(http.StatusUnauthorized, "api_key_revoked", "", "error api key revoked or expired")
+
+	ErrPasswordNoUpper       = fmt.Errorf("%w: must have capital letter", ErrInvalidPasswordFormat)
+	ErrPasswordNoLower       = fmt.Errorf("%w: must have lowercase letter", ErrInvalidPasswordFormat)
+	ErrPasswordNoNumber      = fmt.Errorf("%w: must have number", ErrInvalidPasswordFormat)
+	ErrPasswordNoSymbol      = fmt.Errorf("%w: must have symbol", ErrInvalidPasswordFormat)
+	ErrPasswordRepeatedChars = fmt.Errorf("%w: too many repeated characters", ErrInvalidPasswordFormat)
+	ErrPasswordContainsName  = fmt.Errorf("%w: must not contain full name", ErrInvalidPasswordFormat)
+	ErrPasswordContainsPhone = fmt.Errorf("%w: must not contain phone number", ErrInvalidPasswordFormat)
+
+	ErrJWTFormat     = NewError(http.StatusUnauthorized, "invalid_token_format", "", "invalid token format")
+	ErrJWTSign       = NewError(http.StatusUnauthorized, "invalid_token_signature", "", "invalid signature")
+	ErrJWTSignMethod = NewError(http.StatusUnauthorized, "invalid_token_signing_method", "", "invalid signing method")
+	ErrJWTExpired    = NewError(http.StatusUnauthorized, "token_expired", "", "invalid token expired")
 )
 
 const (
-	MinLengthName  = 3
-	MaxLengthName  = 60
-	MinLengthPass  = 6
-	MaxLengthPass  = 64
-	MinLengthPhone = 10
-	MaxLengthPhone = 13
-	PrefixPhoneID  = "+62"
-	AuthTokenType  = "Bearer"
+	MinLengthName = 3
+	MaxLengthName = 60
+	MinLengthPass = 6
+	MaxLengthPass = 64
+	// MaxBytesPass is the most bcrypt hashes, whatever the length in runes
+	MaxBytesPass  = 72
+	AuthTokenType = "Bearer"
+
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+
+	// ScopeUser grants every user endpoint, ScopePasswordChange only the
+	// change password endpoint
+	ScopeUser           = "user"
+	ScopePasswordChange = "password_change"
+	// ScopeMFA is the login challenge scope, it is only exchanged for
+	// another token once the second factor is verified
+	ScopeMFA = "mfa"
+	// ScopeOpenID, ScopeProfile and ScopePhone can be requested by OAuth
+	// clients. Their tokens carry them next to ScopeUser, space separated.
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopePhone   = "phone"
+	// ScopeAdmin grants the admin endpoints to administrators, it lets API
+	// keys of batch jobs call them without the user endpoints
+	ScopeAdmin = "admin"
+
+	// APIKeyPrefix starts every API key, it tells them apart from JWTs sent
+	// in the same Authorization header
+	APIKeyPrefix = "usk_"
+
+	// LoginReason* explain why a login attempt failed in the login history
+	LoginReasonInvalidPhone       = "invalid_phone"
+	LoginReasonInvalidCredentials = "invalid_credentials"
+	LoginReasonInvalidMFACode     = "invalid_mfa_code"
+	LoginReasonPasswordChange     = "password_change_required"
+	LoginReasonError              = "error"
+
+	AuditActionCreateUser          = "create_user"
+	AuditActionUpdateProfile       = "update_profile"
+	AuditActionChangePassword      = "change_password"
+	AuditActionForcePasswordChange = "force_password_change"
+	AuditActionSetRole             = "set_role"
+
+	// Event* are the types of the domain events published from the outbox
+	EventUserRegistered   = "user.registered"
+	EventUserPhoneChanged = "user.phone_changed"
+	EventUserLoggedIn     = "user.logged_in"
+	EventUserDeactivated  = "user.deactivated"
+
+	// WebhookStatus* are the states of a webhook delivery, a delivery that
+	// exhausted its attempts is dead-lettered and never retried
+	WebhookStatusPending    = "pending"
+	WebhookStatusSucceeded  = "succeeded"
+	WebhookStatusDeadLetter = "dead_letter"
+
+	// WebhookHeader* are sent with every webhook delivery, the signature is
+	// the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret
+	WebhookHeaderID        = "Webhook-Id"
+	WebhookHeaderEvent     = "Webhook-Event"
+	WebhookHeaderTimestamp = "Webhook-Timestamp"
+	WebhookHeaderSignature = "Webhook-Signature"
 )
+
+// APIKeyScopes are the scopes API keys can be granted.
+var APIKeyScopes = []string{
+	ScopeUser,
+	ScopeAdmin,
+}
+
+// EventTypes are the domain events that can be subscribed to.
+var EventTypes = []string{
+	EventUserRegistered,
+	EventUserPhoneChanged,
+	EventUserLoggedIn,
+	EventUserDeactivated,
+}
@@ -0,0 +1,48 @@
+package cons
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Error is an error reported to API callers. Code is stable for clients to
+// act on, Status is the HTTP status it is answered with and Field the
+// request field it is about, empty when it is not about a single field.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+	Field   string
+}
+
+// NewError returns an Error answered with status.
+func NewError(status int, code, field, message string) *Error {
+	return &Error{
+		Code:    code,
+		Status:  status,
+		Message: message,
+		Field:   field,
+	}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is matches the copies made by WithField to the error they were made from.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithField returns a copy of e about field, message tells what is wrong
+// with it.
+func (e *Error) WithField(field, message string) *Error {
+	return NewError(e.Status, e.Code, field, message)
+}
+
+// StatusCode is the code of the errors only known by their HTTP status,
+// like "not_found".
+func StatusCode(status int) string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// APIKey authorizes machine to machine calls on behalf of the user owning
+// it, limited to its scopes. Keys look like usk_<prefix>_<secret>, the
+// prefix identifies the key and is stored as is.
+type APIKey struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	UserID string   `json:"user_id"`
+	Prefix string   `json:"prefix"`
+	Scopes []string `json:"scopes"`
+	// Key is only returned when the key is created
+	Key string `json:"key"`
+	// KeyHash is the SHA-256 of the key, the key itself is not stored
+	KeyHash    string     `json:"-"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  *time.Time `json:"created_at"`
+}
@@ -0,0 +1,44 @@
+package domain
+
+import "time"
+
+// Actor is who performs a mutation and the request it comes from. UserID is
+// empty when the user acts on their own account before it exists.
+type Actor struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+	RequestID string `json:"request_id"`
+}
+
+// AuditChange is the old and new value of a changed field, both are nil for
+// secrets like the password.
+type AuditChange struct {
+	Field string  `json:"field"`
+	Old   *string `json:"old"`
+	New   *string `json:"new"`
+}
+
+type AuditEntry struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	ActorID   string        `json:"actor_id"`
+	Action    string        `json:"action"`
+	Changes   []AuditChange `json:"changes"`
+	RequestID string        `json:"request_id"`
+	CreatedAt *time.Time    `json:"created_at"`
+}
+
+// AuditFilter selects the entries of a user, From and To are optional.
+type AuditFilter struct {
+	UserID string     `json:"user_id"`
+	From   *time.Time `json:"from"`
+	To     *time.Time `json:"to"`
+}
+
+// AuditLog is a page of audit entries, Total counts every page.
+type AuditLog struct {
+	Entries  []AuditEntry `json:"entries"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int          `json:"total"`
+}
@@ -0,0 +1,72 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+)
+
+// Event is a domain event stored in the outbox. It is written in the same
+// transaction as the change it describes and published afterwards, at least
+// once, so consumers must tolerate duplicates by ID.
+type Event struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	OccurredAt  time.Time       `json:"occurred_at"`
+	// Attempts counts the failed publishes so far
+	Attempts int `json:"-"`
+}
+
+// OutboxFailure is a failed publish of an event. The event is retried at
+// RetryAt, or never again when it is dead-lettered.
+type OutboxFailure struct {
+	EventID    string
+	Error      string
+	RetryAt    time.Time
+	DeadLetter bool
+}
+
+// EventPayload is the typed body of an Event.
+type EventPayload interface {
+	EventType() string
+	AggregateID() string
+}
+
+type UserRegistered struct {
+	UserID      string `json:"user_id"`
+	FullName    string `json:"full_name"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+func (e UserRegistered) EventType() string   { return cons.EventUserRegistered }
+func (e UserRegistered) AggregateID() string { return e.UserID }
+
+type UserPhoneChanged struct {
+	UserID      string `json:"user_id"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+func (e UserPhoneChanged) EventType() string   { return cons.EventUserPhoneChanged }
+func (e UserPhoneChanged) AggregateID() string { return e.UserID }
+
+type UserLoggedIn struct {
+	UserID    string `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+func (e UserLoggedIn) EventType() string   { return cons.EventUserLoggedIn }
+func (e UserLoggedIn) AggregateID() string { return e.UserID }
+
+// UserDeactivated is reserved for the user being deactivated. Nothing emits
+// it yet, users are only ever active as there is no deactivation endpoint,
+// it is kept so webhooks can subscribe to it ahead of one. Whatever sets
+// is_active to false has to write it in the same transaction.
+type UserDeactivated struct {
+	UserID string `json:"user_id"`
+}
+
+func (e UserDeactivated) EventType() string   { return cons.EventUserDeactivated }
+func (e UserDeactivated) AggregateID() string { return e.UserID }
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// IdempotencyRecord is the stored outcome of a request sent with an
+// Idempotency-Key. StatusCode is 0 while the first request is in progress.
+type IdempotencyRecord struct {
+	// Caller identifies who sent the request, every caller has keys of its
+	// own so they never collide with the keys of another caller
+	Caller      string    `json:"caller"`
+	Key         string    `json:"key"`
+	Fingerprint string    `json:"fingerprint"`
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type"`
+	Body        []byte    `json:"body"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
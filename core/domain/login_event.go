@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// LoginEvent is a single login attempt. UserID is empty when the phone
+// number does not belong to any user.
+type LoginEvent struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	PhoneNumber string     `json:"phone_number"`
+	Success     bool       `json:"success"`
+	Reason      string     `json:"reason"`
+	UserAgent   string     `json:"user_agent"`
+	IPAddress   string     `json:"ip_address"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
+
+// LoginHistory is a page of login attempts, Total counts every page.
+type LoginHistory struct {
+	Events   []LoginEvent `json:"events"`
+	Page     int          `json:"page"`
+	PageSize int          `json:"page_size"`
+	Total    int          `json:"total"`
+}
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+type MFA struct {
+	UserID string `json:"user_id"`
+	// TOTPSecret is encrypted at rest, see locker.Locker
+	TOTPSecret  string     `json:"totp_secret"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+	// LastTOTPStep is the time step of the last accepted TOTP code, codes
+	// of this step or an earlier one are rejected as replays
+	LastTOTPStep int64 `json:"last_totp_step"`
+}
+
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
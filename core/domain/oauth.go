@@ -0,0 +1,111 @@
+package domain
+
+import "time"
+
+// OAuthClient is an application allowed to sign users in with the
+// authorization code flow. Public clients, like mobile apps, have no secret
+// and rely on PKCE alone.
+type OAuthClient struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+	// Secret is hashed at rest and only returned when the client is
+	// registered
+	Secret    string     `json:"secret"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// AuthorizationRequest holds the parameters of the authorization endpoint.
+type AuthorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	Nonce               string `json:"nonce"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// OAuthCode is an issued authorization code, it is bound to the session the
+// user signed in with and can be exchanged once.
+type OAuthCode struct {
+	// CodeHash is the SHA-256 of the code, the code itself is not stored
+	CodeHash      string     `json:"code_hash"`
+	ClientID      string     `json:"client_id"`
+	UserID        string     `json:"user_id"`
+	SessionID     string     `json:"session_id"`
+	RedirectURI   string     `json:"redirect_uri"`
+	Scope         string     `json:"scope"`
+	Nonce         string     `json:"nonce"`
+	CodeChallenge string     `json:"code_challenge"`
+	AuthTime      time.Time  `json:"auth_time"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	CreatedAt     *time.Time `json:"created_at"`
+}
+
+// TokenRequest holds the parameters of the token endpoint.
+type TokenRequest struct {
+	GrantType    string `json:"grant_type"`
+	Code         string `json:"code"`
+	RedirectURI  string `json:"redirect_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+	// IDToken is only issued when the openid scope was granted
+	IDToken string `json:"id_token"`
+}
+
+// IDToken is the OpenID Connect identity of a signed in user.
+type IDToken struct {
+	Issuer    string    `json:"iss"`
+	Subject   string    `json:"sub"`
+	Audience  string    `json:"aud"`
+	Nonce     string    `json:"nonce"`
+	SessionID string    `json:"sid"`
+	AuthTime  time.Time `json:"auth_time"`
+}
+
+// UserInfo are the claims of the userinfo endpoint, only those allowed by
+// the scopes of the token are set.
+type UserInfo struct {
+	Subject     string `json:"sub"`
+	Name        string `json:"name"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+// JWK is a public signing key, see RFC 7517.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+	Modulus   string `json:"n"`
+	Exponent  string `json:"e"`
+}
+
+// OIDCDiscovery is the OpenID provider metadata.
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserinfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
@@ -0,0 +1,15 @@
+package domain
+
+// PasswordPolicy describes the rules a password must satisfy. Zero values
+// disable the matching rule, except MinLength which is always enforced.
+type PasswordPolicy struct {
+	MinLength        int  `json:"min_length"`
+	MaxLength        int  `json:"max_length"`
+	RequireUpper     bool `json:"require_upper"`
+	RequireLower     bool `json:"require_lower"`
+	RequireNumber    bool `json:"require_number"`
+	RequireSymbol    bool `json:"require_symbol"`
+	MaxRepeatedChars int  `json:"max_repeated_chars"`
+	DisallowUserInfo bool `json:"disallow_user_info"`
+	HistoryDepth     int  `json:"history_depth"`
+}
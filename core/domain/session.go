@@ -0,0 +1,23 @@
+package domain
+
+import "time"
+
+// Device describes the client a login comes from.
+type Device struct {
+	UserAgent string `json:"user_agent"`
+	IPAddress string `json:"ip_address"`
+	// NoPasswordChange is set by login flows that can not change the
+	// password, a login needing a password change is refused instead of
+	// starting a limited scope session
+	NoPasswordChange bool `json:"-"`
+}
+
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  *time.Time `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
@@ -3,16 +3,53 @@ package domain
 import "time"
 
 type User struct {
-	ID          string     `json:"id"`
-	FullName    string     `json:"full_name"`
-	PhoneNumber string     `json:"phone_number"`
-	LoginCount  int        `json:"login_count"`
-	Password    string     `json:"password"`
-	CreatedAt   *time.Time `json:"created_at"`
-	UpdatedAt   *time.Time `json:"updated_at"`
+	ID                 string     `json:"id"`
+	FullName           string     `json:"full_name"`
+	PhoneNumber        string     `json:"phone_number"`
+	LoginCount         int        `json:"login_count"`
+	Password           string     `json:"password"`
+	Role               string     `json:"role"`
+	MustChangePassword bool       `json:"must_change_password"`
+	PasswordChangedAt  *time.Time `json:"password_changed_at"`
+	MFAEnabled         bool       `json:"mfa_enabled"`
+	Version            int        `json:"version"`
+	CreatedAt          *time.Time `json:"created_at"`
+	UpdatedAt          *time.Time `json:"updated_at"`
 }
 
 type AuthData struct {
-	ID          string `json:"id"`
-	AccessToken string `json:"access_token"`
+	ID                     string `json:"id"`
+	AccessToken            string `json:"access_token"`
+	Scope                  string `json:"scope"`
+	PasswordChangeRequired bool   `json:"password_change_required"`
+	MFARequired            bool   `json:"mfa_required"`
+	MFAToken               string `json:"mfa_token"`
+	// SessionID is the session the access token is bound to
+	SessionID string `json:"session_id"`
+}
+
+type TokenClaims struct {
+	UserID    string `json:"sub"`
+	Role      string `json:"role"`
+	Scope     string `json:"scope"`
+	SessionID string `json:"sid"`
+	// APIKeyID is the API key the request is authorized with, such
+	// requests have no session
+	APIKeyID string `json:"api_key_id"`
+}
+
+// TokenIntrospection is the state of an access token as reported to other
+// services, see RFC 7662. Only Active is set for tokens that are invalid,
+// expired or revoked.
+type TokenIntrospection struct {
+	Active    bool     `json:"active"`
+	Subject   string   `json:"sub"`
+	Scope     string   `json:"scope"`
+	ClientID  string   `json:"client_id"`
+	TokenType string   `json:"token_type"`
+	ExpiresAt int64    `json:"exp"`
+	IssuedAt  int64    `json:"iat"`
+	Issuer    string   `json:"iss"`
+	Audience  []string `json:"aud"`
+	SessionID string   `json:"sid"`
 }
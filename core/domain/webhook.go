@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// Webhook is a subscription of a partner endpoint to domain events.
+type Webhook struct {
+	ID         string   `json:"id"`
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+	// Secret signs the deliveries, it is encrypted at rest, see
+	// locker.Locker, and only returned when the subscription is created
+	Secret    string     `json:"secret"`
+	CreatedAt *time.Time `json:"created_at"`
+}
+
+// WebhookDelivery is an event sent to a subscription, it doubles as the
+// delivery log of the subscription.
+type WebhookDelivery struct {
+	ID        string `json:"id"`
+	WebhookID string `json:"webhook_id"`
+	EventID   string `json:"event_id"`
+	EventType string `json:"event_type"`
+	// Payload is the request body, the JSON encoded Event
+	Payload        []byte     `json:"payload"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	CreatedAt      *time.Time `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+}
+
+// WebhookAttempt is the outcome of sending a delivery once.
+type WebhookAttempt struct {
+	DeliveryID string
+	// Status is pending when the delivery is retried at NextAttemptAt
+	Status        string
+	StatusCode    int
+	Error         string
+	NextAttemptAt time.Time
+}
+
+// WebhookDeliveryLog is a page of deliveries, Total counts every page.
+type WebhookDeliveryLog struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Page       int               `json:"page"`
+	PageSize   int               `json:"page_size"`
+	Total      int               `json:"total"`
+}
@@ -1,27 +1,268 @@
 package port
 
 import (
+	"context"
+	"time"
+
 	"github.com/SawitProRecruitment/UserService/core/domain"
 	_ "github.com/golang/mock/mockgen/model"
 )
 
 //go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . UserService
 type UserService interface {
-	Register(data *domain.User) (*domain.User, error)
+	Register(actor domain.Actor, data *domain.User) (*domain.User, error)
 	Get(id string) (*domain.User, error)
-	Patch(id string, data *domain.User) (*domain.User, error)
+	Patch(actor domain.Actor, id string, data *domain.User) (*domain.User, error)
+	ChangePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error
+	ForcePasswordChange(actor domain.Actor, id string) error
+	// SetRole grants or takes away a role, it is how the first administrator
+	// is made
+	SetRole(actor domain.Actor, id string, role string) error
+	ListAuditLog(filter domain.AuditFilter, page int, pageSize int) (*domain.AuditLog, error)
 }
 
 //go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . AuthService
 type AuthService interface {
-	Login(req *domain.User) (*domain.AuthData, error)
-	VerifyAuthHeader(authHeader string) (id string, err error)
+	Login(req *domain.User, device domain.Device) (*domain.AuthData, error)
+	VerifyAuthHeader(authHeader string, scope string) (*domain.TokenClaims, error)
+	LoginMFA(mfaToken string, code string, device domain.Device) (*domain.AuthData, error)
+	EnrollTOTP(userID string) (*domain.TOTPEnrollment, error)
+	ConfirmTOTP(userID string, code string) (recoveryCodes []string, err error)
+	ListSessions(userID string) ([]domain.Session, error)
+	RevokeSession(userID string, sessionID string) error
+	ListLoginHistory(userID string, page int, pageSize int) (*domain.LoginHistory, error)
+	PruneLoginHistory() (int64, error)
+	PruneMFAChallenges() (int64, error)
+	// AuthenticateClient checks the credentials of a service allowed to
+	// introspect tokens
+	AuthenticateClient(clientID string, clientSecret string) error
+	Introspect(token string) (*domain.TokenIntrospection, error)
+	// IssueClientToken issues an access token of an OAuth client for an
+	// active session of the user
+	IssueClientToken(userID string, sessionID string, clientID string, scope string) (*domain.TokenResponse, error)
+	SignIDToken(token *domain.IDToken) (string, error)
+	// JWKS returns the public keys tokens are signed with
+	JWKS() ([]domain.JWK, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . OAuthService
+type OAuthService interface {
+	// RegisterClient generates the secret of confidential clients, the
+	// returned client is the only one carrying it
+	RegisterClient(data *domain.OAuthClient) (*domain.OAuthClient, error)
+	ListClients() ([]domain.OAuthClient, error)
+	DeleteClient(id string) error
+	// ValidateAuthorization checks an authorization request before the user
+	// signs in. cons.ErrOAuthClientNotFound and cons.ErrInvalidRedirectURI
+	// must not be reported to the redirect uri, other errors are.
+	ValidateAuthorization(req *domain.AuthorizationRequest) (*domain.OAuthClient, error)
+	// Authorize issues an authorization code once the user signed in with
+	// auth, the code is returned as is and only its hash is stored
+	Authorize(req *domain.AuthorizationRequest, auth *domain.AuthData) (string, error)
+	Exchange(req *domain.TokenRequest) (*domain.TokenResponse, error)
+	UserInfo(claims *domain.TokenClaims) (*domain.UserInfo, error)
+	Discovery() *domain.OIDCDiscovery
+	PruneCodes() (int64, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . APIKeyService
+type APIKeyService interface {
+	// CreateAPIKey generates the key, the returned key is the only one
+	// carrying it
+	CreateAPIKey(data *domain.APIKey) (*domain.APIKey, error)
+	ListAPIKeys() ([]domain.APIKey, error)
+	RevokeAPIKey(id string) error
+	// Authenticate returns the key if it is known, not revoked and not
+	// expired, and records its use
+	Authenticate(key string) (*domain.APIKey, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . IdempotencyService
+type IdempotencyService interface {
+	// Begin reserves rec.Key for the request, the stored response is
+	// returned instead when the key was already used by an identical request
+	Begin(rec *domain.IdempotencyRecord) (replay *domain.IdempotencyRecord, err error)
+	Complete(rec *domain.IdempotencyRecord) error
+	Release(rec *domain.IdempotencyRecord) error
+	Prune() (int64, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . WebhookService
+type WebhookService interface {
+	// CreateWebhook generates the secret when data has none, the returned
+	// subscription is the only one carrying the secret
+	CreateWebhook(data *domain.Webhook) (*domain.Webhook, error)
+	ListWebhooks() ([]domain.Webhook, error)
+	GetWebhook(id string) (*domain.Webhook, error)
+	// UpdateWebhook replaces the url and event types, the secret is only
+	// rotated when data has one
+	UpdateWebhook(data *domain.Webhook) (*domain.Webhook, error)
+	DeleteWebhook(id string) error
+	ListDeliveries(webhookID string, page int, pageSize int) (*domain.WebhookDeliveryLog, error)
+	// Publish queues a delivery of event to every subscription of its
+	// type, it is the EventPublisher the outbox relay publishes to
+	Publish(event *domain.Event) error
+	// Deliver sends the next batch of due deliveries and reports how many
+	// were attempted
+	Deliver() (int, error)
 }
 
 //go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . UserRepo
 type UserRepo interface {
-	CreateUser(data *domain.User) (*domain.User, error)
+	// WithTx runs fn in a single transaction, every call on the repo passed
+	// to fn commits or rolls back together
+	WithTx(ctx context.Context, fn func(repo UserRepo) error) error
+	CreateUser(actor domain.Actor, data *domain.User) (*domain.User, error)
+	// Login verifies the password of the active user stored with phone, the
+	// login is only counted by IncrementLoginCount once it completes
 	Login(phone string, password string) (*domain.User, error)
+	IncrementLoginCount(id string) error
 	GetUserByID(id string) (*domain.User, error)
-	PatchUserByID(id string, data *domain.User) (*domain.User, error)
+	// GetUserIDByPhone returns the id of the user stored with phone, active
+	// or not, so failed logins can be attributed to them
+	GetUserIDByPhone(phone string) (string, error)
+	PatchUserByID(actor domain.Actor, id string, data *domain.User) (*domain.User, error)
+	UpdatePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error
+	// IsPasswordReused reports whether password is one of the depth newest
+	// passwords of the user, the current one included
+	IsPasswordReused(id string, password string, depth int) (bool, error)
+	// CheckPassword fails with cons.ErrPasswordNotMatch unless password is
+	// the one of the active user
+	CheckPassword(id string, password string) error
+	// PrunePasswordHistory keeps the depth newest replaced passwords of the
+	// user and deletes the others
+	PrunePasswordHistory(id string, depth int) (int64, error)
+	SetMustChangePassword(actor domain.Actor, id string, mustChange bool) error
+	SetUserRole(actor domain.Actor, id string, role string) error
+	GetMFA(userID string) (*domain.MFA, error)
+	SaveTOTPSecret(userID string, encryptedSecret string) error
+	ConfirmTOTP(userID string, recoveryCodes []string) error
+	UseRecoveryCode(userID string, code string) (bool, error)
+	// UseTOTPStep records step as the last accepted TOTP step of the user,
+	// it reports false when this step or a later one was already accepted
+	UseTOTPStep(userID string, step int64) (bool, error)
+	// CountMFAAttempt records an attempt at the MFA challenge and returns
+	// the attempts made at it so far, this one included
+	CountMFAAttempt(challengeID string, userID string, expiresAt time.Time) (int, error)
+	// UseMFAChallenge marks the challenge as passed, it reports false when it
+	// was already passed so a challenge token is only exchanged once
+	UseMFAChallenge(challengeID string) (bool, error)
+	DeleteMFAChallengesExpiredBefore(before time.Time) (int64, error)
+	CreateSession(data *domain.Session) (*domain.Session, error)
+	TouchSession(userID string, sessionID string) error
+	ListSessions(userID string) ([]domain.Session, error)
+	RevokeSession(userID string, sessionID string) error
+	RevokeUserSessions(userID string, exceptSessionID string) (int64, error)
+	CreateLoginEvent(data *domain.LoginEvent) error
+	ListLoginEvents(userID string, limit int, offset int) (events []domain.LoginEvent, total int, err error)
+	DeleteLoginEventsBefore(before time.Time) (int64, error)
+	ListAuditEntries(filter domain.AuditFilter, limit int, offset int) (entries []domain.AuditEntry, total int, err error)
+	// CreateOutboxEvent stores event in the outbox, call it inside WithTx so
+	// the event is only published when the change it describes commits
+	CreateOutboxEvent(event domain.EventPayload) error
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . OutboxService
+type OutboxService interface {
+	// Relay publishes the next batch of pending events in the order they
+	// were stored and reports how many were published
+	Relay() (int, error)
+	Prune() (int64, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . OutboxRepo
+type OutboxRepo interface {
+	// ClaimOutboxEvents leases up to limit unpublished events, oldest first,
+	// so concurrent relays do not publish the same events. An event whose
+	// lease expired without being marked is claimed again. Events behind a
+	// leased event of the same aggregate are not claimed, so an event waiting
+	// for its retry holds back the later ones. Dead-lettered events are
+	// never claimed and hold back nothing.
+	ClaimOutboxEvents(limit int, lease time.Duration) ([]domain.Event, error)
+	MarkOutboxEventPublished(id string) error
+	// RecordOutboxEventFailure counts the failed attempt and leases the event
+	// until its retry, or dead-letters it
+	RecordOutboxEventFailure(failure domain.OutboxFailure) error
+	DeleteOutboxEventsPublishedBefore(before time.Time) (int64, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . WebhookRepo
+type WebhookRepo interface {
+	CreateWebhook(data *domain.Webhook) (*domain.Webhook, error)
+	ListWebhooks() ([]domain.Webhook, error)
+	GetWebhook(id string) (*domain.Webhook, error)
+	// UpdateWebhook keeps the stored secret when data has none
+	UpdateWebhook(data *domain.Webhook) (*domain.Webhook, error)
+	// DeleteWebhook deletes the deliveries of the subscription with it
+	DeleteWebhook(id string) error
+	// EnqueueWebhookDeliveries creates a pending delivery of event for every
+	// subscription of its type. An event is queued once per subscription,
+	// so republishing it does not deliver it twice.
+	EnqueueWebhookDeliveries(event *domain.Event) (int64, error)
+	// ClaimWebhookDeliveries leases up to limit pending deliveries that are
+	// due, oldest first, like ClaimOutboxEvents
+	ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error)
+	// RecordWebhookAttempt stores the outcome of an attempt and releases the
+	// lease
+	RecordWebhookAttempt(attempt domain.WebhookAttempt) error
+	ListWebhookDeliveries(webhookID string, limit int, offset int) (deliveries []domain.WebhookDelivery, total int, err error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . OAuthRepo
+type OAuthRepo interface {
+	CreateOAuthClient(data *domain.OAuthClient) (*domain.OAuthClient, error)
+	GetOAuthClient(id string) (*domain.OAuthClient, error)
+	ListOAuthClients() ([]domain.OAuthClient, error)
+	// DeleteOAuthClient deletes the client with its unused codes
+	DeleteOAuthClient(id string) error
+	CreateOAuthCode(data *domain.OAuthCode) error
+	// ConsumeOAuthCode marks the code as used and returns it, it fails with
+	// cons.ErrInvalidGrant when the code is unknown, used or expired
+	ConsumeOAuthCode(codeHash string) (*domain.OAuthCode, error)
+	DeleteOAuthCodesExpiredBefore(before time.Time) (int64, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . APIKeyRepo
+type APIKeyRepo interface {
+	// CreateAPIKey fails with cons.ErrUserNotFound when the owner does not
+	// exist or is deactivated
+	CreateAPIKey(data *domain.APIKey) (*domain.APIKey, error)
+	GetAPIKeyByPrefix(prefix string) (*domain.APIKey, error)
+	ListAPIKeys() ([]domain.APIKey, error)
+	// RevokeAPIKey keeps the time a key was first revoked at
+	RevokeAPIKey(id string) error
+	TouchAPIKey(id string) error
+}
+
+// EventPublisher delivers outbox events to a broker or sink
+//
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . EventPublisher
+type EventPublisher interface {
+	Publish(event *domain.Event) error
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . IdempotencyRepo
+type IdempotencyRepo interface {
+	// ReserveIdempotencyKey stores rec unless an unexpired record with the
+	// same key exists, that record is returned instead
+	ReserveIdempotencyKey(rec *domain.IdempotencyRecord) (existing *domain.IdempotencyRecord, err error)
+	CompleteIdempotencyKey(rec *domain.IdempotencyRecord) error
+	DeleteIdempotencyKey(rec *domain.IdempotencyRecord) error
+	DeleteExpiredIdempotencyKeys(before time.Time) (int64, error)
+}
+
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . PasswordBlocklist
+type PasswordBlocklist interface {
+	Contains(password string) bool
+}
+
+// UserCache is a cache of user profiles shared between instances, e.g. redis,
+// used by the caching repository behind its in-process cache
+//
+//go:generate mockgen --build_flags=--mod=mod -destination=./port.mock.gen.go -package=mock . UserCache
+type UserCache interface {
+	// Get reports found false on a miss
+	Get(id string) (user *domain.User, found bool, err error)
+	Set(id string, user *domain.User, ttl time.Duration) error
+	Delete(id string) error
 }
@@ -0,0 +1,171 @@
+package apikeysvc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.APIKeyService = (*Service)(nil)
+
+const (
+	// prefixSize is the number of random bytes of the prefix identifying a
+	// key, secretSize those of the secret part
+	prefixSize = 6
+	secretSize = 32
+
+	maxNameLength = 60
+)
+
+type Service struct {
+	repo port.APIKeyRepo
+}
+
+func New(repo port.APIKeyRepo) *Service {
+	return &Service{
+		repo: repo,
+	}
+}
+
+func (svc *Service) CreateAPIKey(data *domain.APIKey) (*domain.APIKey, error) {
+	name := strings.TrimSpace(data.Name)
+	if name == "" || len(name) > maxNameLength || len(data.Scopes) == 0 {
+		return nil, cons.ErrInvalidAPIKey
+	}
+
+	scopes, err := normalizeScopes(data.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	if data.ExpiresAt != nil && !data.ExpiresAt.After(time.Now()) {
+		return nil, fmt.Errorf("%w: expires_at must be in the future", cons.ErrInvalidAPIKey)
+	}
+
+	prefix, err := randomString(prefixSize, hex.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := randomString(secretSize, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return nil, err
+	}
+
+	key := cons.APIKeyPrefix + prefix + "_" + secret
+	res, err := svc.repo.CreateAPIKey(&domain.APIKey{
+		Name:      name,
+		UserID:    data.UserID,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		KeyHash:   hashKey(key),
+		ExpiresAt: data.ExpiresAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res.Key = key
+	res.KeyHash = ""
+	return res, nil
+}
+
+func (svc *Service) ListAPIKeys() ([]domain.APIKey, error) {
+	keys, err := svc.repo.ListAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range keys {
+		keys[i].KeyHash = ""
+	}
+
+	return keys, nil
+}
+
+func (svc *Service) RevokeAPIKey(id string) error {
+	return svc.repo.RevokeAPIKey(id)
+}
+
+// Authenticate looks the key up by its prefix and compares the hash of the
+// whole key, a key that is not well formed or unknown is an invalid token
+// like any other bearer token.
+func (svc *Service) Authenticate(key string) (*domain.APIKey, error) {
+	prefix, _, ok := strings.Cut(strings.TrimPrefix(key, cons.APIKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(key, cons.APIKeyPrefix) {
+		return nil, cons.ErrInvalidToken
+	}
+
+	data, err := svc.repo.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		if errors.Is(err, cons.ErrAPIKeyNotFound) {
+			return nil, cons.ErrInvalidToken
+		}
+
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashKey(key)), []byte(data.KeyHash)) != 1 {
+		return nil, cons.ErrInvalidToken
+	}
+
+	if data.RevokedAt != nil || (data.ExpiresAt != nil && !data.ExpiresAt.After(time.Now())) {
+		return nil, cons.ErrAPIKeyRevoked
+	}
+
+	err = svc.repo.TouchAPIKey(data.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	data.KeyHash = ""
+	return data, nil
+}
+
+// normalizeScopes drops duplicates and orders scopes like cons.APIKeyScopes.
+func normalizeScopes(scopes []string) ([]string, error) {
+	requested := make(map[string]bool, len(scopes))
+	for _, scope := range scopes {
+		requested[scope] = true
+	}
+
+	res := []string{}
+	for _, scope := range cons.APIKeyScopes {
+		if requested[scope] {
+			res = append(res, scope)
+			delete(requested, scope)
+		}
+	}
+
+	for scope := range requested {
+		return nil, fmt.Errorf("%w: %s", cons.ErrInvalidAPIKeyScope, scope)
+	}
+
+	return res, nil
+}
+
+func randomString(size int, encode func([]byte) string) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return encode(b), nil
+}
+
+// hashKey is how keys are stored, they are random enough that a plain
+// SHA-256 can not be reversed.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,284 @@
+package apikeysvc_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/apikeysvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	keyID  = "0d6f3c2a-5b7e-4a19-9c3d-2e8f1a4b6c70"
+	userID = "3b0f5f3e-2b9a-4c51-8f0e-6f1e2d3c4b5a"
+	prefix = "a1b2c3d4e5f6"
+	apiKey = cons.APIKeyPrefix + prefix + "_Yl7k2s-HqW0mXo_3pTfV9dRzLcN1bE8uJaGiKvSy4Q"
+)
+
+func newService(t *testing.T) (*apikeysvc.Service, *port.MockAPIKeyRepo) {
+	mockCtrl := gomock.NewController(t)
+	t.Cleanup(mockCtrl.Finish)
+
+	repo := port.NewMockAPIKeyRepo(mockCtrl)
+	return apikeysvc.New(repo), repo
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+type testcaseCreate struct {
+	name          string
+	data          *domain.APIKey
+	mockFunc      func(repo *port.MockAPIKeyRepo)
+	assertionFunc func(res *domain.APIKey, err error)
+}
+
+func TestService_CreateAPIKey(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(24 * time.Hour)
+
+	testcases := []testcaseCreate{
+		{
+			name: "failed no name",
+			data: &domain.APIKey{UserID: userID, Scopes: []string{cons.ScopeAdmin}},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidAPIKey))
+			},
+		},
+		{
+			name: "failed no scopes",
+			data: &domain.APIKey{UserID: userID, Name: "billing export"},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidAPIKey))
+			},
+		},
+		{
+			name: "failed unknown scope",
+			data: &domain.APIKey{UserID: userID, Name: "billing export", Scopes: []string{cons.ScopeAdmin, cons.ScopeMFA}},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidAPIKeyScope))
+				Expect(err.Error()).To(ContainSubstring(cons.ScopeMFA))
+			},
+		},
+		{
+			name: "failed expired",
+			data: &domain.APIKey{UserID: userID, Name: "billing export", Scopes: []string{cons.ScopeAdmin}, ExpiresAt: &past},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidAPIKey))
+			},
+		},
+		{
+			name: "failed owner not found",
+			data: &domain.APIKey{UserID: userID, Name: "billing export", Scopes: []string{cons.ScopeAdmin}},
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().CreateAPIKey(gomock.Any()).Return(nil, cons.ErrUserNotFound).Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrUserNotFound))
+			},
+		},
+		{
+			name: "success stores hashed key",
+			data: &domain.APIKey{
+				UserID:    userID,
+				Name:      " billing export ",
+				Scopes:    []string{cons.ScopeAdmin, cons.ScopeUser, cons.ScopeAdmin},
+				ExpiresAt: &future,
+			},
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().
+					CreateAPIKey(gomock.Any()).
+					DoAndReturn(func(data *domain.APIKey) (*domain.APIKey, error) {
+						Expect(data.Name).To(Equal("billing export"))
+						Expect(data.UserID).To(Equal(userID))
+						Expect(data.Scopes).To(Equal([]string{cons.ScopeUser, cons.ScopeAdmin}))
+						Expect(data.Prefix).To(HaveLen(12))
+						Expect(data.KeyHash).To(HaveLen(64))
+						Expect(data.Key).To(BeEmpty())
+						Expect(data.ExpiresAt).To(Equal(&future))
+						res := *data
+						res.ID = keyID
+						return &res, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.ID).To(Equal(keyID))
+				Expect(res.KeyHash).To(BeEmpty())
+				Expect(res.Key).To(HavePrefix(cons.APIKeyPrefix + res.Prefix + "_"))
+				Expect(res.Key).To(HaveLen(len(cons.APIKeyPrefix) + 12 + 1 + 43))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			svc, repo := newService(t)
+			if tc.mockFunc != nil {
+				tc.mockFunc(repo)
+			}
+
+			tc.assertionFunc(svc.CreateAPIKey(tc.data))
+		})
+	}
+}
+
+type testcaseAuthenticate struct {
+	name          string
+	key           string
+	mockFunc      func(repo *port.MockAPIKeyRepo)
+	assertionFunc func(res *domain.APIKey, err error)
+}
+
+func TestService_Authenticate(t *testing.T) {
+	stored := func(modify func(data *domain.APIKey)) *domain.APIKey {
+		data := &domain.APIKey{
+			ID:      keyID,
+			Name:    "billing export",
+			UserID:  userID,
+			Prefix:  prefix,
+			Scopes:  []string{cons.ScopeAdmin},
+			KeyHash: hash(apiKey),
+		}
+		if modify != nil {
+			modify(data)
+		}
+
+		return data
+	}
+
+	testcases := []testcaseAuthenticate{
+		{
+			name: "failed malformed key",
+			key:  cons.APIKeyPrefix + prefix,
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidToken))
+			},
+		},
+		{
+			name: "failed not an api key",
+			key:  prefix + "_" + prefix,
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidToken))
+			},
+		},
+		{
+			name: "failed unknown prefix",
+			key:  apiKey,
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().GetAPIKeyByPrefix(prefix).Return(nil, cons.ErrAPIKeyNotFound).Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidToken))
+			},
+		},
+		{
+			name: "failed repo error",
+			key:  apiKey,
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().GetAPIKeyByPrefix(prefix).Return(nil, errors.New("connection reset")).Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError("connection reset"))
+			},
+		},
+		{
+			name: "failed wrong secret",
+			key:  strings.TrimSuffix(apiKey, "Q") + "R",
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().GetAPIKeyByPrefix(prefix).Return(stored(nil), nil).Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidToken))
+			},
+		},
+		{
+			name: "failed revoked",
+			key:  apiKey,
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().
+					GetAPIKeyByPrefix(prefix).
+					Return(stored(func(data *domain.APIKey) {
+						now := time.Now()
+						data.RevokedAt = &now
+					}), nil).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrAPIKeyRevoked))
+			},
+		},
+		{
+			name: "failed expired",
+			key:  apiKey,
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().
+					GetAPIKeyByPrefix(prefix).
+					Return(stored(func(data *domain.APIKey) {
+						past := time.Now().Add(-time.Second)
+						data.ExpiresAt = &past
+					}), nil).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(MatchError(cons.ErrAPIKeyRevoked))
+			},
+		},
+		{
+			name: "success records use",
+			key:  apiKey,
+			mockFunc: func(repo *port.MockAPIKeyRepo) {
+				repo.EXPECT().GetAPIKeyByPrefix(prefix).Return(stored(nil), nil).Times(1)
+				repo.EXPECT().TouchAPIKey(keyID).Return(nil).Times(1)
+			},
+			assertionFunc: func(res *domain.APIKey, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.ID).To(Equal(keyID))
+				Expect(res.UserID).To(Equal(userID))
+				Expect(res.Scopes).To(Equal([]string{cons.ScopeAdmin}))
+				Expect(res.KeyHash).To(BeEmpty())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			svc, repo := newService(t)
+			if tc.mockFunc != nil {
+				tc.mockFunc(repo)
+			}
+
+			tc.assertionFunc(svc.Authenticate(tc.key))
+		})
+	}
+}
+
+func TestService_ListAPIKeys(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	svc, repo := newService(t)
+
+	repo.EXPECT().
+		ListAPIKeys().
+		Return([]domain.APIKey{{ID: keyID, Prefix: prefix, KeyHash: hash(apiKey)}}, nil).
+		Times(1)
+
+	res, err := svc.ListAPIKeys()
+	Expect(err).To(BeNil())
+	Expect(res).To(HaveLen(1))
+	Expect(res[0].KeyHash).To(BeEmpty())
+}
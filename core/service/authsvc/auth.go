@@ -1,6 +1,7 @@
 package authsvc
 
 import (
+	"context"
 	"errors"
 	"os"
 	"strings"
@@ -9,22 +10,73 @@ import (
 	"github.com/SawitProRecruitment/UserService/cons"
 	"github.com/SawitProRecruitment/UserService/core/domain"
 	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/lib/phone"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
 var _ port.AuthService = (*Service)(nil)
 
+// passwordChangeTokenDuration caps the lifetime of limited scope tokens
+// issued when the password has to be changed.
+const passwordChangeTokenDuration = 15 * time.Minute
+
+const jwtIssuer = "sawitApp"
+
+var jwtAudience = []string{"sawitApp"}
+
 type Service struct {
 	tokenPrvKey      []byte
 	tokenPubKey      []byte
 	tokenExpDuration time.Duration
+	passwordMaxAge   time.Duration
+	phoneParser      *phone.Parser
+	locker           *locker.Locker
 	repo             port.UserRepo
+	logger           logrus.FieldLogger
+
+	loginHistoryRetention time.Duration
+	introspectionClients  map[string]string
+	apiKeys               port.APIKeyService
 }
 
 type ServiceOpts struct {
 	PrvKeyPath       string
 	PubKeyPath       string
 	TokenExpDuration time.Duration
+	// PasswordMaxAge expires passwords older than it, zero disables expiry
+	PasswordMaxAge time.Duration
+	// PhoneParser normalizes the phone number logged in with, phone.Default
+	// when nil
+	PhoneParser *phone.Parser
+	// Locker encrypts the TOTP secrets at rest
+	Locker *locker.Locker
+	// LoginHistoryRetention is how long login attempts are kept, zero keeps
+	// them forever
+	LoginHistoryRetention time.Duration
+	// IntrospectionClients maps the id of every service allowed to
+	// introspect tokens to its secret
+	IntrospectionClients map[string]string
+	// APIKeys authenticates API keys sent instead of access tokens, nil
+	// accepts access tokens only
+	APIKeys port.APIKeyService
+	// Logger reports the failures that are not returned to the caller, nil
+	// uses the standard logger
+	Logger logrus.FieldLogger
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Role  string `json:"role,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	// NextScope is the scope granted once an MFA challenge is passed
+	NextScope string `json:"next_scope,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	// ClientID is the OAuth client the token was issued to, tokens of the
+	// login endpoints have none
+	ClientID string `json:"client_id,omitempty"`
 }
 
 func New(opts ServiceOpts, repo port.UserRepo) (*Service, error) {
@@ -38,110 +90,292 @@ func New(opts ServiceOpts, repo port.UserRepo) (*Service, error) {
 		return nil, err
 	}
 
+	logger := opts.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	phoneParser := opts.PhoneParser
+	if phoneParser == nil {
+		phoneParser = phone.Default()
+	}
+
 	return &Service{
 		tokenPrvKey:      prvKey,
 		tokenPubKey:      pubKey,
 		tokenExpDuration: opts.TokenExpDuration,
+		passwordMaxAge:   opts.PasswordMaxAge,
+		phoneParser:      phoneParser,
+		locker:           opts.Locker,
 		repo:             repo,
+		logger:           logger,
+
+		loginHistoryRetention: opts.LoginHistoryRetention,
+		introspectionClients:  opts.IntrospectionClients,
+		apiKeys:               opts.APIKeys,
 	}, nil
 }
 
-func (svc *Service) Login(req *domain.User) (*domain.AuthData, error) {
-	data, err := svc.repo.Login(req.PhoneNumber, req.Password)
-	if err != nil {
-		return nil, err
+func (svc *Service) Login(req *domain.User, device domain.Device) (*domain.AuthData, error) {
+	// numbers stored before phone numbers were normalized are kept as they
+	// were typed, they are looked up as typed when the normalized number
+	// misses. A number that does not parse is only looked up as typed.
+	rawPhoneNumber := strings.TrimSpace(req.PhoneNumber)
+	phoneNumber, parseErr := svc.phoneParser.Normalize(rawPhoneNumber)
+	lookups := []string{phoneNumber, rawPhoneNumber}
+	if parseErr != nil {
+		phoneNumber = rawPhoneNumber
+		lookups = []string{rawPhoneNumber}
+	} else if phoneNumber == rawPhoneNumber {
+		lookups = []string{phoneNumber}
 	}
 
-	token, err := svc.generateAccessToken(data)
+	// login counting, the session and the login event commit together
+	var (
+		res    *domain.AuthData
+		userID string
+	)
+	err := svc.repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		var (
+			data *domain.User
+			err  error
+		)
+		for _, number := range lookups {
+			data, err = repo.Login(number, req.Password)
+			if !errors.Is(err, cons.ErrLoginNotMatch) {
+				break
+			}
+		}
+		if err != nil {
+			return err
+		}
+		userID = data.ID
+
+		scope := cons.ScopeUser
+		expDuration := svc.tokenExpDuration
+		if svc.passwordChangeRequired(data) {
+			scope = cons.ScopePasswordChange
+			if expDuration > passwordChangeTokenDuration {
+				expDuration = passwordChangeTokenDuration
+			}
+		}
+
+		if scope == cons.ScopePasswordChange && device.NoPasswordChange {
+			return cons.ErrPasswordChangeNeeded
+		}
+
+		if data.MFAEnabled {
+			res, err = svc.mfaChallenge(data, scope)
+			return err
+		}
+
+		res, err = svc.startSession(repo, data, scope, expDuration, device)
+		return err
+	})
 	if err != nil {
-		return nil, err
-	}
+		reason := cons.LoginReasonError
+		switch {
+		case errors.Is(err, cons.ErrLoginNotMatch):
+			reason = cons.LoginReasonInvalidCredentials
+			if parseErr != nil {
+				reason = cons.LoginReasonInvalidPhone
+			}
+			userID, phoneNumber = svc.phoneOwner(lookups, phoneNumber)
+		case errors.Is(err, cons.ErrPasswordChangeNeeded):
+			reason = cons.LoginReasonPasswordChange
+		}
 
-	res := &domain.AuthData{
-		ID:          data.ID,
-		AccessToken: token,
+		svc.recordLoginFailure(userID, phoneNumber, reason, device)
+		return nil, err
 	}
 
 	return res, nil
 }
 
-func (svc *Service) VerifyAuthHeader(authHeader string) (string, error) {
+// VerifyAuthHeader validates the bearer token and makes sure it is allowed
+// for scope. Tokens with the full user scope are allowed everywhere. The
+// bearer token can be an API key as well.
+func (svc *Service) VerifyAuthHeader(authHeader string, scope string) (*domain.TokenClaims, error) {
 	split := strings.Split(strings.TrimSpace(authHeader), " ")
 	if len(split) != 2 {
-		return "", cons.ErrInvalidToken
+		return nil, cons.ErrInvalidToken
 	}
 
 	if split[0] != cons.AuthTokenType {
-		return "", cons.ErrInvalidToken
+		return nil, cons.ErrInvalidToken
 	}
 
 	token := split[1]
-	id, err := svc.verifyToken(token)
+	if svc.apiKeys != nil && strings.HasPrefix(token, cons.APIKeyPrefix) {
+		return svc.verifyAPIKey(token, scope)
+	}
+
+	claims, err := svc.verifyToken(token)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if !hasScope(claims.Scope, cons.ScopeUser) && !hasScope(claims.Scope, scope) {
+		if claims.Scope == cons.ScopePasswordChange {
+			return nil, cons.ErrPasswordChangeNeeded
+		}
+
+		return nil, cons.ErrTokenScope
+	}
+
+	// every access token is bound to a session so it can be revoked before
+	// it expires
+	if claims.SessionID == "" {
+		return nil, cons.ErrInvalidToken
+	}
+
+	err = svc.repo.TouchSession(claims.UserID, claims.SessionID)
+	if err != nil {
+		return nil, err
 	}
 
-	return id, nil
+	return claims, nil
 }
 
-func (svc *Service) generateAccessToken(data *domain.User) (string, error) {
-	jwtIssuer := "sawitApp"
-	jwtAud := []string{
-		"sawitApp",
+// verifyAPIKey authorizes the request as the owner of the key, with the
+// role the owner has now so demoted admins lose access with their keys.
+// Unlike tokens, keys must carry the exact scope of the route so a key
+// scoped to user never reaches admin routes.
+func (svc *Service) verifyAPIKey(key string, scope string) (*domain.TokenClaims, error) {
+	data, err := svc.apiKeys.Authenticate(key)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Join(data.Scopes, " ")
+	if !hasScope(scopes, scope) {
+		return nil, cons.ErrTokenScope
 	}
 
+	user, err := svc.repo.GetUserByID(data.UserID)
+	if err != nil {
+		if errors.Is(err, cons.ErrUserNotFound) {
+			return nil, cons.ErrInvalidToken
+		}
+
+		return nil, err
+	}
+
+	return &domain.TokenClaims{
+		UserID:   user.ID,
+		Role:     user.Role,
+		Scope:    scopes,
+		APIKeyID: data.ID,
+	}, nil
+}
+
+func (svc *Service) passwordChangeRequired(data *domain.User) bool {
+	if data.MustChangePassword {
+		return true
+	}
+
+	if svc.passwordMaxAge <= 0 || data.PasswordChangedAt == nil {
+		return false
+	}
+
+	return time.Since(*data.PasswordChangedAt) > svc.passwordMaxAge
+}
+
+func (svc *Service) generateAccessToken(data *domain.User, sessionID string, scope string, nextScope string, expDuration time.Duration) (string, error) {
+	return svc.signToken(newTokenClaims(data, sessionID, scope, nextScope, expDuration))
+}
+
+func newTokenClaims(data *domain.User, sessionID string, scope string, nextScope string, expDuration time.Duration) *tokenClaims {
+	return &tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   data.ID,
+			Audience:  jwtAudience,
+			Issuer:    jwtIssuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(expDuration)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+		Role:      data.Role,
+		Scope:     scope,
+		NextScope: nextScope,
+		SessionID: sessionID,
+	}
+}
+
+// signToken signs claims with the private key, the key id lets verifiers
+// pick the key from JWKS.
+func (svc *Service) signToken(claims jwt.Claims) (string, error) {
 	key, err := jwt.ParseRSAPrivateKeyFromPEM(svc.tokenPrvKey)
 	if err != nil {
 		return "", err
 	}
 
-	claims := &jwt.RegisteredClaims{
-		Subject:   data.ID,
-		Audience:  jwtAud,
-		Issuer:    jwtIssuer,
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(svc.tokenExpDuration)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		NotBefore: jwt.NewNumericDate(time.Now()),
+	jwk, err := svc.publicJWK()
+	if err != nil {
+		return "", err
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = jwk.KeyID
 	return token.SignedString(key)
 }
 
-func (svc *Service) verifyToken(tokenStr string) (string, error) {
-	var id string
+func (svc *Service) verifyToken(tokenStr string) (*domain.TokenClaims, error) {
+	claims, err := svc.parseToken(tokenStr)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens issued before scopes existed carry full user access
+	scope := claims.Scope
+	if scope == "" {
+		scope = cons.ScopeUser
+	}
+
+	return &domain.TokenClaims{
+		UserID:    claims.Subject,
+		Role:      claims.Role,
+		Scope:     scope,
+		SessionID: claims.SessionID,
+	}, nil
+}
+
+func (svc *Service) parseToken(tokenStr string) (*tokenClaims, error) {
 	key, err := jwt.ParseRSAPublicKeyFromPEM(svc.tokenPubKey)
 	if err != nil {
-		return id, err
+		return nil, err
 	}
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+	// ID tokens are signed with the same key, the issuer and audience keep
+	// them from being used as access tokens
+	claims := &tokenClaims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return id, cons.ErrJWTSignMethod
+			return nil, cons.ErrJWTSignMethod
 		}
 
 		return key, nil
-	})
+	}, jwt.WithIssuer(jwtIssuer), jwt.WithAudience(jwtAudience[0]))
 
 	if err != nil {
 		switch {
 		case errors.Is(err, jwt.ErrTokenMalformed):
-			return id, cons.ErrJWTFormat
+			return nil, cons.ErrJWTFormat
 		case errors.Is(err, jwt.ErrTokenSignatureInvalid):
-			return id, cons.ErrJWTSign
+			return nil, cons.ErrJWTSign
 		case errors.Is(err, jwt.ErrTokenExpired) || errors.Is(err, jwt.ErrTokenNotValidYet):
-			return id, cons.ErrJWTExpired
+			return nil, cons.ErrJWTExpired
+		case errors.Is(err, jwt.ErrTokenInvalidIssuer) || errors.Is(err, jwt.ErrTokenInvalidAudience):
+			return nil, cons.ErrInvalidToken
 		default:
-			return id, err
+			return nil, err
 		}
 	}
 
-	if token.Valid {
-		id, err = token.Claims.GetSubject()
-		if err != nil {
-			return id, err
-		}
+	if !token.Valid {
+		return nil, cons.ErrInvalidToken
 	}
 
-	return id, nil
+	return claims, nil
 }
@@ -1,13 +1,16 @@
 package authsvc_test
 
 import (
+	"context"
 	"log"
 	"testing"
 	"time"
 
+	"github.com/SawitProRecruitment/UserService/cons"
 	"github.com/SawitProRecruitment/UserService/core/domain"
 	"github.com/SawitProRecruitment/UserService/core/port"
 	"github.com/SawitProRecruitment/UserService/core/service/authsvc"
+	"github.com/SawitProRecruitment/UserService/lib/phone"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
@@ -19,6 +22,18 @@ const (
 	ExpDuration    = 2 * time.Hour
 )
 
+var testPhoneParser, _ = phone.New(phone.Options{DefaultCountryCode: "62"})
+
+// expectTx runs the functions passed to WithTx on repo itself.
+func expectTx(repo *port.MockUserRepo) {
+	repo.EXPECT().
+		WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(port.UserRepo) error) error {
+			return fn(repo)
+		}).
+		AnyTimes()
+}
+
 type testcaseLogin struct {
 	name          string
 	phone         string
@@ -29,6 +44,107 @@ type testcaseLogin struct {
 
 func TestService_Login(t *testing.T) {
 	testcases := []testcaseLogin{
+		{
+			name:  "failed invalid phone number",
+			phone: "+62abc",
+			pass:  "Passw0rd!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					Login("+62abc", "Passw0rd!").
+					Return(nil, cons.ErrLoginNotMatch).
+					Times(1)
+				repo.EXPECT().
+					GetUserIDByPhone("+62abc").
+					Return("", cons.ErrUserNotFound).
+					Times(1)
+				repo.EXPECT().
+					CreateLoginEvent(gomock.Any()).
+					DoAndReturn(func(data *domain.LoginEvent) error {
+						Expect(data.Success).To(BeFalse())
+						Expect(data.Reason).To(Equal(cons.LoginReasonInvalidPhone))
+						return nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(tokenData).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrLoginNotMatch))
+			},
+		},
+		{
+			name:  "success login with national phone format",
+			phone: "0851-5630-5136",
+			pass:  "Passw0rd!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					Login("+6285156305136", "Passw0rd!").
+					Return(&domain.User{
+						ID:          "1234-1234-1234-1234",
+						PhoneNumber: "+6285156305136",
+					}, nil).
+					Times(1)
+				repo.EXPECT().
+					IncrementLoginCount(gomock.Any()).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					CreateSession(gomock.Any()).
+					Return(&domain.Session{ID: "5678-5678-5678-5678"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CreateLoginEvent(gomock.Any()).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					CreateOutboxEvent(gomock.Any()).
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(err).To(BeNil())
+				Expect(tokenData.ID).To(Equal("1234-1234-1234-1234"))
+			},
+		},
+		{
+			name:  "success login with phone number stored before normalization",
+			phone: "+62851-5630-5136",
+			pass:  "Passw0rd!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				gomock.InOrder(
+					repo.EXPECT().
+						Login("+6285156305136", "Passw0rd!").
+						Return(nil, cons.ErrLoginNotMatch).
+						Times(1),
+					repo.EXPECT().
+						Login("+62851-5630-5136", "Passw0rd!").
+						Return(&domain.User{
+							ID:          "1234-1234-1234-1234",
+							PhoneNumber: "+62851-5630-5136",
+						}, nil).
+						Times(1),
+				)
+				repo.EXPECT().
+					IncrementLoginCount(gomock.Any()).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					CreateSession(gomock.Any()).
+					Return(&domain.Session{ID: "5678-5678-5678-5678"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CreateLoginEvent(gomock.Any()).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					CreateOutboxEvent(gomock.Any()).
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(err).To(BeNil())
+				Expect(tokenData.ID).To(Equal("1234-1234-1234-1234"))
+			},
+		},
 		{
 			name:  "success login",
 			phone: "+6285156305136",
@@ -43,6 +159,22 @@ func TestService_Login(t *testing.T) {
 						LoginCount:  2,
 					}, nil).
 					Times(1)
+				repo.EXPECT().
+					IncrementLoginCount(gomock.Any()).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					CreateSession(gomock.Any()).
+					Return(&domain.Session{ID: "5678-5678-5678-5678"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CreateLoginEvent(gomock.Any()).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					CreateOutboxEvent(gomock.Any()).
+					Return(nil).
+					Times(1)
 			},
 			assertionFunc: func(tokenData *domain.AuthData, err error) {
 				Expect(*tokenData).To(
@@ -66,6 +198,7 @@ func TestService_Login(t *testing.T) {
 			defer mockCtrl.Finish()
 
 			mockRepo = port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
 			if tc.mockFunc != nil {
 				tc.mockFunc(mockRepo)
 			}
@@ -74,6 +207,7 @@ func TestService_Login(t *testing.T) {
 				PrvKeyPath:       PrivateKeyPath,
 				PubKeyPath:       PublicKeyPath,
 				TokenExpDuration: ExpDuration,
+				PhoneParser:      testPhoneParser,
 			}
 
 			svc, err := authsvc.New(opts, mockRepo)
@@ -86,18 +220,73 @@ func TestService_Login(t *testing.T) {
 				Password:    tc.pass,
 			}
 
-			tokenData, err := svc.Login(req)
+			tokenData, err := svc.Login(req, domain.Device{UserAgent: "test", IPAddress: "127.0.0.1"})
 			tc.assertionFunc(tokenData, err)
 		})
 	}
 }
 
+func TestService_LoginNoPasswordChange(t *testing.T) {
+	testcases := []struct {
+		name string
+		user *domain.User
+	}{
+		{
+			name: "password login",
+			user: &domain.User{ID: "1234-1234-1234-1234", MustChangePassword: true},
+		},
+		{
+			name: "no MFA challenge",
+			user: &domain.User{ID: "1234-1234-1234-1234", MustChangePassword: true, MFAEnabled: true},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			// neither a session nor a successful login is recorded
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
+			mockRepo.EXPECT().
+				Login("+6285156305136", "Passw0rd!").
+				Return(tc.user, nil).
+				Times(1)
+			mockRepo.EXPECT().
+				CreateLoginEvent(gomock.Any()).
+				DoAndReturn(func(data *domain.LoginEvent) error {
+					Expect(data.UserID).To(Equal(tc.user.ID))
+					Expect(data.Success).To(BeFalse())
+					Expect(data.Reason).To(Equal(cons.LoginReasonPasswordChange))
+					return nil
+				}).
+				Times(1)
+
+			opts := authsvc.ServiceOpts{
+				PrvKeyPath:       PrivateKeyPath,
+				PubKeyPath:       PublicKeyPath,
+				TokenExpDuration: ExpDuration,
+				PhoneParser:      testPhoneParser,
+			}
+
+			svc, err := authsvc.New(opts, mockRepo)
+			Expect(err).To(BeNil())
+
+			tokenData, err := svc.Login(&domain.User{PhoneNumber: "+6285156305136", Password: "Passw0rd!"}, domain.Device{NoPasswordChange: true})
+			Expect(tokenData).To(BeNil())
+			Expect(err).To(MatchError(cons.ErrPasswordChangeNeeded))
+		})
+	}
+}
+
 type testcaseVerify struct {
 	name          string
 	authHeader    string
 	isValid       bool
 	mockFunc      func(repo *port.MockUserRepo)
-	assertionFunc func(id string, err error)
+	assertionFunc func(claims *domain.TokenClaims, err error)
 }
 
 func TestService_VerifyAuthHeader(t *testing.T) {
@@ -105,24 +294,24 @@ func TestService_VerifyAuthHeader(t *testing.T) {
 		{
 			name:       "failed invalid token format",
 			authHeader: "token",
-			assertionFunc: func(id string, err error) {
-				Expect(id).To(BeEmpty())
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(claims).To(BeNil())
 				Expect(err).To(HaveOccurred())
 			},
 		},
 		{
 			name:       "failed invalid token type",
 			authHeader: "bear token",
-			assertionFunc: func(id string, err error) {
-				Expect(id).To(BeEmpty())
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(claims).To(BeNil())
 				Expect(err).To(HaveOccurred())
 			},
 		},
 		{
 			name:       "failed invalid token",
 			authHeader: "Bearer xxx",
-			assertionFunc: func(id string, err error) {
-				Expect(id).To(BeEmpty())
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(claims).To(BeNil())
 				Expect(err).To(HaveOccurred())
 			},
 		},
@@ -147,6 +336,7 @@ func TestService_VerifyAuthHeader(t *testing.T) {
 				PrvKeyPath:       PrivateKeyPath,
 				PubKeyPath:       PublicKeyPath,
 				TokenExpDuration: ExpDuration,
+				PhoneParser:      testPhoneParser,
 			}
 
 			svc, err := authsvc.New(opts, mockRepo)
@@ -154,8 +344,238 @@ func TestService_VerifyAuthHeader(t *testing.T) {
 				log.Fatal(err)
 			}
 
-			id, err := svc.VerifyAuthHeader(tc.authHeader)
-			tc.assertionFunc(id, err)
+			claims, err := svc.VerifyAuthHeader(tc.authHeader, cons.ScopeUser)
+			tc.assertionFunc(claims, err)
+		})
+	}
+}
+
+type testcaseVerifyAPIKey struct {
+	name          string
+	scope         string
+	mockFunc      func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService)
+	assertionFunc func(claims *domain.TokenClaims, err error)
+}
+
+func TestService_VerifyAuthHeaderAPIKey(t *testing.T) {
+	const (
+		apiKey   = cons.APIKeyPrefix + "a1b2c3d4e5f6_secret"
+		apiKeyID = "0d6f3c2a-5b7e-4a19-9c3d-2e8f1a4b6c70"
+		ownerID  = "3b0f5f3e-2b9a-4c51-8f0e-6f1e2d3c4b5a"
+	)
+
+	adminKey := &domain.APIKey{ID: apiKeyID, UserID: ownerID, Scopes: []string{cons.ScopeAdmin}}
+
+	testcases := []testcaseVerifyAPIKey{
+		{
+			name:  "failed revoked key",
+			scope: cons.ScopeAdmin,
+			mockFunc: func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService) {
+				apiKeys.EXPECT().Authenticate(apiKey).Return(nil, cons.ErrAPIKeyRevoked).Times(1)
+			},
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(claims).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrAPIKeyRevoked))
+			},
+		},
+		{
+			name:  "failed scope not granted",
+			scope: cons.ScopeUser,
+			mockFunc: func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService) {
+				apiKeys.EXPECT().Authenticate(apiKey).Return(adminKey, nil).Times(1)
+			},
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(err).To(MatchError(cons.ErrTokenScope))
+			},
+		},
+		{
+			name:  "failed owner deactivated",
+			scope: cons.ScopeAdmin,
+			mockFunc: func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService) {
+				apiKeys.EXPECT().Authenticate(apiKey).Return(adminKey, nil).Times(1)
+				repo.EXPECT().GetUserByID(ownerID).Return(nil, cons.ErrUserNotFound).Times(1)
+			},
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidToken))
+			},
+		},
+		{
+			name:  "success as owner with current role",
+			scope: cons.ScopeAdmin,
+			mockFunc: func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService) {
+				apiKeys.EXPECT().Authenticate(apiKey).Return(adminKey, nil).Times(1)
+				repo.EXPECT().GetUserByID(ownerID).Return(&domain.User{ID: ownerID, Role: cons.RoleAdmin}, nil).Times(1)
+			},
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(err).To(BeNil())
+				Expect(claims).To(Equal(&domain.TokenClaims{
+					UserID:   ownerID,
+					Role:     cons.RoleAdmin,
+					Scope:    cons.ScopeAdmin,
+					APIKeyID: apiKeyID,
+				}))
+			},
+		},
+		{
+			name:  "failed user scope does not grant admin",
+			scope: cons.ScopeAdmin,
+			mockFunc: func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService) {
+				apiKeys.EXPECT().
+					Authenticate(apiKey).
+					Return(&domain.APIKey{ID: apiKeyID, UserID: ownerID, Scopes: []string{cons.ScopeUser}}, nil).
+					Times(1)
+			},
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(claims).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrTokenScope))
+			},
+		},
+		{
+			name:  "success with one of many scopes",
+			scope: cons.ScopeUser,
+			mockFunc: func(repo *port.MockUserRepo, apiKeys *port.MockAPIKeyService) {
+				apiKeys.EXPECT().
+					Authenticate(apiKey).
+					Return(&domain.APIKey{ID: apiKeyID, UserID: ownerID, Scopes: []string{cons.ScopeUser, cons.ScopeAdmin}}, nil).
+					Times(1)
+				repo.EXPECT().GetUserByID(ownerID).Return(&domain.User{ID: ownerID, Role: cons.RoleUser}, nil).Times(1)
+			},
+			assertionFunc: func(claims *domain.TokenClaims, err error) {
+				Expect(err).To(BeNil())
+				Expect(claims.Role).To(Equal(cons.RoleUser))
+				Expect(claims.Scope).To(Equal("user admin"))
+				Expect(claims.SessionID).To(BeEmpty())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			mockAPIKeys := port.NewMockAPIKeyService(mockCtrl)
+			tc.mockFunc(mockRepo, mockAPIKeys)
+
+			opts := authsvc.ServiceOpts{
+				PrvKeyPath:       PrivateKeyPath,
+				PubKeyPath:       PublicKeyPath,
+				TokenExpDuration: ExpDuration,
+				PhoneParser:      testPhoneParser,
+				APIKeys:          mockAPIKeys,
+			}
+
+			svc, err := authsvc.New(opts, mockRepo)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			claims, err := svc.VerifyAuthHeader(cons.AuthTokenType+" "+apiKey, tc.scope)
+			tc.assertionFunc(claims, err)
+		})
+	}
+}
+
+func TestService_PasswordChangeScope(t *testing.T) {
+	now := time.Now()
+	changedAt := now.Add(-100 * 24 * time.Hour)
+	testcases := []struct {
+		name           string
+		user           *domain.User
+		wantScope      string
+		wantUserAccess bool
+	}{
+		{
+			name: "full scope for valid password",
+			user: &domain.User{
+				ID:                "1234-1234-1234-1234",
+				Role:              cons.RoleUser,
+				PasswordChangedAt: &now,
+			},
+			wantScope:      cons.ScopeUser,
+			wantUserAccess: true,
+		},
+		{
+			name: "limited scope when admin forces password change",
+			user: &domain.User{
+				ID:                 "1234-1234-1234-1234",
+				MustChangePassword: true,
+			},
+			wantScope: cons.ScopePasswordChange,
+		},
+		{
+			name: "limited scope when password expired",
+			user: &domain.User{
+				ID:                "1234-1234-1234-1234",
+				PasswordChangedAt: &changedAt,
+			},
+			wantScope: cons.ScopePasswordChange,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
+			mockRepo.EXPECT().
+				Login(gomock.Any(), gomock.Any()).
+				Return(tc.user, nil).
+				Times(1)
+			mockRepo.EXPECT().
+				IncrementLoginCount(gomock.Any()).
+				Return(nil).
+				Times(1)
+			mockRepo.EXPECT().
+				CreateSession(gomock.Any()).
+				Return(&domain.Session{ID: "5678-5678-5678-5678"}, nil).
+				Times(1)
+			mockRepo.EXPECT().
+				CreateLoginEvent(gomock.Any()).
+				Return(nil).
+				Times(1)
+			mockRepo.EXPECT().
+				CreateOutboxEvent(gomock.Any()).
+				Return(nil).
+				Times(1)
+			mockRepo.EXPECT().
+				TouchSession(tc.user.ID, "5678-5678-5678-5678").
+				Return(nil).
+				AnyTimes()
+
+			opts := authsvc.ServiceOpts{
+				PrvKeyPath:       PrivateKeyPath,
+				PubKeyPath:       PublicKeyPath,
+				TokenExpDuration: ExpDuration,
+				PasswordMaxAge:   90 * 24 * time.Hour,
+				PhoneParser:      testPhoneParser,
+			}
+
+			svc, err := authsvc.New(opts, mockRepo)
+			Expect(err).To(BeNil())
+
+			tokenData, err := svc.Login(&domain.User{PhoneNumber: "+6285156305136", Password: "Passw0rd!"}, domain.Device{})
+			Expect(err).To(BeNil())
+			Expect(tokenData.Scope).To(Equal(tc.wantScope))
+			Expect(tokenData.PasswordChangeRequired).To(Equal(!tc.wantUserAccess))
+
+			authHeader := cons.AuthTokenType + " " + tokenData.AccessToken
+			claims, err := svc.VerifyAuthHeader(authHeader, cons.ScopePasswordChange)
+			Expect(err).To(BeNil())
+			Expect(claims.UserID).To(Equal(tc.user.ID))
+
+			claims, err = svc.VerifyAuthHeader(authHeader, cons.ScopeUser)
+			if tc.wantUserAccess {
+				Expect(err).To(BeNil())
+				Expect(claims.Role).To(Equal(tc.user.Role))
+			} else {
+				Expect(err).To(MatchError(cons.ErrPasswordChangeNeeded))
+			}
 		})
 	}
 }
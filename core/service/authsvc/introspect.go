@@ -0,0 +1,81 @@
+package authsvc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+)
+
+// AuthenticateClient checks the credentials of a service allowed to
+// introspect tokens. Hashes are compared so the time taken does not leak the
+// length of the secret.
+func (svc *Service) AuthenticateClient(clientID string, clientSecret string) error {
+	secret, ok := svc.introspectionClients[clientID]
+	if !ok || clientID == "" {
+		return cons.ErrInvalidClient
+	}
+
+	want := sha256.Sum256([]byte(secret))
+	got := sha256.Sum256([]byte(clientSecret))
+	if subtle.ConstantTimeCompare(want[:], got[:]) != 1 {
+		return cons.ErrInvalidClient
+	}
+
+	return nil
+}
+
+// Introspect reports whether token is an access token that is still valid
+// and not revoked, like VerifyAuthHeader does for the user endpoints. A
+// token that is not active is not an error, the error is reserved for
+// failures to check it.
+func (svc *Service) Introspect(token string) (*domain.TokenIntrospection, error) {
+	inactive := &domain.TokenIntrospection{Active: false}
+
+	claims, err := svc.parseToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	// MFA challenge tokens are not bound to a session and are only good
+	// for completing the login
+	if claims.SessionID == "" || claims.Scope == cons.ScopeMFA {
+		return inactive, nil
+	}
+
+	err = svc.repo.TouchSession(claims.Subject, claims.SessionID)
+	if err != nil {
+		if errors.Is(err, cons.ErrSessionRevoked) {
+			return inactive, nil
+		}
+
+		return nil, err
+	}
+
+	// tokens issued before scopes existed carry full user access
+	scope := claims.Scope
+	if scope == "" {
+		scope = cons.ScopeUser
+	}
+
+	res := &domain.TokenIntrospection{
+		Active:    true,
+		Subject:   claims.Subject,
+		Scope:     scope,
+		ClientID:  claims.ClientID,
+		TokenType: cons.AuthTokenType,
+		Issuer:    claims.Issuer,
+		Audience:  claims.Audience,
+		SessionID: claims.SessionID,
+	}
+	if claims.ExpiresAt != nil {
+		res.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		res.IssuedAt = claims.IssuedAt.Unix()
+	}
+
+	return res, nil
+}
@@ -0,0 +1,202 @@
+package authsvc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/authsvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+func newIntrospectTestService(t *testing.T, repo port.UserRepo) *authsvc.Service {
+	opts := authsvc.ServiceOpts{
+		PrvKeyPath:       PrivateKeyPath,
+		PubKeyPath:       PublicKeyPath,
+		TokenExpDuration: ExpDuration,
+		PhoneParser:      testPhoneParser,
+		IntrospectionClients: map[string]string{
+			"billing": "billing-secret",
+		},
+	}
+
+	svc, err := authsvc.New(opts, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc
+}
+
+// loginToken logs user in through the mocked repo and returns its token.
+func loginToken(svc *authsvc.Service, repo *port.MockUserRepo, user *domain.User, sessionID string) string {
+	repo.EXPECT().
+		Login(user.PhoneNumber, "Passw0rd!").
+		Return(user, nil).
+		Times(1)
+	if !user.MFAEnabled {
+		repo.EXPECT().
+			IncrementLoginCount(gomock.Any()).
+			Return(nil).
+			Times(1)
+		repo.EXPECT().
+			CreateSession(gomock.Any()).
+			Return(&domain.Session{ID: sessionID, UserID: user.ID}, nil).
+			Times(1)
+		repo.EXPECT().
+			CreateLoginEvent(gomock.Any()).
+			Return(nil).
+			Times(1)
+		repo.EXPECT().
+			CreateOutboxEvent(gomock.Any()).
+			Return(nil).
+			Times(1)
+	}
+
+	data, err := svc.Login(&domain.User{PhoneNumber: user.PhoneNumber, Password: "Passw0rd!"}, domain.Device{})
+	Expect(err).To(BeNil())
+	if user.MFAEnabled {
+		return data.MFAToken
+	}
+
+	return data.AccessToken
+}
+
+func TestService_Introspect(t *testing.T) {
+	const (
+		userID    = "1234-1234-1234-1234"
+		sessionID = "5678-5678-5678-5678"
+	)
+	testcases := []struct {
+		name          string
+		user          *domain.User
+		token         func(token string) string
+		mockFunc      func(repo *port.MockUserRepo)
+		assertionFunc func(res *domain.TokenIntrospection, err error)
+	}{
+		{
+			name: "active token",
+			user: &domain.User{ID: userID, PhoneNumber: "+6285156305136"},
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					TouchSession(userID, sessionID).
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.TokenIntrospection, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.Active).To(BeTrue())
+				Expect(res.Subject).To(Equal(userID))
+				Expect(res.Scope).To(Equal(cons.ScopeUser))
+				Expect(res.SessionID).To(Equal(sessionID))
+				Expect(res.TokenType).To(Equal(cons.AuthTokenType))
+				Expect(res.ExpiresAt - res.IssuedAt).To(Equal(int64(ExpDuration.Seconds())))
+			},
+		},
+		{
+			name: "inactive revoked session",
+			user: &domain.User{ID: userID, PhoneNumber: "+6285156305136"},
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					TouchSession(userID, sessionID).
+					Return(cons.ErrSessionRevoked).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.TokenIntrospection, err error) {
+				Expect(err).To(BeNil())
+				Expect(*res).To(Equal(domain.TokenIntrospection{Active: false}))
+			},
+		},
+		{
+			name: "inactive tampered token",
+			user: &domain.User{ID: userID, PhoneNumber: "+6285156305136"},
+			token: func(token string) string {
+				return token + "x"
+			},
+			assertionFunc: func(res *domain.TokenIntrospection, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.Active).To(BeFalse())
+			},
+		},
+		{
+			name: "inactive mfa challenge token",
+			user: &domain.User{ID: userID, PhoneNumber: "+6285156305136", MFAEnabled: true},
+			assertionFunc: func(res *domain.TokenIntrospection, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.Active).To(BeFalse())
+			},
+		},
+		{
+			name: "failed check session",
+			user: &domain.User{ID: userID, PhoneNumber: "+6285156305136"},
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					TouchSession(userID, sessionID).
+					Return(errors.New("connection refused")).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.TokenIntrospection, err error) {
+				Expect(err).To(HaveOccurred())
+				Expect(res).To(BeNil())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
+			svc := newIntrospectTestService(t, mockRepo)
+
+			token := loginToken(svc, mockRepo, tc.user, sessionID)
+			if tc.token != nil {
+				token = tc.token(token)
+			}
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockRepo)
+			}
+
+			res, err := svc.Introspect(token)
+			tc.assertionFunc(res, err)
+		})
+	}
+}
+
+func TestService_AuthenticateClient(t *testing.T) {
+	testcases := []struct {
+		name         string
+		clientID     string
+		clientSecret string
+		wantErr      error
+	}{
+		{name: "success", clientID: "billing", clientSecret: "billing-secret"},
+		{name: "failed wrong secret", clientID: "billing", clientSecret: "billing-secreT", wantErr: cons.ErrInvalidClient},
+		{name: "failed unknown client", clientID: "shipping", clientSecret: "billing-secret", wantErr: cons.ErrInvalidClient},
+		{name: "failed empty credentials", wantErr: cons.ErrInvalidClient},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			svc := newIntrospectTestService(t, port.NewMockUserRepo(mockCtrl))
+
+			err := svc.AuthenticateClient(tc.clientID, tc.clientSecret)
+			if tc.wantErr == nil {
+				Expect(err).To(BeNil())
+				return
+			}
+			Expect(err).To(MatchError(tc.wantErr))
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package authsvc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+)
+
+const (
+	defaultLoginHistoryPageSize = 20
+	maxLoginHistoryPageSize     = 100
+)
+
+// ListLoginHistory returns a page of login attempts of the user, newest
+// first.
+func (svc *Service) ListLoginHistory(userID string, page int, pageSize int) (*domain.LoginHistory, error) {
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultLoginHistoryPageSize
+	}
+
+	if pageSize > maxLoginHistoryPageSize {
+		pageSize = maxLoginHistoryPageSize
+	}
+
+	events, total, err := svc.repo.ListLoginEvents(userID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.LoginHistory{
+		Events:   events,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}
+
+	return res, nil
+}
+
+// PruneLoginHistory deletes login attempts older than the retention, it is
+// a no-op when retention is disabled.
+func (svc *Service) PruneLoginHistory() (int64, error) {
+	if svc.loginHistoryRetention <= 0 {
+		return 0, nil
+	}
+
+	return svc.repo.DeleteLoginEventsBefore(time.Now().Add(-svc.loginHistoryRetention))
+}
+
+// phoneOwner finds the user stored with one of the looked up numbers, in
+// the order they were looked up, and the number it is stored with. The
+// fallback is returned without a user when none of them is taken.
+func (svc *Service) phoneOwner(lookups []string, fallback string) (string, string) {
+	for _, number := range lookups {
+		userID, err := svc.repo.GetUserIDByPhone(number)
+		if err == nil {
+			return userID, number
+		}
+
+		if !errors.Is(err, cons.ErrUserNotFound) {
+			svc.logger.WithError(err).Error("error looking up the owner of a failed login")
+			break
+		}
+	}
+
+	return "", fallback
+}
+
+// recordLoginFailure keeps failed attempts in the login history. The caller
+// still reports the original error, so a failure to record it is logged.
+func (svc *Service) recordLoginFailure(userID string, phoneNumber string, reason string, device domain.Device) {
+	err := svc.repo.CreateLoginEvent(&domain.LoginEvent{
+		UserID:      userID,
+		PhoneNumber: phoneNumber,
+		Reason:      reason,
+		UserAgent:   device.UserAgent,
+		IPAddress:   device.IPAddress,
+	})
+	if err != nil {
+		svc.logger.WithError(err).WithField("reason", reason).Error("error recording failed login attempt")
+	}
+}
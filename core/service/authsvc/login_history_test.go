@@ -0,0 +1,228 @@
+package authsvc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/authsvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestService_LoginFailureRecorded(t *testing.T) {
+	testcases := []struct {
+		name       string
+		loginErr   error
+		wantReason string
+	}{
+		{
+			name:       "wrong password",
+			loginErr:   cons.ErrLoginNotMatch,
+			wantReason: cons.LoginReasonInvalidCredentials,
+		},
+		{
+			name:       "repository error",
+			loginErr:   errors.New("connection refused"),
+			wantReason: cons.LoginReasonError,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			device := domain.Device{UserAgent: "curl/8.0", IPAddress: "203.0.113.7"}
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
+			mockRepo.EXPECT().
+				Login("+6285156305136", "Passw0rd!").
+				Return(nil, tc.loginErr).
+				Times(1)
+			if errors.Is(tc.loginErr, cons.ErrLoginNotMatch) {
+				mockRepo.EXPECT().
+					GetUserIDByPhone("+6285156305136").
+					Return("", cons.ErrUserNotFound).
+					Times(1)
+			}
+			mockRepo.EXPECT().
+				CreateLoginEvent(&domain.LoginEvent{
+					PhoneNumber: "+6285156305136",
+					Reason:      tc.wantReason,
+					UserAgent:   device.UserAgent,
+					IPAddress:   device.IPAddress,
+				}).
+				Return(errors.New("disk full")).
+				Times(1)
+
+			logger, hook := logtest.NewNullLogger()
+			opts := authsvc.ServiceOpts{
+				PrvKeyPath:       PrivateKeyPath,
+				PubKeyPath:       PublicKeyPath,
+				TokenExpDuration: ExpDuration,
+				PhoneParser:      testPhoneParser,
+				Logger:           logger,
+			}
+
+			svc, err := authsvc.New(opts, mockRepo)
+			Expect(err).To(BeNil())
+
+			tokenData, err := svc.Login(&domain.User{PhoneNumber: "+6285156305136", Password: "Passw0rd!"}, device)
+			Expect(tokenData).To(BeNil())
+			Expect(err).To(MatchError(tc.loginErr))
+
+			// the failure to record the attempt is logged, not reported
+			Expect(hook.LastEntry()).ToNot(BeNil())
+			Expect(hook.LastEntry().Level).To(Equal(logrus.ErrorLevel))
+			Expect(hook.LastEntry().Data[logrus.ErrorKey]).To(MatchError("disk full"))
+		})
+	}
+}
+
+func TestService_LoginFailureAttributed(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	// the user was stored before phone numbers were normalized
+	mockRepo := port.NewMockUserRepo(mockCtrl)
+	expectTx(mockRepo)
+	gomock.InOrder(
+		mockRepo.EXPECT().Login("+6285156305136", "wrong").Return(nil, cons.ErrLoginNotMatch),
+		mockRepo.EXPECT().Login("0851-5630-5136", "wrong").Return(nil, cons.ErrLoginNotMatch),
+		mockRepo.EXPECT().GetUserIDByPhone("+6285156305136").Return("", cons.ErrUserNotFound),
+		mockRepo.EXPECT().GetUserIDByPhone("0851-5630-5136").Return("1234-1234-1234-1234", nil),
+		mockRepo.EXPECT().CreateLoginEvent(&domain.LoginEvent{
+			UserID:      "1234-1234-1234-1234",
+			PhoneNumber: "0851-5630-5136",
+			Reason:      cons.LoginReasonInvalidCredentials,
+		}).Return(nil),
+	)
+
+	opts := authsvc.ServiceOpts{
+		PrvKeyPath:       PrivateKeyPath,
+		PubKeyPath:       PublicKeyPath,
+		TokenExpDuration: ExpDuration,
+		PhoneParser:      testPhoneParser,
+	}
+
+	svc, err := authsvc.New(opts, mockRepo)
+	Expect(err).To(BeNil())
+
+	_, err = svc.Login(&domain.User{PhoneNumber: "0851-5630-5136", Password: "wrong"}, domain.Device{})
+	Expect(err).To(MatchError(cons.ErrLoginNotMatch))
+}
+
+func TestService_ListLoginHistory(t *testing.T) {
+	testcases := []struct {
+		name         string
+		page         int
+		pageSize     int
+		wantLimit    int
+		wantOffset   int
+		wantPage     int
+		wantPageSize int
+	}{
+		{
+			name:         "defaults",
+			wantLimit:    20,
+			wantOffset:   0,
+			wantPage:     1,
+			wantPageSize: 20,
+		},
+		{
+			name:         "third page",
+			page:         3,
+			pageSize:     10,
+			wantLimit:    10,
+			wantOffset:   20,
+			wantPage:     3,
+			wantPageSize: 10,
+		},
+		{
+			name:         "page size capped",
+			page:         2,
+			pageSize:     500,
+			wantLimit:    100,
+			wantOffset:   100,
+			wantPage:     2,
+			wantPageSize: 100,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			mockRepo.EXPECT().
+				ListLoginEvents("1234-1234-1234-1234", tc.wantLimit, tc.wantOffset).
+				Return([]domain.LoginEvent{{ID: "1", Success: true}}, 42, nil).
+				Times(1)
+
+			opts := authsvc.ServiceOpts{
+				PrvKeyPath:       PrivateKeyPath,
+				PubKeyPath:       PublicKeyPath,
+				TokenExpDuration: ExpDuration,
+				PhoneParser:      testPhoneParser,
+			}
+
+			svc, err := authsvc.New(opts, mockRepo)
+			Expect(err).To(BeNil())
+
+			history, err := svc.ListLoginHistory("1234-1234-1234-1234", tc.page, tc.pageSize)
+			Expect(err).To(BeNil())
+			Expect(history.Events).To(HaveLen(1))
+			Expect(history.Page).To(Equal(tc.wantPage))
+			Expect(history.PageSize).To(Equal(tc.wantPageSize))
+			Expect(history.Total).To(Equal(42))
+		})
+	}
+}
+
+func TestService_PruneLoginHistory(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRepo := port.NewMockUserRepo(mockCtrl)
+	mockRepo.EXPECT().
+		DeleteLoginEventsBefore(gomock.Any()).
+		DoAndReturn(func(before time.Time) (int64, error) {
+			Expect(before).To(BeTemporally("~", time.Now().Add(-30*24*time.Hour), time.Minute))
+			return 7, nil
+		}).
+		Times(1)
+
+	opts := authsvc.ServiceOpts{
+		PrvKeyPath:            PrivateKeyPath,
+		PubKeyPath:            PublicKeyPath,
+		TokenExpDuration:      ExpDuration,
+		PhoneParser:           testPhoneParser,
+		LoginHistoryRetention: 30 * 24 * time.Hour,
+	}
+
+	svc, err := authsvc.New(opts, mockRepo)
+	Expect(err).To(BeNil())
+
+	n, err := svc.PruneLoginHistory()
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(int64(7)))
+
+	opts.LoginHistoryRetention = 0
+	svc, err = authsvc.New(opts, mockRepo)
+	Expect(err).To(BeNil())
+
+	n, err = svc.PruneLoginHistory()
+	Expect(err).To(BeNil())
+	Expect(n).To(BeZero())
+}
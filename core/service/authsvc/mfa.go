@@ -0,0 +1,237 @@
+package authsvc
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/lib/totp"
+)
+
+const (
+	totpIssuer         = "SawitApp"
+	mfaTokenDuration   = 5 * time.Minute
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+	// maxMFAAttempts is the number of codes that can be submitted with one
+	// challenge token, the user has to log in again after that
+	maxMFAAttempts = 5
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// LoginMFA exchanges the challenge token returned by Login for an access
+// token once a valid TOTP or unused recovery code is submitted. A token is
+// exchanged once and allows maxMFAAttempts codes, a TOTP code is accepted
+// once.
+func (svc *Service) LoginMFA(mfaToken string, code string, device domain.Device) (*domain.AuthData, error) {
+	if mfaToken == "" || strings.TrimSpace(code) == "" {
+		return nil, fmt.Errorf("%w: mfa token and code required", cons.ErrInvalidRequest)
+	}
+
+	claims, err := svc.parseToken(mfaToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Scope != cons.ScopeMFA {
+		return nil, cons.ErrTokenScope
+	}
+
+	if claims.ID == "" {
+		return nil, cons.ErrInvalidToken
+	}
+
+	userID := claims.Subject
+	attempts, err := svc.repo.CountMFAAttempt(claims.ID, userID, claims.ExpiresAt.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts > maxMFAAttempts {
+		return nil, cons.ErrMFAAttemptsExceeded
+	}
+
+	mfa, err := svc.repo.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.ConfirmedAt == nil {
+		return nil, cons.ErrMFANotEnrolled
+	}
+
+	secret, err := svc.locker.Decrypt(mfa.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	valid, err := svc.useMFACode(userID, secret, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !valid {
+		svc.recordLoginFailure(userID, "", cons.LoginReasonInvalidMFACode, device)
+		return nil, cons.ErrInvalidMFACode
+	}
+
+	used, err := svc.repo.UseMFAChallenge(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !used {
+		return nil, cons.ErrInvalidToken
+	}
+
+	scope := claims.NextScope
+	if scope != cons.ScopeUser && device.NoPasswordChange {
+		svc.recordLoginFailure(userID, "", cons.LoginReasonPasswordChange, device)
+		return nil, cons.ErrPasswordChangeNeeded
+	}
+
+	expDuration := svc.tokenExpDuration
+	if scope != cons.ScopeUser && expDuration > passwordChangeTokenDuration {
+		expDuration = passwordChangeTokenDuration
+	}
+
+	user := &domain.User{
+		ID:   userID,
+		Role: claims.Role,
+	}
+
+	return svc.startSession(svc.repo, user, scope, expDuration, device)
+}
+
+// useMFACode accepts a TOTP code of a later time step than the last one
+// accepted, or else an unused recovery code.
+func (svc *Service) useMFACode(userID string, secret string, code string) (bool, error) {
+	step, ok := totp.ValidateStep(secret, code, time.Now())
+	if ok {
+		return svc.repo.UseTOTPStep(userID, step)
+	}
+
+	return svc.repo.UseRecoveryCode(userID, normalizeRecoveryCode(code))
+}
+
+// PruneMFAChallenges deletes the attempt counts of expired challenge tokens.
+func (svc *Service) PruneMFAChallenges() (int64, error) {
+	return svc.repo.DeleteMFAChallengesExpiredBefore(time.Now())
+}
+
+// EnrollTOTP starts a TOTP enrolment, it is only enabled after ConfirmTOTP.
+func (svc *Service) EnrollTOTP(userID string) (*domain.TOTPEnrollment, error) {
+	user, err := svc.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := svc.locker.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	err = svc.repo.SaveTOTPSecret(userID, encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(totpIssuer, user.PhoneNumber, secret),
+	}
+
+	return res, nil
+}
+
+// ConfirmTOTP enables TOTP with the first code of the authenticator and
+// returns one-time recovery codes, they are only shown this once.
+func (svc *Service) ConfirmTOTP(userID string, code string) ([]string, error) {
+	mfa, err := svc.repo.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if mfa.ConfirmedAt != nil {
+		return nil, cons.ErrMFAAlreadyEnabled
+	}
+
+	secret, err := svc.locker.Decrypt(mfa.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := totp.ValidateStep(secret, code, time.Now())
+	if !ok {
+		return nil, cons.ErrInvalidMFACode
+	}
+
+	// the repository only stores the hash of the normalized codes
+	codes := make([]string, 0, recoveryCodeCount)
+	normalizedCodes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		c, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+
+		codes = append(codes, c)
+		normalizedCodes = append(normalizedCodes, normalizeRecoveryCode(c))
+	}
+
+	err = svc.repo.ConfirmTOTP(userID, normalizedCodes)
+	if err != nil {
+		return nil, err
+	}
+
+	// the confirmation code cannot be replayed to log in
+	_, err = svc.repo.UseTOTPStep(userID, step)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (svc *Service) mfaChallenge(data *domain.User, nextScope string) (*domain.AuthData, error) {
+	token, err := svc.generateAccessToken(data, "", cons.ScopeMFA, nextScope, mfaTokenDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.AuthData{
+		ID:          data.ID,
+		Scope:       cons.ScopeMFA,
+		MFARequired: true,
+		MFAToken:    token,
+	}
+
+	return res, nil
+}
+
+// generateRecoveryCode returns a code formatted as "abcde-fghij".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:recoveryCodeLength]
+	half := recoveryCodeLength / 2
+	return raw[:half] + "-" + raw[half:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
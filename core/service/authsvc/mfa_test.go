@@ -0,0 +1,352 @@
+package authsvc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/authsvc"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/lib/totp"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+const testLockerKey = "t4dNxLLolpX8UpehYb1RwbVLF1xFBNHu"
+
+func newMFATestService(t *testing.T, repo port.UserRepo) *authsvc.Service {
+	opts := authsvc.ServiceOpts{
+		PrvKeyPath:       PrivateKeyPath,
+		PubKeyPath:       PublicKeyPath,
+		TokenExpDuration: ExpDuration,
+		PhoneParser:      testPhoneParser,
+		Locker:           locker.New(testLockerKey),
+	}
+
+	svc, err := authsvc.New(opts, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc
+}
+
+type testcaseLoginMFA struct {
+	name          string
+	code          func(secret string) string
+	mockFunc      func(repo *port.MockUserRepo)
+	assertionFunc func(tokenData *domain.AuthData, err error)
+}
+
+func TestService_LoginMFA(t *testing.T) {
+	userID := "1234-1234-1234-1234"
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := locker.New(testLockerKey).Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	confirmedAt := time.Now()
+	mfa := &domain.MFA{
+		UserID:      userID,
+		TOTPSecret:  encrypted,
+		ConfirmedAt: &confirmedAt,
+	}
+
+	totpCode := func(secret string) string {
+		code, _ := totp.Code(secret, time.Now())
+		return code
+	}
+
+	testcases := []testcaseLoginMFA{
+		{
+			name: "success with totp code",
+			code: totpCode,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					IncrementLoginCount(userID).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					UseTOTPStep(userID, gomock.Any()).
+					Return(true, nil).
+					Times(1)
+				repo.EXPECT().
+					UseMFAChallenge(gomock.Any()).
+					Return(true, nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(err).To(BeNil())
+				Expect(tokenData.AccessToken).ToNot(BeEmpty())
+				Expect(tokenData.Scope).To(Equal(cons.ScopeUser))
+			},
+		},
+		{
+			name: "success with recovery code",
+			code: func(string) string {
+				return "ABCDE-FGHIJ"
+			},
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					UseRecoveryCode(userID, "abcdefghij").
+					Return(true, nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(err).To(BeNil())
+				Expect(tokenData.AccessToken).ToNot(BeEmpty())
+			},
+		},
+		{
+			name: "failed invalid code",
+			code: func(string) string {
+				return "000000"
+			},
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					UseRecoveryCode(userID, "000000").
+					Return(false, nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(tokenData).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidMFACode))
+			},
+		},
+		{
+			name: "failed replayed totp code",
+			code: totpCode,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					UseTOTPStep(userID, gomock.Any()).
+					Return(false, nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(tokenData).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidMFACode))
+			},
+		},
+		{
+			name: "failed too many attempts",
+			code: totpCode,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					CountMFAAttempt(gomock.Any(), userID, gomock.Any()).
+					Return(6, nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(tokenData).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrMFAAttemptsExceeded))
+			},
+		},
+		{
+			name: "failed challenge already passed",
+			code: totpCode,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					UseMFAChallenge(gomock.Any()).
+					Return(false, nil).
+					Times(1)
+			},
+			assertionFunc: func(tokenData *domain.AuthData, err error) {
+				Expect(tokenData).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidToken))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			// the expectations of the testcase are matched before the
+			// defaults below
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockRepo)
+			}
+
+			expectTx(mockRepo)
+			mockRepo.EXPECT().
+				Login(gomock.Any(), gomock.Any()).
+				Return(&domain.User{ID: userID, MFAEnabled: true}, nil).
+				Times(1)
+			mockRepo.EXPECT().
+				CountMFAAttempt(gomock.Any(), userID, gomock.Any()).
+				Return(1, nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				GetMFA(userID).
+				Return(mfa, nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				UseTOTPStep(userID, gomock.Any()).
+				Return(true, nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				UseMFAChallenge(gomock.Any()).
+				Return(true, nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				IncrementLoginCount(gomock.Any()).
+				Return(nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				CreateSession(gomock.Any()).
+				Return(&domain.Session{ID: "5678-5678-5678-5678"}, nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				CreateLoginEvent(gomock.Any()).
+				Return(nil).
+				AnyTimes()
+			mockRepo.EXPECT().
+				CreateOutboxEvent(gomock.Any()).
+				Return(nil).
+				AnyTimes()
+
+			svc := newMFATestService(t, mockRepo)
+			challenge, err := svc.Login(&domain.User{PhoneNumber: "+6285156305136", Password: "Passw0rd!"}, domain.Device{})
+			Expect(err).To(BeNil())
+			Expect(challenge.MFARequired).To(BeTrue())
+			Expect(challenge.AccessToken).To(BeEmpty())
+			Expect(challenge.Scope).To(Equal(cons.ScopeMFA))
+
+			_, err = svc.VerifyAuthHeader(cons.AuthTokenType+" "+challenge.MFAToken, cons.ScopeUser)
+			Expect(err).To(MatchError(cons.ErrTokenScope))
+
+			tc.assertionFunc(svc.LoginMFA(challenge.MFAToken, tc.code(secret), domain.Device{}))
+		})
+	}
+}
+
+func TestService_LoginMFANoPasswordChange(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	userID := "1234-1234-1234-1234"
+	secret, err := totp.GenerateSecret()
+	Expect(err).To(BeNil())
+
+	encrypted, err := locker.New(testLockerKey).Encrypt(secret)
+	Expect(err).To(BeNil())
+
+	confirmedAt := time.Now()
+	code, err := totp.Code(secret, time.Now())
+	Expect(err).To(BeNil())
+
+	// neither a session nor a successful login is recorded
+	mockRepo := port.NewMockUserRepo(mockCtrl)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().
+		Login(gomock.Any(), gomock.Any()).
+		Return(&domain.User{ID: userID, MFAEnabled: true, MustChangePassword: true}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		CountMFAAttempt(gomock.Any(), userID, gomock.Any()).
+		Return(1, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		GetMFA(userID).
+		Return(&domain.MFA{UserID: userID, TOTPSecret: encrypted, ConfirmedAt: &confirmedAt}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		UseTOTPStep(userID, gomock.Any()).
+		Return(true, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		UseMFAChallenge(gomock.Any()).
+		Return(true, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		CreateLoginEvent(gomock.Any()).
+		DoAndReturn(func(data *domain.LoginEvent) error {
+			Expect(data.UserID).To(Equal(userID))
+			Expect(data.Success).To(BeFalse())
+			Expect(data.Reason).To(Equal(cons.LoginReasonPasswordChange))
+			return nil
+		}).
+		Times(1)
+
+	svc := newMFATestService(t, mockRepo)
+	challenge, err := svc.Login(&domain.User{PhoneNumber: "+6285156305136", Password: "Passw0rd!"}, domain.Device{})
+	Expect(err).To(BeNil())
+	Expect(challenge.MFARequired).To(BeTrue())
+
+	tokenData, err := svc.LoginMFA(challenge.MFAToken, code, domain.Device{NoPasswordChange: true})
+	Expect(tokenData).To(BeNil())
+	Expect(err).To(MatchError(cons.ErrPasswordChangeNeeded))
+}
+
+func TestService_ConfirmTOTP(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	userID := "1234-1234-1234-1234"
+	mockRepo := port.NewMockUserRepo(mockCtrl)
+	mockRepo.EXPECT().
+		GetUserByID(userID).
+		Return(&domain.User{ID: userID, PhoneNumber: "+6285156305136"}, nil).
+		Times(1)
+
+	var encrypted string
+	mockRepo.EXPECT().
+		SaveTOTPSecret(userID, gomock.Any()).
+		DoAndReturn(func(_ string, secret string) error {
+			encrypted = secret
+			return nil
+		}).
+		Times(1)
+
+	svc := newMFATestService(t, mockRepo)
+	enrollment, err := svc.EnrollTOTP(userID)
+	Expect(err).To(BeNil())
+	Expect(enrollment.URI).To(HavePrefix("otpauth://totp/"))
+	Expect(encrypted).ToNot(Equal(enrollment.Secret))
+
+	mockRepo.EXPECT().
+		GetMFA(userID).
+		DoAndReturn(func(string) (*domain.MFA, error) {
+			return &domain.MFA{UserID: userID, TOTPSecret: encrypted}, nil
+		}).
+		Times(2)
+
+	_, err = svc.ConfirmTOTP(userID, "000000")
+	Expect(err).To(MatchError(cons.ErrInvalidMFACode))
+
+	var stored []string
+	mockRepo.EXPECT().
+		ConfirmTOTP(userID, gomock.Any()).
+		DoAndReturn(func(_ string, codes []string) error {
+			stored = codes
+			return nil
+		}).
+		Times(1)
+
+	mockRepo.EXPECT().
+		UseTOTPStep(userID, gomock.Any()).
+		Return(true, nil).
+		Times(1)
+
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	Expect(err).To(BeNil())
+
+	codes, err := svc.ConfirmTOTP(userID, code)
+	Expect(err).To(BeNil())
+	Expect(codes).To(HaveLen(10))
+	Expect(stored).To(HaveLen(10))
+	Expect(codes[0]).To(MatchRegexp(`^[a-z2-7]{5}-[a-z2-7]{5}$`))
+	Expect(stored[0]).To(Equal(codes[0][:5] + codes[0][6:]))
+}
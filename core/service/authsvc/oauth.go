@@ -0,0 +1,127 @@
+package authsvc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	AuthTime  int64  `json:"auth_time"`
+	Nonce     string `json:"nonce,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+}
+
+// IssueClientToken issues the access token of an authorization code
+// exchanged by an OAuth client. The token is bound to the session the user
+// signed in with, so revoking it revokes the token as well.
+func (svc *Service) IssueClientToken(userID string, sessionID string, clientID string, scope string) (*domain.TokenResponse, error) {
+	err := svc.repo.TouchSession(userID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := svc.repo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// first party clients act as the user, the OpenID scopes only decide
+	// which claims they get
+	scope = strings.TrimSpace(cons.ScopeUser + " " + scope)
+	claims := newTokenClaims(data, sessionID, scope, "", svc.tokenExpDuration)
+	claims.ClientID = clientID
+
+	token, err := svc.signToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.TokenResponse{
+		AccessToken: token,
+		TokenType:   cons.AuthTokenType,
+		ExpiresIn:   int64(svc.tokenExpDuration / time.Second),
+		Scope:       scope,
+	}, nil
+}
+
+// SignIDToken signs the OpenID Connect ID token, it expires with the access
+// token issued along with it.
+func (svc *Service) SignIDToken(token *domain.IDToken) (string, error) {
+	now := time.Now()
+	return svc.signToken(&idTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    token.Issuer,
+			Subject:   token.Subject,
+			Audience:  jwt.ClaimStrings{token.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(svc.tokenExpDuration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		AuthTime:  token.AuthTime.Unix(),
+		Nonce:     token.Nonce,
+		SessionID: token.SessionID,
+	})
+}
+
+// JWKS returns the keys tokens are signed with, so clients can verify ID
+// tokens themselves.
+func (svc *Service) JWKS() ([]domain.JWK, error) {
+	jwk, err := svc.publicJWK()
+	if err != nil {
+		return nil, err
+	}
+
+	return []domain.JWK{*jwk}, nil
+}
+
+// publicJWK returns the public key as JWK, the key id is its RFC 7638
+// thumbprint so it changes along with the key.
+func (svc *Service) publicJWK() (*domain.JWK, error) {
+	key, err := jwt.ParseRSAPublicKeyFromPEM(svc.tokenPubKey)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk := &domain.JWK{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: jwt.SigningMethodRS256.Alg(),
+		Modulus:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+
+	// the members are marshalled in lexicographic order as the thumbprint
+	// requires
+	thumbprint, err := json.Marshal(struct {
+		E   string `json:"e"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+	}{jwk.Exponent, jwk.KeyType, jwk.Modulus})
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(thumbprint)
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return jwk, nil
+}
+
+// hasScope reports whether the space separated scopes include scope.
+func hasScope(scopes string, scope string) bool {
+	for _, s := range strings.Fields(scopes) {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,132 @@
+package authsvc_test
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	oauthUserID    = "1234-1234-1234-1234"
+	oauthSessionID = "5678-5678-5678-5678"
+	oauthClientID  = "9abc-9abc-9abc-9abc"
+)
+
+// parseWithJWKS verifies token with the key JWKS publishes under its kid,
+// like a client of the provider does.
+func parseWithJWKS(jwks []domain.JWK, token string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		for _, key := range jwks {
+			if key.KeyID != token.Header["kid"] {
+				continue
+			}
+
+			n, err := base64.RawURLEncoding.DecodeString(key.Modulus)
+			if err != nil {
+				return nil, err
+			}
+			e, err := base64.RawURLEncoding.DecodeString(key.Exponent)
+			if err != nil {
+				return nil, err
+			}
+
+			return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+		}
+
+		return nil, cons.ErrInvalidToken
+	}, jwt.WithValidMethods([]string{"RS256"}))
+
+	return err
+}
+
+func TestService_IssueClientToken(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	repo := port.NewMockUserRepo(mockCtrl)
+	svc := newIntrospectTestService(t, repo)
+
+	repo.EXPECT().TouchSession(oauthUserID, oauthSessionID).Return(cons.ErrSessionRevoked).Times(1)
+	_, err := svc.IssueClientToken(oauthUserID, oauthSessionID, oauthClientID, "openid")
+	Expect(err).To(MatchError(cons.ErrSessionRevoked))
+
+	repo.EXPECT().TouchSession(oauthUserID, oauthSessionID).Return(nil).Times(4)
+	repo.EXPECT().
+		GetUserByID(oauthUserID).
+		Return(&domain.User{ID: oauthUserID, Role: cons.RoleUser}, nil).
+		Times(1)
+
+	res, err := svc.IssueClientToken(oauthUserID, oauthSessionID, oauthClientID, "openid profile")
+	Expect(err).To(BeNil())
+	Expect(res.TokenType).To(Equal(cons.AuthTokenType))
+	Expect(res.Scope).To(Equal("user openid profile"))
+	Expect(res.ExpiresIn).To(Equal(int64(ExpDuration / time.Second)))
+
+	// the token works on the user endpoints and for the OpenID scopes
+	claims, err := svc.VerifyAuthHeader("Bearer "+res.AccessToken, cons.ScopeUser)
+	Expect(err).To(BeNil())
+	Expect(claims.UserID).To(Equal(oauthUserID))
+	Expect(claims.SessionID).To(Equal(oauthSessionID))
+
+	_, err = svc.VerifyAuthHeader("Bearer "+res.AccessToken, cons.ScopeOpenID)
+	Expect(err).To(BeNil())
+
+	introspection, err := svc.Introspect(res.AccessToken)
+	Expect(err).To(BeNil())
+	Expect(introspection.Active).To(BeTrue())
+	Expect(introspection.ClientID).To(Equal(oauthClientID))
+
+	jwks, err := svc.JWKS()
+	Expect(err).To(BeNil())
+	Expect(jwks).To(HaveLen(1))
+	Expect(parseWithJWKS(jwks, res.AccessToken, &jwt.RegisteredClaims{})).To(Succeed())
+}
+
+func TestService_SignIDToken(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	svc := newIntrospectTestService(t, port.NewMockUserRepo(mockCtrl))
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	token, err := svc.SignIDToken(&domain.IDToken{
+		Issuer:    "https://id.example.com/api/v1",
+		Subject:   oauthUserID,
+		Audience:  oauthClientID,
+		Nonce:     "n-0S6_WzA2Mj",
+		SessionID: oauthSessionID,
+		AuthTime:  authTime,
+	})
+	Expect(err).To(BeNil())
+
+	jwks, err := svc.JWKS()
+	Expect(err).To(BeNil())
+	Expect(jwks[0].KeyType).To(Equal("RSA"))
+	Expect(jwks[0].Use).To(Equal("sig"))
+	Expect(jwks[0].Algorithm).To(Equal("RS256"))
+	Expect(jwks[0].KeyID).To(HaveLen(43))
+
+	claims := jwt.MapClaims{}
+	Expect(parseWithJWKS(jwks, token, claims)).To(Succeed())
+	Expect(claims["iss"]).To(Equal("https://id.example.com/api/v1"))
+	Expect(claims["sub"]).To(Equal(oauthUserID))
+	Expect(claims["aud"]).To(ConsistOf(oauthClientID))
+	Expect(claims["nonce"]).To(Equal("n-0S6_WzA2Mj"))
+	Expect(claims["sid"]).To(Equal(oauthSessionID))
+	Expect(claims["auth_time"]).To(BeNumerically("==", authTime.Unix()))
+
+	// an ID token is no access token
+	_, err = svc.VerifyAuthHeader("Bearer "+token, cons.ScopeUser)
+	Expect(err).To(MatchError(cons.ErrInvalidToken))
+}
@@ -0,0 +1,85 @@
+package authsvc
+
+import (
+	"context"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+// startSession counts and records the successful login of device and issues
+// an access token bound to the new session, joining the transaction of repo
+// if any.
+func (svc *Service) startSession(repo port.UserRepo, data *domain.User, scope string, expDuration time.Duration, device domain.Device) (*domain.AuthData, error) {
+	var res *domain.AuthData
+	err := repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		err := repo.IncrementLoginCount(data.ID)
+		if err != nil {
+			return err
+		}
+
+		expiresAt := time.Now().Add(expDuration)
+		session, err := repo.CreateSession(&domain.Session{
+			UserID:    data.ID,
+			UserAgent: device.UserAgent,
+			IPAddress: device.IPAddress,
+			ExpiresAt: &expiresAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		token, err := svc.generateAccessToken(data, session.ID, scope, "", expDuration)
+		if err != nil {
+			return err
+		}
+
+		err = repo.CreateLoginEvent(&domain.LoginEvent{
+			UserID:      data.ID,
+			PhoneNumber: data.PhoneNumber,
+			Success:     true,
+			UserAgent:   device.UserAgent,
+			IPAddress:   device.IPAddress,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = repo.CreateOutboxEvent(domain.UserLoggedIn{
+			UserID:    data.ID,
+			SessionID: session.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		res = &domain.AuthData{
+			ID:                     data.ID,
+			AccessToken:            token,
+			Scope:                  scope,
+			PasswordChangeRequired: scope == cons.ScopePasswordChange,
+			SessionID:              session.ID,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// ListSessions returns the sessions of the user that are neither revoked
+// nor expired, most recently used first.
+func (svc *Service) ListSessions(userID string) ([]domain.Session, error) {
+	return svc.repo.ListSessions(userID)
+}
+
+// RevokeSession signs out a single session, its tokens are rejected from
+// then on.
+func (svc *Service) RevokeSession(userID string, sessionID string) error {
+	return svc.repo.RevokeSession(userID, sessionID)
+}
@@ -0,0 +1,86 @@
+package authsvc_test
+
+import (
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/authsvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+func TestService_SessionRevocation(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	userID := "1234-1234-1234-1234"
+	sessionID := "5678-5678-5678-5678"
+	device := domain.Device{UserAgent: "Mozilla/5.0", IPAddress: "203.0.113.7"}
+
+	mockRepo := port.NewMockUserRepo(mockCtrl)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().
+		Login("+6285156305136", "Passw0rd!").
+		Return(&domain.User{ID: userID}, nil).
+		Times(1)
+	mockRepo.EXPECT().
+		IncrementLoginCount(gomock.Any()).
+		Return(nil).
+		Times(1)
+	mockRepo.EXPECT().
+		CreateSession(gomock.Any()).
+		DoAndReturn(func(data *domain.Session) (*domain.Session, error) {
+			Expect(data.UserID).To(Equal(userID))
+			Expect(data.UserAgent).To(Equal(device.UserAgent))
+			Expect(data.IPAddress).To(Equal(device.IPAddress))
+			Expect(data.ExpiresAt).ToNot(BeNil())
+
+			return &domain.Session{ID: sessionID, UserID: userID}, nil
+		}).
+		Times(1)
+	mockRepo.EXPECT().
+		CreateLoginEvent(gomock.Any()).
+		Return(nil).
+		Times(1)
+	mockRepo.EXPECT().
+		CreateOutboxEvent(domain.UserLoggedIn{
+			UserID:    userID,
+			SessionID: sessionID,
+		}).
+		Return(nil).
+		Times(1)
+
+	opts := authsvc.ServiceOpts{
+		PrvKeyPath:       PrivateKeyPath,
+		PubKeyPath:       PublicKeyPath,
+		TokenExpDuration: ExpDuration,
+		PhoneParser:      testPhoneParser,
+	}
+
+	svc, err := authsvc.New(opts, mockRepo)
+	Expect(err).To(BeNil())
+
+	tokenData, err := svc.Login(&domain.User{PhoneNumber: "+6285156305136", Password: "Passw0rd!"}, device)
+	Expect(err).To(BeNil())
+	authHeader := cons.AuthTokenType + " " + tokenData.AccessToken
+
+	gomock.InOrder(
+		mockRepo.EXPECT().
+			TouchSession(userID, sessionID).
+			Return(nil),
+		mockRepo.EXPECT().
+			TouchSession(userID, sessionID).
+			Return(cons.ErrSessionRevoked),
+	)
+
+	claims, err := svc.VerifyAuthHeader(authHeader, cons.ScopeUser)
+	Expect(err).To(BeNil())
+	Expect(claims.SessionID).To(Equal(sessionID))
+
+	claims, err = svc.VerifyAuthHeader(authHeader, cons.ScopeUser)
+	Expect(claims).To(BeNil())
+	Expect(err).To(MatchError(cons.ErrSessionRevoked))
+}
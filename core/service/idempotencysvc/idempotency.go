@@ -0,0 +1,64 @@
+package idempotencysvc
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.IdempotencyService = (*Service)(nil)
+
+type Service struct {
+	repo port.IdempotencyRepo
+	ttl  time.Duration
+}
+
+type ServiceOpts struct {
+	// TTL is how long a response is replayed for retries of the same key
+	TTL time.Duration
+}
+
+func New(opts ServiceOpts, repo port.IdempotencyRepo) *Service {
+	return &Service{
+		repo: repo,
+		ttl:  opts.TTL,
+	}
+}
+
+func (svc *Service) Begin(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	rec.ExpiresAt = time.Now().Add(svc.ttl)
+	existing, err := svc.repo.ReserveIdempotencyKey(rec)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		return nil, nil
+	}
+
+	if existing.Fingerprint != rec.Fingerprint {
+		return nil, cons.ErrIdempotencyKeyReused
+	}
+
+	if existing.StatusCode == 0 {
+		return nil, cons.ErrIdempotencyInProgress
+	}
+
+	return existing, nil
+}
+
+func (svc *Service) Complete(rec *domain.IdempotencyRecord) error {
+	return svc.repo.CompleteIdempotencyKey(rec)
+}
+
+// Release gives up a reservation without storing a response, e.g. when the
+// request failed on the server side and is worth retrying.
+func (svc *Service) Release(rec *domain.IdempotencyRecord) error {
+	return svc.repo.DeleteIdempotencyKey(rec)
+}
+
+func (svc *Service) Prune() (int64, error) {
+	return svc.repo.DeleteExpiredIdempotencyKeys(time.Now())
+}
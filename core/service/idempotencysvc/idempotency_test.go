@@ -0,0 +1,108 @@
+package idempotencysvc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/idempotencysvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+type testcaseBegin struct {
+	name          string
+	mockFunc      func(repo *port.MockIdempotencyRepo)
+	assertionFunc func(replay *domain.IdempotencyRecord, err error)
+}
+
+func TestService_Begin(t *testing.T) {
+	testcases := []testcaseBegin{
+		{
+			name: "reserved new key",
+			mockFunc: func(repo *port.MockIdempotencyRepo) {
+				repo.EXPECT().
+					ReserveIdempotencyKey(gomock.Any()).
+					DoAndReturn(func(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+						Expect(rec.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+						return nil, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(replay *domain.IdempotencyRecord, err error) {
+				Expect(err).To(BeNil())
+				Expect(replay).To(BeNil())
+			},
+		},
+		{
+			name: "replay completed request",
+			mockFunc: func(repo *port.MockIdempotencyRepo) {
+				repo.EXPECT().
+					ReserveIdempotencyKey(gomock.Any()).
+					Return(&domain.IdempotencyRecord{Key: "key-1", Fingerprint: "abc", StatusCode: 201, Body: []byte(`{}`)}, nil).
+					Times(1)
+			},
+			assertionFunc: func(replay *domain.IdempotencyRecord, err error) {
+				Expect(err).To(BeNil())
+				Expect(replay.StatusCode).To(Equal(201))
+				Expect(replay.Body).To(Equal([]byte(`{}`)))
+			},
+		},
+		{
+			name: "failed key reused with different request",
+			mockFunc: func(repo *port.MockIdempotencyRepo) {
+				repo.EXPECT().
+					ReserveIdempotencyKey(gomock.Any()).
+					Return(&domain.IdempotencyRecord{Key: "key-1", Fingerprint: "def", StatusCode: 201}, nil).
+					Times(1)
+			},
+			assertionFunc: func(replay *domain.IdempotencyRecord, err error) {
+				Expect(replay).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrIdempotencyKeyReused))
+			},
+		},
+		{
+			name: "failed first request in progress",
+			mockFunc: func(repo *port.MockIdempotencyRepo) {
+				repo.EXPECT().
+					ReserveIdempotencyKey(gomock.Any()).
+					Return(&domain.IdempotencyRecord{Key: "key-1", Fingerprint: "abc"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(replay *domain.IdempotencyRecord, err error) {
+				Expect(replay).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrIdempotencyInProgress))
+			},
+		},
+		{
+			name: "failed reserve key",
+			mockFunc: func(repo *port.MockIdempotencyRepo) {
+				repo.EXPECT().
+					ReserveIdempotencyKey(gomock.Any()).
+					Return(nil, errors.New("error occurred")).
+					Times(1)
+			},
+			assertionFunc: func(replay *domain.IdempotencyRecord, err error) {
+				Expect(replay).To(BeNil())
+				Expect(err).To(HaveOccurred())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockIdempotencyRepo(mockCtrl)
+			tc.mockFunc(mockRepo)
+
+			svc := idempotencysvc.New(idempotencysvc.ServiceOpts{TTL: time.Hour}, mockRepo)
+			tc.assertionFunc(svc.Begin(&domain.IdempotencyRecord{Key: "key-1", Fingerprint: "abc"}))
+		})
+	}
+}
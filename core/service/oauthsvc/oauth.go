@@ -0,0 +1,413 @@
+package oauthsvc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.OAuthService = (*Service)(nil)
+
+const (
+	responseTypeCode       = "code"
+	grantTypeAuthorization = "authorization_code"
+	codeChallengeS256      = "S256"
+
+	// tokenSize is the number of random bytes of codes and client secrets
+	tokenSize = 32
+
+	// a PKCE code verifier is 43 to 128 characters, see RFC 7636
+	minVerifierLength = 43
+	maxVerifierLength = 128
+)
+
+// supportedScopes are the scopes clients can request, in the order they are
+// granted.
+var supportedScopes = []string{cons.ScopeOpenID, cons.ScopeProfile, cons.ScopePhone}
+
+type Service struct {
+	repo    port.OAuthRepo
+	authSvc port.AuthService
+	userSvc port.UserService
+	issuer  string
+	codeTTL time.Duration
+}
+
+type ServiceOpts struct {
+	// Issuer is the public base url of the API, the OAuth endpoints and
+	// the discovery document are served below it
+	Issuer string
+	// CodeTTL is how long an authorization code can be exchanged
+	CodeTTL time.Duration
+}
+
+func New(opts ServiceOpts, repo port.OAuthRepo, authSvc port.AuthService, userSvc port.UserService) *Service {
+	codeTTL := opts.CodeTTL
+	if codeTTL <= 0 {
+		codeTTL = time.Minute
+	}
+
+	return &Service{
+		repo:    repo,
+		authSvc: authSvc,
+		userSvc: userSvc,
+		issuer:  strings.TrimSuffix(opts.Issuer, "/"),
+		codeTTL: codeTTL,
+	}
+}
+
+func (svc *Service) RegisterClient(data *domain.OAuthClient) (*domain.OAuthClient, error) {
+	name := strings.TrimSpace(data.Name)
+	if name == "" || len(data.RedirectURIs) == 0 {
+		return nil, cons.ErrInvalidOAuthClient
+	}
+
+	for _, uri := range data.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, err
+		}
+	}
+
+	client := &domain.OAuthClient{
+		Name:         name,
+		RedirectURIs: data.RedirectURIs,
+		Confidential: data.Confidential,
+	}
+
+	secret := ""
+	if data.Confidential {
+		var err error
+		secret, err = generateToken()
+		if err != nil {
+			return nil, err
+		}
+
+		client.Secret = hashToken(secret)
+	}
+
+	res, err := svc.repo.CreateOAuthClient(client)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Secret = secret
+	return res, nil
+}
+
+func (svc *Service) ListClients() ([]domain.OAuthClient, error) {
+	clients, err := svc.repo.ListOAuthClients()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range clients {
+		clients[i].Secret = ""
+	}
+
+	return clients, nil
+}
+
+func (svc *Service) DeleteClient(id string) error {
+	return svc.repo.DeleteOAuthClient(id)
+}
+
+// ValidateAuthorization checks the client and redirect uri first, until
+// they are known to be valid errors must not be sent to the redirect uri.
+func (svc *Service) ValidateAuthorization(req *domain.AuthorizationRequest) (*domain.OAuthClient, error) {
+	client, err := svc.repo.GetOAuthClient(req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, cons.ErrInvalidRedirectURI
+	}
+
+	if req.ResponseType != responseTypeCode {
+		return nil, fmt.Errorf("%w: response_type must be code", cons.ErrInvalidOAuthRequest)
+	}
+
+	// PKCE is required from every client, the plain method would send the
+	// verifier along with the authorization request
+	if req.CodeChallengeMethod != codeChallengeS256 {
+		return nil, fmt.Errorf("%w: code_challenge_method must be S256", cons.ErrInvalidOAuthRequest)
+	}
+
+	if len(req.CodeChallenge) < minVerifierLength || len(req.CodeChallenge) > maxVerifierLength {
+		return nil, fmt.Errorf("%w: code_challenge is required", cons.ErrInvalidOAuthRequest)
+	}
+
+	if _, err = parseScope(req.Scope); err != nil {
+		return nil, err
+	}
+
+	client.Secret = ""
+	return client, nil
+}
+
+// Authorize issues the code once the user signed in, auth has to carry a
+// session so the tokens the code is exchanged for can be revoked with it.
+func (svc *Service) Authorize(req *domain.AuthorizationRequest, auth *domain.AuthData) (string, error) {
+	client, err := svc.ValidateAuthorization(req)
+	if err != nil {
+		return "", err
+	}
+
+	if auth.ID == "" || auth.SessionID == "" {
+		return "", cons.ErrInvalidToken
+	}
+
+	scopes, err := parseScope(req.Scope)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := generateToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = svc.repo.CreateOAuthCode(&domain.OAuthCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        auth.ID,
+		SessionID:     auth.SessionID,
+		RedirectURI:   req.RedirectURI,
+		Scope:         strings.Join(scopes, " "),
+		Nonce:         req.Nonce,
+		CodeChallenge: req.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(svc.codeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// Exchange redeems an authorization code for an access token, and an ID
+// token when the openid scope was granted.
+func (svc *Service) Exchange(req *domain.TokenRequest) (*domain.TokenResponse, error) {
+	if req.GrantType != grantTypeAuthorization {
+		return nil, cons.ErrUnsupportedGrantType
+	}
+
+	client, err := svc.authenticateClient(req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, fmt.Errorf("%w: code and code_verifier are required", cons.ErrInvalidOAuthRequest)
+	}
+
+	code, err := svc.repo.ConsumeOAuthCode(hashToken(req.Code))
+	if err != nil {
+		return nil, err
+	}
+
+	if code.ClientID != client.ID || code.RedirectURI != req.RedirectURI || !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, cons.ErrInvalidGrant
+	}
+
+	res, err := svc.authSvc.IssueClientToken(code.UserID, code.SessionID, client.ID, code.Scope)
+	if err != nil {
+		// the user signed out before the code was exchanged
+		if errors.Is(err, cons.ErrSessionRevoked) {
+			return nil, cons.ErrInvalidGrant
+		}
+
+		return nil, err
+	}
+
+	if !contains(strings.Fields(code.Scope), cons.ScopeOpenID) {
+		return res, nil
+	}
+
+	res.IDToken, err = svc.authSvc.SignIDToken(&domain.IDToken{
+		Issuer:    svc.issuer,
+		Subject:   code.UserID,
+		Audience:  client.ID,
+		Nonce:     code.Nonce,
+		SessionID: code.SessionID,
+		AuthTime:  code.AuthTime,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// UserInfo returns the claims of the user the token belongs to, limited to
+// the scopes granted to the client.
+func (svc *Service) UserInfo(claims *domain.TokenClaims) (*domain.UserInfo, error) {
+	scopes := strings.Fields(claims.Scope)
+	if !contains(scopes, cons.ScopeOpenID) {
+		return nil, cons.ErrTokenScope
+	}
+
+	user, err := svc.userSvc.Get(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.UserInfo{Subject: user.ID}
+	if contains(scopes, cons.ScopeProfile) {
+		res.Name = user.FullName
+	}
+	if contains(scopes, cons.ScopePhone) {
+		res.PhoneNumber = user.PhoneNumber
+	}
+
+	return res, nil
+}
+
+func (svc *Service) Discovery() *domain.OIDCDiscovery {
+	return &domain.OIDCDiscovery{
+		Issuer:                            svc.issuer,
+		AuthorizationEndpoint:             svc.issuer + "/oauth/authorize",
+		TokenEndpoint:                     svc.issuer + "/oauth/token",
+		UserinfoEndpoint:                  svc.issuer + "/oauth/userinfo",
+		JWKSURI:                           svc.issuer + "/oauth/jwks",
+		IntrospectionEndpoint:             svc.issuer + "/oauth/introspect",
+		ScopesSupported:                   supportedScopes,
+		ResponseTypesSupported:            []string{responseTypeCode},
+		GrantTypesSupported:               []string{grantTypeAuthorization},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "sid", "name", "phone_number"},
+	}
+}
+
+// PruneCodes deletes the expired authorization codes, used or not.
+func (svc *Service) PruneCodes() (int64, error) {
+	return svc.repo.DeleteOAuthCodesExpiredBefore(time.Now())
+}
+
+// authenticateClient checks the secret of confidential clients, public
+// clients are identified by their id alone and rely on PKCE.
+func (svc *Service) authenticateClient(clientID string, clientSecret string) (*domain.OAuthClient, error) {
+	client, err := svc.repo.GetOAuthClient(clientID)
+	if err != nil {
+		if errors.Is(err, cons.ErrOAuthClientNotFound) {
+			return nil, cons.ErrInvalidClient
+		}
+
+		return nil, err
+	}
+
+	if client.Confidential {
+		want, err := hex.DecodeString(client.Secret)
+		if err != nil {
+			return nil, err
+		}
+
+		got := sha256.Sum256([]byte(clientSecret))
+		if subtle.ConstantTimeCompare(want, got[:]) != 1 {
+			return nil, cons.ErrInvalidClient
+		}
+	}
+
+	return client, nil
+}
+
+// parseScope returns the requested scopes without duplicates, an unknown
+// scope fails the whole request.
+func parseScope(scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	for _, s := range requested {
+		if !contains(supportedScopes, s) {
+			return nil, fmt.Errorf("%w: %s", cons.ErrInvalidScope, s)
+		}
+	}
+
+	res := []string{}
+	for _, s := range supportedScopes {
+		if contains(requested, s) {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+// validateRedirectURI accepts https urls, http on the loopback interface
+// and the private-use schemes of native apps, see RFC 8252.
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return cons.ErrInvalidRedirectURI
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return cons.ErrInvalidRedirectURI
+		}
+	case "http":
+		ip := net.ParseIP(u.Hostname())
+		if u.Hostname() != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return cons.ErrInvalidRedirectURI
+		}
+	default:
+		// private-use schemes are reverse domain names
+		if !strings.Contains(u.Scheme, ".") {
+			return cons.ErrInvalidRedirectURI
+		}
+	}
+
+	return nil
+}
+
+func verifyCodeChallenge(verifier string, challenge string) bool {
+	if len(verifier) < minVerifierLength || len(verifier) > maxVerifierLength {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	want := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(want), []byte(challenge)) == 1
+}
+
+func generateToken() (string, error) {
+	b := make([]byte, tokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how codes and client secrets are stored, they are random
+// enough that a plain SHA-256 can not be reversed.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,502 @@
+package oauthsvc_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/oauthsvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	clientID    = "8f1d2a8e-6a52-4d8e-a7a4-1f3a5c2b9e01"
+	userID      = "3b0f5f3e-2b9a-4c51-8f0e-6f1e2d3c4b5a"
+	sessionID   = "c5e1b7a2-9d04-4e3f-b1a6-7d2c8e9f0a1b"
+	redirectURI = "https://app.example.com/callback"
+	issuer      = "https://id.example.com/api/v1"
+
+	// codeVerifier and codeChallenge are the example of RFC 7636 appendix B
+	codeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	codeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	clientSecret = "s3cr3t"
+)
+
+type mocks struct {
+	repo    *port.MockOAuthRepo
+	authSvc *port.MockAuthService
+	userSvc *port.MockUserService
+}
+
+func newService(t *testing.T) (*oauthsvc.Service, mocks) {
+	mockCtrl := gomock.NewController(t)
+	t.Cleanup(mockCtrl.Finish)
+
+	m := mocks{
+		repo:    port.NewMockOAuthRepo(mockCtrl),
+		authSvc: port.NewMockAuthService(mockCtrl),
+		userSvc: port.NewMockUserService(mockCtrl),
+	}
+
+	svc := oauthsvc.New(oauthsvc.ServiceOpts{Issuer: issuer + "/", CodeTTL: time.Minute}, m.repo, m.authSvc, m.userSvc)
+	return svc, m
+}
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func testClient(confidential bool) *domain.OAuthClient {
+	client := &domain.OAuthClient{
+		ID:           clientID,
+		Name:         "web",
+		RedirectURIs: []string{redirectURI},
+		Confidential: confidential,
+	}
+	if confidential {
+		client.Secret = hash(clientSecret)
+	}
+
+	return client
+}
+
+func testAuthorizationRequest() *domain.AuthorizationRequest {
+	return &domain.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            clientID,
+		RedirectURI:         redirectURI,
+		Scope:               "openid profile",
+		State:               "xyz",
+		Nonce:               "n-0S6_WzA2Mj",
+		CodeChallenge:       codeChallenge,
+		CodeChallengeMethod: "S256",
+	}
+}
+
+type testcaseRegister struct {
+	name          string
+	data          *domain.OAuthClient
+	mockFunc      func(m mocks)
+	assertionFunc func(res *domain.OAuthClient, err error)
+}
+
+func TestService_RegisterClient(t *testing.T) {
+	testcases := []testcaseRegister{
+		{
+			name: "failed no name",
+			data: &domain.OAuthClient{RedirectURIs: []string{redirectURI}},
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidOAuthClient))
+			},
+		},
+		{
+			name: "failed http redirect uri",
+			data: &domain.OAuthClient{Name: "web", RedirectURIs: []string{"http://app.example.com/callback"}},
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidRedirectURI))
+			},
+		},
+		{
+			name: "failed redirect uri with fragment",
+			data: &domain.OAuthClient{Name: "web", RedirectURIs: []string{redirectURI + "#done"}},
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidRedirectURI))
+			},
+		},
+		{
+			name: "success public client with loopback and private-use redirect",
+			data: &domain.OAuthClient{
+				Name:         "mobile",
+				RedirectURIs: []string{"http://127.0.0.1:3000/callback", "com.example.app:/callback"},
+			},
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().
+					CreateOAuthClient(gomock.Any()).
+					DoAndReturn(func(data *domain.OAuthClient) (*domain.OAuthClient, error) {
+						Expect(data.Secret).To(BeEmpty())
+						res := *data
+						res.ID = clientID
+						return &res, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.Confidential).To(BeFalse())
+				Expect(res.Secret).To(BeEmpty())
+			},
+		},
+		{
+			name: "success confidential client stores hashed secret",
+			data: &domain.OAuthClient{Name: " web ", RedirectURIs: []string{redirectURI}, Confidential: true},
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().
+					CreateOAuthClient(gomock.Any()).
+					DoAndReturn(func(data *domain.OAuthClient) (*domain.OAuthClient, error) {
+						Expect(data.Name).To(Equal("web"))
+						Expect(data.Secret).To(HaveLen(64))
+						res := *data
+						res.ID = clientID
+						return &res, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.ID).To(Equal(clientID))
+				Expect(res.Secret).To(HaveLen(43))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			svc, m := newService(t)
+			if tc.mockFunc != nil {
+				tc.mockFunc(m)
+			}
+
+			tc.assertionFunc(svc.RegisterClient(tc.data))
+		})
+	}
+}
+
+type testcaseValidate struct {
+	name          string
+	req           func(req *domain.AuthorizationRequest)
+	client        *domain.OAuthClient
+	clientErr     error
+	assertionFunc func(res *domain.OAuthClient, err error)
+}
+
+func TestService_ValidateAuthorization(t *testing.T) {
+	testcases := []testcaseValidate{
+		{
+			name:      "failed unknown client",
+			clientErr: cons.ErrOAuthClientNotFound,
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrOAuthClientNotFound))
+			},
+		},
+		{
+			name:   "failed unregistered redirect uri",
+			req:    func(req *domain.AuthorizationRequest) { req.RedirectURI = "https://evil.example.com/callback" },
+			client: testClient(false),
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidRedirectURI))
+			},
+		},
+		{
+			name:   "failed response type",
+			req:    func(req *domain.AuthorizationRequest) { req.ResponseType = "token" },
+			client: testClient(false),
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidOAuthRequest))
+			},
+		},
+		{
+			name:   "failed plain code challenge",
+			req:    func(req *domain.AuthorizationRequest) { req.CodeChallengeMethod = "plain" },
+			client: testClient(false),
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidOAuthRequest))
+			},
+		},
+		{
+			name:   "failed no code challenge",
+			req:    func(req *domain.AuthorizationRequest) { req.CodeChallenge = "" },
+			client: testClient(false),
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidOAuthRequest))
+			},
+		},
+		{
+			name:   "failed unknown scope",
+			req:    func(req *domain.AuthorizationRequest) { req.Scope = "openid email" },
+			client: testClient(false),
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidScope))
+				Expect(err.Error()).To(ContainSubstring("email"))
+			},
+		},
+		{
+			name:   "success hides secret",
+			client: testClient(true),
+			assertionFunc: func(res *domain.OAuthClient, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.ID).To(Equal(clientID))
+				Expect(res.Secret).To(BeEmpty())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			svc, m := newService(t)
+			m.repo.EXPECT().GetOAuthClient(clientID).Return(tc.client, tc.clientErr).Times(1)
+
+			req := testAuthorizationRequest()
+			if tc.req != nil {
+				tc.req(req)
+			}
+
+			tc.assertionFunc(svc.ValidateAuthorization(req))
+		})
+	}
+}
+
+func TestService_Authorize(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	svc, m := newService(t)
+
+	var stored *domain.OAuthCode
+	m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+	m.repo.EXPECT().
+		CreateOAuthCode(gomock.Any()).
+		DoAndReturn(func(data *domain.OAuthCode) error {
+			stored = data
+			return nil
+		}).
+		Times(1)
+
+	req := testAuthorizationRequest()
+	req.Scope = "profile openid profile"
+	code, err := svc.Authorize(req, &domain.AuthData{ID: userID, SessionID: sessionID})
+	Expect(err).To(BeNil())
+	Expect(code).To(HaveLen(43))
+
+	Expect(stored.CodeHash).To(Equal(hash(code)))
+	Expect(stored.ClientID).To(Equal(clientID))
+	Expect(stored.UserID).To(Equal(userID))
+	Expect(stored.SessionID).To(Equal(sessionID))
+	Expect(stored.Scope).To(Equal("openid profile"))
+	Expect(stored.Nonce).To(Equal("n-0S6_WzA2Mj"))
+	Expect(stored.CodeChallenge).To(Equal(codeChallenge))
+	Expect(stored.ExpiresAt).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+}
+
+type testcaseExchange struct {
+	name          string
+	req           func(req *domain.TokenRequest)
+	mockFunc      func(m mocks)
+	assertionFunc func(res *domain.TokenResponse, err error)
+}
+
+func TestService_Exchange(t *testing.T) {
+	authTime := time.Now().Add(-time.Minute).Truncate(time.Second)
+	storedCode := func(scope string) *domain.OAuthCode {
+		return &domain.OAuthCode{
+			CodeHash:      hash("the-code"),
+			ClientID:      clientID,
+			UserID:        userID,
+			SessionID:     sessionID,
+			RedirectURI:   redirectURI,
+			Scope:         scope,
+			Nonce:         "n-0S6_WzA2Mj",
+			CodeChallenge: codeChallenge,
+			AuthTime:      authTime,
+		}
+	}
+
+	testcases := []testcaseExchange{
+		{
+			name: "failed grant type",
+			req:  func(req *domain.TokenRequest) { req.GrantType = "password" },
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrUnsupportedGrantType))
+			},
+		},
+		{
+			name: "failed unknown client",
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(nil, cons.ErrOAuthClientNotFound).Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidClient))
+			},
+		},
+		{
+			name: "failed wrong client secret",
+			req:  func(req *domain.TokenRequest) { req.ClientSecret = "wrong" },
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(true), nil).Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidClient))
+			},
+		},
+		{
+			name: "failed used code",
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(nil, cons.ErrInvalidGrant).Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidGrant))
+			},
+		},
+		{
+			name: "failed wrong code verifier",
+			req:  func(req *domain.TokenRequest) { req.CodeVerifier = codeVerifier + "x" },
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(storedCode(""), nil).Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidGrant))
+			},
+		},
+		{
+			name: "failed other redirect uri",
+			req:  func(req *domain.TokenRequest) { req.RedirectURI = "https://app.example.com/other" },
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(storedCode(""), nil).Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidGrant))
+			},
+		},
+		{
+			name: "failed session revoked",
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(storedCode(""), nil).Times(1)
+				m.authSvc.EXPECT().
+					IssueClientToken(userID, sessionID, clientID, "").
+					Return(nil, cons.ErrSessionRevoked).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidGrant))
+			},
+		},
+		{
+			name: "success without openid has no id token",
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(storedCode("phone"), nil).Times(1)
+				m.authSvc.EXPECT().
+					IssueClientToken(userID, sessionID, clientID, "phone").
+					Return(&domain.TokenResponse{AccessToken: "access", Scope: "user phone"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.AccessToken).To(Equal("access"))
+				Expect(res.IDToken).To(BeEmpty())
+			},
+		},
+		{
+			name: "success confidential client with id token",
+			req:  func(req *domain.TokenRequest) { req.ClientSecret = clientSecret },
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(true), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(storedCode("openid profile"), nil).Times(1)
+				m.authSvc.EXPECT().
+					IssueClientToken(userID, sessionID, clientID, "openid profile").
+					Return(&domain.TokenResponse{AccessToken: "access", Scope: "user openid profile"}, nil).
+					Times(1)
+				m.authSvc.EXPECT().
+					SignIDToken(&domain.IDToken{
+						Issuer:    issuer,
+						Subject:   userID,
+						Audience:  clientID,
+						Nonce:     "n-0S6_WzA2Mj",
+						SessionID: sessionID,
+						AuthTime:  authTime,
+					}).
+					Return("id-token", nil).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.IDToken).To(Equal("id-token"))
+			},
+		},
+		{
+			name: "failed sign id token",
+			mockFunc: func(m mocks) {
+				m.repo.EXPECT().GetOAuthClient(clientID).Return(testClient(false), nil).Times(1)
+				m.repo.EXPECT().ConsumeOAuthCode(hash("the-code")).Return(storedCode("openid"), nil).Times(1)
+				m.authSvc.EXPECT().
+					IssueClientToken(userID, sessionID, clientID, "openid").
+					Return(&domain.TokenResponse{AccessToken: "access"}, nil).
+					Times(1)
+				m.authSvc.EXPECT().SignIDToken(gomock.Any()).Return("", errors.New("some error")).Times(1)
+			},
+			assertionFunc: func(res *domain.TokenResponse, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError("some error"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			svc, m := newService(t)
+			if tc.mockFunc != nil {
+				tc.mockFunc(m)
+			}
+
+			req := &domain.TokenRequest{
+				GrantType:    "authorization_code",
+				Code:         "the-code",
+				RedirectURI:  redirectURI,
+				ClientID:     clientID,
+				CodeVerifier: codeVerifier,
+			}
+			if tc.req != nil {
+				tc.req(req)
+			}
+
+			tc.assertionFunc(svc.Exchange(req))
+		})
+	}
+}
+
+func TestService_UserInfo(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	svc, m := newService(t)
+
+	_, err := svc.UserInfo(&domain.TokenClaims{UserID: userID, Scope: cons.ScopeUser})
+	Expect(err).To(MatchError(cons.ErrTokenScope))
+
+	m.userSvc.EXPECT().
+		Get(userID).
+		Return(&domain.User{ID: userID, FullName: "john doe", PhoneNumber: "+62812345677"}, nil).
+		Times(2)
+
+	res, err := svc.UserInfo(&domain.TokenClaims{UserID: userID, Scope: "user openid profile"})
+	Expect(err).To(BeNil())
+	Expect(res).To(Equal(&domain.UserInfo{Subject: userID, Name: "john doe"}))
+
+	res, err = svc.UserInfo(&domain.TokenClaims{UserID: userID, Scope: "user openid phone"})
+	Expect(err).To(BeNil())
+	Expect(res).To(Equal(&domain.UserInfo{Subject: userID, PhoneNumber: "+62812345677"}))
+}
+
+func TestService_Discovery(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	svc, _ := newService(t)
+
+	res := svc.Discovery()
+	Expect(res.Issuer).To(Equal(issuer))
+	Expect(res.AuthorizationEndpoint).To(Equal(issuer + "/oauth/authorize"))
+	Expect(res.JWKSURI).To(Equal(issuer + "/oauth/jwks"))
+	Expect(res.CodeChallengeMethodsSupported).To(Equal([]string{"S256"}))
+}
@@ -0,0 +1,136 @@
+package outboxsvc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.OutboxService = (*Service)(nil)
+
+type Service struct {
+	repo        port.OutboxRepo
+	publisher   port.EventPublisher
+	batchSize   int
+	lease       time.Duration
+	retention   time.Duration
+	maxAttempts int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+}
+
+type ServiceOpts struct {
+	// BatchSize is the number of events claimed per relay
+	BatchSize int
+	// Lease is how long claimed events are kept from other relays, it has
+	// to outlast publishing a whole batch or events are published twice
+	Lease time.Duration
+	// Retention is how long published events are kept
+	Retention time.Duration
+	// MaxAttempts is the number of failed publishes before an event is
+	// dead-lettered, zero retries forever
+	MaxAttempts int
+	// BackoffBase is the wait after the first failed publish, it doubles
+	// with every further one up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+func New(opts ServiceOpts, repo port.OutboxRepo, publisher port.EventPublisher) *Service {
+	return &Service{
+		repo:        repo,
+		publisher:   publisher,
+		batchSize:   opts.BatchSize,
+		lease:       opts.Lease,
+		retention:   opts.Retention,
+		maxAttempts: opts.MaxAttempts,
+		backoffBase: opts.BackoffBase,
+		backoffMax:  opts.BackoffMax,
+	}
+}
+
+// Relay publishes the next batch of events. An event is marked published
+// only after the publisher accepted it, so a crash in between publishes it
+// again. A failed event is retried with exponential backoff until
+// MaxAttempts, after that it is dead-lettered. Once an event fails the later
+// events of the same aggregate are left to their lease, so each aggregate is
+// published in order. The first publish failure is returned after the rest
+// of the batch was relayed.
+func (svc *Service) Relay() (int, error) {
+	events, err := svc.repo.ClaimOutboxEvents(svc.batchSize, svc.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		published int
+		firstErr  error
+		blocked   = map[string]bool{}
+	)
+	for i := range events {
+		e := &events[i]
+		if blocked[e.AggregateID] {
+			continue
+		}
+
+		if err = svc.publisher.Publish(e); err != nil {
+			blocked[e.AggregateID] = true
+			if firstErr == nil {
+				firstErr = fmt.Errorf("publish event %s: %w", e.ID, err)
+			}
+
+			if err = svc.repo.RecordOutboxEventFailure(svc.failure(e, err)); err != nil {
+				return published, err
+			}
+			continue
+		}
+
+		if err = svc.repo.MarkOutboxEventPublished(e.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, firstErr
+}
+
+func (svc *Service) failure(e *domain.Event, err error) domain.OutboxFailure {
+	res := domain.OutboxFailure{
+		EventID: e.ID,
+		Error:   err.Error(),
+	}
+
+	attempts := e.Attempts + 1
+	if svc.maxAttempts > 0 && attempts >= svc.maxAttempts {
+		res.DeadLetter = true
+		return res
+	}
+
+	res.RetryAt = time.Now().Add(svc.backoff(attempts))
+	return res
+}
+
+// backoff is the wait after the given number of failed publishes.
+func (svc *Service) backoff(attempts int) time.Duration {
+	wait := svc.backoffBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= svc.backoffMax {
+			return svc.backoffMax
+		}
+	}
+
+	return wait
+}
+
+// Prune deletes the events published longer than the retention ago, a zero
+// retention keeps them forever.
+func (svc *Service) Prune() (int64, error) {
+	if svc.retention <= 0 {
+		return 0, nil
+	}
+
+	return svc.repo.DeleteOutboxEventsPublishedBefore(time.Now().Add(-svc.retention))
+}
@@ -0,0 +1,193 @@
+package outboxsvc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/outboxsvc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+var testOpts = outboxsvc.ServiceOpts{
+	BatchSize:   10,
+	Lease:       time.Minute,
+	Retention:   24 * time.Hour,
+	MaxAttempts: 3,
+	BackoffBase: time.Second,
+	BackoffMax:  time.Minute,
+}
+
+func testEvents() []domain.Event {
+	return []domain.Event{
+		{ID: "event-1", AggregateID: "user-1"},
+		{ID: "event-2", AggregateID: "user-1"},
+		{ID: "event-3", AggregateID: "user-2"},
+	}
+}
+
+type testcaseRelay struct {
+	name          string
+	mockFunc      func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher)
+	assertionFunc func(published int, err error)
+}
+
+func TestService_Relay(t *testing.T) {
+	testcases := []testcaseRelay{
+		{
+			name: "publish batch in order",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return(testEvents(), nil).Times(1)
+				gomock.InOrder(
+					publisher.EXPECT().Publish(&testEvents()[0]).Return(nil),
+					repo.EXPECT().MarkOutboxEventPublished("event-1").Return(nil),
+					publisher.EXPECT().Publish(&testEvents()[1]).Return(nil),
+					repo.EXPECT().MarkOutboxEventPublished("event-2").Return(nil),
+					publisher.EXPECT().Publish(&testEvents()[2]).Return(nil),
+					repo.EXPECT().MarkOutboxEventPublished("event-3").Return(nil),
+				)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(BeNil())
+				Expect(published).To(Equal(3))
+			},
+		},
+		{
+			name: "nothing to publish",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return([]domain.Event{}, nil).Times(1)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(BeNil())
+				Expect(published).To(Equal(0))
+			},
+		},
+		{
+			name: "hold back later events of a failed aggregate",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return(testEvents(), nil).Times(1)
+				gomock.InOrder(
+					publisher.EXPECT().Publish(&testEvents()[0]).Return(errors.New("broker unavailable")),
+					repo.EXPECT().RecordOutboxEventFailure(gomock.Any()).DoAndReturn(func(failure domain.OutboxFailure) error {
+						Expect(failure.EventID).To(Equal("event-1"))
+						Expect(failure.Error).To(Equal("broker unavailable"))
+						Expect(failure.DeadLetter).To(BeFalse())
+						Expect(failure.RetryAt).To(BeTemporally("~", time.Now().Add(time.Second), time.Second/2))
+						return nil
+					}),
+					publisher.EXPECT().Publish(&testEvents()[2]).Return(nil),
+					repo.EXPECT().MarkOutboxEventPublished("event-3").Return(nil),
+				)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(MatchError(ContainSubstring("broker unavailable")))
+				Expect(published).To(Equal(1))
+			},
+		},
+		{
+			name: "back off exponentially",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				events := []domain.Event{{ID: "event-1", AggregateID: "user-1", Attempts: 1}}
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return(events, nil).Times(1)
+				publisher.EXPECT().Publish(gomock.Any()).Return(errors.New("broker unavailable")).Times(1)
+				repo.EXPECT().RecordOutboxEventFailure(gomock.Any()).DoAndReturn(func(failure domain.OutboxFailure) error {
+					Expect(failure.DeadLetter).To(BeFalse())
+					Expect(failure.RetryAt).To(BeTemporally("~", time.Now().Add(2*time.Second), time.Second/2))
+					return nil
+				}).Times(1)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(HaveOccurred())
+				Expect(published).To(Equal(0))
+			},
+		},
+		{
+			name: "dead-letter after max attempts",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				events := []domain.Event{{ID: "event-1", AggregateID: "user-1", Attempts: 2}}
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return(events, nil).Times(1)
+				publisher.EXPECT().Publish(gomock.Any()).Return(errors.New("broker unavailable")).Times(1)
+				repo.EXPECT().RecordOutboxEventFailure(gomock.Any()).DoAndReturn(func(failure domain.OutboxFailure) error {
+					Expect(failure.EventID).To(Equal("event-1"))
+					Expect(failure.DeadLetter).To(BeTrue())
+					return nil
+				}).Times(1)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(HaveOccurred())
+				Expect(published).To(Equal(0))
+			},
+		},
+		{
+			name: "failed claim events",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return(nil, errors.New("error occurred")).Times(1)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(HaveOccurred())
+				Expect(published).To(Equal(0))
+			},
+		},
+		{
+			name: "failed mark event published",
+			mockFunc: func(repo *port.MockOutboxRepo, publisher *port.MockEventPublisher) {
+				repo.EXPECT().ClaimOutboxEvents(10, time.Minute).Return(testEvents(), nil).Times(1)
+				publisher.EXPECT().Publish(gomock.Any()).Return(nil).Times(1)
+				repo.EXPECT().MarkOutboxEventPublished("event-1").Return(errors.New("error occurred")).Times(1)
+			},
+			assertionFunc: func(published int, err error) {
+				Expect(err).To(HaveOccurred())
+				Expect(published).To(Equal(0))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockOutboxRepo(mockCtrl)
+			mockPublisher := port.NewMockEventPublisher(mockCtrl)
+			tc.mockFunc(mockRepo, mockPublisher)
+
+			svc := outboxsvc.New(testOpts, mockRepo, mockPublisher)
+			tc.assertionFunc(svc.Relay())
+		})
+	}
+}
+
+func TestService_Prune(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRepo := port.NewMockOutboxRepo(mockCtrl)
+	mockRepo.EXPECT().
+		DeleteOutboxEventsPublishedBefore(gomock.Any()).
+		DoAndReturn(func(before time.Time) (int64, error) {
+			Expect(before).To(BeTemporally("~", time.Now().Add(-24*time.Hour), time.Minute))
+			return 5, nil
+		}).
+		Times(1)
+
+	svc := outboxsvc.New(testOpts, mockRepo, port.NewMockEventPublisher(mockCtrl))
+	n, err := svc.Prune()
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(int64(5)))
+}
+
+func TestService_PruneDisabled(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	svc := outboxsvc.New(outboxsvc.ServiceOpts{}, port.NewMockOutboxRepo(mockCtrl), port.NewMockEventPublisher(mockCtrl))
+	n, err := svc.Prune()
+	Expect(err).To(BeNil())
+	Expect(n).To(BeZero())
+}
@@ -0,0 +1,122 @@
+package usersvc
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/lib/phone"
+)
+
+// minPhoneFragment is the shortest phone number fragment worth matching,
+// shorter ones would reject too many unrelated passwords.
+const minPhoneFragment = 6
+
+// validatePassword checks password against every rule of the policy and
+// returns all violated rules at once. user is optional and only used for
+// the personal information rule.
+func validatePassword(policy domain.PasswordPolicy, password string, user *domain.User) error {
+	var errs multiError
+	length := len([]rune(password))
+	if length < policy.MinLength || (policy.MaxLength > 0 && length > policy.MaxLength) || len(password) > cons.MaxBytesPass {
+		errs = append(errs, cons.ErrInvalidPasswordLength)
+	}
+
+	var number, capital, lower, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsNumber(r):
+			number = true
+		case unicode.IsUpper(r):
+			capital = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	if policy.RequireUpper && !capital {
+		errs = append(errs, cons.ErrPasswordNoUpper)
+	}
+
+	if policy.RequireLower && !lower {
+		errs = append(errs, cons.ErrPasswordNoLower)
+	}
+
+	if policy.RequireNumber && !number {
+		errs = append(errs, cons.ErrPasswordNoNumber)
+	}
+
+	if policy.RequireSymbol && !symbol {
+		errs = append(errs, cons.ErrPasswordNoSymbol)
+	}
+
+	if policy.MaxRepeatedChars > 0 && maxRepeatedRun(password) > policy.MaxRepeatedChars {
+		errs = append(errs, cons.ErrPasswordRepeatedChars)
+	}
+
+	if policy.DisallowUserInfo && user != nil {
+		lowerPass := strings.ToLower(password)
+		if containsFullName(lowerPass, user.FullName) {
+			errs = append(errs, cons.ErrPasswordContainsName)
+		}
+
+		if containsPhoneNumber(lowerPass, user.PhoneNumber) {
+			errs = append(errs, cons.ErrPasswordContainsPhone)
+		}
+	}
+
+	return errs.errOrNil()
+}
+
+func maxRepeatedRun(s string) int {
+	var (
+		longest, run int
+		prev         rune
+	)
+
+	for i, r := range []rune(s) {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+
+		if run > longest {
+			longest = run
+		}
+		prev = r
+	}
+
+	return longest
+}
+
+func containsFullName(lowerPass string, fullName string) bool {
+	name := strings.ToLower(strings.TrimSpace(fullName))
+	if name == "" {
+		return false
+	}
+
+	if strings.Contains(lowerPass, strings.ReplaceAll(name, " ", "")) {
+		return true
+	}
+
+	for _, part := range strings.Fields(name) {
+		if len(part) >= cons.MinLengthName && strings.Contains(lowerPass, part) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsPhoneNumber(lowerPass string, phoneNumber string) bool {
+	_, national, ok := phone.Split(phoneNumber)
+	if !ok || len(national) < minPhoneFragment {
+		return false
+	}
+
+	return strings.Contains(lowerPass, national)
+}
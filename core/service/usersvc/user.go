@@ -1,43 +1,83 @@
 package usersvc
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
-	"unicode"
 
 	"github.com/SawitProRecruitment/UserService/cons"
 	"github.com/SawitProRecruitment/UserService/core/domain"
 	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/lib/phone"
 )
 
 var _ port.UserService = (*Service)(nil)
 
+const (
+	defaultAuditPageSize = 20
+	maxAuditPageSize     = 100
+)
+
 type Service struct {
-	repo port.UserRepo
+	repo           port.UserRepo
+	passwordPolicy domain.PasswordPolicy
+	blocklist      port.PasswordBlocklist
+	phoneParser    *phone.Parser
+}
+
+type ServiceOpts struct {
+	PasswordPolicy domain.PasswordPolicy
+	// PhoneParser normalizes phone numbers, phone.Default when nil
+	PhoneParser *phone.Parser
+	// Blocklist is optional, breached password check is skipped when nil
+	Blocklist port.PasswordBlocklist
 }
 
-func New(repo port.UserRepo) *Service {
+func New(opts ServiceOpts, repo port.UserRepo) *Service {
+	phoneParser := opts.PhoneParser
+	if phoneParser == nil {
+		phoneParser = phone.Default()
+	}
+
 	return &Service{
-		repo: repo,
+		repo:           repo,
+		passwordPolicy: opts.PasswordPolicy,
+		blocklist:      opts.Blocklist,
+		phoneParser:    phoneParser,
 	}
 }
 
-func (svc *Service) Register(data *domain.User) (*domain.User, error) {
+func (svc *Service) Register(actor domain.Actor, data *domain.User) (*domain.User, error) {
 	data.FullName = strings.TrimSpace(data.FullName)
 	data.PhoneNumber = strings.TrimSpace(data.PhoneNumber)
 	data.Password = strings.TrimSpace(data.Password)
 
 	if data.FullName == "" || data.PhoneNumber == "" || data.Password == "" {
-		return nil, errors.New("fullname, phone and password required")
+		return nil, fmt.Errorf("%w: fullname, phone and password required", cons.ErrInvalidRequest)
 	}
 
-	err := validateUserData(data)
-	if err != nil {
+	var errs multiError
+	errs = errs.appendErr(validateUserData(svc.passwordPolicy, svc.phoneParser, data))
+	errs = errs.appendErr(svc.checkBlocklist(data.Password))
+	if err := errs.errOrNil(); err != nil {
 		return nil, err
 	}
 
-	newUser, err := svc.repo.CreateUser(data)
+	var newUser *domain.User
+	err := svc.repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		var err error
+		newUser, err = repo.CreateUser(actor, data)
+		if err != nil {
+			return err
+		}
+
+		return repo.CreateOutboxEvent(domain.UserRegistered{
+			UserID:      newUser.ID,
+			FullName:    newUser.FullName,
+			PhoneNumber: newUser.PhoneNumber,
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +87,7 @@ func (svc *Service) Register(data *domain.User) (*domain.User, error) {
 
 func (svc *Service) Get(id string) (*domain.User, error) {
 	if id == "" {
-		return nil, errors.New("user ID required")
+		return nil, fmt.Errorf("%w: user ID required", cons.ErrInvalidRequest)
 	}
 
 	data, err := svc.repo.GetUserByID(id)
@@ -58,16 +98,40 @@ func (svc *Service) Get(id string) (*domain.User, error) {
 	return data, nil
 }
 
-func (svc *Service) Patch(id string, data *domain.User) (*domain.User, error) {
+func (svc *Service) Patch(actor domain.Actor, id string, data *domain.User) (*domain.User, error) {
 	data.FullName = strings.TrimSpace(data.FullName)
 	data.PhoneNumber = strings.TrimSpace(data.PhoneNumber)
 
-	err := validateUserData(data)
+	err := validateUserData(svc.passwordPolicy, svc.phoneParser, data)
 	if err != nil {
 		return nil, err
 	}
 
-	res, err := svc.repo.PatchUserByID(id, data)
+	if data.PhoneNumber == "" {
+		return svc.repo.PatchUserByID(actor, id, data)
+	}
+
+	var res *domain.User
+	err = svc.repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		before, err := repo.GetUserByID(id)
+		if err != nil {
+			return err
+		}
+
+		res, err = repo.PatchUserByID(actor, id, data)
+		if err != nil {
+			return err
+		}
+
+		if res.PhoneNumber == before.PhoneNumber {
+			return nil
+		}
+
+		return repo.CreateOutboxEvent(domain.UserPhoneChanged{
+			UserID:      res.ID,
+			PhoneNumber: res.PhoneNumber,
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -75,85 +139,234 @@ func (svc *Service) Patch(id string, data *domain.User) (*domain.User, error) {
 	return res, nil
 }
 
-func validateUserData(data *domain.User) error {
-	var errWrap error
-	if data.FullName != "" {
-		err := validateFullName(data.FullName)
+func (svc *Service) ChangePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error {
+	oldPassword = strings.TrimSpace(oldPassword)
+	newPassword = strings.TrimSpace(newPassword)
+
+	if id == "" || oldPassword == "" || newPassword == "" {
+		return fmt.Errorf("%w: user ID, old and new password required", cons.ErrInvalidRequest)
+	}
+
+	user, err := svc.repo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	var errs multiError
+	errs = errs.appendErr(validatePassword(svc.passwordPolicy, newPassword, user))
+	errs = errs.appendErr(svc.checkBlocklist(newPassword))
+	if err = errs.errOrNil(); err != nil {
+		return err
+	}
+
+	return svc.repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		// the old password is checked first, the reuse check must not tell
+		// a caller without it which passwords the user had
+		err := repo.CheckPassword(id, oldPassword)
+		if err != nil {
+			return err
+		}
+
+		if svc.passwordPolicy.HistoryDepth > 0 {
+			reused, err := repo.IsPasswordReused(id, newPassword, svc.passwordPolicy.HistoryDepth)
+			if err != nil {
+				return err
+			}
+
+			if reused {
+				return cons.ErrPasswordReused
+			}
+		}
+
+		err = repo.UpdatePassword(actor, id, oldPassword, newPassword)
 		if err != nil {
-			errWrap = err
+			return err
 		}
+
+		// only the passwords the reuse check looks at are kept, the new
+		// password is the first of them
+		keep := svc.passwordPolicy.HistoryDepth - 1
+		if keep < 0 {
+			keep = 0
+		}
+
+		_, err = repo.PrunePasswordHistory(id, keep)
+		if err != nil {
+			return err
+		}
+
+		// sign out every other device, only the session changing the
+		// password is kept
+		_, err = repo.RevokeUserSessions(id, actor.SessionID)
+		return err
+	})
+}
+
+func (svc *Service) ForcePasswordChange(actor domain.Actor, id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: user ID required", cons.ErrInvalidRequest)
 	}
 
-	if data.Password != "" {
-		err := validatePassword(data.Password)
+	return svc.repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		err := repo.SetMustChangePassword(actor, id, true)
 		if err != nil {
-			if errWrap == nil {
-				errWrap = err
-			} else {
-				errWrap = fmt.Errorf("%w; %w", errWrap, err)
-			}
+			return err
 		}
+
+		// existing tokens would keep full access until they expire
+		_, err = repo.RevokeUserSessions(id, "")
+		return err
+	})
+}
+
+// SetRole grants the user role, every session is revoked so tokens signed
+// with the old role stop working.
+func (svc *Service) SetRole(actor domain.Actor, id string, role string) error {
+	if id == "" {
+		return fmt.Errorf("%w: user ID required", cons.ErrInvalidRequest)
 	}
 
-	if data.PhoneNumber != "" {
-		err := validatePhoneNumber(data.PhoneNumber)
+	if role != cons.RoleUser && role != cons.RoleAdmin {
+		return fmt.Errorf("%w: unknown role %q", cons.ErrInvalidRequest, role)
+	}
+
+	return svc.repo.WithTx(context.Background(), func(repo port.UserRepo) error {
+		err := repo.SetUserRole(actor, id, role)
 		if err != nil {
-			if errWrap == nil {
-				errWrap = err
-			} else {
-				errWrap = fmt.Errorf("%w; %w", errWrap, err)
-			}
+			return err
 		}
+
+		_, err = repo.RevokeUserSessions(id, "")
+		return err
+	})
+}
+
+// ListAuditLog returns a page of the audit entries of a user, newest first.
+func (svc *Service) ListAuditLog(filter domain.AuditFilter, page int, pageSize int) (*domain.AuditLog, error) {
+	if filter.UserID == "" {
+		return nil, fmt.Errorf("%w: user ID required", cons.ErrInvalidRequest)
 	}
 
-	if errWrap != nil {
-		return errWrap
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		return nil, cons.ErrInvalidTimeRange
 	}
 
-	return nil
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultAuditPageSize
+	}
+
+	if pageSize > maxAuditPageSize {
+		pageSize = maxAuditPageSize
+	}
+
+	entries, total, err := svc.repo.ListAuditEntries(filter, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.AuditLog{
+		Entries:  entries,
+		Page:     page,
+		PageSize: pageSize,
+		Total:    total,
+	}
+
+	return res, nil
 }
 
-func validateFullName(name string) error {
-	if len(name) < cons.MinLengthName || len(name) > cons.MaxLengthName {
-		return cons.ErrInvalidNameLength
+func (svc *Service) checkBlocklist(password string) error {
+	if svc.blocklist != nil && svc.blocklist.Contains(password) {
+		return cons.ErrPasswordBreached
 	}
 
 	return nil
 }
 
-func validatePassword(password string) error {
-	if len(password) < cons.MinLengthPass || len(password) > cons.MaxLengthPass {
-		return cons.ErrInvalidPasswordLength
+// multiError collects validation errors so each of them can be reported
+// separately through Unwrap.
+type multiError []error
+
+func (m multiError) Error() string {
+	msgs := make([]string, 0, len(m))
+	for _, err := range m {
+		msgs = append(msgs, err.Error())
 	}
 
-	var number, capital, symbol bool
-	for _, r := range password {
-		switch {
-		case unicode.IsNumber(r):
-			number = true
-		case unicode.IsUpper(r):
-			capital = true
-		case unicode.IsPunct(r) || unicode.IsSymbol(r):
-			symbol = true
-		}
+	return strings.Join(msgs, "; ")
+}
+
+func (m multiError) Unwrap() []error {
+	return m
+}
+
+func (m multiError) errOrNil() error {
+	if len(m) == 0 {
+		return nil
 	}
 
-	if !number || !capital || !symbol {
-		return cons.ErrInvalidPasswordFormat
+	return m
+}
+
+// appendErr adds err to m, flattening it when err is itself a multi error.
+func (m multiError) appendErr(err error) multiError {
+	if err == nil {
+		return m
 	}
 
-	return nil
+	if errs, is := err.(interface{ Unwrap() []error }); is {
+		return append(m, errs.Unwrap()...)
+	}
+
+	return append(m, err)
 }
 
-func validatePhoneNumber(phone string) error {
-	if !strings.HasPrefix(phone, cons.PrefixPhoneID) {
-		return cons.ErrInvalidPhonePrefix
+func validateUserData(policy domain.PasswordPolicy, parser *phone.Parser, data *domain.User) error {
+	// normalize phone first so the password rules see the stored format,
+	// its error is still reported last
+	var phoneErr error
+	if data.PhoneNumber != "" {
+		data.PhoneNumber, phoneErr = validatePhoneNumber(parser, data.PhoneNumber)
+	}
+
+	var errs multiError
+	if data.FullName != "" {
+		errs = errs.appendErr(validateFullName(data.FullName))
+	}
+
+	if data.Password != "" {
+		errs = errs.appendErr(validatePassword(policy, data.Password, data))
 	}
 
-	cleanPhone := strings.Replace(phone, cons.PrefixPhoneID, "0", 1)
-	if len(cleanPhone) < cons.MinLengthPhone || len(cleanPhone) > cons.MaxLengthPhone {
-		return cons.ErrInvalidPhoneLength
+	errs = errs.appendErr(phoneErr)
+	return errs.errOrNil()
+}
+
+func validateFullName(name string) error {
+	if len(name) < cons.MinLengthName || len(name) > cons.MaxLengthName {
+		return cons.ErrInvalidNameLength
 	}
 
 	return nil
 }
+
+// validatePhoneNumber returns the E.164 form of the phone number, or the
+// original value along with the validation error.
+func validatePhoneNumber(parser *phone.Parser, phoneNumber string) (string, error) {
+	normalized, err := parser.Normalize(phoneNumber)
+	if err != nil {
+		switch {
+		case errors.Is(err, phone.ErrLength):
+			return phoneNumber, cons.ErrInvalidPhoneLength
+		case errors.Is(err, phone.ErrCountryNotAllowed) || errors.Is(err, phone.ErrUnknownCountry):
+			return phoneNumber, cons.ErrInvalidPhonePrefix
+		default:
+			return phoneNumber, cons.ErrInvalidPhoneFormat
+		}
+	}
+
+	return normalized, nil
+}
@@ -1,19 +1,60 @@
 package usersvc_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/cons"
 	"github.com/SawitProRecruitment/UserService/core/domain"
 	"github.com/SawitProRecruitment/UserService/core/port"
 	"github.com/SawitProRecruitment/UserService/core/service/usersvc"
+	"github.com/SawitProRecruitment/UserService/lib/phone"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gstruct"
 )
 
+var testPhoneParser, _ = phone.New(phone.Options{
+	DefaultCountryCode:  "62",
+	AllowedCountryCodes: []string{"62", "65"},
+})
+
+var testPolicy = domain.PasswordPolicy{
+	MinLength:     6,
+	MaxLength:     64,
+	RequireUpper:  true,
+	RequireNumber: true,
+	RequireSymbol: true,
+	HistoryDepth:  3,
+}
+
+var testActor = domain.Actor{
+	UserID:    "1234-1234-1234-1234",
+	SessionID: "5678-5678-5678-5678",
+	RequestID: "req-1",
+}
+
+func testServiceOpts() usersvc.ServiceOpts {
+	return usersvc.ServiceOpts{
+		PasswordPolicy: testPolicy,
+		PhoneParser:    testPhoneParser,
+	}
+}
+
+// expectTx runs the functions passed to WithTx on repo itself.
+func expectTx(repo *port.MockUserRepo) {
+	repo.EXPECT().
+		WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, fn func(port.UserRepo) error) error {
+			return fn(repo)
+		}).
+		AnyTimes()
+}
+
 type testcaseValidate struct {
 	name string
 	user *domain.User
@@ -78,6 +119,13 @@ func TestValidatePassword(t *testing.T) {
 			},
 			want: cons.ErrInvalidPasswordLength,
 		},
+		{
+			name: "failed more bytes than bcrypt hashes",
+			user: &domain.User{
+				Password: "Pässwörd1!" + strings.Repeat("ü", 35),
+			},
+			want: cons.ErrInvalidPasswordLength,
+		},
 		{
 			name: "failed only lowercase",
 			user: &domain.User{
@@ -145,7 +193,7 @@ func TestValidatePassword(t *testing.T) {
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := usersvc.ValidatePassword(tc.user.Password)
+			got := usersvc.ValidatePassword(testPolicy, tc.user.Password, nil)
 			if !errors.Is(got, tc.want) {
 				t.Errorf("error: expect %s not %s", tc.want, got)
 			}
@@ -153,15 +201,110 @@ func TestValidatePassword(t *testing.T) {
 	}
 }
 
+func TestValidatePasswordPolicy(t *testing.T) {
+	policy := domain.PasswordPolicy{
+		MinLength:        8,
+		MaxLength:        32,
+		RequireUpper:     true,
+		RequireLower:     true,
+		RequireNumber:    true,
+		RequireSymbol:    true,
+		MaxRepeatedChars: 2,
+		DisallowUserInfo: true,
+	}
+	owner := &domain.User{
+		FullName:    "Edison Tantra",
+		PhoneNumber: "+6285156305136",
+	}
+
+	testcases := []struct {
+		name     string
+		password string
+		want     []error
+	}{
+		{
+			name:     "failed missing lowercase",
+			password: "PASSW0RD!",
+			want:     []error{cons.ErrPasswordNoLower},
+		},
+		{
+			name:     "failed too many repeated characters",
+			password: "Passsw0rd!",
+			want:     []error{cons.ErrPasswordRepeatedChars},
+		},
+		{
+			name:     "failed contains part of full name",
+			password: "Edison#2024",
+			want:     []error{cons.ErrPasswordContainsName},
+		},
+		{
+			name:     "failed contains phone number",
+			password: "X!85156305136a",
+			want:     []error{cons.ErrPasswordContainsPhone},
+		},
+		{
+			name:     "failed report every violated rule",
+			password: "aaa",
+			want: []error{
+				cons.ErrInvalidPasswordLength,
+				cons.ErrPasswordNoUpper,
+				cons.ErrPasswordNoNumber,
+				cons.ErrPasswordNoSymbol,
+				cons.ErrPasswordRepeatedChars,
+			},
+		},
+		{
+			name:     "success satisfy all rules",
+			password: "Passw0rd!",
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			got := usersvc.ValidatePassword(policy, tc.password, owner)
+			if len(tc.want) == 0 {
+				Expect(got).To(BeNil())
+				return
+			}
+
+			errs, is := got.(interface{ Unwrap() []error })
+			Expect(is).To(BeTrue())
+			Expect(errs.Unwrap()).To(Equal(tc.want))
+		})
+	}
+}
+
 func TestValidatePhoneNumber(t *testing.T) {
 	testcases := []testcaseValidate{
 		{
-			name: "failed dont have prefix +62",
+			name: "failed country code not allowed",
 			user: &domain.User{
-				PhoneNumber: "085156305136",
+				PhoneNumber: "+14155552671",
 			},
 			want: cons.ErrInvalidPhonePrefix,
 		},
+		{
+			name: "failed contains letters",
+			user: &domain.User{
+				PhoneNumber: "+62851563O5136",
+			},
+			want: cons.ErrInvalidPhoneFormat,
+		},
+		{
+			name: "success national format",
+			user: &domain.User{
+				PhoneNumber: "085156305136",
+			},
+			want: nil,
+		},
+		{
+			name: "success another allowed country",
+			user: &domain.User{
+				PhoneNumber: "+65 9123 4567",
+			},
+			want: nil,
+		},
 		{
 			name: "failed less than min length",
 			user: &domain.User{
@@ -194,7 +337,7 @@ func TestValidatePhoneNumber(t *testing.T) {
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := usersvc.ValidatePhoneNumber(tc.user.PhoneNumber)
+			_, got := usersvc.ValidatePhoneNumber(testPhoneParser, tc.user.PhoneNumber)
 			if !errors.Is(got, tc.want) {
 				t.Errorf("error: expect %s not %s", tc.want, got)
 			}
@@ -229,7 +372,11 @@ func TestValidateUserData(t *testing.T) {
 				PhoneNumber: "+6285156305136",
 				Password:    "Pass",
 			},
-			want: cons.ErrInvalidPasswordLength,
+			want: fmt.Errorf("%w; %w; %w",
+				cons.ErrInvalidPasswordLength,
+				cons.ErrPasswordNoNumber,
+				cons.ErrPasswordNoSymbol,
+			),
 		},
 		{
 			name: "failed invalid name and phone length",
@@ -247,9 +394,11 @@ func TestValidateUserData(t *testing.T) {
 				PhoneNumber: "+6285156305",
 				Password:    "Pass",
 			},
-			want: fmt.Errorf("%w; %w; %w",
+			want: fmt.Errorf("%w; %w; %w; %w; %w",
 				cons.ErrInvalidNameLength,
 				cons.ErrInvalidPasswordLength,
+				cons.ErrPasswordNoNumber,
+				cons.ErrPasswordNoSymbol,
 				cons.ErrInvalidPhoneLength,
 			),
 		},
@@ -266,7 +415,7 @@ func TestValidateUserData(t *testing.T) {
 
 	for _, tc := range testcases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := usersvc.ValidateUserData(tc.user)
+			got := usersvc.ValidateUserData(testPolicy, testPhoneParser, tc.user)
 			if got != nil || tc.want != nil {
 				if got.Error() != tc.want.Error() {
 					t.Errorf("error: expect %s not %s", tc.want, got)
@@ -284,6 +433,7 @@ type testcaseRegister struct {
 	name          string
 	user          *domain.User
 	mockFunc      func(repo *port.MockUserRepo)
+	blocklistFunc func(list *port.MockPasswordBlocklist)
 	assertionFunc func(newUser *domain.User, err error)
 }
 
@@ -337,6 +487,24 @@ func TestService_Register(t *testing.T) {
 				Expect(err).To(HaveOccurred())
 			},
 		},
+		{
+			name: "failed password is breached",
+			user: &domain.User{
+				FullName:    "Edison",
+				Password:    "Password1!",
+				PhoneNumber: "+625156305136",
+			},
+			blocklistFunc: func(list *port.MockPasswordBlocklist) {
+				list.EXPECT().
+					Contains("Password1!").
+					Return(true).
+					Times(1)
+			},
+			assertionFunc: func(newUser *domain.User, err error) {
+				Expect(newUser).To(BeNil())
+				Expect(errors.Is(err, cons.ErrPasswordBreached)).To(BeTrue())
+			},
+		},
 		{
 			name: "failed repo create user",
 			user: &domain.User{
@@ -345,8 +513,9 @@ func TestService_Register(t *testing.T) {
 				PhoneNumber: "+625156305136",
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
 				repo.EXPECT().
-					CreateUser(gomock.Any()).
+					CreateUser(gomock.Any(), gomock.Any()).
 					Return(nil, errors.New("error occurred")).
 					Times(1)
 			},
@@ -355,6 +524,29 @@ func TestService_Register(t *testing.T) {
 				Expect(err).To(HaveOccurred())
 			},
 		},
+		{
+			name: "failed create outbox event",
+			user: &domain.User{
+				FullName:    "Edison",
+				Password:    "Password123@",
+				PhoneNumber: "+625156305136",
+			},
+			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
+				repo.EXPECT().
+					CreateUser(gomock.Any(), gomock.Any()).
+					Return(&domain.User{ID: "1234-1234-1234-1234"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CreateOutboxEvent(gomock.Any()).
+					Return(errors.New("error occurred")).
+					Times(1)
+			},
+			assertionFunc: func(newUser *domain.User, err error) {
+				Expect(newUser).To(BeNil())
+				Expect(err).To(HaveOccurred())
+			},
+		},
 		{
 			name: "success register new user",
 			user: &domain.User{
@@ -363,14 +555,23 @@ func TestService_Register(t *testing.T) {
 				PhoneNumber: "+625156305136",
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
 				repo.EXPECT().
-					CreateUser(gomock.Any()).
+					CreateUser(gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						ID:          "1234-1234-1234-1234",
 						FullName:    "Edison",
 						PhoneNumber: "+625156305136",
 					}, nil).
 					Times(1)
+				repo.EXPECT().
+					CreateOutboxEvent(domain.UserRegistered{
+						UserID:      "1234-1234-1234-1234",
+						FullName:    "Edison",
+						PhoneNumber: "+625156305136",
+					}).
+					Return(nil).
+					Times(1)
 			},
 			assertionFunc: func(newUser *domain.User, err error) {
 				Expect(*newUser).To(
@@ -399,9 +600,19 @@ func TestService_Register(t *testing.T) {
 			if tc.mockFunc != nil {
 				tc.mockFunc(mockRepo)
 			}
-			svc = usersvc.New(mockRepo)
 
-			newUser, err := svc.Register(tc.user)
+			mockBlocklist := port.NewMockPasswordBlocklist(mockCtrl)
+			if tc.blocklistFunc != nil {
+				tc.blocklistFunc(mockBlocklist)
+			} else {
+				mockBlocklist.EXPECT().Contains(gomock.Any()).Return(false).AnyTimes()
+			}
+
+			opts := testServiceOpts()
+			opts.Blocklist = mockBlocklist
+			svc = usersvc.New(opts, mockRepo)
+
+			newUser, err := svc.Register(domain.Actor{}, tc.user)
 			tc.assertionFunc(newUser, err)
 		})
 	}
@@ -461,7 +672,7 @@ func TestService_Get(t *testing.T) {
 			if tc.mockFunc != nil {
 				tc.mockFunc(mockRepo)
 			}
-			svc = usersvc.New(mockRepo)
+			svc = usersvc.New(testServiceOpts(), mockRepo)
 
 			newUser, err := svc.Get(tc.id)
 			tc.assertionFunc(newUser, err)
@@ -492,6 +703,24 @@ func TestService_Patch(t *testing.T) {
 				Expect(err).To(HaveOccurred())
 			},
 		},
+		{
+			name: "failed get user before phone change",
+			id:   "1234-1234-1234-1234",
+			user: &domain.User{
+				PhoneNumber: "+625156305136",
+			},
+			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
+				repo.EXPECT().
+					GetUserByID("1234-1234-1234-1234").
+					Return(nil, cons.ErrUserNotFound).
+					Times(1)
+			},
+			assertionFunc: func(newUser *domain.User, err error) {
+				Expect(newUser).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrUserNotFound))
+			},
+		},
 		{
 			name: "failed patch user",
 			id:   "1234-1234-1234-1234",
@@ -501,8 +730,13 @@ func TestService_Patch(t *testing.T) {
 				PhoneNumber: "+625156305136",
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
 				repo.EXPECT().
-					PatchUserByID(gomock.Any(), gomock.Any()).
+					GetUserByID("1234-1234-1234-1234").
+					Return(&domain.User{PhoneNumber: "+625156305136"}, nil).
+					Times(1)
+				repo.EXPECT().
+					PatchUserByID(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(nil, errors.New("error occurred")).
 					Times(1)
 			},
@@ -518,7 +752,7 @@ func TestService_Patch(t *testing.T) {
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
 				repo.EXPECT().
-					PatchUserByID(gomock.Any(), gomock.Any()).
+					PatchUserByID(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						ID:          "1234-1234-1234-1234",
 						FullName:    "Edison Tantra",
@@ -545,7 +779,7 @@ func TestService_Patch(t *testing.T) {
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
 				repo.EXPECT().
-					PatchUserByID(gomock.Any(), gomock.Any()).
+					PatchUserByID(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						ID:          "1234-1234-1234-1234",
 						FullName:    "Edison",
@@ -571,8 +805,20 @@ func TestService_Patch(t *testing.T) {
 				PhoneNumber: "+62515630513678",
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
+				repo.EXPECT().
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{PhoneNumber: "+625156305136"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CreateOutboxEvent(domain.UserPhoneChanged{
+						UserID:      "1234-1234-1234-1234",
+						PhoneNumber: "+62515630513678",
+					}).
+					Return(nil).
+					Times(1)
 				repo.EXPECT().
-					PatchUserByID(gomock.Any(), gomock.Any()).
+					PatchUserByID(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						ID:          "1234-1234-1234-1234",
 						FullName:    "Edison",
@@ -600,8 +846,13 @@ func TestService_Patch(t *testing.T) {
 				PhoneNumber: "+62515630513678",
 			},
 			mockFunc: func(repo *port.MockUserRepo) {
+				expectTx(repo)
 				repo.EXPECT().
-					PatchUserByID(gomock.Any(), gomock.Any()).
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{PhoneNumber: "+62515630513678"}, nil).
+					Times(1)
+				repo.EXPECT().
+					PatchUserByID(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						ID:          "1234-1234-1234-1234",
 						FullName:    "Edison Tantra",
@@ -638,10 +889,361 @@ func TestService_Patch(t *testing.T) {
 			if tc.mockFunc != nil {
 				tc.mockFunc(mockRepo)
 			}
-			svc = usersvc.New(mockRepo)
+			svc = usersvc.New(testServiceOpts(), mockRepo)
 
-			newUser, err := svc.Patch(tc.id, tc.user)
+			newUser, err := svc.Patch(testActor, tc.id, tc.user)
 			tc.assertionFunc(newUser, err)
 		})
 	}
 }
+
+type testcaseChangePassword struct {
+	name          string
+	id            string
+	oldPassword   string
+	newPassword   string
+	mockFunc      func(repo *port.MockUserRepo)
+	assertionFunc func(err error)
+}
+
+func TestService_ChangePassword(t *testing.T) {
+	testcases := []testcaseChangePassword{
+		{
+			name:        "failed new password empty",
+			id:          "1234-1234-1234-1234",
+			oldPassword: "Password123@",
+			newPassword: " ",
+			assertionFunc: func(err error) {
+				Expect(err).To(HaveOccurred())
+			},
+		},
+		{
+			name:        "failed new password violate policy",
+			id:          "1234-1234-1234-1234",
+			oldPassword: "Password123@",
+			newPassword: "password",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{
+						ID:          "1234-1234-1234-1234",
+						FullName:    "Edison",
+						PhoneNumber: "+625156305136",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(err error) {
+				Expect(errors.Is(err, cons.ErrPasswordNoUpper)).To(BeTrue())
+				Expect(errors.Is(err, cons.ErrPasswordNoNumber)).To(BeTrue())
+				Expect(errors.Is(err, cons.ErrPasswordNoSymbol)).To(BeTrue())
+			},
+		},
+		{
+			name:        "failed new password used recently",
+			id:          "1234-1234-1234-1234",
+			oldPassword: "Password123@",
+			newPassword: "Password12345!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{ID: "1234-1234-1234-1234"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CheckPassword("1234-1234-1234-1234", "Password123@").
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					IsPasswordReused("1234-1234-1234-1234", "Password12345!", 3).
+					Return(true, nil).
+					Times(1)
+			},
+			assertionFunc: func(err error) {
+				Expect(err).To(MatchError(cons.ErrPasswordReused))
+			},
+		},
+		{
+			name:        "failed old password does not match before reuse check",
+			id:          "1234-1234-1234-1234",
+			oldPassword: "Password123@",
+			newPassword: "Password12345!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{ID: "1234-1234-1234-1234"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CheckPassword("1234-1234-1234-1234", "Password123@").
+					Return(cons.ErrPasswordNotMatch).
+					Times(1)
+			},
+			assertionFunc: func(err error) {
+				Expect(err).To(MatchError(cons.ErrPasswordNotMatch))
+			},
+		},
+		{
+			name:        "failed revoke sessions",
+			id:          "1234-1234-1234-1234",
+			oldPassword: "Password123@",
+			newPassword: "Password12345!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{ID: "1234-1234-1234-1234"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CheckPassword("1234-1234-1234-1234", "Password123@").
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					IsPasswordReused("1234-1234-1234-1234", "Password12345!", 3).
+					Return(false, nil).
+					Times(1)
+				repo.EXPECT().
+					UpdatePassword(testActor, "1234-1234-1234-1234", "Password123@", "Password12345!").
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					PrunePasswordHistory("1234-1234-1234-1234", 2).
+					Return(int64(1), nil).
+					Times(1)
+				repo.EXPECT().
+					RevokeUserSessions("1234-1234-1234-1234", "5678-5678-5678-5678").
+					Return(int64(0), errors.New("connection reset")).
+					Times(1)
+			},
+			assertionFunc: func(err error) {
+				Expect(err).To(MatchError("connection reset"))
+			},
+		},
+		{
+			name:        "success change password",
+			id:          "1234-1234-1234-1234",
+			oldPassword: "Password123@",
+			newPassword: "Password12345!",
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					GetUserByID(gomock.Any()).
+					Return(&domain.User{ID: "1234-1234-1234-1234"}, nil).
+					Times(1)
+				repo.EXPECT().
+					CheckPassword("1234-1234-1234-1234", "Password123@").
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					IsPasswordReused("1234-1234-1234-1234", "Password12345!", 3).
+					Return(false, nil).
+					Times(1)
+				repo.EXPECT().
+					UpdatePassword(testActor, "1234-1234-1234-1234", "Password123@", "Password12345!").
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					PrunePasswordHistory("1234-1234-1234-1234", 2).
+					Return(int64(1), nil).
+					Times(1)
+				repo.EXPECT().
+					RevokeUserSessions("1234-1234-1234-1234", "5678-5678-5678-5678").
+					Return(int64(2), nil).
+					Times(1)
+			},
+			assertionFunc: func(err error) {
+				Expect(err).To(BeNil())
+			},
+		},
+	}
+
+	var (
+		mockRepo *port.MockUserRepo
+		svc      *usersvc.Service
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo = port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockRepo)
+			}
+			svc = usersvc.New(testServiceOpts(), mockRepo)
+
+			err := svc.ChangePassword(testActor, tc.id, tc.oldPassword, tc.newPassword)
+			tc.assertionFunc(err)
+		})
+	}
+}
+
+func TestService_ForcePasswordChange(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	admin := domain.Actor{UserID: "abcd-abcd-abcd-abcd", RequestID: "req-2"}
+	mockRepo := port.NewMockUserRepo(mockCtrl)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().
+		SetMustChangePassword(admin, "1234-1234-1234-1234", true).
+		Return(nil).
+		Times(1)
+	mockRepo.EXPECT().
+		RevokeUserSessions("1234-1234-1234-1234", "").
+		Return(int64(1), nil).
+		Times(1)
+
+	svc := usersvc.New(testServiceOpts(), mockRepo)
+	Expect(svc.ForcePasswordChange(admin, "1234-1234-1234-1234")).To(Succeed())
+}
+
+func TestService_SetRole(t *testing.T) {
+	testcases := []struct {
+		name     string
+		id       string
+		role     string
+		mockFunc func(repo *port.MockUserRepo)
+		wantErr  error
+	}{
+		{
+			name:    "failed empty user id",
+			role:    cons.RoleAdmin,
+			wantErr: cons.ErrInvalidRequest,
+		},
+		{
+			name:    "failed unknown role",
+			id:      "1234-1234-1234-1234",
+			role:    "root",
+			wantErr: cons.ErrInvalidRequest,
+		},
+		{
+			name: "failed user not found",
+			id:   "1234-1234-1234-1234",
+			role: cons.RoleAdmin,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					SetUserRole(testActor, "1234-1234-1234-1234", cons.RoleAdmin).
+					Return(cons.ErrUserNotFound).
+					Times(1)
+			},
+			wantErr: cons.ErrUserNotFound,
+		},
+		{
+			name: "success promote and sign out",
+			id:   "1234-1234-1234-1234",
+			role: cons.RoleAdmin,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					SetUserRole(testActor, "1234-1234-1234-1234", cons.RoleAdmin).
+					Return(nil).
+					Times(1)
+				repo.EXPECT().
+					RevokeUserSessions("1234-1234-1234-1234", "").
+					Return(int64(2), nil).
+					Times(1)
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			expectTx(mockRepo)
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockRepo)
+			}
+
+			svc := usersvc.New(testServiceOpts(), mockRepo)
+			err := svc.SetRole(testActor, tc.id, tc.role)
+			if tc.wantErr != nil {
+				Expect(err).To(MatchError(tc.wantErr))
+				return
+			}
+
+			Expect(err).To(BeNil())
+		})
+	}
+}
+
+func TestService_New_DefaultPhoneParser(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	svc := usersvc.New(usersvc.ServiceOpts{PasswordPolicy: testPolicy}, port.NewMockUserRepo(mockCtrl))
+	_, err := svc.Register(testActor, &domain.User{
+		FullName:    "Edison Tantra",
+		PhoneNumber: "+14155552671",
+		Password:    "Passw0rd!",
+	})
+	Expect(err).To(MatchError(cons.ErrInvalidPhonePrefix))
+}
+
+func TestService_ListAuditLog(t *testing.T) {
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	testcases := []struct {
+		name       string
+		filter     domain.AuditFilter
+		page       int
+		pageSize   int
+		mockFunc   func(repo *port.MockUserRepo)
+		wantErr    bool
+		wantPage   int
+		wantLength int
+	}{
+		{
+			name:    "failed empty user id",
+			filter:  domain.AuditFilter{},
+			wantErr: true,
+		},
+		{
+			name:    "failed reversed time range",
+			filter:  domain.AuditFilter{UserID: "1234-1234-1234-1234", From: &to, To: &from},
+			wantErr: true,
+		},
+		{
+			name:     "success second page capped",
+			filter:   domain.AuditFilter{UserID: "1234-1234-1234-1234", From: &from, To: &to},
+			page:     2,
+			pageSize: 1000,
+			mockFunc: func(repo *port.MockUserRepo) {
+				repo.EXPECT().
+					ListAuditEntries(domain.AuditFilter{UserID: "1234-1234-1234-1234", From: &from, To: &to}, 100, 100).
+					Return([]domain.AuditEntry{{ID: "1", Action: cons.AuditActionUpdateProfile}}, 101, nil).
+					Times(1)
+			},
+			wantPage:   2,
+			wantLength: 1,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockUserRepo(mockCtrl)
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockRepo)
+			}
+
+			svc := usersvc.New(testServiceOpts(), mockRepo)
+			res, err := svc.ListAuditLog(tc.filter, tc.page, tc.pageSize)
+			if tc.wantErr {
+				Expect(err).To(HaveOccurred())
+				return
+			}
+
+			Expect(err).To(BeNil())
+			Expect(res.Page).To(Equal(tc.wantPage))
+			Expect(res.PageSize).To(Equal(100))
+			Expect(res.Entries).To(HaveLen(tc.wantLength))
+		})
+	}
+}
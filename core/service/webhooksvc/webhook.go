@@ -0,0 +1,349 @@
+package webhooksvc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/lib/webhooksig"
+)
+
+var _ port.WebhookService = (*Service)(nil)
+
+var _ port.EventPublisher = (*Service)(nil)
+
+const (
+	defaultDeliveryPageSize = 20
+	maxDeliveryPageSize     = 100
+
+	// maxErrorBodySize bounds how much of a failed response is kept in the
+	// delivery log
+	maxErrorBodySize = 512
+)
+
+type Service struct {
+	repo        port.WebhookRepo
+	locker      *locker.Locker
+	client      *http.Client
+	batchSize   int
+	lease       time.Duration
+	maxAttempts int
+	backoffBase time.Duration
+	backoffMax  time.Duration
+}
+
+type ServiceOpts struct {
+	// Locker encrypts the secrets at rest
+	Locker *locker.Locker
+	// Client sends the deliveries, its timeout bounds a single attempt
+	Client *http.Client
+	// BatchSize is the number of deliveries claimed per Deliver
+	BatchSize int
+	// Lease has to outlast sending a whole batch or deliveries are sent
+	// twice
+	Lease time.Duration
+	// MaxAttempts is the number of attempts before a delivery is
+	// dead-lettered
+	MaxAttempts int
+	// BackoffBase is the wait after the first failed attempt, it doubles
+	// with every further attempt up to BackoffMax
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+}
+
+func New(opts ServiceOpts, repo port.WebhookRepo) *Service {
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &Service{
+		repo:        repo,
+		locker:      opts.Locker,
+		client:      client,
+		batchSize:   opts.BatchSize,
+		lease:       opts.Lease,
+		maxAttempts: opts.MaxAttempts,
+		backoffBase: opts.BackoffBase,
+		backoffMax:  opts.BackoffMax,
+	}
+}
+
+func (svc *Service) CreateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	eventTypes, err := validateWebhook(data)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := data.Secret
+	if secret == "" {
+		secret, err = webhooksig.GenerateSecret()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encrypted, err := svc.locker.Encrypt(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := svc.repo.CreateWebhook(&domain.Webhook{
+		URL:        data.URL,
+		EventTypes: eventTypes,
+		Secret:     encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res.Secret = secret
+	return res, nil
+}
+
+func (svc *Service) ListWebhooks() ([]domain.Webhook, error) {
+	webhooks, err := svc.repo.ListWebhooks()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+func (svc *Service) GetWebhook(id string) (*domain.Webhook, error) {
+	res, err := svc.repo.GetWebhook(id)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Secret = ""
+	return res, nil
+}
+
+func (svc *Service) UpdateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	eventTypes, err := validateWebhook(data)
+	if err != nil {
+		return nil, err
+	}
+
+	update := &domain.Webhook{
+		ID:         data.ID,
+		URL:        data.URL,
+		EventTypes: eventTypes,
+	}
+
+	if data.Secret != "" {
+		update.Secret, err = svc.locker.Encrypt(data.Secret)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := svc.repo.UpdateWebhook(update)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Secret = ""
+	return res, nil
+}
+
+func (svc *Service) DeleteWebhook(id string) error {
+	return svc.repo.DeleteWebhook(id)
+}
+
+// ListDeliveries returns a page of deliveries of the webhook, newest first.
+func (svc *Service) ListDeliveries(webhookID string, page int, pageSize int) (*domain.WebhookDeliveryLog, error) {
+	if _, err := svc.repo.GetWebhook(webhookID); err != nil {
+		return nil, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+
+	if pageSize <= 0 {
+		pageSize = defaultDeliveryPageSize
+	}
+
+	if pageSize > maxDeliveryPageSize {
+		pageSize = maxDeliveryPageSize
+	}
+
+	deliveries, total, err := svc.repo.ListWebhookDeliveries(webhookID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &domain.WebhookDeliveryLog{
+		Deliveries: deliveries,
+		Page:       page,
+		PageSize:   pageSize,
+		Total:      total,
+	}
+
+	return res, nil
+}
+
+func (svc *Service) Publish(event *domain.Event) error {
+	_, err := svc.repo.EnqueueWebhookDeliveries(event)
+	return err
+}
+
+// Deliver sends the due deliveries one after another. A failed attempt is
+// retried with exponential backoff until MaxAttempts, after that the
+// delivery is dead-lettered. Only storage failures are returned.
+func (svc *Service) Deliver() (int, error) {
+	deliveries, err := svc.repo.ClaimWebhookDeliveries(svc.batchSize, svc.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	webhooks := map[string]*domain.Webhook{}
+	for i := range deliveries {
+		d := &deliveries[i]
+
+		w, ok := webhooks[d.WebhookID]
+		if !ok {
+			w, err = svc.repo.GetWebhook(d.WebhookID)
+			if errors.Is(err, cons.ErrWebhookNotFound) {
+				// deleted since the claim, its deliveries went with it
+				continue
+			}
+			if err != nil {
+				return i, err
+			}
+
+			w.Secret, err = svc.locker.Decrypt(w.Secret)
+			if err != nil {
+				return i, err
+			}
+			webhooks[d.WebhookID] = w
+		}
+
+		statusCode, sendErr := svc.send(w, d)
+		if err = svc.repo.RecordWebhookAttempt(svc.attempt(d, statusCode, sendErr)); err != nil {
+			return i, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// send posts the delivery to the webhook, any status outside 2xx is a
+// failure.
+func (svc *Service) send(w *domain.Webhook, d *domain.WebhookDelivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(cons.WebhookHeaderID, d.EventID)
+	req.Header.Set(cons.WebhookHeaderEvent, d.EventType)
+	req.Header.Set(cons.WebhookHeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(cons.WebhookHeaderSignature, webhooksig.Sign(w.Secret, timestamp, d.Payload))
+
+	resp, err := svc.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	return resp.StatusCode, nil
+}
+
+func (svc *Service) attempt(d *domain.WebhookDelivery, statusCode int, err error) domain.WebhookAttempt {
+	now := time.Now()
+	res := domain.WebhookAttempt{
+		DeliveryID:    d.ID,
+		Status:        cons.WebhookStatusSucceeded,
+		StatusCode:    statusCode,
+		NextAttemptAt: now,
+	}
+
+	if err == nil {
+		return res
+	}
+
+	res.Error = err.Error()
+	attempts := d.Attempts + 1
+	if attempts >= svc.maxAttempts {
+		res.Status = cons.WebhookStatusDeadLetter
+		return res
+	}
+
+	res.Status = cons.WebhookStatusPending
+	res.NextAttemptAt = now.Add(svc.backoff(attempts))
+	return res
+}
+
+// backoff is the wait after the given number of failed attempts.
+func (svc *Service) backoff(attempts int) time.Duration {
+	wait := svc.backoffBase
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= svc.backoffMax {
+			return svc.backoffMax
+		}
+	}
+
+	return wait
+}
+
+// validateWebhook checks the url and returns the event types without
+// duplicates.
+func validateWebhook(data *domain.Webhook) ([]string, error) {
+	u, err := url.Parse(data.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, cons.ErrInvalidWebhookURL
+	}
+
+	if len(data.EventTypes) == 0 {
+		return nil, cons.ErrInvalidWebhookEvent
+	}
+
+	seen := map[string]bool{}
+	res := []string{}
+	for _, t := range data.EventTypes {
+		if !isEventType(t) {
+			return nil, fmt.Errorf("%w: %s", cons.ErrInvalidWebhookEvent, t)
+		}
+
+		if !seen[t] {
+			seen[t] = true
+			res = append(res, t)
+		}
+	}
+
+	return res, nil
+}
+
+func isEventType(t string) bool {
+	for _, known := range cons.EventTypes {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
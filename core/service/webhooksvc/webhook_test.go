@@ -0,0 +1,405 @@
+package webhooksvc_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/webhooksvc"
+	"github.com/SawitProRecruitment/UserService/lib/locker"
+	"github.com/SawitProRecruitment/UserService/lib/webhooksig"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+)
+
+const (
+	testLockerKey = "t4dNxLLolpX8UpehYb1RwbVLF1xFBNHu"
+	testSecret    = "whsec_test"
+	webhookID     = "2c7a4b5e-0f31-4a8e-9a61-3a0f1f7e6b10"
+)
+
+var testLocker = locker.New(testLockerKey)
+
+func newService(repo port.WebhookRepo) *webhooksvc.Service {
+	return webhooksvc.New(webhooksvc.ServiceOpts{
+		Locker:      testLocker,
+		BatchSize:   10,
+		Lease:       time.Minute,
+		MaxAttempts: 3,
+		BackoffBase: time.Minute,
+		BackoffMax:  time.Hour,
+	}, repo)
+}
+
+func encryptedWebhook(url string) *domain.Webhook {
+	secret, err := testLocker.Encrypt(testSecret)
+	Expect(err).To(BeNil())
+
+	return &domain.Webhook{
+		ID:         webhookID,
+		URL:        url,
+		EventTypes: []string{cons.EventUserRegistered},
+		Secret:     secret,
+	}
+}
+
+func testDelivery(attempts int) domain.WebhookDelivery {
+	return domain.WebhookDelivery{
+		ID:        "delivery-1",
+		WebhookID: webhookID,
+		EventID:   "event-1",
+		EventType: cons.EventUserRegistered,
+		Payload:   []byte(`{"id":"event-1","type":"user.registered"}`),
+		Status:    cons.WebhookStatusPending,
+		Attempts:  attempts,
+	}
+}
+
+type testcaseDeliver struct {
+	name          string
+	attempts      int
+	status        int
+	assertionFunc func(attempt domain.WebhookAttempt)
+}
+
+func TestService_Deliver(t *testing.T) {
+	testcases := []testcaseDeliver{
+		{
+			name:   "success delivery",
+			status: http.StatusNoContent,
+			assertionFunc: func(attempt domain.WebhookAttempt) {
+				Expect(attempt.Status).To(Equal(cons.WebhookStatusSucceeded))
+				Expect(attempt.StatusCode).To(Equal(http.StatusNoContent))
+				Expect(attempt.Error).To(BeEmpty())
+			},
+		},
+		{
+			name:   "retry first failure after backoff base",
+			status: http.StatusServiceUnavailable,
+			assertionFunc: func(attempt domain.WebhookAttempt) {
+				Expect(attempt.Status).To(Equal(cons.WebhookStatusPending))
+				Expect(attempt.StatusCode).To(Equal(http.StatusServiceUnavailable))
+				Expect(attempt.Error).To(ContainSubstring("unexpected status 503: try later"))
+				Expect(attempt.NextAttemptAt).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+			},
+		},
+		{
+			name:     "double backoff on later failures",
+			attempts: 1,
+			status:   http.StatusInternalServerError,
+			assertionFunc: func(attempt domain.WebhookAttempt) {
+				Expect(attempt.Status).To(Equal(cons.WebhookStatusPending))
+				Expect(attempt.NextAttemptAt).To(BeTemporally("~", time.Now().Add(2*time.Minute), time.Second))
+			},
+		},
+		{
+			name:     "dead letter after max attempts",
+			attempts: 2,
+			status:   http.StatusInternalServerError,
+			assertionFunc: func(attempt domain.WebhookAttempt) {
+				Expect(attempt.Status).To(Equal(cons.WebhookStatusDeadLetter))
+				Expect(attempt.StatusCode).To(Equal(http.StatusInternalServerError))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				Expect(err).To(BeNil())
+				Expect(body).To(MatchJSON(testDelivery(0).Payload))
+				Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+				Expect(r.Header.Get(cons.WebhookHeaderID)).To(Equal("event-1"))
+				Expect(r.Header.Get(cons.WebhookHeaderEvent)).To(Equal(cons.EventUserRegistered))
+
+				err = webhooksig.Verify(
+					testSecret,
+					r.Header.Get(cons.WebhookHeaderTimestamp),
+					body,
+					r.Header.Get(cons.WebhookHeaderSignature),
+					time.Minute,
+					time.Now(),
+				)
+				Expect(err).To(BeNil())
+
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte("try later\n"))
+			}))
+			defer receiver.Close()
+
+			mockRepo := port.NewMockWebhookRepo(mockCtrl)
+			mockRepo.EXPECT().
+				ClaimWebhookDeliveries(10, time.Minute).
+				Return([]domain.WebhookDelivery{testDelivery(tc.attempts)}, nil).
+				Times(1)
+			mockRepo.EXPECT().GetWebhook(webhookID).Return(encryptedWebhook(receiver.URL), nil).Times(1)
+			mockRepo.EXPECT().
+				RecordWebhookAttempt(gomock.Any()).
+				DoAndReturn(func(attempt domain.WebhookAttempt) error {
+					Expect(attempt.DeliveryID).To(Equal("delivery-1"))
+					tc.assertionFunc(attempt)
+					return nil
+				}).
+				Times(1)
+
+			n, err := newService(mockRepo).Deliver()
+			Expect(err).To(BeNil())
+			Expect(n).To(Equal(1))
+		})
+	}
+}
+
+func TestService_DeliverUnreachable(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	receiver.Close()
+
+	mockRepo := port.NewMockWebhookRepo(mockCtrl)
+	mockRepo.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Return([]domain.WebhookDelivery{testDelivery(0)}, nil)
+	mockRepo.EXPECT().GetWebhook(webhookID).Return(encryptedWebhook(receiver.URL), nil)
+	mockRepo.EXPECT().
+		RecordWebhookAttempt(gomock.Any()).
+		DoAndReturn(func(attempt domain.WebhookAttempt) error {
+			Expect(attempt.Status).To(Equal(cons.WebhookStatusPending))
+			Expect(attempt.StatusCode).To(BeZero())
+			Expect(attempt.Error).ToNot(BeEmpty())
+			return nil
+		})
+
+	n, err := newService(mockRepo).Deliver()
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(1))
+}
+
+func TestService_DeliverBatch(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	var received atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer receiver.Close()
+
+	first, second, deleted := testDelivery(0), testDelivery(0), testDelivery(0)
+	second.ID = "delivery-2"
+	deleted.ID = "delivery-3"
+	deleted.WebhookID = "deleted-webhook"
+
+	mockRepo := port.NewMockWebhookRepo(mockCtrl)
+	mockRepo.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Return([]domain.WebhookDelivery{first, second, deleted}, nil)
+	// the webhook is read once per batch
+	mockRepo.EXPECT().GetWebhook(webhookID).Return(encryptedWebhook(receiver.URL), nil).Times(1)
+	mockRepo.EXPECT().GetWebhook("deleted-webhook").Return(nil, cons.ErrWebhookNotFound).Times(1)
+	mockRepo.EXPECT().RecordWebhookAttempt(gomock.Any()).Return(nil).Times(2)
+
+	n, err := newService(mockRepo).Deliver()
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(3))
+	Expect(received.Load()).To(Equal(int32(2)))
+}
+
+func TestService_DeliverClaimFailure(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRepo := port.NewMockWebhookRepo(mockCtrl)
+	mockRepo.EXPECT().
+		ClaimWebhookDeliveries(gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("error occurred"))
+
+	_, err := newService(mockRepo).Deliver()
+	Expect(err).To(HaveOccurred())
+}
+
+type testcaseCreate struct {
+	name          string
+	data          *domain.Webhook
+	mockFunc      func(repo *port.MockWebhookRepo)
+	assertionFunc func(res *domain.Webhook, err error)
+}
+
+func TestService_CreateWebhook(t *testing.T) {
+	testcases := []testcaseCreate{
+		{
+			name: "failed relative url",
+			data: &domain.Webhook{URL: "/hooks", EventTypes: []string{cons.EventUserRegistered}},
+			assertionFunc: func(res *domain.Webhook, err error) {
+				Expect(res).To(BeNil())
+				Expect(err).To(MatchError(cons.ErrInvalidWebhookURL))
+			},
+		},
+		{
+			name: "failed unsupported scheme",
+			data: &domain.Webhook{URL: "ftp://partner.example.com", EventTypes: []string{cons.EventUserRegistered}},
+			assertionFunc: func(res *domain.Webhook, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidWebhookURL))
+			},
+		},
+		{
+			name: "failed no event types",
+			data: &domain.Webhook{URL: "https://partner.example.com"},
+			assertionFunc: func(res *domain.Webhook, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidWebhookEvent))
+			},
+		},
+		{
+			name: "failed unknown event type",
+			data: &domain.Webhook{URL: "https://partner.example.com", EventTypes: []string{"user.deleted"}},
+			assertionFunc: func(res *domain.Webhook, err error) {
+				Expect(err).To(MatchError(cons.ErrInvalidWebhookEvent))
+				Expect(err.Error()).To(ContainSubstring("user.deleted"))
+			},
+		},
+		{
+			name: "success generate and encrypt secret",
+			data: &domain.Webhook{
+				URL:        "https://partner.example.com/hooks",
+				EventTypes: []string{cons.EventUserRegistered, cons.EventUserLoggedIn, cons.EventUserRegistered},
+			},
+			mockFunc: func(repo *port.MockWebhookRepo) {
+				repo.EXPECT().
+					CreateWebhook(gomock.Any()).
+					DoAndReturn(func(data *domain.Webhook) (*domain.Webhook, error) {
+						Expect(data.EventTypes).To(Equal([]string{cons.EventUserRegistered, cons.EventUserLoggedIn}))
+
+						secret, err := testLocker.Decrypt(data.Secret)
+						Expect(err).To(BeNil())
+						Expect(secret).To(HaveLen(43))
+
+						res := *data
+						res.ID = webhookID
+						return &res, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.Webhook, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.ID).To(Equal(webhookID))
+				Expect(res.Secret).To(HaveLen(43))
+			},
+		},
+		{
+			name: "success keep given secret",
+			data: &domain.Webhook{
+				URL:        "http://localhost:9000/hooks",
+				EventTypes: []string{cons.EventUserPhoneChanged},
+				Secret:     testSecret,
+			},
+			mockFunc: func(repo *port.MockWebhookRepo) {
+				repo.EXPECT().
+					CreateWebhook(gomock.Any()).
+					DoAndReturn(func(data *domain.Webhook) (*domain.Webhook, error) {
+						Expect(data.Secret).ToNot(Equal(testSecret))
+						res := *data
+						return &res, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(res *domain.Webhook, err error) {
+				Expect(err).To(BeNil())
+				Expect(res.Secret).To(Equal(testSecret))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockRepo := port.NewMockWebhookRepo(mockCtrl)
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockRepo)
+			}
+
+			tc.assertionFunc(newService(mockRepo).CreateWebhook(tc.data))
+		})
+	}
+}
+
+func TestService_HideSecrets(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRepo := port.NewMockWebhookRepo(mockCtrl)
+	mockRepo.EXPECT().ListWebhooks().Return([]domain.Webhook{*encryptedWebhook("https://partner.example.com")}, nil)
+	mockRepo.EXPECT().GetWebhook(webhookID).Return(encryptedWebhook("https://partner.example.com"), nil)
+	mockRepo.EXPECT().
+		UpdateWebhook(gomock.Any()).
+		DoAndReturn(func(data *domain.Webhook) (*domain.Webhook, error) {
+			secret, err := testLocker.Decrypt(data.Secret)
+			Expect(err).To(BeNil())
+			Expect(secret).To(Equal("rotated"))
+			return encryptedWebhook(data.URL), nil
+		})
+
+	svc := newService(mockRepo)
+
+	list, err := svc.ListWebhooks()
+	Expect(err).To(BeNil())
+	Expect(list[0].Secret).To(BeEmpty())
+
+	w, err := svc.GetWebhook(webhookID)
+	Expect(err).To(BeNil())
+	Expect(w.Secret).To(BeEmpty())
+
+	w, err = svc.UpdateWebhook(&domain.Webhook{
+		ID:         webhookID,
+		URL:        "https://partner.example.com/v2",
+		EventTypes: []string{cons.EventUserRegistered},
+		Secret:     "rotated",
+	})
+	Expect(err).To(BeNil())
+	Expect(w.Secret).To(BeEmpty())
+}
+
+func TestService_ListDeliveries(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockRepo := port.NewMockWebhookRepo(mockCtrl)
+	gomock.InOrder(
+		mockRepo.EXPECT().GetWebhook("unknown").Return(nil, cons.ErrWebhookNotFound),
+		mockRepo.EXPECT().GetWebhook(webhookID).Return(encryptedWebhook("https://partner.example.com"), nil),
+		mockRepo.EXPECT().
+			ListWebhookDeliveries(webhookID, 100, 100).
+			Return([]domain.WebhookDelivery{testDelivery(1)}, 101, nil),
+	)
+
+	svc := newService(mockRepo)
+
+	_, err := svc.ListDeliveries("unknown", 1, 20)
+	Expect(err).To(MatchError(cons.ErrWebhookNotFound))
+
+	log, err := svc.ListDeliveries(webhookID, 2, 500)
+	Expect(err).To(BeNil())
+	Expect(log.Page).To(Equal(2))
+	Expect(log.PageSize).To(Equal(100))
+	Expect(log.Total).To(Equal(101))
+	Expect(log.Deliveries).To(HaveLen(1))
+}
@@ -17,11 +17,211 @@ CREATE TABLE users (
 	phone_number VARCHAR (20) UNIQUE NOT NULL,
 	login_count INT DEFAULT 0,
 	password TEXT NOT NULL,
+	password_changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	must_change_password BOOLEAN DEFAULT FALSE,
+	role VARCHAR (20) NOT NULL DEFAULT 'user',
 	is_active BOOLEAN DEFAULT TRUE,
+	version INT NOT NULL DEFAULT 1,
     created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
 	updated_at TIMESTAMP WITH TIME ZONE NULL
 );
 
+CREATE TABLE user_password_history (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX user_password_history_user_id_created_at_idx ON user_password_history (user_id, created_at DESC);
+
+CREATE TABLE user_mfa (
+	user_id uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	totp_secret TEXT NOT NULL,
+	confirmed_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	last_totp_step BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE user_recovery_codes (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
+
+-- mfa_challenges count the codes submitted with an MFA challenge token, id
+-- is the jti of the token and used_at makes sure it is exchanged once
+CREATE TABLE mfa_challenges (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	attempts INT NOT NULL DEFAULT 0,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX mfa_challenges_expires_at_idx ON mfa_challenges (expires_at);
+
+CREATE TABLE user_sessions (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address INET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	revoked_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE login_events (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NULL REFERENCES users (id) ON DELETE CASCADE,
+	phone_number TEXT NOT NULL DEFAULT '',
+	success BOOLEAN NOT NULL,
+	reason VARCHAR (30) NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address INET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX login_events_user_id_created_at_idx ON login_events (user_id, created_at DESC);
+CREATE INDEX login_events_created_at_idx ON login_events (created_at);
+
+-- append-only, rows are written in the same statement as the mutation
+CREATE TABLE user_audit_log (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id),
+	actor_id uuid NOT NULL REFERENCES users (id),
+	action VARCHAR (40) NOT NULL,
+	changes JSONB NOT NULL DEFAULT '[]',
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX user_audit_log_user_id_created_at_idx ON user_audit_log (user_id, created_at DESC);
+
+-- responses replayed for retries carrying the same Idempotency-Key, keys are
+-- scoped to the caller, the signed in user or the request fingerprint of an
+-- anonymous one. status_code is NULL while the first request is in progress
+CREATE TABLE idempotency_keys (
+	caller TEXT NOT NULL,
+	key VARCHAR (255) NOT NULL,
+	fingerprint TEXT NOT NULL,
+	status_code INT NULL,
+	content_type TEXT NOT NULL DEFAULT '',
+	response_body BYTEA NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	PRIMARY KEY (caller, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- outbox holds domain events until the relay publishes them, seq keeps them
+-- in the order they were stored
+CREATE TABLE outbox (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	seq BIGSERIAL NOT NULL,
+	event_type VARCHAR (64) NOT NULL,
+	aggregate_id UUID NOT NULL,
+	payload JSONB NOT NULL,
+	occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMP WITH TIME ZONE NULL,
+	published_at TIMESTAMP WITH TIME ZONE NULL,
+	-- dead_lettered_at is set once an event failed outbox.maxAttempts times,
+	-- it is never published
+	dead_lettered_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (seq) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX outbox_pending_aggregate_idx ON outbox (aggregate_id, seq) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- webhooks are partner endpoints subscribed to domain events, the secret is
+-- encrypted by the service
+CREATE TABLE webhooks (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	url TEXT NOT NULL,
+	event_types TEXT[] NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- webhook_deliveries queue the events sent to each webhook and keep the
+-- outcome as the delivery log, an event is queued once per webhook
+CREATE TABLE webhook_deliveries (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	webhook_id UUID NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event_id UUID NOT NULL,
+	event_type VARCHAR (64) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR (16) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	last_status_code INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	locked_until TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	delivered_at TIMESTAMP WITH TIME ZONE NULL,
+	UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+
+-- oauth_clients are the applications signing users in with the authorization
+-- code flow, the secret of confidential clients is hashed
+CREATE TABLE oauth_clients (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR (60) NOT NULL,
+	redirect_uris TEXT[] NOT NULL,
+	confidential BOOLEAN NOT NULL DEFAULT FALSE,
+	secret TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- oauth_codes are the issued authorization codes, only their hash is stored
+-- and used_at makes sure each is exchanged once
+CREATE TABLE oauth_codes (
+	code_hash TEXT PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	session_id UUID NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL DEFAULT '',
+	nonce TEXT NOT NULL DEFAULT '',
+	code_challenge TEXT NOT NULL,
+	auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX oauth_codes_expires_at_idx ON oauth_codes (expires_at);
+
+-- api_keys authorize batch jobs on behalf of their owner, only the hash of
+-- a key is stored and the prefix identifies it
+CREATE TABLE api_keys (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR (60) NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	prefix TEXT UNIQUE NOT NULL,
+	key_hash TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NULL,
+	last_used_at TIMESTAMP WITH TIME ZONE NULL,
+	revoked_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
 -- function for update updated_at
 CREATE FUNCTION update_updated_at_column() RETURNS trigger
     LANGUAGE plpgsql
@@ -32,12 +232,31 @@ BEGIN
 END;
 $$;
 
+-- function for keeping audit log append-only
+CREATE FUNCTION reject_audit_log_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    RAISE EXCEPTION 'user_audit_log is append-only';
+END;
+$$;
+
 -- create triggers
 CREATE TRIGGER users_updated_at BEFORE
 UPDATE
     ON
     users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
 
+CREATE TRIGGER webhooks_updated_at BEFORE
+UPDATE
+    ON
+    webhooks FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+CREATE TRIGGER user_audit_log_append_only BEFORE
+UPDATE OR DELETE
+    ON
+    user_audit_log FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change();
+
 
 INSERT INTO users (full_name, phone_number, password) VALUES ('john doe', '+62812345677', crypt('test1', gen_salt('bf')));
 INSERT INTO users (full_name, phone_number, password) VALUES ('mamang doe', '+62812345678', crypt('test2', gen_salt('bf')));
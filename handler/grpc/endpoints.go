@@ -0,0 +1,127 @@
+package sawitgrpc
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/generated/userpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (h *Handler) Register(ctx context.Context, req *userpb.RegisterRequest) (*userpb.User, error) {
+	u := &domain.User{
+		FullName:    req.GetFullName(),
+		Password:    req.GetPassword(),
+		PhoneNumber: req.GetPhoneNumber(),
+	}
+
+	data, err := h.userSvc.Register(requestActor(ctx, nil), u)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUser(data), nil
+}
+
+func (h *Handler) Login(ctx context.Context, req *userpb.LoginRequest) (*userpb.LoginResponse, error) {
+	u := &domain.User{
+		PhoneNumber: req.GetPhoneNumber(),
+		Password:    req.GetPassword(),
+	}
+
+	data, err := h.authSvc.Login(u, h.requestDevice(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &userpb.LoginResponse{
+		Id:                     data.ID,
+		AccessToken:            data.AccessToken,
+		Scope:                  data.Scope,
+		PasswordChangeRequired: data.PasswordChangeRequired,
+		MfaRequired:            data.MFARequired,
+		MfaToken:               data.MFAToken,
+	}
+
+	return resp, nil
+}
+
+func (h *Handler) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.User, error) {
+	_, err := h.authorizeUser(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := h.userSvc.Get(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	return toUser(data), nil
+}
+
+func (h *Handler) PatchUser(ctx context.Context, req *userpb.PatchUserRequest) (*userpb.User, error) {
+	claims, err := h.authorizeUser(ctx, req.GetId())
+	if err != nil {
+		return nil, err
+	}
+
+	u := &domain.User{
+		FullName:    req.GetFullName(),
+		PhoneNumber: req.GetPhoneNumber(),
+		Version:     int(req.GetVersion()),
+	}
+	data, err := h.userSvc.Patch(requestActor(ctx, claims), req.GetId(), u)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUser(data), nil
+}
+
+func (h *Handler) VerifyToken(ctx context.Context, req *userpb.VerifyTokenRequest) (*userpb.VerifyTokenResponse, error) {
+	scope := req.GetScope()
+	if scope == "" {
+		scope = cons.ScopeUser
+	}
+
+	claims, err := h.authSvc.VerifyAuthHeader(cons.AuthTokenType+" "+req.GetAccessToken(), scope)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &userpb.VerifyTokenResponse{
+		UserId:    claims.UserID,
+		Role:      claims.Role,
+		Scope:     claims.Scope,
+		SessionId: claims.SessionID,
+	}
+
+	return resp, nil
+}
+
+// authorizeUser verifies the bearer token in the metadata and makes sure it
+// belongs to the user with id.
+func (h *Handler) authorizeUser(ctx context.Context, id string) (*domain.TokenClaims, error) {
+	claims, err := h.authSvc.VerifyAuthHeader(metadataValue(ctx, "authorization"), cons.ScopeUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.UserID != id {
+		return nil, status.Error(codes.PermissionDenied, cons.ErrInvalidAuthorized.Error())
+	}
+
+	return claims, nil
+}
+
+func toUser(data *domain.User) *userpb.User {
+	return &userpb.User{
+		Id:          data.ID,
+		FullName:    data.FullName,
+		PhoneNumber: data.PhoneNumber,
+		Version:     int32(data.Version),
+	}
+}
@@ -0,0 +1,413 @@
+package sawitgrpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/core/service/usersvc"
+	"github.com/SawitProRecruitment/UserService/generated/userpb"
+	sawitgrpc "github.com/SawitProRecruitment/UserService/handler/grpc"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const userID = "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"
+
+type multiError []error
+
+func (m multiError) Error() string   { return fmt.Sprint([]error(m)) }
+func (m multiError) Unwrap() []error { return m }
+
+// newClient serves the handler in process, with the interceptors of the
+// grpc command.
+func newClient(t *testing.T, userSvc port.UserService, authSvc port.AuthService) userpb.UserServiceClient {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	handler := sawitgrpc.NewHandler(logger, userSvc, authSvc, nil)
+
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		handler.UnaryRequestID,
+		handler.UnaryLogging,
+		handler.UnaryError,
+	))
+	userpb.RegisterUserServiceServer(s, handler)
+
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return userpb.NewUserServiceClient(conn)
+}
+
+func TestHandler_Register(t *testing.T) {
+	testcases := []struct {
+		name          string
+		mockFunc      func(userSvc *port.MockUserService)
+		assertionFunc func(resp *userpb.User, header metadata.MD, err error)
+	}{
+		{
+			name: "invalid argument validation",
+			mockFunc: func(userSvc *port.MockUserService) {
+				userSvc.EXPECT().
+					Register(gomock.Any(), gomock.Any()).
+					Return(nil, multiError{cons.ErrInvalidNameLength, cons.ErrPasswordNoSymbol}).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, header metadata.MD, err error) {
+				Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+				Expect(status.Convert(err).Message()).To(ContainSubstring(cons.ErrInvalidNameLength.Error()))
+				Expect(status.Convert(err).Message()).To(ContainSubstring(cons.ErrPasswordNoSymbol.Error()))
+			},
+		},
+		{
+			name: "already exists phone number",
+			mockFunc: func(userSvc *port.MockUserService) {
+				userSvc.EXPECT().
+					Register(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrDataConflict).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, header metadata.MD, err error) {
+				Expect(status.Code(err)).To(Equal(codes.AlreadyExists))
+			},
+		},
+		{
+			name: "success register with request id",
+			mockFunc: func(userSvc *port.MockUserService) {
+				userSvc.EXPECT().
+					Register(domain.Actor{RequestID: "req-from-gateway"}, &domain.User{
+						FullName:    "Edison Tantra",
+						Password:    "Passw@rd123",
+						PhoneNumber: "+6285156305150",
+					}).
+					Return(&domain.User{
+						ID:          userID,
+						FullName:    "Edison Tantra",
+						PhoneNumber: "+6285156305150",
+						Version:     1,
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, header metadata.MD, err error) {
+				Expect(err).To(BeNil())
+				Expect(resp.GetId()).To(Equal(userID))
+				Expect(resp.GetVersion()).To(Equal(int32(1)))
+				Expect(header.Get("x-request-id")).To(Equal([]string{"req-from-gateway"}))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc := port.NewMockUserService(mockCtrl)
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			client := newClient(t, mockUserSvc, mockAuthSvc)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc)
+			}
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "x-request-id", "req-from-gateway")
+			var header metadata.MD
+			resp, err := client.Register(ctx, &userpb.RegisterRequest{
+				FullName:    "Edison Tantra",
+				Password:    "Passw@rd123",
+				PhoneNumber: "+6285156305150",
+			}, grpc.Header(&header))
+			tc.assertionFunc(resp, header, err)
+		})
+	}
+}
+
+// TestHandler_RegisterEmpty runs an empty request through the user service
+// itself, its errors have to keep their code over gRPC.
+func TestHandler_RegisterEmpty(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	userSvc := usersvc.New(usersvc.ServiceOpts{}, port.NewMockUserRepo(mockCtrl))
+	client := newClient(t, userSvc, port.NewMockAuthService(mockCtrl))
+
+	_, err := client.Register(context.Background(), &userpb.RegisterRequest{})
+	Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+	Expect(status.Convert(err).Message()).To(ContainSubstring("required"))
+}
+
+func TestHandler_GetUser(t *testing.T) {
+	testcases := []struct {
+		name          string
+		authHeader    string
+		id            string
+		mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+		assertionFunc func(resp *userpb.User, err error)
+	}{
+		{
+			name: "unauthenticated without token",
+			id:   userID,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("", cons.ScopeUser).
+					Return(nil, cons.ErrInvalidToken).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+			},
+		},
+		{
+			name:       "permission denied other user",
+			authHeader: "Bearer token",
+			id:         "c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: userID}, nil).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			},
+		},
+		{
+			name:       "permission denied password change needed",
+			authHeader: "Bearer token",
+			id:         userID,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(nil, cons.ErrPasswordChangeNeeded).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+				Expect(status.Convert(err).Message()).To(Equal(cons.ErrPasswordChangeNeeded.Error()))
+			},
+		},
+		{
+			name:       "internal error hides its cause",
+			authHeader: "Bearer token",
+			id:         userID,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: userID}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Get(userID).
+					Return(nil, errors.New("dial tcp 10.0.0.5:5432: connection refused")).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(status.Code(err)).To(Equal(codes.Internal))
+				Expect(status.Convert(err).Message()).To(Equal(cons.ErrInternal.Error()))
+			},
+		},
+		{
+			name:       "success get user",
+			authHeader: "Bearer token",
+			id:         userID,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: userID}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Get(userID).
+					Return(&domain.User{ID: userID, FullName: "Edison Tantra", Version: 3}, nil).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(err).To(BeNil())
+				Expect(resp.GetFullName()).To(Equal("Edison Tantra"))
+				Expect(resp.GetVersion()).To(Equal(int32(3)))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc := port.NewMockUserService(mockCtrl)
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			client := newClient(t, mockUserSvc, mockAuthSvc)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			ctx := context.Background()
+			if tc.authHeader != "" {
+				ctx = metadata.AppendToOutgoingContext(ctx, "authorization", tc.authHeader)
+			}
+			resp, err := client.GetUser(ctx, &userpb.GetUserRequest{Id: tc.id})
+			tc.assertionFunc(resp, err)
+		})
+	}
+}
+
+func TestHandler_PatchUser(t *testing.T) {
+	testcases := []struct {
+		name          string
+		mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+		assertionFunc func(resp *userpb.User, err error)
+	}{
+		{
+			name: "failed precondition stale version",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: userID, SessionID: "session-1"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Patch(gomock.Any(), userID, gomock.Any()).
+					Return(nil, cons.ErrVersionMismatch).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+			},
+		},
+		{
+			name: "success patch user",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: userID, SessionID: "session-1"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Patch(gomock.Any(), userID, &domain.User{FullName: "Edison", Version: 2}).
+					DoAndReturn(func(actor domain.Actor, id string, data *domain.User) (*domain.User, error) {
+						Expect(actor.UserID).To(Equal(userID))
+						Expect(actor.SessionID).To(Equal("session-1"))
+						Expect(actor.RequestID).NotTo(BeEmpty())
+
+						return &domain.User{ID: userID, FullName: "Edison", Version: 3}, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.User, err error) {
+				Expect(err).To(BeNil())
+				Expect(resp.GetVersion()).To(Equal(int32(3)))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc := port.NewMockUserService(mockCtrl)
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			client := newClient(t, mockUserSvc, mockAuthSvc)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer token")
+			resp, err := client.PatchUser(ctx, &userpb.PatchUserRequest{Id: userID, FullName: "Edison", Version: 2})
+			tc.assertionFunc(resp, err)
+		})
+	}
+}
+
+func TestHandler_VerifyToken(t *testing.T) {
+	testcases := []struct {
+		name          string
+		req           *userpb.VerifyTokenRequest
+		mockFunc      func(authSvc *port.MockAuthService)
+		assertionFunc func(resp *userpb.VerifyTokenResponse, err error)
+	}{
+		{
+			name: "unauthenticated expired token",
+			req:  &userpb.VerifyTokenRequest{AccessToken: "token"},
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(nil, cons.ErrJWTExpired).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.VerifyTokenResponse, err error) {
+				Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+			},
+		},
+		{
+			name: "success verify token for scope",
+			req:  &userpb.VerifyTokenRequest{AccessToken: "token", Scope: cons.ScopePasswordChange},
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopePasswordChange).
+					Return(&domain.TokenClaims{
+						UserID:    userID,
+						Role:      cons.RoleUser,
+						Scope:     cons.ScopePasswordChange,
+						SessionID: "session-1",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(resp *userpb.VerifyTokenResponse, err error) {
+				Expect(err).To(BeNil())
+				Expect(resp.GetUserId()).To(Equal(userID))
+				Expect(resp.GetScope()).To(Equal(cons.ScopePasswordChange))
+				Expect(resp.GetSessionId()).To(Equal("session-1"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			client := newClient(t, nil, mockAuthSvc)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockAuthSvc)
+			}
+
+			resp, err := client.VerifyToken(context.Background(), tc.req)
+			tc.assertionFunc(resp, err)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package sawitgrpc
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusCodes maps the HTTP status of a cons.Error to its gRPC code, any
+// other status is reported as Internal.
+var statusCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.AlreadyExists,
+	http.StatusPreconditionFailed:  codes.FailedPrecondition,
+	http.StatusUnprocessableEntity: codes.InvalidArgument,
+}
+
+// toStatus converts err to a gRPC status with the code of the cons.Error it
+// is. Validation reports every failed rule at once, they are joined in the
+// message under the code of the first one. Anything else is reported as
+// Internal, without its message.
+func toStatus(err error) *status.Status {
+	errs := []error{err}
+	if multi, is := err.(interface{ Unwrap() []error }); is && len(multi.Unwrap()) > 0 {
+		errs = multi.Unwrap()
+	}
+
+	code := codes.Internal
+	msgs := make([]string, 0, len(errs))
+	for i, err := range errs {
+		var e *cons.Error
+		if !errors.As(err, &e) {
+			return status.New(codes.Internal, cons.ErrInternal.Error())
+		}
+
+		c, ok := statusCodes[e.Status]
+		if !ok {
+			c = codes.Internal
+		}
+		if i == 0 {
+			code = c
+		}
+
+		msgs = append(msgs, err.Error())
+	}
+
+	return status.New(code, strings.Join(msgs, "; "))
+}
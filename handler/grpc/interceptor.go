@@ -0,0 +1,162 @@
+package sawitgrpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxRequestIDLength bounds request ids supplied by callers.
+	maxRequestIDLength = 128
+	metadataRequestID  = "x-request-id"
+)
+
+type requestIDKey struct{}
+
+// UnaryRequestID keeps the x-request-id of the caller or generates one, it
+// is sent back in the response header and recorded in the audit log.
+func (h *Handler) UnaryRequestID(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	requestID := metadataValue(ctx, metadataRequestID)
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		requestID = uuid.NewString()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(metadataRequestID, requestID))
+	return handler(context.WithValue(ctx, requestIDKey{}, requestID), req)
+}
+
+func (h *Handler) UnaryLogging(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	h.makeLogEntry(ctx, info.FullMethod).Info("incoming request")
+	return handler(ctx, req)
+}
+
+// UnaryError converts the errors of the services to a gRPC status, errors
+// that already are one are returned as is. The error itself is logged, the
+// status of an internal one does not tell its cause.
+func (h *Handler) UnaryError(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err == nil {
+		return resp, nil
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		st = toStatus(err)
+	}
+
+	h.makeLogEntry(ctx, info.FullMethod).WithField("code", st.Code().String()).Error(err)
+	return nil, st.Err()
+}
+
+func (h *Handler) makeLogEntry(ctx context.Context, method string) *log.Entry {
+	const timeFormat = "2006-01-02 15:04:05"
+	return h.logger.WithFields(log.Fields{
+		"at":         time.Now().Format(timeFormat),
+		"method":     method,
+		"ip":         peerAddress(ctx),
+		"request_id": requestID(ctx),
+	})
+}
+
+func requestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// requestActor identifies who performs a mutation for the audit log, claims
+// is nil for anonymous requests.
+func requestActor(ctx context.Context, claims *domain.TokenClaims) domain.Actor {
+	actor := domain.Actor{
+		RequestID: requestID(ctx),
+	}
+	if claims != nil {
+		actor.UserID = claims.UserID
+		actor.SessionID = claims.SessionID
+	}
+
+	return actor
+}
+
+// requestDevice describes the client of a login. Its address is the one of
+// the peer, unless the peer is a trusted proxy: then it is the last address
+// of x-forwarded-for not added by a trusted proxy. It is left empty when it
+// is not an ip.
+func (h *Handler) requestDevice(ctx context.Context) domain.Device {
+	ip := net.ParseIP(peerHost(ctx))
+	if h.trustedProxy(ip) {
+		forwarded := strings.Split(metadataValue(ctx, "x-forwarded-for"), ",")
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(forwarded[i]))
+			if hop == nil {
+				break
+			}
+
+			ip = hop
+			if !h.trustedProxy(hop) {
+				break
+			}
+		}
+	}
+
+	device := domain.Device{
+		UserAgent: metadataValue(ctx, "user-agent"),
+	}
+	if ip != nil {
+		device.IPAddress = ip.String()
+	}
+
+	return device
+}
+
+func (h *Handler) trustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	for _, proxy := range h.trustedProxies {
+		if proxy.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// peerHost is the address of the peer without its port.
+func peerHost(ctx context.Context) string {
+	addr := peerAddress(ctx)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+
+	return addr
+}
+
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	return p.Addr.String()
+}
+
+// metadataValue returns the first value of key in the incoming metadata.
+func metadataValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
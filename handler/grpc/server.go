@@ -0,0 +1,37 @@
+package sawitgrpc
+
+import (
+	"net"
+
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/generated/userpb"
+	"github.com/sirupsen/logrus"
+)
+
+var _ userpb.UserServiceServer = (*Handler)(nil)
+
+type Handler struct {
+	userpb.UnimplementedUserServiceServer
+
+	logger  *logrus.Logger
+	userSvc port.UserService
+	authSvc port.AuthService
+
+	// trustedProxies are the peers whose x-forwarded-for is believed
+	trustedProxies []*net.IPNet
+}
+
+func NewHandler(
+	logger *logrus.Logger,
+	userSvc port.UserService,
+	authSvc port.AuthService,
+	trustedProxies []*net.IPNet,
+) *Handler {
+	return &Handler{
+		logger:  logger,
+		userSvc: userSvc,
+		authSvc: authSvc,
+
+		trustedProxies: trustedProxies,
+	}
+}
@@ -0,0 +1,89 @@
+package sawithttp
+
+import (
+	"net/http"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+// AdminCreateApiKey is not open to API keys in api.yml, a leaked key must
+// not be able to mint more keys.
+func (h *Handler) AdminCreateApiKey(ctx echo.Context) error {
+	claims, err := h.authorizeAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	req := generated.APIKeyRequest{}
+	err = ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	res, err := h.apiKeySvc.CreateAPIKey(&domain.APIKey{
+		Name:      req.Name,
+		UserID:    claims.UserID,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusCreated, apiKeyResponse(res))
+}
+
+func (h *Handler) AdminListApiKeys(ctx echo.Context) error {
+	if _, err := h.authorizeAdmin(ctx); err != nil {
+		return err
+	}
+
+	data, err := h.apiKeySvc.ListAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	resp := generated.APIKeyListResponse{
+		ApiKeys: make([]generated.APIKey, 0, len(data)),
+	}
+	for i := range data {
+		resp.ApiKeys = append(resp.ApiKeys, apiKeyResponse(&data[i]))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminRevokeApiKey(ctx echo.Context, id uuid.UUID) error {
+	if _, err := h.authorizeAdmin(ctx); err != nil {
+		return err
+	}
+
+	err := h.apiKeySvc.RevokeAPIKey(id.String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func apiKeyResponse(data *domain.APIKey) generated.APIKey {
+	resp := generated.APIKey{
+		Id:         data.ID,
+		Name:       data.Name,
+		UserId:     data.UserID,
+		Prefix:     data.Prefix,
+		Scopes:     data.Scopes,
+		ExpiresAt:  data.ExpiresAt,
+		LastUsedAt: data.LastUsedAt,
+		RevokedAt:  data.RevokedAt,
+		CreatedAt:  derefTime(data.CreatedAt),
+	}
+	if data.Key != "" {
+		resp.Key = &data.Key
+	}
+
+	return resp
+}
@@ -0,0 +1,143 @@
+package sawithttp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// security schemes of api.yml verified by MiddlewareAuth, both are sent
+	// as a bearer token
+	bearerAuthScheme = "bearerAuth"
+	apiKeyAuthScheme = "apiKeyAuth"
+
+	// oauthTag marks the operations called by OAuth clients, their
+	// authentication errors follow RFC 6750
+	oauthTag = "oauth"
+
+	principalKey = "principal"
+)
+
+// routeSecurity is how MiddlewareAuth authenticates one operation.
+type routeSecurity struct {
+	scope     string
+	apiKey    bool
+	challenge bool
+}
+
+// MiddlewareAuth verifies the bearer token of the operations listing
+// bearerAuth or apiKeyAuth in their security section of spec, once per
+// request, and keeps the claims for Principal. The first scope listed by the
+// requirement is the one the token needs, user when none is listed. API keys
+// are only accepted by operations listing apiKeyAuth. Operations without
+// bearer security, like register and login, are left to their handler.
+func (h *Handler) MiddlewareAuth(spec *openapi3.T, baseURL string) (echo.MiddlewareFunc, error) {
+	routes, err := securityRoutes(spec, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			sec, ok := routes[specRouteKey(c)]
+			if !ok {
+				return next(c)
+			}
+
+			authHeader := c.Request().Header.Get("Authorization")
+			claims, err := h.authSvc.VerifyAuthHeader(authHeader, sec.scope)
+			if err == nil && claims.APIKeyID != "" && !sec.apiKey {
+				err = cons.ErrInvalidAuthorized
+			}
+			if err != nil {
+				if sec.challenge {
+					challenge := `Bearer error="invalid_token"`
+					if errors.Is(err, cons.ErrTokenScope) {
+						challenge = fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, sec.scope)
+					}
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+				}
+
+				return err
+			}
+
+			c.Set(principalKey, claims)
+			return next(c)
+		}
+	}, nil
+}
+
+// Principal returns who the request is authorized as by MiddlewareAuth, it
+// fails closed when the operation was not authenticated.
+func Principal(ctx echo.Context) (*domain.TokenClaims, error) {
+	claims, ok := ctx.Get(principalKey).(*domain.TokenClaims)
+	if !ok {
+		return nil, cons.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// securityRoutes maps the routes of specRoutes with bearer security to it.
+func securityRoutes(spec *openapi3.T, baseURL string) (map[string]routeSecurity, error) {
+	routes := map[string]routeSecurity{}
+	for key, route := range specRoutes(spec, baseURL) {
+		reqs := spec.Security
+		if route.Operation.Security != nil {
+			reqs = *route.Operation.Security
+		}
+
+		sec, ok, err := operationSecurity(reqs, route.Operation.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", route.Method, route.Path, err)
+		}
+
+		if ok {
+			routes[key] = sec
+		}
+	}
+
+	return routes, nil
+}
+
+// operationSecurity reads the bearer requirements of an operation, false
+// when it has none.
+func operationSecurity(reqs openapi3.SecurityRequirements, tags []string) (routeSecurity, bool, error) {
+	sec := routeSecurity{}
+	found := false
+	for _, req := range reqs {
+		for name, scopes := range req {
+			if name != bearerAuthScheme && name != apiKeyAuthScheme {
+				continue
+			}
+
+			scope := cons.ScopeUser
+			if len(scopes) > 0 {
+				scope = scopes[0]
+			}
+
+			if found && scope != sec.scope {
+				return sec, false, fmt.Errorf("bearer requirements need different scopes %q and %q", sec.scope, scope)
+			}
+
+			found = true
+			sec.scope = scope
+			if name == apiKeyAuthScheme {
+				sec.apiKey = true
+			}
+		}
+	}
+
+	for _, tag := range tags {
+		if tag == oauthTag {
+			sec.challenge = true
+		}
+	}
+
+	return sec, found, nil
+}
@@ -0,0 +1,191 @@
+package sawithttp_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/generated"
+	sawithttp "github.com/SawitProRecruitment/UserService/handler/http"
+	"github.com/golang/mock/gomock"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+// withAuth runs next behind MiddlewareAuth as the route of the server, with
+// errors rendered by MiddlewareError.
+func withAuth(handler *sawithttp.Handler, c echo.Context, route string, next echo.HandlerFunc) error {
+	spec, err := generated.GetSwagger()
+	if err != nil {
+		return err
+	}
+
+	auth, err := handler.MiddlewareAuth(spec, "/api/v1")
+	if err != nil {
+		return err
+	}
+
+	c.SetPath(route)
+	return handler.MiddlewareError(auth(next))(c)
+}
+
+// errorBody decodes the ErrorResponse rendered by MiddlewareError.
+func errorBody(recorder *httptest.ResponseRecorder) generated.ErrorResponse {
+	resp := generated.ErrorResponse{}
+	Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+	return resp
+}
+
+type testcaseAuth struct {
+	name          string
+	method        string
+	route         string
+	mockFunc      func(authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error)
+}
+
+func TestHandler_MiddlewareAuth(t *testing.T) {
+	testcases := []testcaseAuth{
+		{
+			name:   "open operation is not authenticated",
+			method: http.MethodPost,
+			route:  "/api/v1/users/login",
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(err).To(BeNil())
+				Expect(called).To(BeTrue())
+			},
+		},
+		{
+			name:   "unknown route is left to the router",
+			method: http.MethodGet,
+			route:  "/api/v1/unknown",
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(err).To(BeNil())
+				Expect(called).To(BeTrue())
+			},
+		},
+		{
+			name:   "verified once with the scope of the operation",
+			method: http.MethodPut,
+			route:  "/api/v1/users/:id/password",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopePasswordChange).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(err).To(BeNil())
+				Expect(called).To(BeTrue())
+				Expect(claims.UserID).To(Equal("9ae8810c-7b28-4c4c-8dbc-ed43be3da208"))
+			},
+		},
+		{
+			name:   "unauthorized invalid token",
+			method: http.MethodGet,
+			route:  "/api/v1/users/:id",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(nil, cons.ErrInvalidToken).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(called).To(BeFalse())
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrInvalidToken.Code))
+			},
+		},
+		{
+			name:   "admin api key accepted where listed",
+			method: http.MethodGet,
+			route:  "/api/v1/admin/webhooks",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208", APIKeyID: "key"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(err).To(BeNil())
+				Expect(claims.APIKeyID).To(Equal("key"))
+			},
+		},
+		{
+			name:   "api key rejected where not listed",
+			method: http.MethodPost,
+			route:  "/api/v1/admin/api-keys",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208", APIKeyID: "key"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(called).To(BeFalse())
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrInvalidAuthorized.Code))
+			},
+		},
+		{
+			name:   "oauth operation challenges the client",
+			method: http.MethodGet,
+			route:  "/api/v1/oauth/userinfo",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader("Bearer token", cons.ScopeOpenID).
+					Return(nil, cons.ErrJWTExpired).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, claims *domain.TokenClaims, called bool, err error) {
+				Expect(called).To(BeFalse())
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal(`Bearer error="invalid_token"`))
+			},
+		},
+	}
+
+	var mockAuthSvc *port.MockAuthService
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(tc.method, "/", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockAuthSvc)
+			}
+
+			var claims *domain.TokenClaims
+			called := false
+			err := withAuth(handler, c, tc.route, func(c echo.Context) error {
+				called = true
+				claims, _ = sawithttp.Principal(c)
+				return nil
+			})
+			tc.assertionFunc(rec, claims, called, err)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package sawithttp
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/labstack/echo/v4"
+)
+
+//go:embed templates/authorize.html
+var templates embed.FS
+
+var authorizeTemplate = template.Must(template.ParseFS(templates, "templates/authorize.html"))
+
+// authorizePage is the data of the login page of the authorization code
+// flow.
+type authorizePage struct {
+	Action      string
+	ClientName  string
+	Request     *domain.AuthorizationRequest
+	PhoneNumber string
+	MFAToken    string
+	Error       string
+	// Fatal errors can not be sent to the redirect uri, the page only shows
+	// them
+	Fatal bool
+}
+
+func (h *Handler) OauthAuthorize(ctx echo.Context, params generated.OauthAuthorizeParams) error {
+	req := &domain.AuthorizationRequest{
+		ResponseType:        derefString(params.ResponseType),
+		ClientID:            derefString(params.ClientId),
+		RedirectURI:         derefString(params.RedirectUri),
+		Scope:               derefString(params.Scope),
+		State:               derefString(params.State),
+		Nonce:               derefString(params.Nonce),
+		CodeChallenge:       derefString(params.CodeChallenge),
+		CodeChallengeMethod: derefString(params.CodeChallengeMethod),
+	}
+
+	client, err := h.oauthSvc.ValidateAuthorization(req)
+	if err != nil {
+		return h.authorizeError(ctx, req, err)
+	}
+
+	return renderAuthorizePage(ctx, http.StatusOK, &authorizePage{
+		ClientName: client.Name,
+		Request:    req,
+	})
+}
+
+// OauthAuthorizeSubmit signs the user in with the login page, the second
+// factor is asked for on the same page when it is enabled.
+func (h *Handler) OauthAuthorizeSubmit(ctx echo.Context) error {
+	req := &domain.AuthorizationRequest{
+		ResponseType:        ctx.FormValue("response_type"),
+		ClientID:            ctx.FormValue("client_id"),
+		RedirectURI:         ctx.FormValue("redirect_uri"),
+		Scope:               ctx.FormValue("scope"),
+		State:               ctx.FormValue("state"),
+		Nonce:               ctx.FormValue("nonce"),
+		CodeChallenge:       ctx.FormValue("code_challenge"),
+		CodeChallengeMethod: ctx.FormValue("code_challenge_method"),
+	}
+
+	client, err := h.oauthSvc.ValidateAuthorization(req)
+	if err != nil {
+		return h.authorizeError(ctx, req, err)
+	}
+
+	page := &authorizePage{
+		ClientName:  client.Name,
+		Request:     req,
+		PhoneNumber: ctx.FormValue("phone_number"),
+	}
+
+	// the password change token is not good for anything else, clients
+	// would get a token they can not use
+	device := requestDevice(ctx)
+	device.NoPasswordChange = true
+
+	var auth *domain.AuthData
+	if mfaToken := ctx.FormValue("mfa_token"); mfaToken != "" {
+		auth, err = h.authSvc.LoginMFA(mfaToken, ctx.FormValue("mfa_code"), device)
+		// a wrong code can be retried, any other failure like an expired
+		// challenge starts over with the password
+		if errors.Is(err, cons.ErrInvalidMFACode) {
+			page.MFAToken = mfaToken
+		}
+	} else {
+		auth, err = h.authSvc.Login(&domain.User{
+			PhoneNumber: page.PhoneNumber,
+			Password:    ctx.FormValue("password"),
+		}, device)
+	}
+	if err != nil {
+		page.Error = h.loginPageError(ctx, err)
+		return renderAuthorizePage(ctx, http.StatusOK, page)
+	}
+
+	if auth.MFARequired {
+		page.MFAToken = auth.MFAToken
+		return renderAuthorizePage(ctx, http.StatusOK, page)
+	}
+
+	code, err := h.oauthSvc.Authorize(req, auth)
+	if err != nil {
+		return h.authorizeError(ctx, req, err)
+	}
+
+	return redirectAuthorization(ctx, req, url.Values{"code": {code}})
+}
+
+// loginPageError is the message shown on the login page for err. Only the
+// messages of cons errors are meant for users, anything else is logged and
+// shown as a generic failure.
+func (h *Handler) loginPageError(ctx echo.Context, err error) string {
+	var e *cons.Error
+	if errors.As(err, &e) && e.Status < http.StatusInternalServerError {
+		return err.Error()
+	}
+
+	h.makeLogEntry(ctx).WithError(err).Error("error login oauth user")
+	return "something went wrong, try again"
+}
+
+// authorizeError reports err to the redirect uri of the client, unless the
+// client or redirect uri itself is invalid. Redirecting then would send the
+// user to an arbitrary site.
+func (h *Handler) authorizeError(ctx echo.Context, req *domain.AuthorizationRequest, err error) error {
+	if errors.Is(err, cons.ErrOAuthClientNotFound) || errors.Is(err, cons.ErrInvalidRedirectURI) {
+		return renderAuthorizePage(ctx, http.StatusBadRequest, &authorizePage{
+			Request: req,
+			Error:   err.Error(),
+			Fatal:   true,
+		})
+	}
+
+	var code, description string
+	switch {
+	case errors.Is(err, cons.ErrInvalidScope):
+		code, description = "invalid_scope", err.Error()
+	case errors.Is(err, cons.ErrInvalidOAuthRequest):
+		code, description = "invalid_request", err.Error()
+	default:
+		h.makeLogEntry(ctx).WithError(err).Error("error authorize oauth client")
+		code, description = "server_error", http.StatusText(http.StatusInternalServerError)
+	}
+
+	return redirectAuthorization(ctx, req, url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+// redirectAuthorization sends the user back to the client with query, the
+// state is always returned as it was sent.
+func redirectAuthorization(ctx echo.Context, req *domain.AuthorizationRequest, query url.Values) error {
+	redirectURI, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		return err
+	}
+
+	q := redirectURI.Query()
+	for k, v := range query {
+		q[k] = v
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	redirectURI.RawQuery = q.Encode()
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.Redirect(http.StatusFound, redirectURI.String())
+}
+
+func renderAuthorizePage(ctx echo.Context, code int, page *authorizePage) error {
+	page.Action = ctx.Request().URL.Path
+
+	buf := &bytes.Buffer{}
+	if err := authorizeTemplate.Execute(buf, page); err != nil {
+		return err
+	}
+
+	// the page takes a password, it must not be cached or framed by
+	// another site
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderCacheControl, "no-store")
+	header.Set(echo.HeaderXFrameOptions, "DENY")
+	header.Set(echo.HeaderContentSecurityPolicy, "frame-ancestors 'none'")
+
+	return ctx.HTMLBlob(code, buf.Bytes())
+}
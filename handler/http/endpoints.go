@@ -1,8 +1,9 @@
 package sawithttp
 
 import (
-	"errors"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/cons"
 	"github.com/SawitProRecruitment/UserService/core/domain"
@@ -16,10 +17,7 @@ func (h *Handler) UserRegister(ctx echo.Context) error {
 	req := generated.UserRegisterRequest{}
 	err := ctx.Bind(&req)
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusBadRequest,
-			err.Error(),
-		)
+		return err
 	}
 
 	//TODO not sure this requirement
@@ -37,12 +35,9 @@ func (h *Handler) UserRegister(ctx echo.Context) error {
 		PhoneNumber: req.PhoneNumber,
 	}
 
-	data, err := h.userSvc.Register(u)
+	data, err := h.userSvc.Register(requestActor(ctx, nil), u)
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusBadRequest,
-			err.Error(),
-		)
+		return err
 	}
 
 	resp := generated.UserRegisterResponse{
@@ -58,10 +53,7 @@ func (h *Handler) UserLogin(ctx echo.Context) error {
 	req := generated.UserLoginRequest{}
 	err := ctx.Bind(&req)
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusBadRequest,
-			err.Error(),
-		)
+		return err
 	}
 
 	//TODO not sure this requirement
@@ -78,46 +70,93 @@ func (h *Handler) UserLogin(ctx echo.Context) error {
 		Password:    req.Password,
 	}
 
-	data, err := h.authSvc.Login(u)
+	data, err := h.authSvc.Login(u, requestDevice(ctx))
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusBadRequest,
-			err.Error(),
-		)
+		return err
 	}
 
+	return ctx.JSON(http.StatusOK, toLoginResponse(data))
+}
+
+func (h *Handler) UserLoginMfa(ctx echo.Context) error {
+	req := generated.UserLoginMfaRequest{}
+	err := ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	data, err := h.authSvc.LoginMFA(req.MfaToken, req.Code, requestDevice(ctx))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, toLoginResponse(data))
+}
+
+// requestDevice describes the client of a login, its address is the one
+// found by the IPExtractor of the server and left empty when it is not an ip.
+func requestDevice(ctx echo.Context) domain.Device {
+	ip := ctx.RealIP()
+	if net.ParseIP(ip) == nil {
+		ip = ""
+	}
+
+	return domain.Device{
+		UserAgent: ctx.Request().UserAgent(),
+		IPAddress: ip,
+	}
+}
+
+// requestActor identifies who performs a mutation for the audit log, claims
+// is nil for anonymous requests.
+func requestActor(ctx echo.Context, claims *domain.TokenClaims) domain.Actor {
+	actor := domain.Actor{
+		RequestID: requestID(ctx),
+	}
+	if claims != nil {
+		actor.UserID = claims.UserID
+		actor.SessionID = claims.SessionID
+	}
+
+	return actor
+}
+
+func toLoginResponse(data *domain.AuthData) generated.UserLoginResponse {
 	resp := generated.UserLoginResponse{
-		Id:          data.ID,
-		AccessToken: data.AccessToken,
+		Id:                     data.ID,
+		Scope:                  data.Scope,
+		PasswordChangeRequired: data.PasswordChangeRequired,
+		MfaRequired:            data.MFARequired,
+	}
+	if data.AccessToken != "" {
+		resp.AccessToken = &data.AccessToken
+	}
+	if data.MFAToken != "" {
+		resp.MfaToken = &data.MFAToken
 	}
 
-	return ctx.JSON(http.StatusOK, resp)
+	return resp
 }
 
-func (h *Handler) UserDetail(ctx echo.Context, id openapi_types.UUID) error {
-	header := ctx.Request().Header
-	authHeader := header.Get("Authorization")
-	claimID, err := h.authSvc.VerifyAuthHeader(authHeader)
+func (h *Handler) UserDetail(ctx echo.Context, id openapi_types.UUID, params generated.UserDetailParams) error {
+	claims, err := Principal(ctx)
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusForbidden,
-			err.Error(),
-		)
+		return err
 	}
 
-	if claimID != id.String() {
-		return echo.NewHTTPError(
-			http.StatusForbidden,
-			cons.ErrInvalidAuthorized.Error(),
-		)
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
 	}
 
 	data, err := h.userSvc.Get(id.String())
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusForbidden,
-			err.Error(),
-		)
+		return err
+	}
+
+	etag := userETag(data.Version)
+	ctx.Response().Header().Set("ETag", etag)
+	if matchIfNoneMatch(params.IfNoneMatch, etag) {
+		return ctx.NoContent(http.StatusNotModified)
 	}
 
 	resp := generated.UserDetailResponse{
@@ -129,57 +168,35 @@ func (h *Handler) UserDetail(ctx echo.Context, id openapi_types.UUID) error {
 	return ctx.JSON(http.StatusOK, resp)
 }
 
-func (h *Handler) UserPatch(ctx echo.Context, id uuid.UUID) error {
-	header := ctx.Request().Header
-	authHeader := header.Get("Authorization")
-	claimID, err := h.authSvc.VerifyAuthHeader(authHeader)
+func (h *Handler) UserPatch(ctx echo.Context, id uuid.UUID, params generated.UserPatchParams) error {
+	claims, err := Principal(ctx)
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusForbidden,
-			err.Error(),
-		)
+		return err
 	}
 
-	if claimID != id.String() {
-		return echo.NewHTTPError(
-			http.StatusForbidden,
-			cons.ErrInvalidAuthorized.Error(),
-		)
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
 	}
 
 	req := generated.UserPatchRequest{}
 	err = ctx.Bind(&req)
 	if err != nil {
-		return echo.NewHTTPError(
-			http.StatusBadRequest,
-			err.Error(),
-		)
+		return err
+	}
+
+	version, err := parseIfMatch(params.IfMatch)
+	if err != nil {
+		return err
 	}
 
 	u := &domain.User{
 		FullName:    req.FullName,
 		PhoneNumber: req.PhoneNumber,
+		Version:     version,
 	}
-	data, err := h.userSvc.Patch(id.String(), u)
+	data, err := h.userSvc.Patch(requestActor(ctx, claims), id.String(), u)
 	if err != nil {
-		if errors.Is(err, cons.ErrDataConflict) {
-			return echo.NewHTTPError(
-				http.StatusConflict,
-				err.Error(),
-			)
-		}
-
-		if _, is := err.(interface{ Unwrap() []error }); is {
-			return echo.NewHTTPError(
-				http.StatusBadRequest,
-				err,
-			)
-		}
-
-		return echo.NewHTTPError(
-			http.StatusForbidden,
-			err.Error(),
-		)
+		return err
 	}
 
 	resp := generated.UserPatchResponse{
@@ -188,5 +205,266 @@ func (h *Handler) UserPatch(ctx echo.Context, id uuid.UUID) error {
 		PhoneNumber: data.PhoneNumber,
 	}
 
+	ctx.Response().Header().Set("ETag", userETag(data.Version))
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UserChangePassword(ctx echo.Context, id uuid.UUID) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
+	}
+
+	req := generated.UserChangePasswordRequest{}
+	err = ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	err = h.userSvc.ChangePassword(requestActor(ctx, claims), id.String(), req.OldPassword, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) AdminForcePasswordChange(ctx echo.Context, id uuid.UUID) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.Role != cons.RoleAdmin {
+		return cons.ErrInvalidAuthorized
+	}
+
+	err = h.userSvc.ForcePasswordChange(requestActor(ctx, claims), id.String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) UserEnrollTotp(ctx echo.Context, id uuid.UUID) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
+	}
+
+	data, err := h.authSvc.EnrollTOTP(id.String())
+	if err != nil {
+		return err
+	}
+
+	resp := generated.UserEnrollTotpResponse{
+		Secret:     data.Secret,
+		OtpauthUri: data.URI,
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UserConfirmTotp(ctx echo.Context, id uuid.UUID) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
+	}
+
+	req := generated.UserConfirmTotpRequest{}
+	err = ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	codes, err := h.authSvc.ConfirmTOTP(id.String(), req.Code)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.UserConfirmTotpResponse{
+		RecoveryCodes: codes,
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UserListSessions(ctx echo.Context, id uuid.UUID) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
+	}
+
+	data, err := h.authSvc.ListSessions(id.String())
+	if err != nil {
+		return err
+	}
+
+	resp := generated.UserSessionListResponse{
+		Sessions: make([]generated.UserSession, 0, len(data)),
+	}
+	for _, s := range data {
+		resp.Sessions = append(resp.Sessions, generated.UserSession{
+			Id:         s.ID,
+			UserAgent:  s.UserAgent,
+			IpAddress:  s.IPAddress,
+			CreatedAt:  derefTime(s.CreatedAt),
+			LastSeenAt: derefTime(s.LastSeenAt),
+			ExpiresAt:  derefTime(s.ExpiresAt),
+			Current:    s.ID == claims.SessionID,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) UserRevokeSession(ctx echo.Context, id uuid.UUID, sessionID uuid.UUID) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserID != id.String() {
+		return cons.ErrInvalidAuthorized
+	}
+
+	err = h.authSvc.RevokeSession(id.String(), sessionID.String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) UserLoginHistory(ctx echo.Context, id uuid.UUID, params generated.UserLoginHistoryParams) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserID != id.String() && claims.Role != cons.RoleAdmin {
+		return cons.ErrInvalidAuthorized
+	}
+
+	page, pageSize := 0, 0
+	if params.Page != nil {
+		page = *params.Page
+	}
+	if params.PageSize != nil {
+		pageSize = *params.PageSize
+	}
+
+	data, err := h.authSvc.ListLoginHistory(id.String(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.UserLoginHistoryResponse{
+		Events:   make([]generated.UserLoginEvent, 0, len(data.Events)),
+		Page:     data.Page,
+		PageSize: data.PageSize,
+		Total:    data.Total,
+	}
+	for _, e := range data.Events {
+		resp.Events = append(resp.Events, generated.UserLoginEvent{
+			Id:        e.ID,
+			Success:   e.Success,
+			Reason:    e.Reason,
+			UserAgent: e.UserAgent,
+			IpAddress: e.IPAddress,
+			CreatedAt: derefTime(e.CreatedAt),
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return *t
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+func (h *Handler) AdminListAuditLog(ctx echo.Context, id uuid.UUID, params generated.AdminListAuditLogParams) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.Role != cons.RoleAdmin {
+		return cons.ErrInvalidAuthorized
+	}
+
+	page, pageSize := 0, 0
+	if params.Page != nil {
+		page = *params.Page
+	}
+	if params.PageSize != nil {
+		pageSize = *params.PageSize
+	}
+
+	filter := domain.AuditFilter{
+		UserID: id.String(),
+		From:   params.From,
+		To:     params.To,
+	}
+	data, err := h.userSvc.ListAuditLog(filter, page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.AuditLogResponse{
+		Entries:  make([]generated.AuditEntry, 0, len(data.Entries)),
+		Page:     data.Page,
+		PageSize: data.PageSize,
+		Total:    data.Total,
+	}
+	for _, e := range data.Entries {
+		changes := make([]generated.AuditChange, 0, len(e.Changes))
+		for _, c := range e.Changes {
+			changes = append(changes, generated.AuditChange{
+				Field: c.Field,
+				Old:   c.Old,
+				New:   c.New,
+			})
+		}
+
+		resp.Entries = append(resp.Entries, generated.AuditEntry{
+			Id:        e.ID,
+			ActorId:   e.ActorID,
+			Action:    e.Action,
+			Changes:   changes,
+			RequestId: e.RequestID,
+			CreatedAt: derefTime(e.CreatedAt),
+		})
+	}
+
 	return ctx.JSON(http.StatusOK, resp)
 }
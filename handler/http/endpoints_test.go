@@ -1,12 +1,15 @@
 package sawithttp_test
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/SawitProRecruitment/UserService/cons"
 
@@ -14,6 +17,7 @@ import (
 
 	"github.com/SawitProRecruitment/UserService/core/domain"
 	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/generated"
 	sawithttp "github.com/SawitProRecruitment/UserService/handler/http"
 	"github.com/golang/mock/gomock"
 	"github.com/labstack/echo/v4"
@@ -31,7 +35,7 @@ type testcaseRegister struct {
 func TestHandler_UserRegister(t *testing.T) {
 	testcases := []testcaseRegister{
 		{
-			name: "bad request invalid request",
+			name: "unprocessable invalid full name",
 			reqBody: `{
 				"full_name": "ed",
 				"password": "Passw@rd123",
@@ -39,14 +43,14 @@ func TestHandler_UserRegister(t *testing.T) {
 			}`,
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				userSvc.EXPECT().
-					Register(gomock.Any()).
-					Return(nil, errors.New("error occurred")).
+					Register(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrInvalidNameLength).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusBadRequest))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrInvalidNameLength.Code))
 			},
 		},
 		{
@@ -58,7 +62,7 @@ func TestHandler_UserRegister(t *testing.T) {
 			}`,
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				userSvc.EXPECT().
-					Register(gomock.Any()).
+					Register(gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						FullName:    "Edison Tantra",
 						Password:    "Passw@rd123",
@@ -90,7 +94,7 @@ func TestHandler_UserRegister(t *testing.T) {
 
 			logger := logrus.New()
 			logger.SetOutput(io.Discard)
-			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
 
 			e := echo.New()
 			req := httptest.NewRequest(http.MethodPost, URLPath, strings.NewReader(tc.reqBody))
@@ -102,7 +106,7 @@ func TestHandler_UserRegister(t *testing.T) {
 				tc.mockFunc(mockUserSvc, mockAuthSvc)
 			}
 
-			err := handler.UserRegister(c)
+			err := handler.MiddlewareError(handler.UserRegister)(c)
 			tc.assertionFunc(rec, err)
 		})
 	}
@@ -111,6 +115,7 @@ func TestHandler_UserRegister(t *testing.T) {
 type testcaseLogin struct {
 	name          string
 	reqBody       string
+	realIP        string
 	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
 	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
 }
@@ -118,21 +123,21 @@ type testcaseLogin struct {
 func TestHandler_UserLogin(t *testing.T) {
 	testcases := []testcaseLogin{
 		{
-			name: "bad request login",
+			name: "unauthorized login not match",
 			reqBody: `{
 				"phone_number": "+6285156305150",
 				"password": "Password123@"
 			}`,
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					Login(gomock.Any()).
-					Return(nil, errors.New("error occurred")).
+					Login(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrLoginNotMatch).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusBadRequest))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrLoginNotMatch.Code))
 			},
 		},
 		{
@@ -143,7 +148,52 @@ func TestHandler_UserLogin(t *testing.T) {
 			}`,
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					Login(gomock.Any()).
+					Login(gomock.Any(), gomock.Any()).
+					Return(&domain.AuthData{
+						ID:          "1234-1234-1234-1234",
+						AccessToken: "eysomethingtoken",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(err).To(BeNil())
+			},
+		},
+		{
+			name: "success login with mfa challenge",
+			reqBody: `{
+				"phone_number": "+6285156305150",
+				"password": "Password123@"
+			}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					Login(gomock.Any(), gomock.Any()).
+					Return(&domain.AuthData{
+						ID:          "1234-1234-1234-1234",
+						Scope:       cons.ScopeMFA,
+						MFARequired: true,
+						MFAToken:    "eysomethingmfatoken",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(err).To(BeNil())
+				Expect(recorder.Body.String()).To(ContainSubstring(`"mfa_token":"eysomethingmfatoken"`))
+				Expect(recorder.Body.String()).ToNot(ContainSubstring("access_token"))
+			},
+		},
+		{
+			name: "success login client address not an ip is left out",
+			reqBody: `{
+				"phone_number": "+6285156305150",
+				"password": "Password123@"
+			}`,
+			realIP: "1.2.3.4'); DROP TABLE sessions;--",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					Login(gomock.Any(), domain.Device{UserAgent: "", IPAddress: ""}).
 					Return(&domain.AuthData{
 						ID:          "1234-1234-1234-1234",
 						AccessToken: "eysomethingtoken",
@@ -174,11 +224,14 @@ func TestHandler_UserLogin(t *testing.T) {
 
 			logger := logrus.New()
 			logger.SetOutput(io.Discard)
-			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
 
 			e := echo.New()
 			req := httptest.NewRequest(http.MethodPost, URLPath, strings.NewReader(tc.reqBody))
 			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tc.realIP != "" {
+				req.Header.Set(echo.HeaderXRealIP, tc.realIP)
+			}
 			rec := httptest.NewRecorder()
 			c := e.NewContext(req, rec)
 
@@ -186,7 +239,7 @@ func TestHandler_UserLogin(t *testing.T) {
 				tc.mockFunc(mockUserSvc, mockAuthSvc)
 			}
 
-			err := handler.UserLogin(c)
+			err := handler.MiddlewareError(handler.UserLogin)(c)
 			tc.assertionFunc(rec, err)
 		})
 	}
@@ -195,6 +248,7 @@ func TestHandler_UserLogin(t *testing.T) {
 type testcaseDetail struct {
 	name          string
 	id            string
+	params        generated.UserDetailParams
 	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
 	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
 }
@@ -202,18 +256,17 @@ type testcaseDetail struct {
 func TestHandler_UserDetail(t *testing.T) {
 	testcases := []testcaseDetail{
 		{
-			name: "forbidden invalid token",
+			name: "unauthorized invalid token",
 			id:   "1234-1234-1234-1234",
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("", errors.New("error occurred")).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrInvalidToken).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusForbidden))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
 			},
 		},
 		{
@@ -221,34 +274,54 @@ func TestHandler_UserDetail(t *testing.T) {
 			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("abcd-abcd-abcd-abcd", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd"}, nil).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusForbidden))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
 			},
 		},
 		{
-			name: "forbidden failed get user data",
+			name: "not found user",
 			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
 					Times(1)
 
 				userSvc.EXPECT().
 					Get(gomock.Any()).
-					Return(nil, errors.New("error occurred")).
+					Return(nil, cons.ErrUserNotFound).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrUserNotFound.Code))
+			},
+		},
+		{
+			name: "internal error failed get user data",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Get(gomock.Any()).
+					Return(nil, errors.New("dial tcp 10.0.0.5:5432: connection refused")).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusForbidden))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrInternal.Code))
+				Expect(recorder.Body.String()).NotTo(ContainSubstring("10.0.0.5"))
 			},
 		},
 		{
@@ -256,8 +329,8 @@ func TestHandler_UserDetail(t *testing.T) {
 			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
 					Times(1)
 
 				userSvc.EXPECT().
@@ -266,12 +339,57 @@ func TestHandler_UserDetail(t *testing.T) {
 						ID:          "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 						FullName:    "Edison Tantra",
 						PhoneNumber: "+6285156305136",
+						Version:     3,
 					}, nil).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
 				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"3"`))
+				Expect(err).To(BeNil())
+			},
+		},
+		{
+			name:   "not modified when etag still current",
+			id:     "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			params: generated.UserDetailParams{IfNoneMatch: strPtr(`"2", W/"3"`)},
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Get(gomock.Any()).
+					Return(&domain.User{ID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208", Version: 3}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotModified))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"3"`))
+				Expect(recorder.Body.Len()).To(BeZero())
+			},
+		},
+		{
+			name:   "success when etag is stale",
+			id:     "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			params: generated.UserDetailParams{IfNoneMatch: strPtr(`"2"`)},
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Get(gomock.Any()).
+					Return(&domain.User{ID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208", Version: 3}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
 				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"3"`))
 			},
 		},
 	}
@@ -293,7 +411,7 @@ func TestHandler_UserDetail(t *testing.T) {
 
 			logger := logrus.New()
 			logger.SetOutput(io.Discard)
-			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
 
 			e := echo.New()
 			req := httptest.NewRequest(http.MethodGet, URLPath, nil)
@@ -305,7 +423,9 @@ func TestHandler_UserDetail(t *testing.T) {
 			}
 
 			validID, _ := uuid.Parse(tc.id)
-			err := handler.UserDetail(c, validID)
+			err := withAuth(handler, c, "/api/v1/users/:id", func(c echo.Context) error {
+				return handler.UserDetail(c, validID, tc.params)
+			})
 			tc.assertionFunc(rec, err)
 		})
 	}
@@ -315,6 +435,7 @@ type testcasePatch struct {
 	name          string
 	id            string
 	reqBody       string
+	params        generated.UserPatchParams
 	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
 	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
 }
@@ -322,19 +443,18 @@ type testcasePatch struct {
 func TestHandler_UserPatch(t *testing.T) {
 	testcases := []testcasePatch{
 		{
-			name:    "forbidden invalid token",
+			name:    "unauthorized invalid token",
 			id:      "1234-1234-1234-1234",
 			reqBody: "{}",
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("", errors.New("error occurred")).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrInvalidToken).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusForbidden))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
 			},
 		},
 		{
@@ -343,18 +463,17 @@ func TestHandler_UserPatch(t *testing.T) {
 			reqBody: "{}",
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("abcd-abcd-abcd-abcd", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd"}, nil).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusForbidden))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
 			},
 		},
 		{
-			name: "forbidden failed patch user data",
+			name: "internal error failed patch user data",
 			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 			reqBody: `{
 				"full_name": "edison tantra",
@@ -362,23 +481,23 @@ func TestHandler_UserPatch(t *testing.T) {
 			}`,
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
 					Times(1)
 
 				userSvc.EXPECT().
-					Patch(gomock.Any(), gomock.Any()).
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(nil, errors.New("error occurred")).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusForbidden))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(errorBody(recorder).Details).To(Equal([]string{cons.ErrInternal.Error()}))
 			},
 		},
 		{
-			name: "forbidden conflict when patch user data",
+			name: "conflict when patch user data",
 			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 			reqBody: `{
 				"full_name": "edison tantra",
@@ -386,41 +505,83 @@ func TestHandler_UserPatch(t *testing.T) {
 			}`,
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
 					Times(1)
 
 				userSvc.EXPECT().
-					Patch(gomock.Any(), gomock.Any()).
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(nil, cons.ErrDataConflict).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
-				Expect(err).To(HaveOccurred())
-				e := err.(*echo.HTTPError)
-				Expect(e.Code).To(Equal(http.StatusConflict))
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
 			},
 		},
 		{
-			name: "success patch user data",
-			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			name:    "precondition failed malformed if-match",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{"full_name": "edison tantra"}`,
+			params:  generated.UserPatchParams{IfMatch: strPtr(`W/"3"`)},
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+			},
+		},
+		{
+			name:    "precondition failed stale version",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{"full_name": "edison tantra"}`,
+			params:  generated.UserPatchParams{IfMatch: strPtr(`"2"`)},
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ domain.Actor, _ string, data *domain.User) (*domain.User, error) {
+						Expect(data.Version).To(Equal(2))
+						return nil, cons.ErrVersionMismatch
+					}).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusPreconditionFailed))
+			},
+		},
+		{
+			name:   "success patch user data",
+			id:     "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			params: generated.UserPatchParams{IfMatch: strPtr(`"3"`)},
 			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
 				authSvc.EXPECT().
-					VerifyAuthHeader(gomock.Any()).
-					Return("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", nil).
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
 					Times(1)
 
 				userSvc.EXPECT().
-					Patch(gomock.Any(), gomock.Any()).
+					Patch(gomock.Any(), gomock.Any(), gomock.Any()).
 					Return(&domain.User{
 						ID:          "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
 						FullName:    "Edison Tantra",
 						PhoneNumber: "+6285156305136",
+						Version:     4,
 					}, nil).
 					Times(1)
 			},
 			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
 				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get("ETag")).To(Equal(`"4"`))
 				Expect(err).To(BeNil())
 			},
 		},
@@ -443,7 +604,7 @@ func TestHandler_UserPatch(t *testing.T) {
 
 			logger := logrus.New()
 			logger.SetOutput(io.Discard)
-			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
 
 			e := echo.New()
 			req := httptest.NewRequest(http.MethodPatch, URLPath, strings.NewReader(tc.reqBody))
@@ -456,7 +617,1937 @@ func TestHandler_UserPatch(t *testing.T) {
 			}
 
 			validID, _ := uuid.Parse(tc.id)
-			err := handler.UserPatch(c, validID)
+			err := withAuth(handler, c, "/api/v1/users/:id", func(c echo.Context) error {
+				return handler.UserPatch(c, validID, tc.params)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+type testcaseChangePassword struct {
+	name          string
+	id            string
+	reqBody       string
+	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_UserChangePassword(t *testing.T) {
+	testcases := []testcaseChangePassword{
+		{
+			name:    "unauthorized invalid token",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: "{}",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrInvalidToken).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			},
+		},
+		{
+			name:    "forbidden id does not match",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: "{}",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name: "unprocessable password violate policy",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{
+				"old_password": "Passw0rd!",
+				"new_password": "password"
+			}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					ChangePassword(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("%w; %w", cons.ErrPasswordNoUpper, cons.ErrPasswordNoNumber)).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrInvalidPasswordFormat.Code))
+				Expect(errorBody(recorder).Details).To(HaveLen(2))
+			},
+		},
+		{
+			name: "success change password",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{
+				"old_password": "Passw0rd!",
+				"new_password": "N3wPassw0rd!"
+			}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					ChangePassword(gomock.Any(), "9ae8810c-7b28-4c4c-8dbc-ed43be3da208", "Passw0rd!", "N3wPassw0rd!").
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusNoContent))
+				Expect(err).To(BeNil())
+			},
+		},
+	}
+
+	const URLPath = "/api/v1/users/9ae8810c-7b28-4c4c-8dbc-ed43be3da208/password"
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPut, URLPath, strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(tc.id)
+			err := withAuth(handler, c, "/api/v1/users/:id/password", func(c echo.Context) error {
+				return handler.UserChangePassword(c, validID)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+type testcaseForcePasswordChange struct {
+	name          string
+	id            string
+	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_AdminForcePasswordChange(t *testing.T) {
+	testcases := []testcaseForcePasswordChange{
+		{
+			name: "forbidden not an admin",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208", Role: cons.RoleUser}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name: "not found user",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					ForcePasswordChange(domain.Actor{UserID: "abcd-abcd-abcd-abcd"}, "9ae8810c-7b28-4c4c-8dbc-ed43be3da208").
+					Return(cons.ErrUserNotFound).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			},
+		},
+		{
+			name: "success force password change",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					ForcePasswordChange(domain.Actor{UserID: "abcd-abcd-abcd-abcd"}, "9ae8810c-7b28-4c4c-8dbc-ed43be3da208").
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusNoContent))
+				Expect(err).To(BeNil())
+			},
+		},
+	}
+
+	const URLPath = "/api/v1/admin/users/9ae8810c-7b28-4c4c-8dbc-ed43be3da208/force-password-change"
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, URLPath, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(tc.id)
+			err := withAuth(handler, c, "/api/v1/admin/users/:id/force-password-change", func(c echo.Context) error {
+				return handler.AdminForcePasswordChange(c, validID)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_UserLoginMfa(t *testing.T) {
+	testcases := []testcaseLogin{
+		{
+			name: "unprocessable invalid code",
+			reqBody: `{
+				"mfa_token": "eysomethingmfatoken",
+				"code": "000000"
+			}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					LoginMFA("eysomethingmfatoken", "000000", gomock.Any()).
+					Return(nil, cons.ErrInvalidMFACode).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			},
+		},
+		{
+			name: "success login mfa",
+			reqBody: `{
+				"mfa_token": "eysomethingmfatoken",
+				"code": "123456"
+			}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					LoginMFA("eysomethingmfatoken", "123456", gomock.Any()).
+					Return(&domain.AuthData{
+						ID:          "1234-1234-1234-1234",
+						AccessToken: "eysomethingtoken",
+						Scope:       cons.ScopeUser,
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(err).To(BeNil())
+				Expect(recorder.Body.String()).To(ContainSubstring(`"access_token":"eysomethingtoken"`))
+			},
+		},
+	}
+
+	const URLPath = "/api/v1/users/login/mfa"
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, URLPath, strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			err := handler.MiddlewareError(handler.UserLoginMfa)(c)
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+type testcaseConfirmTotp struct {
+	name          string
+	id            string
+	reqBody       string
+	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_UserConfirmTotp(t *testing.T) {
+	testcases := []testcaseConfirmTotp{
+		{
+			name:    "forbidden different user",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{"code": "123456"}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:    "conflict already enabled",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{"code": "123456"}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					ConfirmTOTP("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", "123456").
+					Return(nil, cons.ErrMFAAlreadyEnabled).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			},
+		},
+		{
+			name:    "unprocessable invalid code",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{"code": "000000"}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					ConfirmTOTP("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", "000000").
+					Return(nil, cons.ErrInvalidMFACode).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			},
+		},
+		{
+			name:    "success confirm totp",
+			id:      "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			reqBody: `{"code": "123456"}`,
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					ConfirmTOTP("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", "123456").
+					Return([]string{"abcde-fghij", "klmno-pqrst"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(err).To(BeNil())
+				Expect(recorder.Body.String()).To(ContainSubstring("abcde-fghij"))
+			},
+		},
+	}
+
+	const URLPath = "/api/v1/users/9ae8810c-7b28-4c4c-8dbc-ed43be3da208/mfa/totp/confirm"
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, URLPath, strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(tc.id)
+			err := withAuth(handler, c, "/api/v1/users/:id/mfa/totp/confirm", func(c echo.Context) error {
+				return handler.UserConfirmTotp(c, validID)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_UserListSessions(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	const userID = "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"
+	mockUserSvc := port.NewMockUserService(mockCtrl)
+	mockAuthSvc := port.NewMockAuthService(mockCtrl)
+	mockAuthSvc.EXPECT().
+		VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+		Return(&domain.TokenClaims{UserID: userID, SessionID: "session-2"}, nil).
+		Times(1)
+	mockAuthSvc.EXPECT().
+		ListSessions(userID).
+		Return([]domain.Session{
+			{ID: "session-2", UserID: userID, UserAgent: "Mozilla/5.0"},
+			{ID: "session-1", UserID: userID, UserAgent: "curl/8.0"},
+		}, nil).
+		Times(1)
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+userID+"/sessions", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	validID, _ := uuid.Parse(userID)
+	err := withAuth(handler, c, "/api/v1/users/:id/sessions", func(c echo.Context) error {
+		return handler.UserListSessions(c, validID)
+	})
+	Expect(err).To(BeNil())
+	Expect(rec.Code).To(Equal(http.StatusOK))
+
+	resp := generated.UserSessionListResponse{}
+	Expect(json.Unmarshal(rec.Body.Bytes(), &resp)).To(Succeed())
+	Expect(resp.Sessions).To(HaveLen(2))
+	Expect(resp.Sessions[0].Current).To(BeTrue())
+	Expect(resp.Sessions[1].Current).To(BeFalse())
+}
+
+type testcaseRevokeSession struct {
+	name          string
+	id            string
+	sessionID     string
+	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_UserRevokeSession(t *testing.T) {
+	testcases := []testcaseRevokeSession{
+		{
+			name:      "forbidden different user",
+			id:        "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			sessionID: "0b6d3b1e-4f7a-4a43-9f0e-2d5b2b8a6c11",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:      "not found session",
+			id:        "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			sessionID: "0b6d3b1e-4f7a-4a43-9f0e-2d5b2b8a6c11",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					RevokeSession("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", "0b6d3b1e-4f7a-4a43-9f0e-2d5b2b8a6c11").
+					Return(cons.ErrSessionNotFound).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			},
+		},
+		{
+			name:      "success revoke session",
+			id:        "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			sessionID: "0b6d3b1e-4f7a-4a43-9f0e-2d5b2b8a6c11",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					RevokeSession("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", "0b6d3b1e-4f7a-4a43-9f0e-2d5b2b8a6c11").
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(recorder.Code).To(Equal(http.StatusNoContent))
+				Expect(err).To(BeNil())
+			},
+		},
+	}
+
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodDelete, "/api/v1/users/"+tc.id+"/sessions/"+tc.sessionID, nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(tc.id)
+			validSessionID, _ := uuid.Parse(tc.sessionID)
+			err := withAuth(handler, c, "/api/v1/users/:id/sessions/:sessionId", func(c echo.Context) error {
+				return handler.UserRevokeSession(c, validID, validSessionID)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+type testcaseLoginHistory struct {
+	name          string
+	id            string
+	params        generated.UserLoginHistoryParams
+	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_UserLoginHistory(t *testing.T) {
+	page, pageSize := 2, 10
+	testcases := []testcaseLoginHistory{
+		{
+			name: "forbidden different user",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleUser}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:   "success admin reads other user",
+			id:     "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			params: generated.UserLoginHistoryParams{Page: &page, PageSize: &pageSize},
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					ListLoginHistory("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", 2, 10).
+					Return(&domain.LoginHistory{
+						Events: []domain.LoginEvent{
+							{ID: "event-1", Reason: cons.LoginReasonInvalidCredentials},
+						},
+						Page:     2,
+						PageSize: 10,
+						Total:    11,
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				resp := generated.UserLoginHistoryResponse{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Total).To(Equal(11))
+				Expect(resp.Events).To(HaveLen(1))
+				Expect(resp.Events[0].Reason).To(Equal(cons.LoginReasonInvalidCredentials))
+			},
+		},
+		{
+			name: "success own history with default paging",
+			id:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					ListLoginHistory("9ae8810c-7b28-4c4c-8dbc-ed43be3da208", 0, 0).
+					Return(&domain.LoginHistory{Page: 1, PageSize: 20}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(ContainSubstring(`"events":[]`))
+			},
+		},
+	}
+
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users/"+tc.id+"/login-history", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(tc.id)
+			err := withAuth(handler, c, "/api/v1/users/:id/login-history", func(c echo.Context) error {
+				return handler.UserLoginHistory(c, validID, tc.params)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+type testcaseAuditLog struct {
+	name          string
+	params        generated.AdminListAuditLogParams
+	mockFunc      func(userSvc *port.MockUserService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_AdminListAuditLog(t *testing.T) {
+	const userID = "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"
+	from := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	oldName, newName := "Edison", "Edison Tantra"
+	testcases := []testcaseAuditLog{
+		{
+			name: "forbidden not an admin",
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: userID, Role: cons.RoleUser}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:   "success list audit log",
+			params: generated.AdminListAuditLogParams{From: &from},
+			mockFunc: func(userSvc *port.MockUserService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				userSvc.EXPECT().
+					ListAuditLog(domain.AuditFilter{UserID: userID, From: &from}, 0, 0).
+					Return(&domain.AuditLog{
+						Entries: []domain.AuditEntry{
+							{
+								ID:      "entry-1",
+								ActorID: userID,
+								Action:  cons.AuditActionUpdateProfile,
+								Changes: []domain.AuditChange{
+									{Field: "full_name", Old: &oldName, New: &newName},
+								},
+								RequestID: "req-1",
+							},
+						},
+						Page:     1,
+						PageSize: 20,
+						Total:    1,
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				resp := generated.AuditLogResponse{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Entries).To(HaveLen(1))
+				Expect(resp.Entries[0].RequestId).To(Equal("req-1"))
+				Expect(resp.Entries[0].Changes).To(HaveLen(1))
+				Expect(*resp.Entries[0].Changes[0].Old).To(Equal(oldName))
+				Expect(*resp.Entries[0].Changes[0].New).To(Equal(newName))
+			},
+		},
+	}
+
+	var (
+		mockUserSvc *port.MockUserService
+		mockAuthSvc *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc = port.NewMockUserService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/users/"+userID+"/audit-log", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockUserSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(userID)
+			err := withAuth(handler, c, "/api/v1/admin/users/:id/audit-log", func(c echo.Context) error {
+				return handler.AdminListAuditLog(c, validID, tc.params)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_MiddlewareRequestID(t *testing.T) {
+	const userID = "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"
+	testcases := []struct {
+		name          string
+		requestID     string
+		wantRequestID func(got string)
+	}{
+		{
+			name:      "keeps request id of caller",
+			requestID: "req-from-gateway",
+			wantRequestID: func(got string) {
+				Expect(got).To(Equal("req-from-gateway"))
+			},
+		},
+		{
+			name: "generates request id",
+			wantRequestID: func(got string) {
+				_, err := uuid.Parse(got)
+				Expect(err).To(BeNil())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockUserSvc := port.NewMockUserService(mockCtrl)
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			mockAuthSvc.EXPECT().
+				VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+				Return(&domain.TokenClaims{UserID: userID}, nil).
+				Times(1)
+
+			var actor domain.Actor
+			mockUserSvc.EXPECT().
+				Patch(gomock.Any(), userID, gomock.Any()).
+				DoAndReturn(func(a domain.Actor, id string, data *domain.User) (*domain.User, error) {
+					actor = a
+					return &domain.User{ID: id}, nil
+				}).
+				Times(1)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, mockUserSvc, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPatch, "/api/v1/users/"+userID, strings.NewReader(`{"full_name": "Edison Tantra"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tc.requestID != "" {
+				req.Header.Set(echo.HeaderXRequestID, tc.requestID)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			validID, _ := uuid.Parse(userID)
+			err := handler.MiddlewareRequestID(func(c echo.Context) error {
+				return withAuth(handler, c, "/api/v1/users/:id", func(c echo.Context) error {
+					return handler.UserPatch(c, validID, generated.UserPatchParams{})
+				})
+			})(c)
+			Expect(err).To(BeNil())
+			Expect(actor.UserID).To(Equal(userID))
+			Expect(actor.RequestID).To(Equal(rec.Header().Get(echo.HeaderXRequestID)))
+			tc.wantRequestID(actor.RequestID)
+		})
+	}
+}
+
+func TestHandler_MiddlewareIdempotency(t *testing.T) {
+	const reqBody = `{"full_name": "Edison Tantra"}`
+	created := func(c echo.Context) error {
+		return c.JSON(http.StatusCreated, map[string]string{"id": "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"})
+	}
+
+	const userPath = "/api/v1/admin/users/0d3b1c58-5a8e-4f5e-9b1f-8c2a6d9e4f11/force-password-change"
+	signedIn := func(authSvc *port.MockAuthService) {
+		authSvc.EXPECT().
+			VerifyAuthHeader(gomock.Any(), gomock.Any()).
+			Return(&domain.TokenClaims{UserID: "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"}, nil).
+			Times(1)
+	}
+
+	testcases := []struct {
+		name          string
+		path          string
+		route         string
+		authorization string
+		next          echo.HandlerFunc
+		authFunc      func(authSvc *port.MockAuthService)
+		mockFunc      func(svc *port.MockIdempotencyService)
+		assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+	}{
+		{
+			name: "stores response of first request",
+			path: "/api/v1/users/register",
+			next: created,
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					Return(nil, nil).
+					Times(1)
+				svc.EXPECT().
+					Complete(gomock.Any()).
+					DoAndReturn(func(rec *domain.IdempotencyRecord) error {
+						Expect(rec.Fingerprint).ToNot(BeEmpty())
+						Expect(rec.Caller).To(Equal("anonymous:" + rec.Fingerprint))
+						Expect(rec.Key).To(Equal("key-1"))
+						Expect(rec.StatusCode).To(Equal(http.StatusCreated))
+						Expect(rec.ContentType).To(HavePrefix(echo.MIMEApplicationJSON))
+						Expect(string(rec.Body)).To(ContainSubstring("9ae8810c-7b28-4c4c-8dbc-ed43be3da208"))
+						return nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+			},
+		},
+		{
+			name:          "scopes key to the signed in user",
+			path:          userPath,
+			route:         "/api/v1/admin/users/:id/force-password-change",
+			authorization: "Bearer token-a",
+			next:          created,
+			authFunc:      signedIn,
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					DoAndReturn(func(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+						Expect(rec.Caller).To(Equal("user:9ae8810c-7b28-4c4c-8dbc-ed43be3da208"))
+						Expect(rec.Key).To(Equal("key-1"))
+						return nil, nil
+					}).
+					Times(1)
+				svc.EXPECT().
+					Complete(gomock.Any()).
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+			},
+		},
+		{
+			name:          "same user with another token shares the scope",
+			path:          userPath,
+			route:         "/api/v1/admin/users/:id/force-password-change",
+			authorization: "Bearer token-b",
+			next: func(c echo.Context) error {
+				return errors.New("handler must not run on replay")
+			},
+			authFunc: signedIn,
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					DoAndReturn(func(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+						Expect(rec.Caller).To(Equal("user:9ae8810c-7b28-4c4c-8dbc-ed43be3da208"))
+						return &domain.IdempotencyRecord{StatusCode: http.StatusNoContent}, nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNoContent))
+				Expect(recorder.Header().Get("Idempotent-Replayed")).To(Equal("true"))
+			},
+		},
+		{
+			name:          "unauthorized never reaches the store",
+			path:          userPath,
+			route:         "/api/v1/admin/users/:id/force-password-change",
+			authorization: "Bearer invalid",
+			next:          created,
+			authFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), gomock.Any()).
+					Return(nil, cons.ErrInvalidToken).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get("Idempotent-Replayed")).To(BeEmpty())
+			},
+		},
+		{
+			name: "stores rendered error of handler",
+			path: "/api/v1/users/register",
+			next: func(c echo.Context) error {
+				return cons.ErrDataConflict
+			},
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					Return(nil, nil).
+					Times(1)
+				svc.EXPECT().
+					Complete(gomock.Any()).
+					DoAndReturn(func(rec *domain.IdempotencyRecord) error {
+						Expect(rec.StatusCode).To(Equal(http.StatusConflict))
+						Expect(string(rec.Body)).ToNot(BeEmpty())
+						return nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			},
+		},
+		{
+			name: "replays stored response",
+			path: "/api/v1/users/register",
+			next: func(c echo.Context) error {
+				return errors.New("handler must not run on replay")
+			},
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					Return(&domain.IdempotencyRecord{
+						StatusCode:  http.StatusCreated,
+						ContentType: echo.MIMEApplicationJSON,
+						Body:        []byte(`{"id":"stored"}`),
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+				Expect(recorder.Header().Get("Idempotent-Replayed")).To(Equal("true"))
+				Expect(recorder.Body.String()).To(Equal(`{"id":"stored"}`))
+			},
+		},
+		{
+			name: "unprocessable key reused with different request",
+			path: "/api/v1/users/register",
+			next: created,
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					Return(nil, cons.ErrIdempotencyKeyReused).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			},
+		},
+		{
+			name: "conflict first request in progress",
+			path: "/api/v1/users/register",
+			next: created,
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					Return(nil, cons.ErrIdempotencyInProgress).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusConflict))
+			},
+		},
+		{
+			name: "releases key on server error",
+			path: "/api/v1/users/register",
+			next: func(c echo.Context) error {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"message": "error occurred"})
+			},
+			mockFunc: func(svc *port.MockIdempotencyService) {
+				svc.EXPECT().
+					Begin(gomock.Any()).
+					Return(nil, nil).
+					Times(1)
+				svc.EXPECT().
+					Release(gomock.Any()).
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			},
+		},
+		{
+			name: "skips routes returning credentials",
+			path: "/api/v1/users/login",
+			next: created,
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockIdempotencySvc := port.NewMockIdempotencyService(mockCtrl)
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockIdempotencySvc)
+			}
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			if tc.authFunc != nil {
+				tc.authFunc(mockAuthSvc)
+			}
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, nil, nil, nil, "")
+
+			route := tc.path
+			if tc.route != "" {
+				route = tc.route
+			}
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			req.Header.Set("Idempotency-Key", "key-1")
+			if tc.authorization != "" {
+				req.Header.Set(echo.HeaderAuthorization, tc.authorization)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := withAuth(handler, c, route, handler.MiddlewareIdempotency(mockIdempotencySvc)(tc.next))
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+type testcaseWebhook struct {
+	name          string
+	reqBody       string
+	mockFunc      func(webhookSvc *port.MockWebhookService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_AdminCreateWebhook(t *testing.T) {
+	const webhookID = "0d3b1c58-5a8e-4f5e-9b1f-8c2a6d9e4f11"
+	testcases := []testcaseWebhook{
+		{
+			name:    "forbidden not an admin",
+			reqBody: `{"url": "https://partner.example.com/hooks", "event_types": ["user.registered"]}`,
+			mockFunc: func(webhookSvc *port.MockWebhookService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleUser}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:    "unprocessable invalid event type",
+			reqBody: `{"url": "https://partner.example.com/hooks", "event_types": ["user.unknown"]}`,
+			mockFunc: func(webhookSvc *port.MockWebhookService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				webhookSvc.EXPECT().
+					CreateWebhook(gomock.Any()).
+					Return(nil, fmt.Errorf("%w: user.unknown", cons.ErrInvalidWebhookEvent)).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			},
+		},
+		{
+			name:    "success create webhook returns secret",
+			reqBody: `{"url": "https://partner.example.com/hooks", "event_types": ["user.registered"]}`,
+			mockFunc: func(webhookSvc *port.MockWebhookService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				webhookSvc.EXPECT().
+					CreateWebhook(&domain.Webhook{
+						URL:        "https://partner.example.com/hooks",
+						EventTypes: []string{cons.EventUserRegistered},
+					}).
+					Return(&domain.Webhook{
+						ID:         webhookID,
+						URL:        "https://partner.example.com/hooks",
+						EventTypes: []string{cons.EventUserRegistered},
+						Secret:     "generated-secret",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+				resp := generated.Webhook{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Id).To(Equal(webhookID))
+				Expect(resp.Secret).NotTo(BeNil())
+				Expect(*resp.Secret).To(Equal("generated-secret"))
+			},
+		},
+	}
+
+	var (
+		mockWebhookSvc *port.MockWebhookService
+		mockAuthSvc    *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockWebhookSvc = port.NewMockWebhookService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, mockWebhookSvc, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/webhooks", strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockWebhookSvc, mockAuthSvc)
+			}
+
+			err := withAuth(handler, c, "/api/v1/admin/webhooks", func(c echo.Context) error {
+				return handler.AdminCreateWebhook(c)
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_AdminListWebhookDeliveries(t *testing.T) {
+	const webhookID = "0d3b1c58-5a8e-4f5e-9b1f-8c2a6d9e4f11"
+	nextAttemptAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	testcases := []testcaseWebhook{
+		{
+			name: "not found webhook",
+			mockFunc: func(webhookSvc *port.MockWebhookService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				webhookSvc.EXPECT().
+					ListDeliveries(webhookID, 0, 0).
+					Return(nil, cons.ErrWebhookNotFound).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			},
+		},
+		{
+			name: "success list deliveries",
+			mockFunc: func(webhookSvc *port.MockWebhookService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeAdmin).
+					Return(&domain.TokenClaims{UserID: "abcd-abcd-abcd-abcd", Role: cons.RoleAdmin}, nil).
+					Times(1)
+
+				webhookSvc.EXPECT().
+					ListDeliveries(webhookID, 0, 0).
+					Return(&domain.WebhookDeliveryLog{
+						Deliveries: []domain.WebhookDelivery{
+							{
+								ID:             "delivery-2",
+								EventType:      cons.EventUserPhoneChanged,
+								Status:         cons.WebhookStatusPending,
+								Attempts:       1,
+								LastStatusCode: http.StatusServiceUnavailable,
+								LastError:      "unexpected status 503",
+								NextAttemptAt:  &nextAttemptAt,
+							},
+							{
+								ID:             "delivery-1",
+								EventType:      cons.EventUserRegistered,
+								Status:         cons.WebhookStatusSucceeded,
+								Attempts:       1,
+								LastStatusCode: http.StatusOK,
+								NextAttemptAt:  &nextAttemptAt,
+							},
+						},
+						Page:     1,
+						PageSize: 20,
+						Total:    2,
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				resp := generated.WebhookDeliveryLogResponse{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Deliveries).To(HaveLen(2))
+				Expect(resp.Deliveries[0].LastError).To(Equal("unexpected status 503"))
+				Expect(resp.Deliveries[0].NextAttemptAt).NotTo(BeNil())
+				Expect(resp.Deliveries[1].NextAttemptAt).To(BeNil())
+			},
+		},
+	}
+
+	var (
+		mockWebhookSvc *port.MockWebhookService
+		mockAuthSvc    *port.MockAuthService
+	)
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockWebhookSvc = port.NewMockWebhookService(mockCtrl)
+			mockAuthSvc = port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, mockWebhookSvc, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/webhooks/"+webhookID+"/deliveries", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockWebhookSvc, mockAuthSvc)
+			}
+
+			validID, _ := uuid.Parse(webhookID)
+			err := withAuth(handler, c, "/api/v1/admin/webhooks/:id/deliveries", func(c echo.Context) error {
+				return handler.AdminListWebhookDeliveries(c, validID, generated.AdminListWebhookDeliveriesParams{})
+			})
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_OauthIntrospect(t *testing.T) {
+	testcases := []struct {
+		name          string
+		reqBody       string
+		basicAuth     bool
+		mockFunc      func(authSvc *port.MockAuthService)
+		assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+	}{
+		{
+			name:      "unauthorized invalid client",
+			reqBody:   "token=abc",
+			basicAuth: true,
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					AuthenticateClient("billing", "billing-secret").
+					Return(cons.ErrInvalidClient).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get(echo.HeaderWWWAuthenticate)).To(HavePrefix("Basic"))
+			},
+		},
+		{
+			name:    "unauthorized without client credentials",
+			reqBody: "token=abc",
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			},
+		},
+		{
+			name:    "bad request missing token",
+			reqBody: "client_id=billing&client_secret=billing-secret",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					AuthenticateClient("billing", "billing-secret").
+					Return(nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			},
+		},
+		{
+			name:    "success inactive token",
+			reqBody: "token=abc&client_id=billing&client_secret=billing-secret",
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					AuthenticateClient("billing", "billing-secret").
+					Return(nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					Introspect("abc").
+					Return(&domain.TokenIntrospection{Active: false}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"active": false}`))
+			},
+		},
+		{
+			name:      "success active token",
+			reqBody:   "token=abc&token_type_hint=access_token",
+			basicAuth: true,
+			mockFunc: func(authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					AuthenticateClient("billing", "billing-secret").
+					Return(nil).
+					Times(1)
+
+				authSvc.EXPECT().
+					Introspect("abc").
+					Return(&domain.TokenIntrospection{
+						Active:    true,
+						Subject:   "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+						Scope:     cons.ScopeUser,
+						TokenType: cons.AuthTokenType,
+						ExpiresAt: 1704164400,
+						IssuedAt:  1704157200,
+						Issuer:    "sawitApp",
+						Audience:  []string{"sawitApp"},
+						SessionID: "session-1",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get(echo.HeaderCacheControl)).To(Equal("no-store"))
+
+				resp := generated.IntrospectionResponse{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Active).To(BeTrue())
+				Expect(*resp.Sub).To(Equal("9ae8810c-7b28-4c4c-8dbc-ed43be3da208"))
+				Expect(*resp.Exp).To(Equal(int64(1704164400)))
+				Expect(resp.ClientId).To(BeNil())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, nil, nil, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/oauth/introspect", strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			if tc.basicAuth {
+				req.SetBasicAuth("billing", "billing-secret")
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockAuthSvc)
+			}
+
+			err := handler.MiddlewareError(handler.OauthIntrospect)(c)
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_OauthAuthorizeSubmit(t *testing.T) {
+	const authorizeForm = "response_type=code&client_id=client-1&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcallback" +
+		"&scope=openid&state=xyz&code_challenge=E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM&code_challenge_method=S256"
+	client := &domain.OAuthClient{ID: "client-1", Name: "Sawit Web"}
+
+	testcases := []struct {
+		name          string
+		reqBody       string
+		mockFunc      func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService)
+		assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+	}{
+		{
+			name:    "bad request unknown redirect uri is not redirected",
+			reqBody: authorizeForm,
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().
+					ValidateAuthorization(gomock.Any()).
+					Return(nil, cons.ErrInvalidRedirectURI).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Header().Get(echo.HeaderLocation)).To(BeEmpty())
+				Expect(recorder.Body.String()).To(ContainSubstring(cons.ErrInvalidRedirectURI.Error()))
+			},
+		},
+		{
+			name:    "redirect invalid scope with state",
+			reqBody: authorizeForm,
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().
+					ValidateAuthorization(gomock.Any()).
+					Return(nil, fmt.Errorf("%w: email", cons.ErrInvalidScope)).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusFound))
+				Expect(recorder.Header().Get(echo.HeaderLocation)).To(HavePrefix("https://app.example.com/callback?"))
+				Expect(recorder.Header().Get(echo.HeaderLocation)).To(ContainSubstring("error=invalid_scope"))
+				Expect(recorder.Header().Get(echo.HeaderLocation)).To(ContainSubstring("state=xyz"))
+			},
+		},
+		{
+			name:    "wrong password renders the page again",
+			reqBody: authorizeForm + "&phone_number=%2B6285156305136&password=wrong",
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().ValidateAuthorization(gomock.Any()).Return(client, nil).Times(1)
+				authSvc.EXPECT().
+					Login(&domain.User{PhoneNumber: "+6285156305136", Password: "wrong"}, gomock.Any()).
+					Return(nil, cons.ErrLoginNotMatch).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get(echo.HeaderXFrameOptions)).To(Equal("DENY"))
+				Expect(recorder.Body.String()).To(ContainSubstring(cons.ErrLoginNotMatch.Error()))
+				Expect(recorder.Body.String()).To(ContainSubstring(`value="&#43;6285156305136"`))
+			},
+		},
+		{
+			name:    "internal error is not shown on the page",
+			reqBody: authorizeForm + "&phone_number=%2B6285156305136&password=Passw0rd!",
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().ValidateAuthorization(gomock.Any()).Return(client, nil).Times(1)
+				authSvc.EXPECT().
+					Login(gomock.Any(), gomock.Any()).
+					Return(nil, errors.New("pq: connection refused")).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).NotTo(ContainSubstring("connection refused"))
+				Expect(recorder.Body.String()).To(ContainSubstring("something went wrong, try again"))
+			},
+		},
+		{
+			name:    "password change required is refused without a code",
+			reqBody: authorizeForm + "&phone_number=%2B6285156305136&password=Passw0rd!",
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().ValidateAuthorization(gomock.Any()).Return(client, nil).Times(1)
+				authSvc.EXPECT().
+					Login(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(req *domain.User, device domain.Device) (*domain.AuthData, error) {
+						Expect(device.NoPasswordChange).To(BeTrue())
+						return nil, cons.ErrPasswordChangeNeeded
+					}).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get(echo.HeaderLocation)).To(BeEmpty())
+				Expect(recorder.Body.String()).To(ContainSubstring(cons.ErrPasswordChangeNeeded.Error()))
+			},
+		},
+		{
+			name:    "second factor is asked for",
+			reqBody: authorizeForm + "&phone_number=%2B6285156305136&password=Passw0rd!",
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().ValidateAuthorization(gomock.Any()).Return(client, nil).Times(1)
+				authSvc.EXPECT().
+					Login(gomock.Any(), gomock.Any()).
+					Return(&domain.AuthData{ID: "user-1", MFARequired: true, MFAToken: "mfa-token"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(ContainSubstring(`name="mfa_token" value="mfa-token"`))
+				Expect(recorder.Body.String()).To(ContainSubstring(`name="mfa_code"`))
+			},
+		},
+		{
+			name:    "success redirect with code after second factor",
+			reqBody: authorizeForm + "&mfa_token=mfa-token&mfa_code=123456",
+			mockFunc: func(authSvc *port.MockAuthService, oauthSvc *port.MockOAuthService) {
+				auth := &domain.AuthData{ID: "user-1", AccessToken: "token", SessionID: "session-1"}
+				oauthSvc.EXPECT().ValidateAuthorization(gomock.Any()).Return(client, nil).Times(1)
+				authSvc.EXPECT().LoginMFA("mfa-token", "123456", gomock.Any()).Return(auth, nil).Times(1)
+				oauthSvc.EXPECT().
+					Authorize(gomock.Any(), auth).
+					DoAndReturn(func(req *domain.AuthorizationRequest, auth *domain.AuthData) (string, error) {
+						Expect(req.ClientID).To(Equal("client-1"))
+						Expect(req.CodeChallengeMethod).To(Equal("S256"))
+						return "the-code", nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusFound))
+				Expect(recorder.Header().Get(echo.HeaderLocation)).To(Equal("https://app.example.com/callback?code=the-code&state=xyz"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+			mockOAuthSvc := port.NewMockOAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, nil, mockOAuthSvc, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/oauth/authorize", strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockAuthSvc, mockOAuthSvc)
+			}
+
+			err := handler.MiddlewareError(handler.OauthAuthorizeSubmit)(c)
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+func TestHandler_OauthToken(t *testing.T) {
+	testcases := []struct {
+		name          string
+		reqBody       string
+		basicAuth     bool
+		mockFunc      func(oauthSvc *port.MockOAuthService)
+		assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+	}{
+		{
+			name:    "unauthorized no client",
+			reqBody: "grant_type=authorization_code&code=the-code",
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Body.String()).To(ContainSubstring(`"error":"invalid_client"`))
+			},
+		},
+		{
+			name:      "unauthorized wrong secret",
+			reqBody:   "grant_type=authorization_code&code=the-code",
+			basicAuth: true,
+			mockFunc: func(oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().Exchange(gomock.Any()).Return(nil, cons.ErrInvalidClient).Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get(echo.HeaderWWWAuthenticate)).To(Equal(`Basic realm="oauth"`))
+			},
+		},
+		{
+			name:    "bad request used code",
+			reqBody: "grant_type=authorization_code&code=the-code&client_id=client-1&code_verifier=verifier",
+			mockFunc: func(oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().Exchange(gomock.Any()).Return(nil, cons.ErrInvalidGrant).Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(recorder.Body.String()).To(MatchJSON(fmt.Sprintf(
+					`{"error": "invalid_grant", "error_description": %q}`, cons.ErrInvalidGrant.Error(),
+				)))
+			},
+		},
+		{
+			name:    "failed exchange",
+			reqBody: "grant_type=authorization_code&code=the-code&client_id=client-1",
+			mockFunc: func(oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().Exchange(gomock.Any()).Return(nil, errors.New("some error")).Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				Expect(recorder.Body.String()).NotTo(ContainSubstring("some error"))
+			},
+		},
+		{
+			name:      "success",
+			reqBody:   "grant_type=authorization_code&code=the-code&redirect_uri=https%3A%2F%2Fapp.example.com%2Fcallback&code_verifier=verifier",
+			basicAuth: true,
+			mockFunc: func(oauthSvc *port.MockOAuthService) {
+				oauthSvc.EXPECT().
+					Exchange(&domain.TokenRequest{
+						GrantType:    "authorization_code",
+						Code:         "the-code",
+						RedirectURI:  "https://app.example.com/callback",
+						ClientID:     "client-1",
+						ClientSecret: "client-secret",
+						CodeVerifier: "verifier",
+					}).
+					Return(&domain.TokenResponse{
+						AccessToken: "access",
+						TokenType:   cons.AuthTokenType,
+						ExpiresIn:   7200,
+						Scope:       "user openid",
+						IDToken:     "id-token",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Header().Get(echo.HeaderCacheControl)).To(Equal("no-store"))
+				Expect(recorder.Body.String()).To(MatchJSON(`{
+					"access_token": "access",
+					"token_type": "Bearer",
+					"expires_in": 7200,
+					"scope": "user openid",
+					"id_token": "id-token"
+				}`))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockOAuthSvc := port.NewMockOAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, nil, nil, mockOAuthSvc, nil, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/oauth/token", strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+			if tc.basicAuth {
+				req.SetBasicAuth("client-1", "client-secret")
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			if tc.mockFunc != nil {
+				tc.mockFunc(mockOAuthSvc)
+			}
+
+			err := handler.MiddlewareError(handler.OauthToken)(c)
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
+
+type testcaseAPIKey struct {
+	name          string
+	reqBody       string
+	mockFunc      func(apiKeySvc *port.MockAPIKeyService, authSvc *port.MockAuthService)
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_AdminCreateApiKey(t *testing.T) {
+	const (
+		adminID  = "abcd-abcd-abcd-abcd"
+		apiKeyID = "0d6f3c2a-5b7e-4a19-9c3d-2e8f1a4b6c70"
+	)
+
+	testcases := []testcaseAPIKey{
+		{
+			name:    "forbidden api key can not create keys",
+			reqBody: `{"name": "billing export", "scopes": ["admin"]}`,
+			mockFunc: func(apiKeySvc *port.MockAPIKeyService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: adminID, Role: cons.RoleAdmin, Scope: cons.ScopeUser, APIKeyID: apiKeyID}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:    "forbidden not an admin",
+			reqBody: `{"name": "billing export", "scopes": ["admin"]}`,
+			mockFunc: func(apiKeySvc *port.MockAPIKeyService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: adminID, Role: cons.RoleUser, SessionID: "s"}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+			},
+		},
+		{
+			name:    "unprocessable invalid scope",
+			reqBody: `{"name": "billing export", "scopes": ["mfa"]}`,
+			mockFunc: func(apiKeySvc *port.MockAPIKeyService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: adminID, Role: cons.RoleAdmin, SessionID: "s"}, nil).
+					Times(1)
+
+				apiKeySvc.EXPECT().
+					CreateAPIKey(gomock.Any()).
+					Return(nil, fmt.Errorf("%w: mfa", cons.ErrInvalidAPIKeyScope)).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+			},
+		},
+		{
+			name:    "success create key owned by caller",
+			reqBody: `{"name": "billing export", "scopes": ["admin"]}`,
+			mockFunc: func(apiKeySvc *port.MockAPIKeyService, authSvc *port.MockAuthService) {
+				authSvc.EXPECT().
+					VerifyAuthHeader(gomock.Any(), cons.ScopeUser).
+					Return(&domain.TokenClaims{UserID: adminID, Role: cons.RoleAdmin, SessionID: "s"}, nil).
+					Times(1)
+
+				apiKeySvc.EXPECT().
+					CreateAPIKey(&domain.APIKey{
+						Name:   "billing export",
+						UserID: adminID,
+						Scopes: []string{cons.ScopeAdmin},
+					}).
+					Return(&domain.APIKey{
+						ID:     apiKeyID,
+						Name:   "billing export",
+						UserID: adminID,
+						Prefix: "a1b2c3d4e5f6",
+						Scopes: []string{cons.ScopeAdmin},
+						Key:    cons.APIKeyPrefix + "a1b2c3d4e5f6_secret",
+					}, nil).
+					Times(1)
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusCreated))
+
+				resp := generated.APIKey{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Id).To(Equal(apiKeyID))
+				Expect(resp.UserId).To(Equal(adminID))
+				Expect(resp.Key).NotTo(BeNil())
+				Expect(*resp.Key).To(Equal(cons.APIKeyPrefix + "a1b2c3d4e5f6_secret"))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			mockCtrl := gomock.NewController(t)
+			defer mockCtrl.Finish()
+
+			mockAPIKeySvc := port.NewMockAPIKeyService(mockCtrl)
+			mockAuthSvc := port.NewMockAuthService(mockCtrl)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, mockAuthSvc, nil, nil, mockAPIKeySvc, "")
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/api-keys", strings.NewReader(tc.reqBody))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			tc.mockFunc(mockAPIKeySvc, mockAuthSvc)
+
+			err := withAuth(handler, c, "/api/v1/admin/api-keys", func(c echo.Context) error {
+				return handler.AdminCreateApiKey(c)
+			})
 			tc.assertionFunc(rec, err)
 		})
 	}
@@ -0,0 +1,55 @@
+package sawithttp
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+)
+
+// userETag is the strong entity tag of a user profile at the given version.
+func userETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseIfMatch returns the profile version an update is conditioned on, 0
+// when the update is unconditional. Only a single strong ETag can be checked
+// atomically, anything else can never match and fails the precondition.
+func parseIfMatch(value *string) (int, error) {
+	if value == nil {
+		return 0, nil
+	}
+
+	tag := strings.TrimSpace(*value)
+	if tag == "*" {
+		return 0, nil
+	}
+
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, cons.ErrVersionMismatch
+	}
+
+	version, err := strconv.Atoi(tag[1 : len(tag)-1])
+	if err != nil || version < 1 {
+		return 0, cons.ErrVersionMismatch
+	}
+
+	return version, nil
+}
+
+// matchIfNoneMatch reports whether etag is listed in an If-None-Match header,
+// using the weak comparison required for GET.
+func matchIfNoneMatch(value *string, etag string) bool {
+	if value == nil {
+		return false
+	}
+
+	for _, tag := range strings.Split(*value, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package sawithttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// credentialRoutes return tokens or secrets, their responses are never
+// stored for replay.
+var credentialRoutes = []string{
+	"/users/login",
+	"/users/login/mfa",
+	"/users/:id/mfa/totp",
+	"/users/:id/mfa/totp/confirm",
+	"/admin/webhooks",
+	"/admin/oauth/clients",
+	"/admin/api-keys",
+	"/oauth/authorize",
+	"/oauth/token",
+	"/oauth/introspect",
+}
+
+// MiddlewareIdempotency replays the stored response of a POST retried with
+// the same Idempotency-Key. It has to run after the auth middleware, keys are
+// scoped to the principal, and renders errors itself so the stored response
+// includes them.
+func (h *Handler) MiddlewareIdempotency(svc port.IdempotencyService) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			key := c.Request().Header.Get(headerIdempotencyKey)
+			if key == "" || c.Request().Method != http.MethodPost || isCredentialRoute(c.Path()) {
+				return next(c)
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				return h.renderError(c, cons.ErrInvalidRequest.WithField(
+					headerIdempotencyKey,
+					"idempotency key is too long",
+				))
+			}
+
+			body, err := io.ReadAll(c.Request().Body)
+			if err != nil {
+				return h.renderError(c, echo.NewHTTPError(
+					http.StatusBadRequest,
+					err.Error(),
+				))
+			}
+			c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+			rec := &domain.IdempotencyRecord{
+				Key:         key,
+				Fingerprint: requestFingerprint(c.Request(), body),
+			}
+			rec.Caller = requestCaller(c, rec.Fingerprint)
+			replay, err := svc.Begin(rec)
+			if err != nil {
+				return h.renderError(c, err)
+			}
+
+			if replay != nil {
+				c.Response().Header().Set(headerIdempotentReplayed, "true")
+				if len(replay.Body) == 0 {
+					return c.NoContent(replay.StatusCode)
+				}
+
+				return c.Blob(replay.StatusCode, replay.ContentType, replay.Body)
+			}
+
+			writer := c.Response().Writer
+			recorder := &bodyRecorder{ResponseWriter: writer}
+			c.Response().Writer = recorder
+			err = next(c)
+			if err != nil {
+				err = h.renderError(c, err)
+			}
+			c.Response().Writer = writer
+
+			// server errors are not the outcome of the request, the key is
+			// released so the client can retry
+			if err != nil || c.Response().Status >= http.StatusInternalServerError {
+				if relErr := svc.Release(rec); relErr != nil {
+					h.makeLogEntry(c).WithError(relErr).Error("error release idempotency key")
+				}
+
+				return err
+			}
+
+			rec.StatusCode = c.Response().Status
+			rec.ContentType = c.Response().Header().Get(echo.HeaderContentType)
+			rec.Body = recorder.body.Bytes()
+			if err = svc.Complete(rec); err != nil {
+				h.makeLogEntry(c).WithError(err).Error("error store idempotent response")
+			}
+
+			return nil
+		}
+	}
+}
+
+// requestCaller scopes the keys to the authenticated user, whatever token or
+// API key it signed in with. Anonymous keys are scoped to the request itself,
+// they are only replayed to the very same request.
+func requestCaller(c echo.Context, fingerprint string) string {
+	claims, err := Principal(c)
+	if err != nil {
+		return "anonymous:" + fingerprint
+	}
+
+	return "user:" + claims.UserID
+}
+
+// requestFingerprint identifies a request, a key reused for another request
+// of the same caller is rejected.
+func requestFingerprint(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	for _, part := range []string{req.Method, req.URL.Path} {
+		hash.Write([]byte(part))
+		hash.Write([]byte("\n"))
+	}
+	hash.Write(body)
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func isCredentialRoute(path string) bool {
+	for _, route := range credentialRoutes {
+		if strings.HasSuffix(path, route) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bodyRecorder keeps a copy of the response body while writing it through.
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -1,14 +1,35 @@
 package sawithttp
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/SawitProRecruitment/UserService/cons"
 	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 	log "github.com/sirupsen/logrus"
 )
 
+// maxRequestIDLength bounds request ids supplied by callers.
+const maxRequestIDLength = 128
+
+// MiddlewareRequestID keeps the X-Request-ID of the caller or generates one,
+// it is echoed in the response and recorded in the audit log.
+func (h *Handler) MiddlewareRequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		requestID := c.Request().Header.Get(echo.HeaderXRequestID)
+		if requestID == "" || len(requestID) > maxRequestIDLength {
+			requestID = uuid.NewString()
+		}
+
+		c.Response().Header().Set(echo.HeaderXRequestID, requestID)
+		return next(c)
+	}
+}
+
 func (h *Handler) MiddlewareLogging(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		h.makeLogEntry(c).Info("incoming request")
@@ -20,40 +41,92 @@ func (h *Handler) MiddlewareError(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		err := next(c)
 		if err != nil {
-			report, ok := err.(*echo.HTTPError)
-			if !ok {
-				resp := generated.ErrorResponse{
-					Message: http.StatusText(http.StatusInternalServerError),
-					Details: []string{
-						err.Error(),
-					},
-				}
-				return c.JSON(http.StatusInternalServerError, resp)
-			}
-
-			msg := http.StatusText(report.Code)
-			details := make([]string, 0)
-			if errs, is := report.Message.(interface{ Unwrap() []error }); is {
-				for _, err := range errs.Unwrap() {
-					details = append(details, err.Error())
-				}
-			} else {
-				details = append(details, report.Message.(string))
-			}
-
-			resp := generated.ErrorResponse{
-				Message: msg,
-				Details: details,
-			}
-
-			h.makeLogEntry(c).Error(report.Message)
-			return c.JSON(report.Code, resp)
+			return h.renderError(c, err)
 		}
 
 		return nil
 	}
 }
 
+// renderError writes err with the status of the cons.Error it is, as an
+// ErrorResponse or as a problem document to clients asking for one. Errors of
+// echo keep their status, anything else is an internal error whose message is
+// only logged.
+func (h *Handler) renderError(c echo.Context, err error) error {
+	status, code, errs := resolveError(err)
+	h.makeLogEntry(c).WithField("code", code).Error(err)
+
+	header := c.Response().Header()
+	if !strings.Contains(header.Get(echo.HeaderVary), echo.HeaderAccept) {
+		header.Add(echo.HeaderVary, echo.HeaderAccept)
+	}
+	if acceptsProblem(c.Request().Header.Get(echo.HeaderAccept)) {
+		return h.renderProblem(c, status, code, errs)
+	}
+
+	resp := generated.ErrorResponse{
+		Code:    code,
+		Message: http.StatusText(status),
+		Details: make([]string, 0, len(errs)),
+	}
+	for _, e := range errs {
+		resp.Details = append(resp.Details, e.Message)
+	}
+
+	return c.JSON(status, resp)
+}
+
+// resolveError maps err to its status, code and the errors it reports. A
+// multi error reports each of its errors, under the code they share or the
+// one of its status.
+func resolveError(err error) (int, string, []*cons.Error) {
+	errs := []error{err}
+	if multi, is := err.(interface{ Unwrap() []error }); is && len(multi.Unwrap()) > 0 {
+		errs = multi.Unwrap()
+	}
+
+	first := apiError(errs[0])
+	code := first.Code
+	reported := make([]*cons.Error, 0, len(errs))
+	for _, err := range errs {
+		e := apiError(err)
+		if e.Code != code {
+			code = cons.StatusCode(first.Status)
+		}
+
+		reported = append(reported, e)
+	}
+
+	return first.Status, code, reported
+}
+
+// apiError resolves err to the cons.Error it is answered as, with the
+// message shown to callers.
+func apiError(err error) *cons.Error {
+	var report *echo.HTTPError
+	if errors.As(err, &report) {
+		msg := http.StatusText(report.Code)
+		switch m := report.Message.(type) {
+		case string:
+			msg = m
+		case error:
+			msg = m.Error()
+		}
+		if report.Code >= http.StatusInternalServerError {
+			msg = http.StatusText(report.Code)
+		}
+
+		return cons.NewError(report.Code, cons.StatusCode(report.Code), "", msg)
+	}
+
+	var e *cons.Error
+	if errors.As(err, &e) {
+		return cons.NewError(e.Status, e.Code, e.Field, err.Error())
+	}
+
+	return cons.ErrInternal
+}
+
 func (h *Handler) makeLogEntry(c echo.Context) *log.Entry {
 	const timeFormat = "2006-01-02 15:04:05"
 	if c == nil {
@@ -63,9 +136,14 @@ func (h *Handler) makeLogEntry(c echo.Context) *log.Entry {
 	}
 
 	return h.logger.WithFields(log.Fields{
-		"at":     time.Now().Format(timeFormat),
-		"method": c.Request().Method,
-		"uri":    c.Request().URL.String(),
-		"ip":     c.Request().RemoteAddr,
+		"at":         time.Now().Format(timeFormat),
+		"method":     c.Request().Method,
+		"uri":        c.Request().URL.String(),
+		"ip":         c.Request().RemoteAddr,
+		"request_id": requestID(c),
 	})
 }
+
+func requestID(c echo.Context) string {
+	return c.Response().Header().Get(echo.HeaderXRequestID)
+}
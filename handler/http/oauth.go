@@ -0,0 +1,292 @@
+package sawithttp
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) OauthIntrospect(ctx echo.Context) error {
+	clientID, clientSecret, ok := clientCredentials(ctx)
+	if !ok {
+		return invalidClient(ctx)
+	}
+
+	err := h.authSvc.AuthenticateClient(clientID, clientSecret)
+	if err != nil {
+		return invalidClient(ctx)
+	}
+
+	token := ctx.FormValue("token")
+	if token == "" {
+		return cons.ErrInvalidRequest.WithField("token", "token is required")
+	}
+
+	data, err := h.authSvc.Introspect(token)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.IntrospectionResponse{
+		Active: data.Active,
+	}
+	if data.Active {
+		tokenType := data.TokenType
+		resp.Sub = &data.Subject
+		resp.Scope = &data.Scope
+		resp.TokenType = &tokenType
+		resp.Exp = &data.ExpiresAt
+		resp.Iat = &data.IssuedAt
+		resp.Iss = &data.Issuer
+		resp.Aud = &data.Audience
+		resp.Sid = &data.SessionID
+		if data.ClientID != "" {
+			resp.ClientId = &data.ClientID
+		}
+	}
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// clientCredentials reads the client id and secret from HTTP Basic, where
+// both are form encoded, or else from the form parameters.
+func clientCredentials(ctx echo.Context) (string, string, bool) {
+	clientID, clientSecret, ok := ctx.Request().BasicAuth()
+	if !ok {
+		clientID, clientSecret = ctx.FormValue("client_id"), ctx.FormValue("client_secret")
+		return clientID, clientSecret, clientID != ""
+	}
+
+	clientID, err := url.QueryUnescape(clientID)
+	if err != nil {
+		return "", "", false
+	}
+
+	clientSecret, err = url.QueryUnescape(clientSecret)
+	if err != nil {
+		return "", "", false
+	}
+
+	return clientID, clientSecret, true
+}
+
+func invalidClient(ctx echo.Context) error {
+	ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+	return cons.ErrInvalidClient
+}
+
+func (h *Handler) OauthToken(ctx echo.Context) error {
+	_, _, basic := ctx.Request().BasicAuth()
+	clientID, clientSecret, ok := clientCredentials(ctx)
+	if !ok {
+		return tokenError(ctx, cons.ErrInvalidClient, basic)
+	}
+
+	data, err := h.oauthSvc.Exchange(&domain.TokenRequest{
+		GrantType:    ctx.FormValue("grant_type"),
+		Code:         ctx.FormValue("code"),
+		RedirectURI:  ctx.FormValue("redirect_uri"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CodeVerifier: ctx.FormValue("code_verifier"),
+	})
+	if err != nil {
+		return tokenError(ctx, err, basic)
+	}
+
+	resp := generated.TokenResponse{
+		AccessToken: data.AccessToken,
+		TokenType:   data.TokenType,
+		ExpiresIn:   data.ExpiresIn,
+		Scope:       data.Scope,
+	}
+	if data.IDToken != "" {
+		resp.IdToken = &data.IDToken
+	}
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) OauthUserinfo(ctx echo.Context) error {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return err
+	}
+
+	data, err := h.oauthSvc.UserInfo(claims)
+	if err != nil {
+		if errors.Is(err, cons.ErrTokenScope) {
+			ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="insufficient_scope", scope="openid"`)
+		}
+
+		return err
+	}
+
+	resp := generated.UserInfoResponse{
+		Sub: data.Subject,
+	}
+	if data.Name != "" {
+		resp.Name = &data.Name
+	}
+	if data.PhoneNumber != "" {
+		resp.PhoneNumber = &data.PhoneNumber
+	}
+
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) OauthJwks(ctx echo.Context) error {
+	keys, err := h.authSvc.JWKS()
+	if err != nil {
+		return err
+	}
+
+	resp := generated.JWKSResponse{
+		Keys: make([]generated.JWK, 0, len(keys)),
+	}
+	for _, k := range keys {
+		resp.Keys = append(resp.Keys, generated.JWK{
+			Kty: k.KeyType,
+			Use: k.Use,
+			Alg: k.Algorithm,
+			Kid: k.KeyID,
+			N:   k.Modulus,
+			E:   k.Exponent,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) OauthOpenIDConfiguration(ctx echo.Context) error {
+	data := h.oauthSvc.Discovery()
+
+	return ctx.JSON(http.StatusOK, generated.OpenIDConfiguration{
+		Issuer:                            data.Issuer,
+		AuthorizationEndpoint:             data.AuthorizationEndpoint,
+		TokenEndpoint:                     data.TokenEndpoint,
+		UserinfoEndpoint:                  data.UserinfoEndpoint,
+		JwksUri:                           data.JWKSURI,
+		IntrospectionEndpoint:             data.IntrospectionEndpoint,
+		ScopesSupported:                   data.ScopesSupported,
+		ResponseTypesSupported:            data.ResponseTypesSupported,
+		GrantTypesSupported:               data.GrantTypesSupported,
+		SubjectTypesSupported:             data.SubjectTypesSupported,
+		IdTokenSigningAlgValuesSupported:  data.IDTokenSigningAlgValuesSupported,
+		TokenEndpointAuthMethodsSupported: data.TokenEndpointAuthMethodsSupported,
+		CodeChallengeMethodsSupported:     data.CodeChallengeMethodsSupported,
+		ClaimsSupported:                   data.ClaimsSupported,
+	})
+}
+
+func (h *Handler) AdminCreateOAuthClient(ctx echo.Context) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	req := generated.OAuthClientRequest{}
+	err := ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	data := &domain.OAuthClient{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectUris,
+	}
+	if req.Confidential != nil {
+		data.Confidential = *req.Confidential
+	}
+
+	res, err := h.oauthSvc.RegisterClient(data)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusCreated, oauthClientResponse(res))
+}
+
+func (h *Handler) AdminListOAuthClients(ctx echo.Context) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	data, err := h.oauthSvc.ListClients()
+	if err != nil {
+		return err
+	}
+
+	resp := generated.OAuthClientListResponse{
+		Clients: make([]generated.OAuthClient, 0, len(data)),
+	}
+	for i := range data {
+		resp.Clients = append(resp.Clients, oauthClientResponse(&data[i]))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminDeleteOAuthClient(ctx echo.Context, id uuid.UUID) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	err := h.oauthSvc.DeleteClient(id.String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+// tokenError renders err as the error response of RFC 6749 section 5.2,
+// basic tells whether the client tried to authenticate with HTTP Basic.
+func tokenError(ctx echo.Context, err error, basic bool) error {
+	status, code := http.StatusBadRequest, ""
+	switch {
+	case errors.Is(err, cons.ErrInvalidClient):
+		status, code = http.StatusUnauthorized, "invalid_client"
+		if basic {
+			ctx.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="oauth"`)
+		}
+	case errors.Is(err, cons.ErrInvalidGrant):
+		code = "invalid_grant"
+	case errors.Is(err, cons.ErrUnsupportedGrantType):
+		code = "unsupported_grant_type"
+	case errors.Is(err, cons.ErrInvalidOAuthRequest):
+		code = "invalid_request"
+	default:
+		return err
+	}
+
+	description := err.Error()
+	ctx.Response().Header().Set(echo.HeaderCacheControl, "no-store")
+	return ctx.JSON(status, generated.OAuthErrorResponse{
+		Error:            code,
+		ErrorDescription: &description,
+	})
+}
+
+func oauthClientResponse(data *domain.OAuthClient) generated.OAuthClient {
+	resp := generated.OAuthClient{
+		Id:           data.ID,
+		Name:         data.Name,
+		RedirectUris: data.RedirectURIs,
+		Confidential: data.Confidential,
+		CreatedAt:    derefTime(data.CreatedAt),
+	}
+	if data.Secret != "" {
+		resp.Secret = &data.Secret
+	}
+
+	return resp
+}
@@ -0,0 +1,95 @@
+package sawithttp
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	mimeApplicationProblemJSON = "application/problem+json"
+
+	// problemTypeBlank is the type of the problems with no more meaning
+	// than their status, RFC 7807 section 4.2
+	problemTypeBlank = "about:blank"
+)
+
+// acceptsProblem tells whether the Accept header of a client prefers
+// application/problem+json, it has to be listed with a quality not lower
+// than the one of application/json. Clients accepting anything get an
+// ErrorResponse.
+func acceptsProblem(accept string) bool {
+	problem, legacy := 0.0, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		switch mediaType {
+		case mimeApplicationProblemJSON:
+			problem = q
+		case echo.MIMEApplicationJSON:
+			legacy = q
+		}
+	}
+
+	return problem > 0 && problem >= legacy
+}
+
+// renderProblem writes errs as an RFC 7807 problem document. The errors
+// about a request field are listed in invalid-params, with the field name
+// their message starts with left out of the reason.
+func (h *Handler) renderProblem(c echo.Context, status int, code string, errs []*cons.Error) error {
+	problemType := problemTypeBlank
+	if h.problemTypeBase != "" && code != cons.StatusCode(status) {
+		problemType = h.problemTypeBase + code
+	}
+
+	msgs := make([]string, 0, len(errs))
+	params := make([]generated.InvalidParam, 0, len(errs))
+	for _, e := range errs {
+		msgs = append(msgs, e.Message)
+		if e.Field != "" {
+			params = append(params, generated.InvalidParam{
+				Name:   e.Field,
+				Reason: strings.TrimPrefix(e.Message, e.Field+": "),
+			})
+		}
+	}
+
+	detail := strings.Join(msgs, "; ")
+	instance := c.Request().URL.Path
+	resp := generated.ProblemDetails{
+		Type:     problemType,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   &detail,
+		Instance: &instance,
+		Code:     code,
+	}
+	if len(params) > 0 {
+		resp.InvalidParams = &params
+	}
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	return c.Blob(status, mimeApplicationProblemJSON, body)
+}
@@ -0,0 +1,149 @@
+package sawithttp_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	sawithttp "github.com/SawitProRecruitment/UserService/handler/http"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+// problemBody decodes the problem document rendered by MiddlewareError.
+func problemBody(recorder *httptest.ResponseRecorder) generated.ProblemDetails {
+	resp := generated.ProblemDetails{}
+	Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+	return resp
+}
+
+type testcaseProblem struct {
+	name          string
+	accept        string
+	typeBase      string
+	err           error
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_MiddlewareErrorProblem(t *testing.T) {
+	testcases := []testcaseProblem{
+		{
+			name:   "legacy error response by default",
+			accept: "*/*",
+			err:    cons.ErrUserNotFound,
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				Expect(recorder.Header().Get(echo.HeaderContentType)).To(HavePrefix(echo.MIMEApplicationJSON))
+				Expect(recorder.Header().Get(echo.HeaderVary)).To(Equal(echo.HeaderAccept))
+				Expect(errorBody(recorder).Code).To(Equal(cons.ErrUserNotFound.Code))
+			},
+		},
+		{
+			name:   "legacy error response preferred by quality",
+			accept: "application/problem+json;q=0.5, application/json",
+			err:    cons.ErrUserNotFound,
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Header().Get(echo.HeaderContentType)).To(HavePrefix(echo.MIMEApplicationJSON))
+			},
+		},
+		{
+			name:   "problem document when accepted",
+			accept: "application/problem+json",
+			err:    cons.ErrUserNotFound,
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				Expect(recorder.Header().Get(echo.HeaderContentType)).To(Equal("application/problem+json"))
+				Expect(recorder.Body.String()).To(MatchJSON(`{
+					"type": "about:blank",
+					"title": "Not Found",
+					"status": 404,
+					"detail": "error user not found",
+					"instance": "/api/v1/users/9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+					"code": "user_not_found"
+				}`))
+			},
+		},
+		{
+			name:     "problem type built from the code",
+			accept:   "application/problem+json, application/json",
+			typeBase: "https://errors.example.com/",
+			err:      cons.ErrUserNotFound,
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(problemBody(recorder).Type).To(Equal("https://errors.example.com/user_not_found"))
+			},
+		},
+		{
+			name:     "problem type blank for errors only known by their status",
+			accept:   "application/problem+json",
+			typeBase: "https://errors.example.com/",
+			err:      echo.ErrMethodNotAllowed,
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(problemBody(recorder).Type).To(Equal("about:blank"))
+				Expect(problemBody(recorder).Code).To(Equal("method_not_allowed"))
+			},
+		},
+		{
+			name:   "invalid params from multi error",
+			accept: "application/problem+json",
+			err: fmt.Errorf("%w; %w",
+				cons.ErrInvalidRequest.WithField("full_name", "full_name: property \"full_name\" is missing"),
+				cons.ErrInvalidPhonePrefix,
+			),
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				resp := problemBody(recorder)
+				Expect(resp.Code).To(Equal("bad_request"))
+				Expect(*resp.InvalidParams).To(Equal([]generated.InvalidParam{
+					{Name: "full_name", Reason: "property \"full_name\" is missing"},
+					{Name: "phone_number", Reason: cons.ErrInvalidPhonePrefix.Error()},
+				}))
+			},
+		},
+		{
+			name:   "internal error not leaked",
+			accept: "application/problem+json",
+			err:    errors.New("dial tcp 10.0.0.5:5432: connection refused"),
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+				resp := problemBody(recorder)
+				Expect(*resp.Detail).To(Equal(cons.ErrInternal.Error()))
+				Expect(resp.InvalidParams).To(BeNil())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, nil, nil, nil, nil, tc.typeBase)
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users/9ae8810c-7b28-4c4c-8dbc-ed43be3da208", nil)
+			req.Header.Set(echo.HeaderAccept, tc.accept)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+
+			err := handler.MiddlewareError(func(c echo.Context) error {
+				return tc.err
+			})(c)
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
@@ -10,10 +10,17 @@ import (
 var _ generated.ServerInterface = (*Handler)(nil)
 
 type Handler struct {
-	logger    *logrus.Logger
-	libLocker *locker.Locker
-	userSvc   port.UserService
-	authSvc   port.AuthService
+	logger     *logrus.Logger
+	libLocker  *locker.Locker
+	userSvc    port.UserService
+	authSvc    port.AuthService
+	webhookSvc port.WebhookService
+	oauthSvc   port.OAuthService
+	apiKeySvc  port.APIKeyService
+
+	// problemTypeBase prefixes the code of an error to build the type of
+	// its problem document
+	problemTypeBase string
 }
 
 func NewHandler(
@@ -21,11 +28,20 @@ func NewHandler(
 	libLocker *locker.Locker,
 	userSvc port.UserService,
 	authSvc port.AuthService,
+	webhookSvc port.WebhookService,
+	oauthSvc port.OAuthService,
+	apiKeySvc port.APIKeyService,
+	problemTypeBase string,
 ) *Handler {
 	return &Handler{
-		logger:    logger,
-		libLocker: libLocker,
-		userSvc:   userSvc,
-		authSvc:   authSvc,
+		logger:     logger,
+		libLocker:  libLocker,
+		userSvc:    userSvc,
+		authSvc:    authSvc,
+		webhookSvc: webhookSvc,
+		oauthSvc:   oauthSvc,
+		apiKeySvc:  apiKeySvc,
+
+		problemTypeBase: problemTypeBase,
 	}
 }
@@ -0,0 +1,38 @@
+package sawithttp
+
+import (
+	"regexp"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/labstack/echo/v4"
+)
+
+// pathParam matches the {param} of an api.yml path.
+var pathParam = regexp.MustCompile(`{([^}/]+)}`)
+
+// specRoutes maps "METHOD route" of the echo routes registered under baseURL
+// to their operation in spec, echo has already matched the request so spec
+// needs no router of its own.
+func specRoutes(spec *openapi3.T, baseURL string) map[string]*routers.Route {
+	routes := map[string]*routers.Route{}
+	for path, item := range spec.Paths {
+		route := baseURL + pathParam.ReplaceAllString(path, ":$1")
+		for method, op := range item.Operations() {
+			routes[method+" "+route] = &routers.Route{
+				Spec:      spec,
+				Path:      path,
+				PathItem:  item,
+				Method:    method,
+				Operation: op,
+			}
+		}
+	}
+
+	return routes
+}
+
+// specRouteKey is the key of the route c was matched to in specRoutes.
+func specRouteKey(c echo.Context) string {
+	return c.Request().Method + " " + c.Path()
+}
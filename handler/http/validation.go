@@ -0,0 +1,245 @@
+package sawithttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	errorResponseRef  = "#/components/schemas/ErrorResponse"
+	problemDetailsRef = "#/components/schemas/ProblemDetails"
+)
+
+func init() {
+	// uuid is not a format kin-openapi knows, it is checked the way the
+	// generated handlers parse it
+	openapi3.DefineStringFormatCallback("uuid", func(value string) error {
+		_, err := uuid.Parse(value)
+		return err
+	})
+
+	// the login page of /oauth/authorize is checked as plain text
+	openapi3filter.RegisterBodyDecoder(echo.MIMETextHTML, openapi3filter.RegisteredBodyDecoder(echo.MIMETextPlain))
+
+	// kin-openapi decodes form fields left out as null, which optional
+	// fields are not allowed to be
+	form := openapi3filter.RegisteredBodyDecoder(echo.MIMEApplicationForm)
+	openapi3filter.RegisterBodyDecoder(echo.MIMEApplicationForm, func(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (interface{}, error) {
+		value, err := form(body, header, schema, encFn)
+		if obj, ok := value.(map[string]interface{}); ok {
+			for name, field := range obj {
+				if field == nil {
+					delete(obj, name)
+				}
+			}
+		}
+
+		return value, err
+	})
+}
+
+// MiddlewareRequestValidation checks requests against their operation in
+// spec, malformed ones are refused before reaching the handler with a detail
+// per field. Operations answering bad requests with another schema than
+// ErrorResponse, like the OAuth endpoints, report them on their own. It has
+// to run inside MiddlewareAuth, authentication is left to it and
+// unauthenticated requests are refused first.
+func (h *Handler) MiddlewareRequestValidation(spec *openapi3.T, baseURL string) echo.MiddlewareFunc {
+	routes := specRoutes(spec, baseURL)
+	for key, route := range routes {
+		if !answersErrorResponse(route.Operation) {
+			delete(routes, key)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route, ok := routes[specRouteKey(c)]
+			if !ok {
+				return next(c)
+			}
+
+			err := openapi3filter.ValidateRequest(c.Request().Context(), requestValidationInput(c, route))
+			if err != nil {
+				return validationErrors(cons.ErrInvalidRequest, "request", err)
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// MiddlewareResponseValidation checks responses against their operation in
+// spec and turns the ones drifting from it into a server error listing why.
+// It holds every response back until it is checked, so it is meant for dev
+// and test. It has to run outside MiddlewareError to check rendered errors.
+func (h *Handler) MiddlewareResponseValidation(spec *openapi3.T, baseURL string) echo.MiddlewareFunc {
+	routes := specRoutes(spec, baseURL)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			route, ok := routes[specRouteKey(c)]
+			if !ok {
+				return next(c)
+			}
+
+			res := c.Response()
+			writer := res.Writer
+			buffer := &responseBuffer{ResponseWriter: writer}
+			res.Writer = buffer
+			err := next(c)
+			res.Writer = writer
+			if err != nil || !res.Committed {
+				return err
+			}
+
+			input := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: requestValidationInput(c, route),
+				Status:                 res.Status,
+				Header:                 res.Header(),
+				Options:                validationOptions(),
+			}
+			input.SetBodyBytes(buffer.body.Bytes())
+
+			err = openapi3filter.ValidateResponse(c.Request().Context(), input)
+			if err != nil {
+				res.Committed = false
+				return h.renderError(c, validationErrors(cons.ErrInvalidResponse, "response", err))
+			}
+
+			writer.WriteHeader(res.Status)
+			_, err = writer.Write(buffer.body.Bytes())
+			return err
+		}
+	}
+}
+
+// answersErrorResponse tells whether op answers bad requests with an
+// ErrorResponse or its problem document, or does not document them at all.
+func answersErrorResponse(op *openapi3.Operation) bool {
+	res := op.Responses.Get(http.StatusBadRequest)
+	if res == nil || res.Value == nil {
+		return true
+	}
+
+	for _, media := range res.Value.Content {
+		if media.Schema == nil || (media.Schema.Ref != errorResponseRef && media.Schema.Ref != problemDetailsRef) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestValidationInput(c echo.Context, route *routers.Route) *openapi3filter.RequestValidationInput {
+	params := make(map[string]string, len(c.ParamNames()))
+	for i, name := range c.ParamNames() {
+		params[name] = c.ParamValues()[i]
+	}
+
+	return &openapi3filter.RequestValidationInput{
+		Request:    c.Request(),
+		PathParams: params,
+		Route:      route,
+		Options:    validationOptions(),
+	}
+}
+
+// validationOptions report every problem at once and leave requests as they
+// are, defaults are applied by the handlers.
+func validationOptions() *openapi3filter.Options {
+	return &openapi3filter.Options{
+		MultiError:          true,
+		SkipSettingDefaults: true,
+		AuthenticationFunc:  openapi3filter.NoopAuthenticationFunc,
+	}
+}
+
+// fieldErrors are the problems found by validation, each one is a detail of
+// the ErrorResponse.
+type fieldErrors []error
+
+func (e fieldErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e fieldErrors) Unwrap() []error {
+	return e
+}
+
+// validationErrors flattens the errors of kin-openapi into a copy of base
+// per field, named by the parameter or the path to the value in the body.
+func validationErrors(base *cons.Error, field string, err error) fieldErrors {
+	errs := fieldErrors{}
+	add := func(field, reason string) {
+		errs = append(errs, base.WithField(field, fmt.Sprintf("%s: %s", field, reason)))
+	}
+
+	var walk func(field string, err error)
+	walk = func(field string, err error) {
+		switch e := err.(type) {
+		case openapi3.MultiError:
+			for _, err := range e {
+				walk(field, err)
+			}
+		case *openapi3filter.RequestError:
+			switch {
+			case e.Parameter != nil:
+				field = e.Parameter.Name
+			case e.RequestBody != nil:
+				field = "body"
+			}
+
+			if e.Err == nil {
+				add(field, e.Reason)
+				return
+			}
+
+			walk(field, e.Err)
+		case *openapi3filter.ResponseError:
+			if e.Err == nil {
+				add(field, e.Reason)
+				return
+			}
+
+			walk(field, e.Err)
+		case *openapi3.SchemaError:
+			if pointer := e.JSONPointer(); len(pointer) > 0 {
+				field = strings.Join(pointer, ".")
+			}
+
+			add(field, e.Reason)
+		default:
+			add(field, err.Error())
+		}
+	}
+
+	walk(field, err)
+	return errs
+}
+
+// responseBuffer holds the response back until it is validated.
+type responseBuffer struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseBuffer) WriteHeader(int) {}
+
+func (w *responseBuffer) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
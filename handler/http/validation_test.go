@@ -0,0 +1,193 @@
+package sawithttp_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/generated"
+	sawithttp "github.com/SawitProRecruitment/UserService/handler/http"
+	"github.com/labstack/echo/v4"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+type testcaseRequestValidation struct {
+	name          string
+	method        string
+	route         string
+	params        []string
+	contentType   string
+	reqBody       string
+	assertionFunc func(called bool, err error)
+}
+
+func TestHandler_MiddlewareRequestValidation(t *testing.T) {
+	testcases := []testcaseRequestValidation{
+		{
+			name:        "bad request missing fields",
+			method:      http.MethodPost,
+			route:       "/api/v1/users/register",
+			contentType: echo.MIMEApplicationJSON,
+			reqBody:     `{"phone_number": "+6285156305136"}`,
+			assertionFunc: func(called bool, err error) {
+				Expect(called).To(BeFalse())
+				errs := err.(interface{ Unwrap() []error }).Unwrap()
+				Expect(errs).To(HaveLen(2))
+				Expect(errs[0]).To(MatchError(cons.ErrInvalidRequest))
+				Expect(errs[0].Error()).To(HavePrefix("full_name: "))
+				Expect(errs[1].Error()).To(HavePrefix("password: "))
+			},
+		},
+		{
+			name:        "bad request wrong type",
+			method:      http.MethodPatch,
+			route:       "/api/v1/users/:id",
+			params:      []string{"id", "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"},
+			contentType: echo.MIMEApplicationJSON,
+			reqBody:     `{"phone_number": "+6285156305136", "full_name": 5}`,
+			assertionFunc: func(called bool, err error) {
+				Expect(called).To(BeFalse())
+				Expect(err).To(MatchError(cons.ErrInvalidRequest))
+				Expect(err.Error()).To(HavePrefix("full_name: "))
+			},
+		},
+		{
+			name:   "bad request path param not a uuid",
+			method: http.MethodGet,
+			route:  "/api/v1/users/:id",
+			params: []string{"id", "1234-1234-1234-1234"},
+			assertionFunc: func(called bool, err error) {
+				Expect(called).To(BeFalse())
+				Expect(err).To(MatchError(cons.ErrInvalidRequest))
+				Expect(err.Error()).To(HavePrefix("id: "))
+			},
+		},
+		{
+			name:        "valid request reaches the handler",
+			method:      http.MethodPost,
+			route:       "/api/v1/users/register",
+			contentType: echo.MIMEApplicationJSON,
+			reqBody:     `{"phone_number": "+6285156305136", "full_name": "Edison Tantra", "password": "Passw0rd!"}`,
+			assertionFunc: func(called bool, err error) {
+				Expect(err).To(BeNil())
+				Expect(called).To(BeTrue())
+			},
+		},
+		{
+			name:        "oauth errors are left to the handler",
+			method:      http.MethodPost,
+			route:       "/api/v1/oauth/token",
+			contentType: echo.MIMEApplicationForm,
+			reqBody:     `grant_type=authorization_code`,
+			assertionFunc: func(called bool, err error) {
+				Expect(err).To(BeNil())
+				Expect(called).To(BeTrue())
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, nil, nil, nil, nil, "")
+
+			spec, err := generated.GetSwagger()
+			Expect(err).To(BeNil())
+
+			e := echo.New()
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.reqBody))
+			if tc.contentType != "" {
+				req.Header.Set(echo.HeaderContentType, tc.contentType)
+			}
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath(tc.route)
+			if len(tc.params) == 2 {
+				c.SetParamNames(tc.params[0])
+				c.SetParamValues(tc.params[1])
+			}
+
+			called := false
+			err = handler.MiddlewareRequestValidation(spec, "/api/v1")(func(c echo.Context) error {
+				called = true
+				return nil
+			})(c)
+			tc.assertionFunc(called, err)
+		})
+	}
+}
+
+type testcaseResponseValidation struct {
+	name          string
+	next          echo.HandlerFunc
+	assertionFunc func(recorder *httptest.ResponseRecorder, err error)
+}
+
+func TestHandler_MiddlewareResponseValidation(t *testing.T) {
+	testcases := []testcaseResponseValidation{
+		{
+			name: "response matching the contract is written",
+			next: func(c echo.Context) error {
+				return c.JSON(http.StatusOK, generated.UserDetailResponse{
+					Id:          "9ae8810c-7b28-4c4c-8dbc-ed43be3da208",
+					FullName:    "Edison Tantra",
+					PhoneNumber: "+6285156305136",
+				})
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(ContainSubstring("Edison Tantra"))
+			},
+		},
+		{
+			name: "response drifting from the contract is a server error",
+			next: func(c echo.Context) error {
+				return c.JSON(http.StatusOK, map[string]string{"id": "9ae8810c-7b28-4c4c-8dbc-ed43be3da208"})
+			},
+			assertionFunc: func(recorder *httptest.ResponseRecorder, err error) {
+				Expect(err).To(BeNil())
+				Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+
+				resp := generated.ErrorResponse{}
+				Expect(json.Unmarshal(recorder.Body.Bytes(), &resp)).To(Succeed())
+				Expect(resp.Details).To(ConsistOf(
+					HavePrefix("full_name: "),
+					HavePrefix("phone_number: "),
+				))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+
+			logger := logrus.New()
+			logger.SetOutput(io.Discard)
+			handler := sawithttp.NewHandler(logger, nil, nil, nil, nil, nil, nil, "")
+
+			spec, err := generated.GetSwagger()
+			Expect(err).To(BeNil())
+
+			e := echo.New()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			c.SetPath("/api/v1/users/:id")
+			c.SetParamNames("id")
+			c.SetParamValues("9ae8810c-7b28-4c4c-8dbc-ed43be3da208")
+
+			err = handler.MiddlewareResponseValidation(spec, "/api/v1")(tc.next)(c)
+			tc.assertionFunc(rec, err)
+		})
+	}
+}
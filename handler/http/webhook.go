@@ -0,0 +1,191 @@
+package sawithttp
+
+import (
+	"net/http"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+func (h *Handler) AdminCreateWebhook(ctx echo.Context) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	req := generated.WebhookRequest{}
+	err := ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	data, err := h.webhookSvc.CreateWebhook(webhookFromRequest("", req))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusCreated, webhookResponse(data))
+}
+
+func (h *Handler) AdminListWebhooks(ctx echo.Context) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	data, err := h.webhookSvc.ListWebhooks()
+	if err != nil {
+		return err
+	}
+
+	resp := generated.WebhookListResponse{
+		Webhooks: make([]generated.Webhook, 0, len(data)),
+	}
+	for i := range data {
+		resp.Webhooks = append(resp.Webhooks, webhookResponse(&data[i]))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (h *Handler) AdminGetWebhook(ctx echo.Context, id uuid.UUID) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	data, err := h.webhookSvc.GetWebhook(id.String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, webhookResponse(data))
+}
+
+func (h *Handler) AdminUpdateWebhook(ctx echo.Context, id uuid.UUID) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	req := generated.WebhookRequest{}
+	err := ctx.Bind(&req)
+	if err != nil {
+		return err
+	}
+
+	data, err := h.webhookSvc.UpdateWebhook(webhookFromRequest(id.String(), req))
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusOK, webhookResponse(data))
+}
+
+func (h *Handler) AdminDeleteWebhook(ctx echo.Context, id uuid.UUID) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	err := h.webhookSvc.DeleteWebhook(id.String())
+	if err != nil {
+		return err
+	}
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (h *Handler) AdminListWebhookDeliveries(ctx echo.Context, id uuid.UUID, params generated.AdminListWebhookDeliveriesParams) error {
+	if err := h.verifyAdmin(ctx); err != nil {
+		return err
+	}
+
+	page, pageSize := 0, 0
+	if params.Page != nil {
+		page = *params.Page
+	}
+	if params.PageSize != nil {
+		pageSize = *params.PageSize
+	}
+
+	data, err := h.webhookSvc.ListDeliveries(id.String(), page, pageSize)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.WebhookDeliveryLogResponse{
+		Deliveries: make([]generated.WebhookDelivery, 0, len(data.Deliveries)),
+		Page:       data.Page,
+		PageSize:   data.PageSize,
+		Total:      data.Total,
+	}
+	for _, d := range data.Deliveries {
+		delivery := generated.WebhookDelivery{
+			Id:             d.ID,
+			EventId:        d.EventID,
+			EventType:      d.EventType,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+			CreatedAt:      derefTime(d.CreatedAt),
+			DeliveredAt:    d.DeliveredAt,
+		}
+		// only pending deliveries are attempted again
+		if d.Status == cons.WebhookStatusPending {
+			delivery.NextAttemptAt = d.NextAttemptAt
+		}
+
+		resp.Deliveries = append(resp.Deliveries, delivery)
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// verifyAdmin makes sure the request is authorized by an administrator,
+// API keys with the admin scope included.
+func (h *Handler) verifyAdmin(ctx echo.Context) error {
+	_, err := h.authorizeAdmin(ctx)
+	return err
+}
+
+// authorizeAdmin makes sure the principal of the request is an
+// administrator.
+func (h *Handler) authorizeAdmin(ctx echo.Context) (*domain.TokenClaims, error) {
+	claims, err := Principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Role != cons.RoleAdmin {
+		return nil, cons.ErrInvalidAuthorized
+	}
+
+	return claims, nil
+}
+
+func webhookFromRequest(id string, req generated.WebhookRequest) *domain.Webhook {
+	data := &domain.Webhook{
+		ID:         id,
+		URL:        req.Url,
+		EventTypes: req.EventTypes,
+	}
+	if req.Secret != nil {
+		data.Secret = *req.Secret
+	}
+
+	return data
+}
+
+func webhookResponse(data *domain.Webhook) generated.Webhook {
+	resp := generated.Webhook{
+		Id:         data.ID,
+		Url:        data.URL,
+		EventTypes: data.EventTypes,
+		CreatedAt:  derefTime(data.CreatedAt),
+	}
+	if data.Secret != "" {
+		resp.Secret = &data.Secret
+	}
+
+	return resp
+}
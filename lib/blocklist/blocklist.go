@@ -0,0 +1,258 @@
+package blocklist
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Blocklist is a bloom filter of SHA-1 password hashes. A positive answer
+// from Contains may be a false positive, a negative answer is always exact.
+type Blocklist struct {
+	hashCount uint32
+	bitCount  uint64
+	bits      []byte
+}
+
+var (
+	indexMagic   = []byte("SBLF")
+	indexVersion = byte(1)
+
+	ErrIndexFormat = errors.New("invalid blocklist index format")
+)
+
+const (
+	DefaultFalsePositiveRate = 0.001
+
+	headerSize = 4 + 1 + 4 + 8
+)
+
+// SourceFormat tells Build how to read the lines of a source list.
+type SourceFormat string
+
+const (
+	// FormatPlain lists a password per line, taken as is.
+	FormatPlain SourceFormat = "plain"
+	// FormatSHA1 lists a SHA-1 hex digest per line, optionally followed by
+	// ":count" like the HIBP downloads.
+	FormatSHA1 SourceFormat = "sha1"
+)
+
+// Load reads an index file created by Build.
+func Load(path string) (*Blocklist, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	return Read(bufio.NewReader(f), info.Size())
+}
+
+// Read decodes an index of size bytes created by Build from r. The size
+// must match the bit count in the header, so a corrupt header can not make
+// it allocate more than the index holds.
+func Read(r io.Reader, size int64) (*Blocklist, error) {
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrIndexFormat
+	}
+
+	if !bytes.Equal(header[:len(indexMagic)], indexMagic) || header[len(indexMagic)] != indexVersion {
+		return nil, ErrIndexFormat
+	}
+
+	offset := len(indexMagic) + 1
+	b := &Blocklist{
+		hashCount: binary.BigEndian.Uint32(header[offset:]),
+		bitCount:  binary.BigEndian.Uint64(header[offset+4:]),
+	}
+
+	if b.hashCount == 0 || b.bitCount == 0 {
+		return nil, ErrIndexFormat
+	}
+
+	byteCount := b.bitCount / 8
+	if b.bitCount%8 != 0 {
+		byteCount++
+	}
+
+	if size < headerSize || uint64(size-headerSize) != byteCount {
+		return nil, ErrIndexFormat
+	}
+
+	b.bits = make([]byte, byteCount)
+	if _, err := io.ReadFull(r, b.bits); err != nil {
+		return nil, ErrIndexFormat
+	}
+
+	return b, nil
+}
+
+// Build reads a source list of the given format from src and writes the
+// bloom filter index to dst. Empty lines and lines starting with "#" are
+// skipped. It returns the number of indexed entries.
+func Build(src io.ReadSeeker, dst io.Writer, format SourceFormat, falsePositiveRate float64) (int, error) {
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return 0, fmt.Errorf("false positive rate must be between 0 and 1, got %v", falsePositiveRate)
+	}
+
+	var digestFn func(line string) ([]byte, error)
+	switch format {
+	case FormatPlain:
+		digestFn = plainDigest
+	case FormatSHA1:
+		digestFn = sha1Digest
+	default:
+		return 0, fmt.Errorf("unknown source format %q, want %q or %q", format, FormatPlain, FormatSHA1)
+	}
+
+	// first pass only counts entries and validates them to size the filter
+	total := 0
+	err := scanSource(src, digestFn, func(digest []byte) {
+		total++
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = src.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	b := newBlocklist(total, falsePositiveRate)
+	err = scanSource(src, digestFn, b.add)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, 0, headerSize)
+	header = append(header, indexMagic...)
+	header = append(header, indexVersion)
+	header = binary.BigEndian.AppendUint32(header, b.hashCount)
+	header = binary.BigEndian.AppendUint64(header, b.bitCount)
+	if _, err = dst.Write(header); err != nil {
+		return 0, err
+	}
+
+	if _, err = dst.Write(b.bits); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Contains reports whether password, or its lowercase form, is listed.
+func (b *Blocklist) Contains(password string) bool {
+	digest := sha1.Sum([]byte(password))
+	if b.test(digest[:]) {
+		return true
+	}
+
+	lower := strings.ToLower(password)
+	if lower == password {
+		return false
+	}
+
+	digest = sha1.Sum([]byte(lower))
+	return b.test(digest[:])
+}
+
+func newBlocklist(n int, falsePositiveRate float64) *Blocklist {
+	if n < 1 {
+		n = 1
+	}
+
+	// optimal bloom filter sizing: m = -n*ln(p)/ln(2)^2, k = m/n*ln(2)
+	m := math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(n) * math.Ln2)
+	if k < 1 {
+		k = 1
+	}
+
+	bitCount := uint64(m)
+	return &Blocklist{
+		hashCount: uint32(k),
+		bitCount:  bitCount,
+		bits:      make([]byte, (bitCount+7)/8),
+	}
+}
+
+// positions derives the bit positions of digest with double hashing, the
+// SHA-1 digest is already uniformly distributed so no extra hashing is needed.
+func (b *Blocklist) positions(digest []byte, fn func(pos uint64) bool) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	for i := uint64(0); i < uint64(b.hashCount); i++ {
+		if !fn((h1 + i*h2) % b.bitCount) {
+			return
+		}
+	}
+}
+
+func (b *Blocklist) add(digest []byte) {
+	b.positions(digest, func(pos uint64) bool {
+		b.bits[pos/8] |= 1 << (pos % 8)
+		return true
+	})
+}
+
+func (b *Blocklist) test(digest []byte) bool {
+	found := true
+	b.positions(digest, func(pos uint64) bool {
+		found = b.bits[pos/8]&(1<<(pos%8)) != 0
+		return found
+	})
+
+	return found
+}
+
+func scanSource(r io.Reader, digestFn func(line string) ([]byte, error), fn func(digest []byte)) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		digest, err := digestFn(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+
+		fn(digest)
+	}
+
+	return scanner.Err()
+}
+
+func plainDigest(line string) ([]byte, error) {
+	digest := sha1.Sum([]byte(line))
+	return digest[:], nil
+}
+
+func sha1Digest(line string) ([]byte, error) {
+	if idx := strings.IndexByte(line, ':'); idx >= 0 {
+		line = line[:idx]
+	}
+
+	digest, err := hex.DecodeString(line)
+	if err != nil || len(digest) != sha1.Size {
+		return nil, errors.New("not a SHA-1 hex digest")
+	}
+
+	return digest, nil
+}
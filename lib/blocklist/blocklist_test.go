@@ -0,0 +1,129 @@
+package blocklist_test
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/lib/blocklist"
+	. "github.com/onsi/gomega"
+)
+
+func TestBuildAndRead(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	source := strings.Join([]string{
+		"# common passwords",
+		"password1!",
+		"qwerty",
+		"",
+	}, "\n")
+
+	var index bytes.Buffer
+	total, err := blocklist.Build(strings.NewReader(source), &index, blocklist.FormatPlain, blocklist.DefaultFalsePositiveRate)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(2))
+
+	list, err := blocklist.Read(&index, int64(index.Len()))
+	Expect(err).To(BeNil())
+	Expect(list.Contains("password1!")).To(BeTrue())
+	Expect(list.Contains("Password1!")).To(BeTrue())
+	Expect(list.Contains("qwerty")).To(BeTrue())
+	Expect(list.Contains("N0t-In-The-L1st")).To(BeFalse())
+}
+
+func TestBuildFormat(t *testing.T) {
+	digest := sha1.Sum([]byte("Sup3rSecret!"))
+	hexDigest := strings.ToUpper(hex.EncodeToString(digest[:]))
+
+	testCases := []struct {
+		name     string
+		format   blocklist.SourceFormat
+		source   string
+		listed   string
+		unlisted string
+		err      string
+	}{
+		{
+			name:     "sha1 digests with counts",
+			format:   blocklist.FormatSHA1,
+			source:   hexDigest + ":42\n",
+			listed:   "Sup3rSecret!",
+			unlisted: hexDigest,
+		},
+		{
+			name:     "plain password looking like a digest",
+			format:   blocklist.FormatPlain,
+			source:   hexDigest + "\n",
+			listed:   hexDigest,
+			unlisted: "Sup3rSecret!",
+		},
+		{
+			name:   "sha1 list with a plain password",
+			format: blocklist.FormatSHA1,
+			source: hexDigest + "\nqwerty\n",
+			err:    "line 2: not a SHA-1 hex digest",
+		},
+		{
+			name:   "unknown format",
+			format: "md5",
+			source: hexDigest + "\n",
+			err:    `unknown source format "md5", want "plain" or "sha1"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+
+			var index bytes.Buffer
+			_, err := blocklist.Build(strings.NewReader(tc.source), &index, tc.format, blocklist.DefaultFalsePositiveRate)
+			if tc.err != "" {
+				Expect(err).To(MatchError(tc.err))
+				return
+			}
+			Expect(err).To(BeNil())
+
+			list, err := blocklist.Read(&index, int64(index.Len()))
+			Expect(err).To(BeNil())
+			Expect(list.Contains(tc.listed)).To(BeTrue())
+			Expect(list.Contains(tc.unlisted)).To(BeFalse())
+		})
+	}
+}
+
+func TestReadInvalidIndex(t *testing.T) {
+	var index bytes.Buffer
+	_, err := blocklist.Build(strings.NewReader("qwerty\n"), &index, blocklist.FormatPlain, blocklist.DefaultFalsePositiveRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := index.Bytes()
+
+	// the header claims the largest bit count while the file is a few bytes
+	oversized := append([]byte{}, valid...)
+	binary.BigEndian.PutUint64(oversized[9:17], 1<<63)
+
+	testCases := []struct {
+		name  string
+		index []byte
+		size  int64
+	}{
+		{name: "not an index", index: []byte("not an index"), size: 12},
+		{name: "bit count larger than the file", index: oversized, size: int64(len(oversized))},
+		{name: "truncated", index: valid[:len(valid)-1], size: int64(len(valid) - 1)},
+		{name: "trailing bytes", index: append(append([]byte{}, valid...), 0), size: int64(len(valid) + 1)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+
+			_, err := blocklist.Read(bytes.NewReader(tc.index), tc.size)
+			Expect(err).To(MatchError(blocklist.ErrIndexFormat))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package lru
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestCache_Evict(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	c := New[string, int](2, 0)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// a becomes the most recently used, b is evicted
+	Expect(value(c, "a")).To(Equal(1))
+	c.Set("c", 3)
+
+	_, ok := c.Get("b")
+	Expect(ok).To(BeFalse())
+	Expect(value(c, "a")).To(Equal(1))
+	Expect(value(c, "c")).To(Equal(3))
+	Expect(c.Len()).To(Equal(2))
+
+	c.Set("a", 10)
+	Expect(value(c, "a")).To(Equal(10))
+
+	c.Delete("a")
+	_, ok = c.Get("a")
+	Expect(ok).To(BeFalse())
+	Expect(c.Len()).To(Equal(1))
+}
+
+func TestCache_Expire(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	now := time.Now()
+	c := New[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	now = now.Add(59 * time.Second)
+	Expect(value(c, "a")).To(Equal(1))
+
+	now = now.Add(time.Second)
+	_, ok := c.Get("a")
+	Expect(ok).To(BeFalse())
+	Expect(c.Len()).To(BeZero())
+}
+
+func TestCache_ZeroSize(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	c := New[string, int](0, time.Minute)
+	c.Set("a", 1)
+
+	_, ok := c.Get("a")
+	Expect(ok).To(BeFalse())
+}
+
+// value fails the test when key is missing.
+func value(c *Cache[string, int], key string) int {
+	v, ok := c.Get(key)
+	Expect(ok).To(BeTrue(), key)
+	return v
+}
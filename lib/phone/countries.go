@@ -0,0 +1,43 @@
+package phone
+
+// Country holds the numbering rule of a country calling code. Lengths are
+// of the national significant number, i.e. without country code and
+// national trunk prefix.
+type Country struct {
+	Code      string
+	Region    string
+	MinLength int
+	MaxLength int
+}
+
+var countries = map[string]Country{
+	"1":   {Code: "1", Region: "US", MinLength: 10, MaxLength: 10},
+	"7":   {Code: "7", Region: "RU", MinLength: 10, MaxLength: 10},
+	"33":  {Code: "33", Region: "FR", MinLength: 9, MaxLength: 9},
+	"44":  {Code: "44", Region: "GB", MinLength: 9, MaxLength: 10},
+	"49":  {Code: "49", Region: "DE", MinLength: 6, MaxLength: 13},
+	"60":  {Code: "60", Region: "MY", MinLength: 8, MaxLength: 10},
+	"61":  {Code: "61", Region: "AU", MinLength: 9, MaxLength: 9},
+	"62":  {Code: "62", Region: "ID", MinLength: 9, MaxLength: 12},
+	"63":  {Code: "63", Region: "PH", MinLength: 8, MaxLength: 10},
+	"64":  {Code: "64", Region: "NZ", MinLength: 8, MaxLength: 10},
+	"65":  {Code: "65", Region: "SG", MinLength: 8, MaxLength: 8},
+	"66":  {Code: "66", Region: "TH", MinLength: 8, MaxLength: 9},
+	"81":  {Code: "81", Region: "JP", MinLength: 9, MaxLength: 10},
+	"82":  {Code: "82", Region: "KR", MinLength: 8, MaxLength: 10},
+	"84":  {Code: "84", Region: "VN", MinLength: 9, MaxLength: 10},
+	"86":  {Code: "86", Region: "CN", MinLength: 10, MaxLength: 11},
+	"91":  {Code: "91", Region: "IN", MinLength: 10, MaxLength: 10},
+	"673": {Code: "673", Region: "BN", MinLength: 7, MaxLength: 7},
+	"670": {Code: "670", Region: "TL", MinLength: 7, MaxLength: 8},
+	"852": {Code: "852", Region: "HK", MinLength: 8, MaxLength: 8},
+	"855": {Code: "855", Region: "KH", MinLength: 8, MaxLength: 9},
+	"856": {Code: "856", Region: "LA", MinLength: 8, MaxLength: 10},
+	"886": {Code: "886", Region: "TW", MinLength: 8, MaxLength: 9},
+	"95":  {Code: "95", Region: "MM", MinLength: 7, MaxLength: 10},
+	"971": {Code: "971", Region: "AE", MinLength: 8, MaxLength: 9},
+	"966": {Code: "966", Region: "SA", MinLength: 9, MaxLength: 9},
+}
+
+// maxCodeLength is the longest calling code, per ITU-T E.164.
+const maxCodeLength = 3
@@ -0,0 +1,157 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// defaultCountryCode is the country of the Default parser.
+const defaultCountryCode = "62"
+
+var (
+	ErrFormat            = errors.New("invalid phone number format")
+	ErrUnknownCountry    = errors.New("unknown phone country code")
+	ErrCountryNotAllowed = errors.New("phone country code not allowed")
+	ErrLength            = errors.New("invalid phone number length")
+)
+
+// Parser normalizes phone numbers into E.164 format, e.g. "+6285156305136".
+type Parser struct {
+	defaultCountry Country
+	allowed        map[string]bool
+}
+
+type Options struct {
+	// DefaultCountryCode is used for numbers written in national format,
+	// e.g. "0851..." becomes "+62851..." when it is "62".
+	DefaultCountryCode string
+	// AllowedCountryCodes limits accepted numbers, every known country is
+	// accepted when empty.
+	AllowedCountryCodes []string
+}
+
+func New(opts Options) (*Parser, error) {
+	defaultCountry, ok := countries[opts.DefaultCountryCode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCountry, opts.DefaultCountryCode)
+	}
+
+	allowed := make(map[string]bool, len(opts.AllowedCountryCodes))
+	for _, code := range opts.AllowedCountryCodes {
+		code = strings.TrimPrefix(strings.TrimSpace(code), "+")
+		if _, ok := countries[code]; !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownCountry, code)
+		}
+		allowed[code] = true
+	}
+
+	return &Parser{
+		defaultCountry: defaultCountry,
+		allowed:        allowed,
+	}, nil
+}
+
+// Default returns the parser services fall back to when none is configured,
+// it accepts Indonesian numbers only.
+func Default() *Parser {
+	return &Parser{
+		defaultCountry: countries[defaultCountryCode],
+		allowed:        map[string]bool{defaultCountryCode: true},
+	}
+}
+
+// Normalize accepts "+62 851-5630-5136", "6285156305136", "0085..." and
+// "085156305136" style numbers and returns the E.164 form.
+func (p *Parser) Normalize(raw string) (string, error) {
+	digits, international, err := clean(raw)
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		country  Country
+		national string
+	)
+
+	switch {
+	case international:
+		var ok bool
+		country, national, ok = splitDigits(digits)
+		if !ok {
+			return "", ErrUnknownCountry
+		}
+	case strings.HasPrefix(digits, "0"):
+		country = p.defaultCountry
+		national = strings.TrimPrefix(digits, "0")
+	case strings.HasPrefix(digits, p.defaultCountry.Code):
+		country = p.defaultCountry
+		national = strings.TrimPrefix(digits, p.defaultCountry.Code)
+	default:
+		return "", ErrFormat
+	}
+
+	if len(p.allowed) > 0 && !p.allowed[country.Code] {
+		return "", ErrCountryNotAllowed
+	}
+
+	if len(national) < country.MinLength || len(national) > country.MaxLength {
+		return "", ErrLength
+	}
+
+	return "+" + country.Code + national, nil
+}
+
+// Split returns the country and national significant number of an E.164
+// phone number.
+func Split(e164 string) (Country, string, bool) {
+	if !strings.HasPrefix(e164, "+") {
+		return Country{}, "", false
+	}
+
+	return splitDigits(e164[1:])
+}
+
+func splitDigits(digits string) (Country, string, bool) {
+	for i := 1; i <= maxCodeLength && i < len(digits); i++ {
+		if country, ok := countries[digits[:i]]; ok {
+			return country, digits[i:], true
+		}
+	}
+
+	return Country{}, "", false
+}
+
+// clean strips separators and reports whether the number is written in
+// international format, either with "+" or the "00" dialing prefix.
+func clean(raw string) (string, bool, error) {
+	raw = strings.TrimSpace(raw)
+	international := strings.HasPrefix(raw, "+")
+	if international {
+		raw = raw[1:]
+	}
+
+	var b strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+			continue
+		default:
+			return "", false, ErrFormat
+		}
+	}
+
+	digits := b.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+
+	if digits == "" {
+		return "", false, ErrFormat
+	}
+
+	return digits, international, nil
+}
@@ -0,0 +1,70 @@
+package phone_test
+
+import (
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/lib/phone"
+	. "github.com/onsi/gomega"
+)
+
+func TestParser_Normalize(t *testing.T) {
+	testcases := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr error
+	}{
+		{name: "success e164", raw: "+6285156305136", want: "+6285156305136"},
+		{name: "success national format", raw: "085156305136", want: "+6285156305136"},
+		{name: "success country code without plus", raw: "6285156305136", want: "+6285156305136"},
+		{name: "success with spaces and dashes", raw: "+62 851-5630-5136", want: "+6285156305136"},
+		{name: "success international dialing prefix", raw: "0065 9123 4567", want: "+6591234567"},
+		{name: "failed letters", raw: "+62abc", wantErr: phone.ErrFormat},
+		{name: "failed empty", raw: " ", wantErr: phone.ErrFormat},
+		{name: "failed without any prefix", raw: "85156305136", wantErr: phone.ErrFormat},
+		{name: "failed unknown country code", raw: "+999123456789", wantErr: phone.ErrUnknownCountry},
+		{name: "failed country not allowed", raw: "+14155552671", wantErr: phone.ErrCountryNotAllowed},
+		{name: "failed too short", raw: "+6285156305", wantErr: phone.ErrLength},
+		{name: "failed too long", raw: "+65912345678", wantErr: phone.ErrLength},
+	}
+
+	parser, err := phone.New(phone.Options{
+		DefaultCountryCode:  "62",
+		AllowedCountryCodes: []string{"62", "+65"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			got, err := parser.Normalize(tc.raw)
+			if tc.wantErr != nil {
+				Expect(err).To(MatchError(tc.wantErr))
+				return
+			}
+
+			Expect(err).To(BeNil())
+			Expect(got).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestDefault(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	got, err := phone.Default().Normalize("085156305136")
+	Expect(err).To(BeNil())
+	Expect(got).To(Equal("+6285156305136"))
+
+	_, err = phone.Default().Normalize("+14155552671")
+	Expect(err).To(MatchError(phone.ErrCountryNotAllowed))
+}
+
+func TestNew_UnknownCountry(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	_, err := phone.New(phone.Options{DefaultCountryCode: "999"})
+	Expect(err).To(MatchError(phone.ErrUnknownCountry))
+}
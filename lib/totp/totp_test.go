@@ -0,0 +1,72 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/lib/totp"
+	. "github.com/onsi/gomega"
+)
+
+// rfc6238Secret is the SHA1 test key of RFC 6238 appendix B.
+var rfc6238Secret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	testcases := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.want, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			got, err := totp.Code(rfc6238Secret, time.Unix(tc.unix, 0))
+			Expect(err).To(BeNil())
+			Expect(got).To(Equal(tc.want))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	secret, err := totp.GenerateSecret()
+	Expect(err).To(BeNil())
+
+	now := time.Now()
+	code, err := totp.Code(secret, now)
+	Expect(err).To(BeNil())
+
+	Expect(totp.Validate(secret, code, now)).To(BeTrue())
+	Expect(totp.Validate(secret, code, now.Add(totp.Period))).To(BeTrue())
+	Expect(totp.Validate(secret, code, now.Add(3*totp.Period))).To(BeFalse())
+	Expect(totp.Validate(secret, "12345", now)).To(BeFalse())
+}
+
+func TestValidateStep(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	now := time.Unix(1234567890, 0)
+	step, ok := totp.ValidateStep(rfc6238Secret, "005924", now.Add(totp.Period))
+	Expect(ok).To(BeTrue())
+	Expect(step).To(Equal(totp.Step(now)))
+
+	_, ok = totp.ValidateStep(rfc6238Secret, "005924", now.Add(3*totp.Period))
+	Expect(ok).To(BeFalse())
+}
+
+func TestURI(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	uri := totp.URI("SawitPro", "+6285156305136", "JBSWY3DPEHPK3PXP")
+	Expect(strings.HasPrefix(uri, "otpauth://totp/SawitPro:+6285156305136?")).To(BeTrue())
+	Expect(uri).To(ContainSubstring("secret=JBSWY3DPEHPK3PXP"))
+	Expect(uri).To(ContainSubstring("issuer=SawitPro"))
+}
@@ -0,0 +1,66 @@
+// Package webhooksig signs webhook deliveries so receivers can check they
+// come from this service and were not replayed.
+package webhooksig
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Prefix names the algorithm in the signature header value.
+const Prefix = "sha256="
+
+const secretSize = 32
+
+var (
+	ErrSignatureMismatch = errors.New("webhook signature does not match")
+	ErrTimestampTooOld   = errors.New("webhook timestamp outside of tolerance")
+)
+
+// GenerateSecret returns a random secret to sign deliveries with.
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Sign returns the signature header value of body sent at timestamp, the
+// HMAC-SHA256 of "<unix seconds>.<body>". Covering the timestamp keeps a
+// captured delivery from being replayed later.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return Prefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks signature of body and rejects a timestamp, in unix seconds,
+// further than tolerance from now.
+func Verify(secret string, timestamp string, body []byte, signature string, tolerance time.Duration, now time.Time) error {
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrSignatureMismatch
+	}
+
+	t := time.Unix(sec, 0)
+	if t.Before(now.Add(-tolerance)) || t.After(now.Add(tolerance)) {
+		return ErrTimestampTooOld
+	}
+
+	if !hmac.Equal([]byte(Sign(secret, t, body)), []byte(signature)) {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
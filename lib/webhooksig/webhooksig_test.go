@@ -0,0 +1,106 @@
+package webhooksig_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/lib/webhooksig"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	testBody = []byte(`{"id":"event-1"}`)
+	testTime = time.Unix(1700000000, 0)
+)
+
+func TestSign(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	got := webhooksig.Sign("whsec_test", testTime, testBody)
+	Expect(got).To(Equal("sha256=8e2971dac7c4d9294c7c65f1bd33cef904855a225900e22ee05f866f468078eb"))
+}
+
+func TestVerify(t *testing.T) {
+	signature := webhooksig.Sign("whsec_test", testTime, testBody)
+
+	testcases := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+		now       time.Time
+		want      error
+	}{
+		{
+			name:      "valid signature",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			body:      testBody,
+			now:       testTime.Add(time.Minute),
+		},
+		{
+			name:      "wrong secret",
+			secret:    "whsec_other",
+			timestamp: "1700000000",
+			body:      testBody,
+			now:       testTime,
+			want:      webhooksig.ErrSignatureMismatch,
+		},
+		{
+			name:      "tampered body",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			body:      []byte(`{"id":"event-2"}`),
+			now:       testTime,
+			want:      webhooksig.ErrSignatureMismatch,
+		},
+		{
+			name:      "tampered timestamp",
+			secret:    "whsec_test",
+			timestamp: "1700000001",
+			body:      testBody,
+			now:       testTime,
+			want:      webhooksig.ErrSignatureMismatch,
+		},
+		{
+			name:      "replayed delivery",
+			secret:    "whsec_test",
+			timestamp: "1700000000",
+			body:      testBody,
+			now:       testTime.Add(10 * time.Minute),
+			want:      webhooksig.ErrTimestampTooOld,
+		},
+		{
+			name:      "invalid timestamp",
+			secret:    "whsec_test",
+			timestamp: "yesterday",
+			body:      testBody,
+			now:       testTime,
+			want:      webhooksig.ErrSignatureMismatch,
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			err := webhooksig.Verify(tc.secret, tc.timestamp, tc.body, signature, 5*time.Minute, tc.now)
+			if tc.want == nil {
+				Expect(err).To(BeNil())
+			} else {
+				Expect(err).To(MatchError(tc.want))
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	a, err := webhooksig.GenerateSecret()
+	Expect(err).To(BeNil())
+	b, err := webhooksig.GenerateSecret()
+	Expect(err).To(BeNil())
+
+	Expect(a).To(HaveLen(43))
+	Expect(a).ToNot(Equal(b))
+}
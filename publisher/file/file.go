@@ -0,0 +1,72 @@
+// Package file publishes outbox events as JSON lines to a file or stdout,
+// for local runs where no broker is available.
+package file
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.EventPublisher = (*Publisher)(nil)
+
+type Publisher struct {
+	mu sync.Mutex
+	w  io.Writer
+	// f is the file opened by Open, nil when writing elsewhere
+	f *os.File
+}
+
+// New writes every event as a line of JSON to w.
+func New(w io.Writer) *Publisher {
+	return &Publisher{w: w}
+}
+
+// Open appends to the file at path, an empty path writes to stdout.
+func Open(path string) (*Publisher, error) {
+	if path == "" {
+		return New(os.Stdout), nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Publisher{w: f, f: f}, nil
+}
+
+// Publish returns only once the event reached the file, so the relay does
+// not mark events published that a crash would lose.
+func (p *Publisher) Publish(event *domain.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, err = p.w.Write(line); err != nil {
+		return err
+	}
+
+	if p.f != nil {
+		return p.f.Sync()
+	}
+
+	return nil
+}
+
+func (p *Publisher) Close() error {
+	if p.f == nil {
+		return nil
+	}
+
+	return p.f.Close()
+}
@@ -0,0 +1,70 @@
+package file_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/publisher/file"
+	. "github.com/onsi/gomega"
+)
+
+func testEvent(id string) *domain.Event {
+	return &domain.Event{
+		ID:          id,
+		Type:        "user.registered",
+		AggregateID: "c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73",
+		Payload:     json.RawMessage(`{"user_id":"c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73"}`),
+		OccurredAt:  time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		Attempts:    2,
+	}
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	var buf bytes.Buffer
+	p := file.New(&buf)
+	Expect(p.Publish(testEvent("event-1"))).To(Succeed())
+	Expect(p.Publish(testEvent("event-2"))).To(Succeed())
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	Expect(lines).To(HaveLen(2))
+	Expect(string(lines[0])).To(MatchJSON(`{
+		"id": "event-1",
+		"type": "user.registered",
+		"aggregate_id": "c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73",
+		"payload": {"user_id": "c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73"},
+		"occurred_at": "2023-01-02T03:04:05Z"
+	}`))
+}
+
+func TestOpen_Appends(t *testing.T) {
+	Default = NewGomegaWithT(t)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	for _, id := range []string{"event-1", "event-2"} {
+		p, err := file.Open(path)
+		Expect(err).To(BeNil())
+		Expect(p.Publish(testEvent(id))).To(Succeed())
+		Expect(p.Close()).To(Succeed())
+	}
+
+	f, err := os.Open(path)
+	Expect(err).To(BeNil())
+	defer f.Close()
+
+	ids := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		e := domain.Event{}
+		Expect(json.Unmarshal(scanner.Bytes(), &e)).To(Succeed())
+		ids = append(ids, e.ID)
+	}
+	Expect(ids).To(Equal([]string{"event-1", "event-2"}))
+}
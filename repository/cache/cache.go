@@ -0,0 +1,266 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/lib/lru"
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+var _ port.UserRepo = (*Repository)(nil)
+
+// Repository serves GetUserByID of the wrapped repo from an in-process LRU
+// cache, backed by an optional external cache shared between instances.
+// Concurrent misses for the same user share a single load. The methods
+// changing the cached profile invalidate the user, every other method is
+// passed through.
+type Repository struct {
+	port.UserRepo
+	*cache
+	// touched collects the users changed inside WithTx, they are invalidated
+	// once the transaction ends. nil outside a transaction.
+	touched *[]string
+}
+
+type Options struct {
+	// Size is the number of users kept in process
+	Size int
+	// TTL bounds how stale a cached user gets, also when another instance
+	// changed it
+	TTL time.Duration
+	// External is consulted on an in-process miss, nil to only cache in
+	// process
+	External port.UserCache
+}
+
+// Stats are counted since the repository was created.
+type Stats struct {
+	// Hits are served from the in-process cache
+	Hits uint64
+	// Misses are not in the in-process cache
+	Misses uint64
+	// ExternalHits are misses served from the external cache
+	ExternalHits uint64
+	// Loads are misses served from the wrapped repo, concurrent misses for
+	// the same user share one load
+	Loads uint64
+	// Invalidations are users dropped because they were changed
+	Invalidations uint64
+	// ExternalErrors are failed calls to the external cache, they are
+	// treated as misses
+	ExternalErrors uint64
+}
+
+type cache struct {
+	mu    sync.Mutex
+	local *lru.Cache[string, domain.User]
+	// generation is bumped by every invalidation, a load that raced with
+	// one does not fill the cache with what it read
+	generation uint64
+	external   port.UserCache
+	ttl        time.Duration
+	group      singleflight.Group
+
+	hits           atomic.Uint64
+	misses         atomic.Uint64
+	externalHits   atomic.Uint64
+	loads          atomic.Uint64
+	invalidations  atomic.Uint64
+	externalErrors atomic.Uint64
+}
+
+func New(repo port.UserRepo, opts Options) *Repository {
+	return &Repository{
+		UserRepo: repo,
+		cache: &cache{
+			local:    lru.New[string, domain.User](opts.Size, opts.TTL),
+			external: opts.External,
+			ttl:      opts.TTL,
+		},
+	}
+}
+
+// GetUserByID reads through the cache, inside a transaction it reads the
+// wrapped repo so the transaction sees its own changes.
+func (r *Repository) GetUserByID(id string) (*domain.User, error) {
+	validID, err := uuid.Parse(id)
+	if r.touched != nil || err != nil {
+		return r.UserRepo.GetUserByID(id)
+	}
+	key := validID.String()
+
+	if u, ok := r.local.Get(key); ok {
+		r.hits.Add(1)
+		return &u, nil
+	}
+	r.misses.Add(1)
+
+	r.mu.Lock()
+	generation := r.generation
+	r.mu.Unlock()
+
+	v, err, _ := r.group.Do(key, func() (interface{}, error) {
+		if r.external != nil {
+			u, found, err := r.external.Get(key)
+			if err != nil {
+				r.externalErrors.Add(1)
+			} else if found {
+				r.externalHits.Add(1)
+				r.fill(key, *u, generation)
+				return *u, nil
+			}
+		}
+
+		r.loads.Add(1)
+		u, err := r.UserRepo.GetUserByID(key)
+		if err != nil {
+			return nil, err
+		}
+
+		if r.fill(key, *u, generation) && r.external != nil {
+			if err = r.external.Set(key, u, r.ttl); err != nil {
+				r.externalErrors.Add(1)
+			}
+		}
+
+		return *u, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	u := v.(domain.User)
+	return &u, nil
+}
+
+// PatchUserByID invalidates the user even when the update fails, a version
+// mismatch means the cached profile may be stale.
+func (r *Repository) PatchUserByID(actor domain.Actor, id string, data *domain.User) (*domain.User, error) {
+	u, err := r.UserRepo.PatchUserByID(actor, id, data)
+	r.touch(id)
+
+	return u, err
+}
+
+// UpdatePassword invalidates the user, the password change time and
+// must_change_password are part of the cached profile.
+func (r *Repository) UpdatePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error {
+	err := r.UserRepo.UpdatePassword(actor, id, oldPassword, newPassword)
+	r.touch(id)
+
+	return err
+}
+
+// SetMustChangePassword invalidates the user, a forced password change has
+// to take effect on the next request.
+func (r *Repository) SetMustChangePassword(actor domain.Actor, id string, mustChange bool) error {
+	err := r.UserRepo.SetMustChangePassword(actor, id, mustChange)
+	r.touch(id)
+
+	return err
+}
+
+// SetUserRole invalidates the user, the role is part of the cached profile.
+func (r *Repository) SetUserRole(actor domain.Actor, id string, role string) error {
+	err := r.UserRepo.SetUserRole(actor, id, role)
+	r.touch(id)
+
+	return err
+}
+
+// ConfirmTOTP invalidates the user, whether MFA is enabled is part of the
+// cached profile.
+func (r *Repository) ConfirmTOTP(userID string, recoveryCodes []string) error {
+	err := r.UserRepo.ConfirmTOTP(userID, recoveryCodes)
+	r.touch(userID)
+
+	return err
+}
+
+// WithTx invalidates the users changed by fn after the transaction ends,
+// before that other readers still see the committed profile.
+func (r *Repository) WithTx(ctx context.Context, fn func(repo port.UserRepo) error) error {
+	if r.touched != nil {
+		return r.UserRepo.WithTx(ctx, func(repo port.UserRepo) error {
+			return fn(&Repository{UserRepo: repo, cache: r.cache, touched: r.touched})
+		})
+	}
+
+	touched := []string{}
+	defer func() {
+		for _, id := range touched {
+			r.Invalidate(id)
+		}
+	}()
+
+	return r.UserRepo.WithTx(ctx, func(repo port.UserRepo) error {
+		return fn(&Repository{UserRepo: repo, cache: r.cache, touched: &touched})
+	})
+}
+
+// touch invalidates the user changed by a mutation, inside a transaction
+// once it ends.
+func (r *Repository) touch(id string) {
+	if r.touched != nil {
+		*r.touched = append(*r.touched, id)
+		return
+	}
+
+	r.Invalidate(id)
+}
+
+// Invalidate drops a user changed outside the repository, e.g. deactivated
+// or demoted directly in the database. Such a change otherwise takes up to
+// the TTL to be seen.
+func (r *Repository) Invalidate(id string) {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return
+	}
+	key := validID.String()
+
+	r.mu.Lock()
+	r.generation++
+	r.local.Delete(key)
+	r.mu.Unlock()
+
+	r.group.Forget(key)
+	r.invalidations.Add(1)
+
+	if r.external != nil {
+		if err = r.external.Delete(key); err != nil {
+			r.externalErrors.Add(1)
+		}
+	}
+}
+
+func (r *Repository) Stats() Stats {
+	return Stats{
+		Hits:           r.hits.Load(),
+		Misses:         r.misses.Load(),
+		ExternalHits:   r.externalHits.Load(),
+		Loads:          r.loads.Load(),
+		Invalidations:  r.invalidations.Load(),
+		ExternalErrors: r.externalErrors.Load(),
+	}
+}
+
+// fill caches u in process unless an invalidation happened since generation
+// was read, and reports whether it did.
+func (c *cache) fill(key string, u domain.User, generation uint64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generation != generation {
+		return false
+	}
+
+	c.local.Set(key, u)
+	return true
+}
@@ -0,0 +1,241 @@
+package cache_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/repository/cache"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+var userID = uuid.NewString()
+
+func testUser(version int) *domain.User {
+	return &domain.User{
+		ID:          userID,
+		FullName:    "Edison Tantra",
+		PhoneNumber: "+6281234567890",
+		Version:     version,
+	}
+}
+
+type testcase struct {
+	name          string
+	external      bool
+	mockFunc      func(repo *port.MockUserRepo, external *port.MockUserCache)
+	assertionFunc func(repo *cache.Repository)
+}
+
+func TestRepository(t *testing.T) {
+	testcases := []testcase{
+		{
+			name: "serve repeated reads from cache",
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				repo.EXPECT().GetUserByID(userID).Return(testUser(1), nil).Times(1)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				for i := 0; i < 3; i++ {
+					u, err := repo.GetUserByID(userID)
+					Expect(err).To(BeNil())
+					Expect(u).To(Equal(testUser(1)))
+				}
+
+				Expect(repo.Stats()).To(Equal(cache.Stats{Hits: 2, Misses: 1, Loads: 1}))
+			},
+		},
+		{
+			name: "share one load between concurrent misses",
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				repo.EXPECT().
+					GetUserByID(userID).
+					DoAndReturn(func(id string) (*domain.User, error) {
+						time.Sleep(50 * time.Millisecond)
+						return testUser(1), nil
+					}).
+					Times(1)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				var wg sync.WaitGroup
+				for i := 0; i < 10; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						u, err := repo.GetUserByID(userID)
+						Expect(err).To(BeNil())
+						Expect(u.Version).To(Equal(1))
+					}()
+				}
+				wg.Wait()
+
+				Expect(repo.Stats().Loads).To(Equal(uint64(1)))
+			},
+		},
+		{
+			name: "do not cache errors",
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				repo.EXPECT().GetUserByID(userID).Return(nil, cons.ErrUserNotFound).Times(2)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				for i := 0; i < 2; i++ {
+					_, err := repo.GetUserByID(userID)
+					Expect(err).To(MatchError(cons.ErrUserNotFound))
+				}
+			},
+		},
+		{
+			name: "invalidate on patch",
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(userID).Return(testUser(1), nil),
+					repo.EXPECT().PatchUserByID(gomock.Any(), userID, gomock.Any()).Return(testUser(2), nil),
+					repo.EXPECT().GetUserByID(userID).Return(testUser(2), nil),
+				)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				_, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+
+				_, err = repo.PatchUserByID(domain.Actor{}, userID, &domain.User{FullName: "Edison"})
+				Expect(err).To(BeNil())
+
+				u, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+				Expect(u.Version).To(Equal(2))
+				Expect(repo.Stats().Invalidations).To(Equal(uint64(1)))
+			},
+		},
+		{
+			name: "invalidate on forced password change",
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				forced := testUser(2)
+				forced.MustChangePassword = true
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(userID).Return(testUser(1), nil),
+					repo.EXPECT().SetMustChangePassword(gomock.Any(), userID, true).Return(nil),
+					repo.EXPECT().GetUserByID(userID).Return(forced, nil),
+					repo.EXPECT().UpdatePassword(gomock.Any(), userID, "old", "new").Return(nil),
+					repo.EXPECT().GetUserByID(userID).Return(testUser(3), nil),
+				)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				_, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+
+				Expect(repo.SetMustChangePassword(domain.Actor{}, userID, true)).To(Succeed())
+
+				u, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+				Expect(u.MustChangePassword).To(BeTrue())
+
+				Expect(repo.UpdatePassword(domain.Actor{}, userID, "old", "new")).To(Succeed())
+
+				u, err = repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+				Expect(u.MustChangePassword).To(BeFalse())
+				Expect(repo.Stats().Invalidations).To(Equal(uint64(2)))
+			},
+		},
+		{
+			name: "invalidate after transaction",
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				repo.EXPECT().
+					WithTx(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, fn func(port.UserRepo) error) error {
+						return fn(repo)
+					})
+				gomock.InOrder(
+					repo.EXPECT().GetUserByID(userID).Return(testUser(1), nil),
+					repo.EXPECT().PatchUserByID(gomock.Any(), userID, gomock.Any()).Return(testUser(2), nil),
+					// read inside the transaction bypasses the cache
+					repo.EXPECT().GetUserByID(userID).Return(testUser(2), nil),
+					repo.EXPECT().GetUserByID(userID).Return(testUser(2), nil),
+				)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				_, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+
+				err = repo.WithTx(context.Background(), func(tx port.UserRepo) error {
+					_, err := tx.PatchUserByID(domain.Actor{}, userID, &domain.User{FullName: "Edison"})
+					if err != nil {
+						return err
+					}
+
+					u, err := tx.GetUserByID(userID)
+					Expect(u.Version).To(Equal(2))
+					return err
+				})
+				Expect(err).To(BeNil())
+
+				u, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+				Expect(u.Version).To(Equal(2))
+			},
+		},
+		{
+			name:     "serve miss from external cache",
+			external: true,
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				external.EXPECT().Get(userID).Return(testUser(1), true, nil).Times(1)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				for i := 0; i < 2; i++ {
+					u, err := repo.GetUserByID(userID)
+					Expect(err).To(BeNil())
+					Expect(u).To(Equal(testUser(1)))
+				}
+
+				Expect(repo.Stats()).To(Equal(cache.Stats{Hits: 1, Misses: 1, ExternalHits: 1}))
+			},
+		},
+		{
+			name:     "fill and invalidate external cache",
+			external: true,
+			mockFunc: func(repo *port.MockUserRepo, external *port.MockUserCache) {
+				gomock.InOrder(
+					external.EXPECT().Get(userID).Return(nil, false, errors.New("connection refused")),
+					repo.EXPECT().GetUserByID(userID).Return(testUser(1), nil),
+					external.EXPECT().Set(userID, testUser(1), time.Minute).Return(nil),
+					repo.EXPECT().PatchUserByID(gomock.Any(), userID, gomock.Any()).Return(testUser(2), nil),
+					external.EXPECT().Delete(userID).Return(nil),
+				)
+			},
+			assertionFunc: func(repo *cache.Repository) {
+				_, err := repo.GetUserByID(userID)
+				Expect(err).To(BeNil())
+
+				_, err = repo.PatchUserByID(domain.Actor{}, userID, &domain.User{FullName: "Edison"})
+				Expect(err).To(BeNil())
+
+				Expect(repo.Stats().ExternalErrors).To(Equal(uint64(1)))
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			repo := port.NewMockUserRepo(ctrl)
+			external := port.NewMockUserCache(ctrl)
+			tc.mockFunc(repo, external)
+
+			opts := cache.Options{Size: 10, TTL: time.Minute}
+			if tc.external {
+				opts.External = external
+			}
+
+			tc.assertionFunc(cache.New(repo, opts))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.APIKeyRepo = (*Repository)(nil)
+
+func (r *Repository) CreateAPIKey(data *domain.APIKey) (*domain.APIKey, error) {
+	now := time.Now()
+	k := domain.APIKey{
+		ID:        uuid.NewString(),
+		Name:      data.Name,
+		UserID:    data.UserID,
+		Prefix:    data.Prefix,
+		KeyHash:   data.KeyHash,
+		Scopes:    append([]string(nil), data.Scopes...),
+		ExpiresAt: data.ExpiresAt,
+		CreatedAt: &now,
+	}
+
+	err := r.do(func(s *state) error {
+		if _, ok := s.activeUser(k.UserID); !ok {
+			return cons.ErrUserNotFound
+		}
+
+		for _, other := range s.apiKeys {
+			if other.Prefix == k.Prefix {
+				return cons.ErrDataConflict
+			}
+		}
+
+		setRow(s, s.apiKeys, k.ID, k)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
+
+func (r *Repository) GetAPIKeyByPrefix(prefix string) (*domain.APIKey, error) {
+	var res *domain.APIKey
+	err := r.do(func(s *state) error {
+		for _, k := range s.apiKeys {
+			if k.Prefix == prefix {
+				res = &k
+				return nil
+			}
+		}
+
+		return cons.ErrAPIKeyNotFound
+	})
+
+	return res, err
+}
+
+func (r *Repository) ListAPIKeys() ([]domain.APIKey, error) {
+	res := []domain.APIKey{}
+	err := r.do(func(s *state) error {
+		for _, k := range s.apiKeys {
+			res = append(res, k)
+		}
+		return nil
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(*res[j].CreatedAt) })
+	return res, err
+}
+
+func (r *Repository) RevokeAPIKey(id string) error {
+	return r.do(func(s *state) error {
+		k, ok := s.apiKeys[id]
+		if !ok {
+			return cons.ErrAPIKeyNotFound
+		}
+
+		if k.RevokedAt == nil {
+			now := time.Now()
+			k.RevokedAt = &now
+			setRow(s, s.apiKeys, id, k)
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) TouchAPIKey(id string) error {
+	return r.do(func(s *state) error {
+		k, ok := s.apiKeys[id]
+		if !ok {
+			return cons.ErrAPIKeyNotFound
+		}
+
+		now := time.Now()
+		k.LastUsedAt = &now
+		setRow(s, s.apiKeys, id, k)
+		return nil
+	})
+}
@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+// audit appends an entry for a mutation of userID, anonymous actors are
+// recorded as the user itself.
+func (s *state) audit(actor domain.Actor, userID string, action string, changes []domain.AuditChange) error {
+	actorID := userID
+	if actor.UserID != "" {
+		validID, err := uuid.Parse(actor.UserID)
+		if err != nil {
+			return err
+		}
+
+		actorID = validID.String()
+	}
+
+	now := time.Now()
+	appendRow(s, &s.auditLog, domain.AuditEntry{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		ActorID:   actorID,
+		Action:    action,
+		Changes:   changes,
+		RequestID: actor.RequestID,
+		CreatedAt: &now,
+	}, auditEntryID)
+
+	return nil
+}
+
+func auditEntryID(e domain.AuditEntry) string { return e.ID }
+
+func (r *Repository) ListAuditEntries(filter domain.AuditFilter, limit int, offset int) ([]domain.AuditEntry, int, error) {
+	validID, err := uuid.Parse(filter.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res := []domain.AuditEntry{}
+	total := 0
+	err = r.do(func(s *state) error {
+		matched := []domain.AuditEntry{}
+		for i := len(s.auditLog) - 1; i >= 0; i-- {
+			e := s.auditLog[i]
+			if e.UserID != validID.String() {
+				continue
+			}
+
+			if filter.From != nil && e.CreatedAt.Before(*filter.From) {
+				continue
+			}
+
+			if filter.To != nil && !e.CreatedAt.Before(*filter.To) {
+				continue
+			}
+
+			matched = append(matched, e)
+		}
+
+		total = len(matched)
+		start, end := page(total, limit, offset)
+		res = append(res, matched[start:end]...)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
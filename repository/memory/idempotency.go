@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.IdempotencyRepo = (*Repository)(nil)
+
+// idempotencyKey is the key of a record in state.idempotencyKeys.
+type idempotencyKey struct {
+	caller string
+	key    string
+}
+
+// ReserveIdempotencyKey stores rec unless an unexpired record with the same
+// caller and key exists, that record is returned instead.
+func (r *Repository) ReserveIdempotencyKey(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	var res *domain.IdempotencyRecord
+	err := r.do(func(s *state) error {
+		existing, ok := s.idempotencyKeys[idempotencyKey{rec.Caller, rec.Key}]
+		if ok && existing.ExpiresAt.After(time.Now()) {
+			res = &existing
+			return nil
+		}
+
+		setRow(s, s.idempotencyKeys, idempotencyKey{rec.Caller, rec.Key}, domain.IdempotencyRecord{
+			Caller:      rec.Caller,
+			Key:         rec.Key,
+			Fingerprint: rec.Fingerprint,
+			ExpiresAt:   rec.ExpiresAt,
+		})
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) CompleteIdempotencyKey(rec *domain.IdempotencyRecord) error {
+	return r.do(func(s *state) error {
+		existing, ok := s.idempotencyKeys[idempotencyKey{rec.Caller, rec.Key}]
+		if !ok || existing.Fingerprint != rec.Fingerprint || existing.StatusCode != 0 {
+			return nil
+		}
+
+		existing.StatusCode = rec.StatusCode
+		existing.ContentType = rec.ContentType
+		existing.Body = append([]byte(nil), rec.Body...)
+		setRow(s, s.idempotencyKeys, idempotencyKey{rec.Caller, rec.Key}, existing)
+		return nil
+	})
+}
+
+// DeleteIdempotencyKey drops a reservation that never completed, so the
+// request can be retried with the same key.
+func (r *Repository) DeleteIdempotencyKey(rec *domain.IdempotencyRecord) error {
+	return r.do(func(s *state) error {
+		existing, ok := s.idempotencyKeys[idempotencyKey{rec.Caller, rec.Key}]
+		if ok && existing.Fingerprint == rec.Fingerprint && existing.StatusCode == 0 {
+			deleteRow(s, s.idempotencyKeys, idempotencyKey{rec.Caller, rec.Key})
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	var n int64
+	err := r.do(func(s *state) error {
+		for key, rec := range s.idempotencyKeys {
+			if !rec.ExpiresAt.After(before) {
+				deleteRow(s, s.idempotencyKeys, key)
+				n++
+			}
+		}
+
+		return nil
+	})
+
+	return n, err
+}
@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+// CreateLoginEvent records a login attempt. Failed attempts usually only
+// know the phone number, the owner of it is looked up so the attempt shows
+// in their history.
+func (r *Repository) CreateLoginEvent(data *domain.LoginEvent) error {
+	userID := ""
+	if data.UserID != "" {
+		validID, err := uuid.Parse(data.UserID)
+		if err != nil {
+			return err
+		}
+
+		userID = validID.String()
+	}
+
+	ip, err := normalizeIP(data.IPAddress)
+	if err != nil {
+		return err
+	}
+
+	return r.do(func(s *state) error {
+		if userID == "" {
+			userID = s.phoneNumbers[data.PhoneNumber]
+		}
+
+		now := time.Now()
+		appendRow(s, &s.loginEvents, domain.LoginEvent{
+			ID:          uuid.NewString(),
+			UserID:      userID,
+			PhoneNumber: data.PhoneNumber,
+			Success:     data.Success,
+			Reason:      data.Reason,
+			UserAgent:   data.UserAgent,
+			IPAddress:   ip,
+			CreatedAt:   &now,
+		}, loginEventID)
+		return nil
+	})
+}
+
+func (r *Repository) ListLoginEvents(userID string, limit int, offset int) ([]domain.LoginEvent, int, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	res := []domain.LoginEvent{}
+	total := 0
+	err = r.do(func(s *state) error {
+		matched := []domain.LoginEvent{}
+		for i := len(s.loginEvents) - 1; i >= 0; i-- {
+			if s.loginEvents[i].UserID == validID.String() {
+				matched = append(matched, s.loginEvents[i])
+			}
+		}
+
+		total = len(matched)
+		start, end := page(total, limit, offset)
+		res = append(res, matched[start:end]...)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return res, total, nil
+}
+
+func (r *Repository) DeleteLoginEventsBefore(before time.Time) (int64, error) {
+	var n int64
+	err := r.do(func(s *state) error {
+		n = deleteRows(s, &s.loginEvents, func(e domain.LoginEvent) bool {
+			return e.CreatedAt.Before(before)
+		})
+		return nil
+	})
+
+	return n, err
+}
+
+func loginEventID(e domain.LoginEvent) string { return e.ID }
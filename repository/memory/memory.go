@@ -0,0 +1,783 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ port.UserRepo = (*Repository)(nil)
+
+// hashCost is low as the memory repository is only for tests and local runs,
+// its hashes never outlive the process.
+const hashCost = 6
+
+// Repository keeps everything in memory, it is meant for tests and local
+// runs and loses all data on restart.
+//
+// Calls hold the lock of the state only while they read or write it, never
+// while hashing. A transaction writes the state right away and keeps an undo
+// log to revert its writes on rollback, so other calls see its writes before
+// it commits. The users it writes stay locked until it ends, like SELECT ...
+// FOR UPDATE, so a rollback never reverts the write of another call.
+type Repository struct {
+	mu    *sync.Mutex
+	state *state
+	users *rowLocks
+	// tx is the transaction of the repository, nil outside one
+	tx *txn
+}
+
+type user struct {
+	domain.User
+	isActive bool
+}
+
+type passwordHistory struct {
+	hash      string
+	createdAt time.Time
+}
+
+type state struct {
+	users           map[string]user
+	phoneNumbers    map[string]string
+	passwordHistory map[string][]passwordHistory
+	mfa             map[string]domain.MFA
+	recoveryCodes   map[string][]recoveryCode
+	mfaChallenges   map[string]mfaChallenge
+	sessions        map[string]session
+	loginEvents     []domain.LoginEvent
+	auditLog        []domain.AuditEntry
+	idempotencyKeys map[idempotencyKey]domain.IdempotencyRecord
+	outbox          []outboxEvent
+	webhooks        map[string]domain.Webhook
+	deliveries      []webhookDelivery
+	oauthClients    map[string]domain.OAuthClient
+	oauthCodes      map[string]oauthCode
+	apiKeys         map[string]domain.APIKey
+
+	// undo is the undo log of the transaction of the running call, nil
+	// outside a transaction
+	undo *[]func()
+}
+
+// txn is the undo log of a transaction and the users it locked.
+type txn struct {
+	undo   []func()
+	locked map[string]bool
+}
+
+func New() *Repository {
+	return &Repository{
+		mu: &sync.Mutex{},
+		state: &state{
+			users:           map[string]user{},
+			phoneNumbers:    map[string]string{},
+			passwordHistory: map[string][]passwordHistory{},
+			mfa:             map[string]domain.MFA{},
+			recoveryCodes:   map[string][]recoveryCode{},
+			mfaChallenges:   map[string]mfaChallenge{},
+			sessions:        map[string]session{},
+			idempotencyKeys: map[idempotencyKey]domain.IdempotencyRecord{},
+			webhooks:        map[string]domain.Webhook{},
+			oauthClients:    map[string]domain.OAuthClient{},
+			oauthCodes:      map[string]oauthCode{},
+			apiKeys:         map[string]domain.APIKey{},
+		},
+		users: &rowLocks{locks: map[string]*rowLock{}},
+	}
+}
+
+// do runs fn on the state while holding its lock, the writes of fn are
+// logged for rollback inside a transaction.
+func (r *Repository) do(fn func(s *state) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.tx != nil {
+		r.state.undo = &r.tx.undo
+		defer func() { r.state.undo = nil }()
+	}
+
+	return fn(r.state)
+}
+
+// lockUser locks the user with id against writes of other calls. A
+// transaction keeps the lock until it ends and unlock does nothing, outside
+// one unlock has to be called once the write is done.
+func (r *Repository) lockUser(id string) (unlock func()) {
+	if r.tx == nil {
+		r.users.lock(id)
+		return func() { r.users.unlock(id) }
+	}
+
+	if !r.tx.locked[id] {
+		r.users.lock(id)
+		r.tx.locked[id] = true
+	}
+
+	return func() {}
+}
+
+// WithTx runs fn in a transaction whose writes are reverted unless fn
+// returns nil. Calling WithTx inside one joins it.
+func (r *Repository) WithTx(ctx context.Context, fn func(repo port.UserRepo) error) error {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	tx := &Repository{
+		mu:    r.mu,
+		state: r.state,
+		users: r.users,
+		tx:    &txn{locked: map[string]bool{}},
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.rollback()
+		}
+
+		for id := range tx.tx.locked {
+			r.users.unlock(id)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	committed = true
+	return nil
+}
+
+// rollback reverts the writes of the transaction, newest first.
+func (r *Repository) rollback() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.tx.undo) - 1; i >= 0; i-- {
+		r.tx.undo[i]()
+	}
+}
+
+// rowLocks are locks by key that only exist while they are held or waited
+// for.
+type rowLocks struct {
+	mu    sync.Mutex
+	locks map[string]*rowLock
+}
+
+type rowLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (l *rowLocks) lock(key string) {
+	l.mu.Lock()
+	rl, ok := l.locks[key]
+	if !ok {
+		rl = &rowLock{}
+		l.locks[key] = rl
+	}
+	rl.refs++
+	l.mu.Unlock()
+
+	rl.Lock()
+}
+
+func (l *rowLocks) unlock(key string) {
+	l.mu.Lock()
+	rl := l.locks[key]
+	rl.refs--
+	if rl.refs == 0 {
+		delete(l.locks, key)
+	}
+	l.mu.Unlock()
+
+	rl.Unlock()
+}
+
+// onRollback logs undo when the running call is part of a transaction.
+func (s *state) onRollback(undo func()) {
+	if s.undo != nil {
+		*s.undo = append(*s.undo, undo)
+	}
+}
+
+// setRow writes row to table, a rollback puts back what it replaced.
+func setRow[K comparable, V any](s *state, table map[K]V, key K, row V) {
+	old, existed := table[key]
+	s.onRollback(func() {
+		if existed {
+			table[key] = old
+			return
+		}
+
+		delete(table, key)
+	})
+
+	table[key] = row
+}
+
+// deleteRow deletes the row of key from table, a rollback puts it back.
+func deleteRow[K comparable, V any](s *state, table map[K]V, key K) {
+	old, existed := table[key]
+	if !existed {
+		return
+	}
+
+	s.onRollback(func() { table[key] = old })
+	delete(table, key)
+}
+
+// appendRow appends row to table, a rollback removes it by its id again so
+// rows appended by other calls meanwhile are kept.
+func appendRow[T any](s *state, table *[]T, row T, id func(T) string) {
+	rowID := id(row)
+	s.onRollback(func() {
+		for i := range *table {
+			if id((*table)[i]) == rowID {
+				*table = append((*table)[:i], (*table)[i+1:]...)
+				return
+			}
+		}
+	})
+
+	*table = append(*table, row)
+}
+
+// updateRow changes the row of table at i in place, a rollback puts the old
+// row back wherever it moved meanwhile.
+func updateRow[T any](s *state, table *[]T, i int, id func(T) string, update func(row *T)) {
+	old := (*table)[i]
+	s.onRollback(func() {
+		for j := range *table {
+			if id((*table)[j]) == id(old) {
+				(*table)[j] = old
+				return
+			}
+		}
+	})
+
+	update(&(*table)[i])
+}
+
+// deleteRows deletes the rows of table matching del and reports how many, a
+// rollback inserts them again where they were.
+func deleteRows[T any](s *state, table *[]T, del func(T) bool) int64 {
+	type deleted struct {
+		at  int
+		row T
+	}
+
+	kept := (*table)[:0:0]
+	gone := []deleted{}
+	for i, row := range *table {
+		if del(row) {
+			gone = append(gone, deleted{at: i, row: row})
+			continue
+		}
+
+		kept = append(kept, row)
+	}
+
+	*table = kept
+	if len(gone) > 0 {
+		s.onRollback(func() {
+			rows := *table
+			for _, d := range gone {
+				at := d.at
+				if at > len(rows) {
+					at = len(rows)
+				}
+
+				rows = append(rows[:at], append([]T{d.row}, rows[at:]...)...)
+			}
+
+			*table = rows
+		})
+	}
+
+	return int64(len(gone))
+}
+
+// activeUser is the user with id unless it is deactivated.
+func (s *state) activeUser(id string) (user, bool) {
+	u, ok := s.users[id]
+	return u, ok && u.isActive
+}
+
+func (s *state) saveUser(u user) {
+	now := time.Now()
+	u.UpdatedAt = &now
+	setRow(s, s.users, u.ID, u)
+}
+
+func (r *Repository) CreateUser(actor domain.Actor, data *domain.User) (*domain.User, error) {
+	hash, err := hashSecret(data.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *domain.User
+	err = r.do(func(s *state) error {
+		if _, ok := s.phoneNumbers[data.PhoneNumber]; ok {
+			return cons.ErrDataConflict
+		}
+
+		now := time.Now()
+		u := user{
+			User: domain.User{
+				ID:                uuid.NewString(),
+				FullName:          data.FullName,
+				PhoneNumber:       data.PhoneNumber,
+				Password:          hash,
+				Role:              cons.RoleUser,
+				PasswordChangedAt: &now,
+				Version:           1,
+				CreatedAt:         &now,
+			},
+			isActive: true,
+		}
+
+		err := s.audit(actor, u.ID, cons.AuditActionCreateUser, []domain.AuditChange{
+			{Field: "full_name", New: &u.FullName},
+			{Field: "phone_number", New: &u.PhoneNumber},
+		})
+		if err != nil {
+			return err
+		}
+
+		setRow(s, s.users, u.ID, u)
+		setRow(s, s.phoneNumbers, u.PhoneNumber, u.ID)
+		res = &domain.User{
+			ID:          u.ID,
+			FullName:    u.FullName,
+			PhoneNumber: u.PhoneNumber,
+			Version:     u.Version,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *Repository) GetUserIDByPhone(phone string) (string, error) {
+	var res string
+	err := r.do(func(s *state) error {
+		id, ok := s.phoneNumbers[phone]
+		if !ok {
+			return cons.ErrUserNotFound
+		}
+
+		res = id
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) GetUserByID(id string) (*domain.User, error) {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *domain.User
+	err = r.do(func(s *state) error {
+		u, ok := s.activeUser(validID.String())
+		if !ok {
+			return cons.ErrUserNotFound
+		}
+
+		res = &domain.User{
+			ID:          u.ID,
+			FullName:    u.FullName,
+			PhoneNumber: u.PhoneNumber,
+			Role:        u.Role,
+			Version:     u.Version,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// PatchUserByID updates the non empty fields, a non zero data.Version must
+// match the current version of the user.
+func (r *Repository) PatchUserByID(actor domain.Actor, id string, data *domain.User) (*domain.User, error) {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	var res *domain.User
+	err = r.do(func(s *state) error {
+		u, ok := s.activeUser(validID.String())
+		if !ok {
+			return cons.ErrUserNotFound
+		}
+
+		if data.Version != 0 && u.Version != data.Version {
+			return cons.ErrVersionMismatch
+		}
+
+		if data.PhoneNumber != "" {
+			if owner, ok := s.phoneNumbers[data.PhoneNumber]; ok && owner != u.ID {
+				return cons.ErrDataConflict
+			}
+		}
+
+		changes := []domain.AuditChange{}
+		old := u
+		if data.FullName != "" && data.FullName != u.FullName {
+			u.FullName = data.FullName
+			changes = append(changes, domain.AuditChange{Field: "full_name", Old: &old.FullName, New: &u.FullName})
+		}
+		if data.PhoneNumber != "" && data.PhoneNumber != u.PhoneNumber {
+			u.PhoneNumber = data.PhoneNumber
+			changes = append(changes, domain.AuditChange{Field: "phone_number", Old: &old.PhoneNumber, New: &u.PhoneNumber})
+		}
+
+		err := s.audit(actor, u.ID, cons.AuditActionUpdateProfile, changes)
+		if err != nil {
+			return err
+		}
+
+		u.Version++
+		deleteRow(s, s.phoneNumbers, old.PhoneNumber)
+		setRow(s, s.phoneNumbers, u.PhoneNumber, u.ID)
+		s.saveUser(u)
+		res = &domain.User{
+			ID:          u.ID,
+			FullName:    u.FullName,
+			PhoneNumber: u.PhoneNumber,
+			Version:     u.Version,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *Repository) Login(phone string, pass string) (*domain.User, error) {
+	var userID string
+	_ = r.do(func(s *state) error {
+		userID = s.phoneNumbers[phone]
+		return nil
+	})
+	if userID == "" {
+		return nil, cons.ErrLoginNotMatch
+	}
+
+	unlock := r.lockUser(userID)
+	defer unlock()
+
+	u, ok := r.activeUser(userID)
+	if !ok || u.PhoneNumber != phone || !matchSecret(u.Password, pass) {
+		return nil, cons.ErrLoginNotMatch
+	}
+
+	var res *domain.User
+	_ = r.do(func(s *state) error {
+		// the lock of the user kept it from changing since it was read
+		m, enrolled := s.mfa[u.ID]
+		res = &domain.User{
+			ID:                 u.ID,
+			FullName:           u.FullName,
+			PhoneNumber:        u.PhoneNumber,
+			LoginCount:         u.LoginCount,
+			Role:               u.Role,
+			MustChangePassword: u.MustChangePassword,
+			PasswordChangedAt:  u.PasswordChangedAt,
+			MFAEnabled:         enrolled && m.ConfirmedAt != nil,
+		}
+		return nil
+	})
+
+	return res, nil
+}
+
+func (r *Repository) IncrementLoginCount(id string) error {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		u, ok := s.activeUser(validID.String())
+		if !ok {
+			return cons.ErrUserNotFound
+		}
+
+		u.LoginCount++
+		s.saveUser(u)
+		return nil
+	})
+}
+
+// UpdatePassword moves the replaced hash into the password history.
+func (r *Repository) UpdatePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashSecret(newPassword)
+	if err != nil {
+		return err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	u, ok := r.activeUser(validID.String())
+	if !ok || !matchSecret(u.Password, oldPassword) {
+		return cons.ErrPasswordNotMatch
+	}
+
+	return r.do(func(s *state) error {
+		err := s.audit(actor, u.ID, cons.AuditActionChangePassword, []domain.AuditChange{
+			{Field: "password"},
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		history := append(append([]passwordHistory(nil), s.passwordHistory[u.ID]...), passwordHistory{
+			hash:      u.Password,
+			createdAt: now,
+		})
+		setRow(s, s.passwordHistory, u.ID, history)
+
+		u.Password = hash
+		u.PasswordChangedAt = &now
+		u.MustChangePassword = false
+		s.saveUser(u)
+		return nil
+	})
+}
+
+func (r *Repository) CheckPassword(id string, password string) error {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	u, ok := r.activeUser(validID.String())
+	if !ok || !matchSecret(u.Password, password) {
+		return cons.ErrPasswordNotMatch
+	}
+
+	return nil
+}
+
+func (r *Repository) IsPasswordReused(id string, password string, depth int) (bool, error) {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return false, err
+	}
+
+	if depth < 1 {
+		return false, nil
+	}
+
+	// the hashes are compared without holding the lock of the state, the
+	// current password takes the first place of the window
+	hashes := []string{}
+	_ = r.do(func(s *state) error {
+		if u, ok := s.users[validID.String()]; ok {
+			hashes = append(hashes, u.Password)
+		}
+
+		history := s.passwordHistory[validID.String()]
+		for i := len(history) - 1; i >= 0 && i >= len(history)-(depth-1); i-- {
+			hashes = append(hashes, history[i].hash)
+		}
+
+		return nil
+	})
+
+	for _, hash := range hashes {
+		if matchSecret(hash, password) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *Repository) PrunePasswordHistory(id string, depth int) (int64, error) {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return 0, err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	var deleted int64
+	err = r.do(func(s *state) error {
+		history := s.passwordHistory[validID.String()]
+		if len(history) <= depth {
+			return nil
+		}
+
+		deleted = int64(len(history) - depth)
+		setRow(s, s.passwordHistory, validID.String(), append([]passwordHistory(nil), history[len(history)-depth:]...))
+		return nil
+	})
+
+	return deleted, err
+}
+
+func (r *Repository) SetMustChangePassword(actor domain.Actor, id string, mustChange bool) error {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		u, ok := s.activeUser(validID.String())
+		if !ok {
+			return cons.ErrUserNotFound
+		}
+
+		old := fmt.Sprint(u.MustChangePassword)
+		updated := fmt.Sprint(mustChange)
+		err := s.audit(actor, u.ID, cons.AuditActionForcePasswordChange, []domain.AuditChange{
+			{Field: "must_change_password", Old: &old, New: &updated},
+		})
+		if err != nil {
+			return err
+		}
+
+		u.MustChangePassword = mustChange
+		s.saveUser(u)
+		return nil
+	})
+}
+
+func (r *Repository) SetUserRole(actor domain.Actor, id string, role string) error {
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		u, ok := s.activeUser(validID.String())
+		if !ok {
+			return cons.ErrUserNotFound
+		}
+
+		old := u.Role
+		err := s.audit(actor, u.ID, cons.AuditActionSetRole, []domain.AuditChange{
+			{Field: "role", Old: &old, New: &role},
+		})
+		if err != nil {
+			return err
+		}
+
+		u.Role = role
+		s.saveUser(u)
+		return nil
+	})
+}
+
+// activeUser reads the user with id unless it is deactivated, for hashing
+// outside the lock of the state.
+func (r *Repository) activeUser(id string) (user, bool) {
+	var (
+		u  user
+		ok bool
+	)
+	_ = r.do(func(s *state) error {
+		u, ok = s.activeUser(id)
+		return nil
+	})
+
+	return u, ok
+}
+
+func hashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), hashCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", cons.ErrInvalidPasswordLength
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func matchSecret(hash string, secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+// normalizeIP stores addresses the way the INET columns do, an empty address
+// stays empty.
+func normalizeIP(addr string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid ip address %q", addr)
+	}
+
+	return ip.String(), nil
+}
+
+// page returns the bounds of the limit and offset window over total items.
+func page(total int, limit int, offset int) (int, int) {
+	start := offset
+	if start > total {
+		start = total
+	}
+
+	end := start + limit
+	if end > total {
+		end = total
+	}
+
+	return start, end
+}
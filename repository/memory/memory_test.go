@@ -0,0 +1,65 @@
+package memory_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/repository/memory"
+	"github.com/SawitProRecruitment/UserService/repository/repotest"
+	. "github.com/onsi/gomega"
+)
+
+func TestRepository_Conformance(t *testing.T) {
+	repotest.RunUserRepo(t, func(t *testing.T) port.UserRepo {
+		return memory.New()
+	})
+}
+
+func TestRepository_WithTxOpen(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	repo := memory.New()
+	patched, err := repo.CreateUser(domain.Actor{}, &domain.User{FullName: "Edison Tantra", PhoneNumber: "+6281234567801", Password: "Passw0rd!"})
+	Expect(err).To(BeNil())
+	other, err := repo.CreateUser(domain.Actor{}, &domain.User{FullName: "Mamang Doe", PhoneNumber: "+6281234567802", Password: "Passw0rd!"})
+	Expect(err).To(BeNil())
+
+	errRollback := errors.New("rollback")
+	entered, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- repo.WithTx(context.Background(), func(tx port.UserRepo) error {
+			_, err := tx.PatchUserByID(domain.Actor{}, patched.ID, &domain.User{FullName: "Edison"})
+			if err != nil {
+				return err
+			}
+
+			close(entered)
+			<-release
+			return errRollback
+		})
+	}()
+	<-entered
+
+	// other users are not held up by the open transaction
+	loggedIn := make(chan error, 1)
+	go func() {
+		loggedIn <- repo.IncrementLoginCount(other.ID)
+	}()
+	Eventually(loggedIn, time.Second).Should(Receive(BeNil()))
+
+	close(release)
+	Expect(<-done).To(MatchError(errRollback))
+
+	got, err := repo.GetUserByID(patched.ID)
+	Expect(err).To(BeNil())
+	Expect(got.FullName).To(Equal("Edison Tantra"))
+
+	logged, err := repo.Login(other.PhoneNumber, "Passw0rd!")
+	Expect(err).To(BeNil())
+	Expect(logged.LoginCount).To(Equal(1))
+}
@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+type recoveryCode struct {
+	hash   string
+	usedAt *time.Time
+}
+
+// mfaChallenge is kept in state.mfaChallenges by the id of its token.
+type mfaChallenge struct {
+	attempts  int
+	used      bool
+	expiresAt time.Time
+}
+
+func (r *Repository) GetMFA(userID string) (*domain.MFA, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *domain.MFA
+	err = r.do(func(s *state) error {
+		m, ok := s.mfa[validID.String()]
+		if !ok {
+			return cons.ErrMFANotEnrolled
+		}
+
+		res = &m
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// SaveTOTPSecret starts or restarts an enrolment, a confirmed enrolment is
+// never overwritten.
+func (r *Repository) SaveTOTPSecret(userID string, encryptedSecret string) error {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		id := validID.String()
+		if _, ok := s.users[id]; !ok {
+			return cons.ErrUserNotFound
+		}
+
+		if m, ok := s.mfa[id]; ok && m.ConfirmedAt != nil {
+			return cons.ErrMFAAlreadyEnabled
+		}
+
+		now := time.Now()
+		setRow(s, s.mfa, id, domain.MFA{
+			UserID:     id,
+			TOTPSecret: encryptedSecret,
+			CreatedAt:  &now,
+		})
+		return nil
+	})
+}
+
+// ConfirmTOTP enables the enrolment and replaces the recovery codes, which
+// are stored hashed like passwords.
+func (r *Repository) ConfirmTOTP(userID string, recoveryCodes []string) error {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+
+	codes := make([]recoveryCode, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hash, err := hashSecret(code)
+		if err != nil {
+			return err
+		}
+
+		codes = append(codes, recoveryCode{hash: hash})
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		id := validID.String()
+		m, ok := s.mfa[id]
+		if !ok || m.ConfirmedAt != nil {
+			return cons.ErrMFANotEnrolled
+		}
+
+		now := time.Now()
+		m.ConfirmedAt = &now
+		setRow(s, s.mfa, id, m)
+		setRow(s, s.recoveryCodes, id, codes)
+		return nil
+	})
+}
+
+func (r *Repository) UseRecoveryCode(userID string, code string) (bool, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, err
+	}
+
+	id := validID.String()
+	unlock := r.lockUser(id)
+	defer unlock()
+
+	// the codes are compared without holding the lock of the state, the
+	// lock of the user keeps them from changing meanwhile
+	var codes []recoveryCode
+	_ = r.do(func(s *state) error {
+		codes = s.recoveryCodes[id]
+		return nil
+	})
+
+	for i, c := range codes {
+		if c.usedAt != nil || !matchSecret(c.hash, code) {
+			continue
+		}
+
+		err = r.do(func(s *state) error {
+			updated := append([]recoveryCode(nil), codes...)
+			now := time.Now()
+			updated[i].usedAt = &now
+			setRow(s, s.recoveryCodes, id, updated)
+			return nil
+		})
+
+		return err == nil, err
+	}
+
+	return false, nil
+}
+
+func (r *Repository) UseTOTPStep(userID string, step int64) (bool, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	used := false
+	err = r.do(func(s *state) error {
+		m, ok := s.mfa[validID.String()]
+		if !ok || m.LastTOTPStep >= step {
+			return nil
+		}
+
+		m.LastTOTPStep = step
+		setRow(s, s.mfa, m.UserID, m)
+		used = true
+		return nil
+	})
+
+	return used, err
+}
+
+func (r *Repository) CountMFAAttempt(challengeID string, userID string, expiresAt time.Time) (int, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	attempts := 0
+	err = r.do(func(s *state) error {
+		if _, ok := s.users[validID.String()]; !ok {
+			return cons.ErrUserNotFound
+		}
+
+		c, ok := s.mfaChallenges[challengeID]
+		if !ok {
+			c = mfaChallenge{expiresAt: expiresAt}
+		}
+
+		c.attempts++
+		setRow(s, s.mfaChallenges, challengeID, c)
+		attempts = c.attempts
+		return nil
+	})
+
+	return attempts, err
+}
+
+func (r *Repository) UseMFAChallenge(challengeID string) (bool, error) {
+	used := false
+	err := r.do(func(s *state) error {
+		c, ok := s.mfaChallenges[challengeID]
+		if !ok || c.used {
+			return nil
+		}
+
+		c.used = true
+		setRow(s, s.mfaChallenges, challengeID, c)
+		used = true
+		return nil
+	})
+
+	return used, err
+}
+
+func (r *Repository) DeleteMFAChallengesExpiredBefore(before time.Time) (int64, error) {
+	var deleted int64
+	err := r.do(func(s *state) error {
+		for id, c := range s.mfaChallenges {
+			if c.expiresAt.Before(before) {
+				deleteRow(s, s.mfaChallenges, id)
+				deleted++
+			}
+		}
+		return nil
+	})
+
+	return deleted, err
+}
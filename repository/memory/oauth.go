@@ -0,0 +1,134 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.OAuthRepo = (*Repository)(nil)
+
+// oauthCode is kept in state.oauthCodes by its hash.
+type oauthCode struct {
+	domain.OAuthCode
+	used bool
+}
+
+func (r *Repository) CreateOAuthClient(data *domain.OAuthClient) (*domain.OAuthClient, error) {
+	now := time.Now()
+	c := domain.OAuthClient{
+		ID:           uuid.NewString(),
+		Name:         data.Name,
+		RedirectURIs: append([]string(nil), data.RedirectURIs...),
+		Confidential: data.Confidential,
+		Secret:       data.Secret,
+		CreatedAt:    &now,
+	}
+
+	err := r.do(func(s *state) error {
+		setRow(s, s.oauthClients, c.ID, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+func (r *Repository) GetOAuthClient(id string) (*domain.OAuthClient, error) {
+	var res *domain.OAuthClient
+	err := r.do(func(s *state) error {
+		c, ok := s.oauthClients[id]
+		if !ok {
+			return cons.ErrOAuthClientNotFound
+		}
+
+		res = &c
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) ListOAuthClients() ([]domain.OAuthClient, error) {
+	res := []domain.OAuthClient{}
+	err := r.do(func(s *state) error {
+		for _, c := range s.oauthClients {
+			res = append(res, c)
+		}
+		return nil
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(*res[j].CreatedAt) })
+	return res, err
+}
+
+func (r *Repository) DeleteOAuthClient(id string) error {
+	return r.do(func(s *state) error {
+		if _, ok := s.oauthClients[id]; !ok {
+			return cons.ErrOAuthClientNotFound
+		}
+
+		deleteRow(s, s.oauthClients, id)
+		for hash, c := range s.oauthCodes {
+			if c.ClientID == id {
+				deleteRow(s, s.oauthCodes, hash)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) CreateOAuthCode(data *domain.OAuthCode) error {
+	now := time.Now()
+	c := *data
+	c.CreatedAt = &now
+
+	return r.do(func(s *state) error {
+		if _, ok := s.oauthClients[c.ClientID]; !ok {
+			return cons.ErrOAuthClientNotFound
+		}
+
+		setRow(s, s.oauthCodes, c.CodeHash, oauthCode{OAuthCode: c})
+		return nil
+	})
+}
+
+func (r *Repository) ConsumeOAuthCode(codeHash string) (*domain.OAuthCode, error) {
+	var res *domain.OAuthCode
+	err := r.do(func(s *state) error {
+		c, ok := s.oauthCodes[codeHash]
+		if !ok || c.used || !c.ExpiresAt.After(time.Now()) {
+			return cons.ErrInvalidGrant
+		}
+
+		c.used = true
+		setRow(s, s.oauthCodes, codeHash, c)
+
+		res = &c.OAuthCode
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) DeleteOAuthCodesExpiredBefore(before time.Time) (int64, error) {
+	var deleted int64
+	err := r.do(func(s *state) error {
+		for hash, c := range s.oauthCodes {
+			if c.ExpiresAt.Before(before) {
+				deleteRow(s, s.oauthCodes, hash)
+				deleted++
+			}
+		}
+		return nil
+	})
+
+	return deleted, err
+}
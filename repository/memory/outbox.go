@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.OutboxRepo = (*Repository)(nil)
+
+type outboxEvent struct {
+	domain.Event
+	lastError      string
+	lockedUntil    time.Time
+	publishedAt    *time.Time
+	deadLetteredAt *time.Time
+}
+
+func (r *Repository) CreateOutboxEvent(event domain.EventPayload) error {
+	aggregateID, err := uuid.Parse(event.AggregateID())
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return r.do(func(s *state) error {
+		appendRow(s, &s.outbox, outboxEvent{
+			Event: domain.Event{
+				ID:          uuid.NewString(),
+				Type:        event.EventType(),
+				AggregateID: aggregateID.String(),
+				Payload:     payload,
+				OccurredAt:  time.Now(),
+			},
+		}, outboxEventID)
+		return nil
+	})
+}
+
+func (r *Repository) ClaimOutboxEvents(limit int, lease time.Duration) ([]domain.Event, error) {
+	res := []domain.Event{}
+	err := r.do(func(s *state) error {
+		now := time.Now()
+		// aggregates with a leased event, their later events wait for it
+		held := map[string]bool{}
+		for i := range s.outbox {
+			if len(res) == limit {
+				break
+			}
+
+			e := s.outbox[i]
+			if e.publishedAt != nil || e.deadLetteredAt != nil {
+				continue
+			}
+
+			if e.lockedUntil.After(now) {
+				held[e.AggregateID] = true
+				continue
+			}
+
+			if held[e.AggregateID] {
+				continue
+			}
+
+			updateRow(s, &s.outbox, i, outboxEventID, func(e *outboxEvent) {
+				e.lockedUntil = now.Add(lease)
+			})
+			res = append(res, s.outbox[i].Event)
+		}
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) MarkOutboxEventPublished(id string) error {
+	return r.do(func(s *state) error {
+		if i := s.outboxEvent(id); i >= 0 {
+			updateRow(s, &s.outbox, i, outboxEventID, func(e *outboxEvent) {
+				now := time.Now()
+				e.publishedAt = &now
+				e.lockedUntil = time.Time{}
+			})
+		}
+		return nil
+	})
+}
+
+func (r *Repository) RecordOutboxEventFailure(failure domain.OutboxFailure) error {
+	return r.do(func(s *state) error {
+		if i := s.outboxEvent(failure.EventID); i >= 0 && s.outbox[i].publishedAt == nil {
+			updateRow(s, &s.outbox, i, outboxEventID, func(e *outboxEvent) {
+				e.Attempts++
+				e.lastError = failure.Error
+				e.lockedUntil = failure.RetryAt
+				if failure.DeadLetter {
+					now := time.Now()
+					e.deadLetteredAt = &now
+					e.lockedUntil = time.Time{}
+				}
+			})
+		}
+		return nil
+	})
+}
+
+func (r *Repository) DeleteOutboxEventsPublishedBefore(before time.Time) (int64, error) {
+	var n int64
+	err := r.do(func(s *state) error {
+		n = deleteRows(s, &s.outbox, func(e outboxEvent) bool {
+			return e.publishedAt != nil && e.publishedAt.Before(before)
+		})
+		return nil
+	})
+
+	return n, err
+}
+
+// outboxEvent is the index of the event with id, -1 when there is none.
+func (s *state) outboxEvent(id string) int {
+	for i := range s.outbox {
+		if s.outbox[i].ID == id {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func outboxEventID(e outboxEvent) string { return e.ID }
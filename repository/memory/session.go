@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+type session struct {
+	domain.Session
+	revokedAt *time.Time
+}
+
+// active reports whether the session is neither revoked nor expired.
+func (s session) active(now time.Time) bool {
+	return s.revokedAt == nil && s.ExpiresAt != nil && s.ExpiresAt.After(now)
+}
+
+func (r *Repository) CreateSession(data *domain.Session) (*domain.Session, error) {
+	validID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ip, err := normalizeIP(data.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	var res *domain.Session
+	err = r.do(func(s *state) error {
+		if _, ok := s.users[validID.String()]; !ok {
+			return cons.ErrUserNotFound
+		}
+
+		now := time.Now()
+		created := session{
+			Session: domain.Session{
+				ID:         uuid.NewString(),
+				UserID:     validID.String(),
+				UserAgent:  data.UserAgent,
+				IPAddress:  ip,
+				CreatedAt:  &now,
+				LastSeenAt: &now,
+				ExpiresAt:  data.ExpiresAt,
+			},
+		}
+
+		setRow(s, s.sessions, created.ID, created)
+		res = &created.Session
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// TouchSession bumps last_seen_at of an active session and fails with
+// cons.ErrSessionRevoked when it is revoked or expired.
+func (r *Repository) TouchSession(userID string, sessionID string) error {
+	validUserID, validID, err := sessionIDs(userID, sessionID)
+	if err != nil {
+		return cons.ErrSessionRevoked
+	}
+
+	unlock := r.lockUser(validUserID)
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		now := time.Now()
+		sess, ok := s.sessions[validID]
+		if !ok || sess.UserID != validUserID || !sess.active(now) {
+			return cons.ErrSessionRevoked
+		}
+
+		sess.LastSeenAt = &now
+		setRow(s, s.sessions, validID, sess)
+		return nil
+	})
+}
+
+func (r *Repository) ListSessions(userID string) ([]domain.Session, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []domain.Session{}
+	err = r.do(func(s *state) error {
+		now := time.Now()
+		for _, sess := range s.sessions {
+			if sess.UserID == validID.String() && sess.active(now) {
+				res = append(res, sess.Session)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].LastSeenAt.After(*res[j].LastSeenAt)
+	})
+
+	return res, nil
+}
+
+func (r *Repository) RevokeSession(userID string, sessionID string) error {
+	validUserID, validID, err := sessionIDs(userID, sessionID)
+	if err != nil {
+		return cons.ErrSessionNotFound
+	}
+
+	unlock := r.lockUser(validUserID)
+	defer unlock()
+
+	return r.do(func(s *state) error {
+		sess, ok := s.sessions[validID]
+		if !ok || sess.UserID != validUserID || sess.revokedAt != nil {
+			return cons.ErrSessionNotFound
+		}
+
+		now := time.Now()
+		sess.revokedAt = &now
+		setRow(s, s.sessions, validID, sess)
+		return nil
+	})
+}
+
+// RevokeUserSessions revokes every active session of the user except
+// exceptSessionID, which may be empty to revoke all of them.
+func (r *Repository) RevokeUserSessions(userID string, exceptSessionID string) (int64, error) {
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	exceptID := ""
+	if exceptSessionID != "" {
+		validExceptID, err := uuid.Parse(exceptSessionID)
+		if err != nil {
+			return 0, err
+		}
+
+		exceptID = validExceptID.String()
+	}
+
+	unlock := r.lockUser(validID.String())
+	defer unlock()
+
+	var n int64
+	err = r.do(func(s *state) error {
+		now := time.Now()
+		for id, sess := range s.sessions {
+			if sess.UserID != validID.String() || sess.revokedAt != nil || id == exceptID {
+				continue
+			}
+
+			sess.revokedAt = &now
+			setRow(s, s.sessions, id, sess)
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+func sessionIDs(userID string, sessionID string) (string, string, error) {
+	validUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	validID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return validUserID.String(), validID.String(), nil
+}
@@ -0,0 +1,236 @@
+package memory
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.WebhookRepo = (*Repository)(nil)
+
+// webhookDelivery is kept in state.deliveries in the order it was queued.
+type webhookDelivery struct {
+	domain.WebhookDelivery
+	lockedUntil time.Time
+}
+
+func (r *Repository) CreateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	now := time.Now()
+	w := domain.Webhook{
+		ID:         uuid.NewString(),
+		URL:        data.URL,
+		EventTypes: append([]string(nil), data.EventTypes...),
+		Secret:     data.Secret,
+		CreatedAt:  &now,
+	}
+
+	err := r.do(func(s *state) error {
+		setRow(s, s.webhooks, w.ID, w)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func (r *Repository) ListWebhooks() ([]domain.Webhook, error) {
+	res := []domain.Webhook{}
+	err := r.do(func(s *state) error {
+		for _, w := range s.webhooks {
+			res = append(res, w)
+		}
+		return nil
+	})
+
+	sort.Slice(res, func(i, j int) bool { return res[i].CreatedAt.Before(*res[j].CreatedAt) })
+	return res, err
+}
+
+func (r *Repository) GetWebhook(id string) (*domain.Webhook, error) {
+	var res *domain.Webhook
+	err := r.do(func(s *state) error {
+		w, ok := s.webhooks[id]
+		if !ok {
+			return cons.ErrWebhookNotFound
+		}
+
+		res = &w
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) UpdateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	var res *domain.Webhook
+	err := r.do(func(s *state) error {
+		w, ok := s.webhooks[data.ID]
+		if !ok {
+			return cons.ErrWebhookNotFound
+		}
+
+		w.URL = data.URL
+		w.EventTypes = append([]string(nil), data.EventTypes...)
+		if data.Secret != "" {
+			w.Secret = data.Secret
+		}
+
+		setRow(s, s.webhooks, w.ID, w)
+		res = &w
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) DeleteWebhook(id string) error {
+	return r.do(func(s *state) error {
+		if _, ok := s.webhooks[id]; !ok {
+			return cons.ErrWebhookNotFound
+		}
+
+		deleteRow(s, s.webhooks, id)
+		deleteRows(s, &s.deliveries, func(d webhookDelivery) bool {
+			return d.WebhookID == id
+		})
+		return nil
+	})
+}
+
+func (r *Repository) EnqueueWebhookDeliveries(event *domain.Event) (int64, error) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	err = r.do(func(s *state) error {
+		queued := map[string]bool{}
+		for _, d := range s.deliveries {
+			if d.EventID == event.ID {
+				queued[d.WebhookID] = true
+			}
+		}
+
+		now := time.Now()
+		for _, w := range s.webhooks {
+			if queued[w.ID] || !contains(w.EventTypes, event.Type) {
+				continue
+			}
+
+			nextAttemptAt, createdAt := now, now
+			appendRow(s, &s.deliveries, webhookDelivery{
+				WebhookDelivery: domain.WebhookDelivery{
+					ID:            uuid.NewString(),
+					WebhookID:     w.ID,
+					EventID:       event.ID,
+					EventType:     event.Type,
+					Payload:       payload,
+					Status:        cons.WebhookStatusPending,
+					NextAttemptAt: &nextAttemptAt,
+					CreatedAt:     &createdAt,
+				},
+			}, webhookDeliveryID)
+			n++
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+func (r *Repository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	res := []domain.WebhookDelivery{}
+	err := r.do(func(s *state) error {
+		now := time.Now()
+		due := []int{}
+		for i, d := range s.deliveries {
+			if d.Status == cons.WebhookStatusPending && !d.NextAttemptAt.After(now) && !d.lockedUntil.After(now) {
+				due = append(due, i)
+			}
+		}
+
+		sort.SliceStable(due, func(i, j int) bool {
+			return s.deliveries[due[i]].NextAttemptAt.Before(*s.deliveries[due[j]].NextAttemptAt)
+		})
+		for _, i := range due {
+			if len(res) == limit {
+				break
+			}
+
+			updateRow(s, &s.deliveries, i, webhookDeliveryID, func(d *webhookDelivery) {
+				d.lockedUntil = now.Add(lease)
+			})
+			res = append(res, s.deliveries[i].WebhookDelivery)
+		}
+		return nil
+	})
+
+	return res, err
+}
+
+func (r *Repository) RecordWebhookAttempt(attempt domain.WebhookAttempt) error {
+	return r.do(func(s *state) error {
+		for i := range s.deliveries {
+			if s.deliveries[i].ID != attempt.DeliveryID {
+				continue
+			}
+
+			updateRow(s, &s.deliveries, i, webhookDeliveryID, func(d *webhookDelivery) {
+				nextAttemptAt := attempt.NextAttemptAt
+				d.Status = attempt.Status
+				d.Attempts++
+				d.LastStatusCode = attempt.StatusCode
+				d.LastError = attempt.Error
+				d.NextAttemptAt = &nextAttemptAt
+				d.lockedUntil = time.Time{}
+				if attempt.Status == cons.WebhookStatusSucceeded {
+					now := time.Now()
+					d.DeliveredAt = &now
+				}
+			})
+		}
+		return nil
+	})
+}
+
+func (r *Repository) ListWebhookDeliveries(webhookID string, limit int, offset int) ([]domain.WebhookDelivery, int, error) {
+	res := []domain.WebhookDelivery{}
+	total := 0
+	err := r.do(func(s *state) error {
+		// newest first
+		for i := len(s.deliveries) - 1; i >= 0; i-- {
+			d := s.deliveries[i]
+			if d.WebhookID != webhookID {
+				continue
+			}
+
+			if total >= offset && len(res) < limit {
+				res = append(res, d.WebhookDelivery)
+			}
+			total++
+		}
+		return nil
+	})
+
+	return res, total, err
+}
+
+func webhookDeliveryID(d webhookDelivery) string { return d.ID }
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+
+	return false
+}
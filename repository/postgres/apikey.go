@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.APIKeyRepo = (*Repository)(nil)
+
+const apiKeyColumns = `id, name, user_id, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+// CreateAPIKey inserts the key only when its owner is active, no row is
+// cons.ErrUserNotFound.
+func (r *Repository) CreateAPIKey(data *domain.APIKey) (*domain.APIKey, error) {
+	q := `
+		INSERT INTO api_keys (name, user_id, prefix, key_hash, scopes, expires_at)
+		SELECT :name, id, :prefix, :key_hash, :scopes, :expires_at
+		FROM users
+		WHERE id = :user_id AND is_active = TRUE
+		RETURNING ` + apiKeyColumns + `;
+	`
+
+	userID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		return nil, cons.ErrUserNotFound
+	}
+
+	arg := APIKey{
+		Name:      data.Name,
+		UserID:    userID,
+		Prefix:    data.Prefix,
+		KeyHash:   data.KeyHash,
+		Scopes:    data.Scopes,
+		ExpiresAt: data.ExpiresAt,
+	}
+
+	res, err := r.getAPIKey(q, &arg)
+	if errors.Is(err, cons.ErrAPIKeyNotFound) {
+		return nil, cons.ErrUserNotFound
+	}
+
+	return res, err
+}
+
+func (r *Repository) GetAPIKeyByPrefix(prefix string) (*domain.APIKey, error) {
+	q := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE prefix = :prefix;
+	`
+
+	return r.getAPIKey(q, &APIKey{Prefix: prefix})
+}
+
+func (r *Repository) ListAPIKeys() ([]domain.APIKey, error) {
+	q := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		ORDER BY created_at, id;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &APIKey{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.APIKey{}
+	for rows.Next() {
+		k := APIKey{}
+		err = rows.StructScan(&k)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *toDomainAPIKey(k))
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) RevokeAPIKey(id string) error {
+	q := `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW())
+		WHERE id = :id
+		RETURNING ` + apiKeyColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrAPIKeyNotFound
+	}
+
+	_, err = r.getAPIKey(q, &APIKey{ID: validID})
+	return err
+}
+
+func (r *Repository) TouchAPIKey(id string) error {
+	q := `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = :id
+		RETURNING ` + apiKeyColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrAPIKeyNotFound
+	}
+
+	_, err = r.getAPIKey(q, &APIKey{ID: validID})
+	return err
+}
+
+// getAPIKey runs q returning one key, no row is cons.ErrAPIKeyNotFound.
+func (r *Repository) getAPIKey(q string, arg *APIKey) (*domain.APIKey, error) {
+	rows, err := r.sawitDB.NamedQuery(q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrAPIKeyNotFound
+	}
+
+	k := APIKey{}
+	err = rows.StructScan(&k)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainAPIKey(k), nil
+}
+
+func toDomainAPIKey(k APIKey) *domain.APIKey {
+	return &domain.APIKey{
+		ID:         k.ID.String(),
+		Name:       k.Name,
+		UserID:     k.UserID.String(),
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scopes:     []string(k.Scopes),
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
@@ -0,0 +1,108 @@
+package postgres
+
+import (
+	"encoding/json"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+func newAuditArg(actor domain.Actor, action string) (AuditArg, error) {
+	arg := AuditArg{
+		RequestID: actor.RequestID,
+		Action:    action,
+	}
+
+	if actor.UserID != "" {
+		actorID, err := uuid.Parse(actor.UserID)
+		if err != nil {
+			return AuditArg{}, err
+		}
+
+		arg.ActorID = &actorID
+	}
+
+	return arg, nil
+}
+
+func (r *Repository) ListAuditEntries(filter domain.AuditFilter, limit int, offset int) ([]domain.AuditEntry, int, error) {
+	const where = `
+		WHERE user_id = :user_id
+		AND (CAST(:from AS TIMESTAMPTZ) IS NULL OR created_at >= :from)
+		AND (CAST(:to AS TIMESTAMPTZ) IS NULL OR created_at < :to)
+	`
+
+	q := `
+		SELECT id, user_id, actor_id, action, changes, request_id, created_at
+		FROM user_audit_log
+	` + where + `
+		ORDER BY created_at DESC
+		LIMIT :limit OFFSET :offset;
+	`
+
+	validID, err := uuid.Parse(filter.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	arg := AuditListArg{
+		UserID: validID,
+		From:   filter.From,
+		To:     filter.To,
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := []domain.AuditEntry{}
+	for rows.Next() {
+		e := AuditEntry{}
+		err = rows.StructScan(&e)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		changes := []domain.AuditChange{}
+		err = json.Unmarshal(e.Changes, &changes)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		res = append(res, domain.AuditEntry{
+			ID:        e.ID.String(),
+			UserID:    e.UserID.String(),
+			ActorID:   e.ActorID.String(),
+			Action:    e.Action,
+			Changes:   changes,
+			RequestID: e.RequestID,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	q = `SELECT COUNT(*) FROM user_audit_log ` + where + `;`
+
+	countRows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer countRows.Close()
+
+	total := 0
+	if countRows.Next() {
+		err = countRows.Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return res, total, countRows.Err()
+}
@@ -0,0 +1,135 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.IdempotencyRepo = (*Repository)(nil)
+
+// ReserveIdempotencyKey inserts rec, or takes over an expired record with
+// the same caller and key, in one statement. When neither happens the live
+// record is returned, a key reserved by a concurrent request after the
+// statement started is reported as in progress.
+func (r *Repository) ReserveIdempotencyKey(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	q := `
+		WITH reserved AS (
+			INSERT INTO idempotency_keys (caller, key, fingerprint, expires_at)
+			VALUES (:caller, :key, :fingerprint, :expires_at)
+			ON CONFLICT (caller, key) DO UPDATE SET
+				fingerprint = EXCLUDED.fingerprint,
+				status_code = NULL,
+				content_type = '',
+				response_body = NULL,
+				created_at = NOW(),
+				expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= NOW()
+			RETURNING key
+		)
+		SELECT key, '' AS fingerprint, 0 AS status_code, '' AS content_type,
+			NULL AS response_body, CAST(:expires_at AS TIMESTAMPTZ) AS expires_at, TRUE AS reserved
+		FROM reserved
+		UNION ALL
+		SELECT key, fingerprint, COALESCE(status_code, 0), content_type,
+			response_body, expires_at, FALSE
+		FROM idempotency_keys
+		WHERE caller = :caller AND key = :key
+		AND NOT EXISTS (SELECT 1 FROM reserved);
+	`
+
+	arg := IdempotencyKey{
+		Caller:      rec.Caller,
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		ExpiresAt:   rec.ExpiresAt,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrIdempotencyInProgress
+	}
+
+	res := IdempotencyKey{}
+	err = rows.StructScan(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Reserved {
+		return nil, nil
+	}
+
+	return &domain.IdempotencyRecord{
+		Caller:      rec.Caller,
+		Key:         res.Key,
+		Fingerprint: res.Fingerprint,
+		StatusCode:  res.StatusCode,
+		ContentType: res.ContentType,
+		Body:        res.ResponseBody,
+		ExpiresAt:   res.ExpiresAt,
+	}, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(rec *domain.IdempotencyRecord) error {
+	q := `
+		UPDATE idempotency_keys
+		SET status_code = :status_code, content_type = :content_type, response_body = :response_body
+		WHERE caller = :caller AND key = :key AND fingerprint = :fingerprint AND status_code IS NULL;
+	`
+
+	arg := IdempotencyKey{
+		Caller:       rec.Caller,
+		Key:          rec.Key,
+		Fingerprint:  rec.Fingerprint,
+		StatusCode:   rec.StatusCode,
+		ContentType:  rec.ContentType,
+		ResponseBody: rec.Body,
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+// DeleteIdempotencyKey drops a reservation that never completed, so the
+// request can be retried with the same key.
+func (r *Repository) DeleteIdempotencyKey(rec *domain.IdempotencyRecord) error {
+	q := `
+		DELETE FROM idempotency_keys
+		WHERE caller = :caller AND key = :key AND fingerprint = :fingerprint AND status_code IS NULL;
+	`
+
+	arg := IdempotencyKey{
+		Caller:      rec.Caller,
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &IdempotencyPruneArg{Before: before})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
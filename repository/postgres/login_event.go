@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+// CreateLoginEvent records a login attempt. Failed attempts usually only
+// know the phone number, the owner of it is looked up so the attempt shows
+// in their history.
+func (r *Repository) CreateLoginEvent(data *domain.LoginEvent) error {
+	q := `
+		INSERT INTO login_events (user_id, phone_number, success, reason, user_agent, ip_address)
+		VALUES (
+			COALESCE(
+				CAST(:user_id AS UUID),
+				(SELECT id FROM users WHERE phone_number = :phone_number)
+			),
+			:phone_number, :success, :reason, :user_agent,
+			CAST(NULLIF(:ip_address, '') AS INET)
+		);
+	`
+
+	arg := LoginEvent{
+		PhoneNumber: data.PhoneNumber,
+		Success:     data.Success,
+		Reason:      data.Reason,
+		UserAgent:   data.UserAgent,
+		IPAddress:   data.IPAddress,
+	}
+
+	if data.UserID != "" {
+		validID, err := uuid.Parse(data.UserID)
+		if err != nil {
+			return err
+		}
+
+		arg.UserID = &validID
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) ListLoginEvents(userID string, limit int, offset int) ([]domain.LoginEvent, int, error) {
+	q := `
+		SELECT id, user_id, phone_number, success, reason, user_agent,
+			COALESCE(host(ip_address), '') AS ip_address, created_at
+		FROM login_events
+		WHERE user_id = :user_id
+		ORDER BY created_at DESC
+		LIMIT :limit OFFSET :offset;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	arg := LoginEventListArg{
+		UserID: validID,
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := []domain.LoginEvent{}
+	for rows.Next() {
+		e := LoginEvent{}
+		err = rows.StructScan(&e)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		res = append(res, domain.LoginEvent{
+			ID:          e.ID.String(),
+			UserID:      userID,
+			PhoneNumber: e.PhoneNumber,
+			Success:     e.Success,
+			Reason:      e.Reason,
+			UserAgent:   e.UserAgent,
+			IPAddress:   e.IPAddress,
+			CreatedAt:   e.CreatedAt,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	q = `
+		SELECT COUNT(*) FROM login_events
+		WHERE user_id = :user_id;
+	`
+
+	countRows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer countRows.Close()
+
+	total := 0
+	if countRows.Next() {
+		err = countRows.Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return res, total, countRows.Err()
+}
+
+func (r *Repository) DeleteLoginEventsBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM login_events
+		WHERE created_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &LoginEventPruneArg{Before: before})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,265 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+func (r *Repository) GetMFA(userID string) (*domain.MFA, error) {
+	q := `
+		SELECT user_id, totp_secret, confirmed_at, created_at, last_totp_step
+		FROM user_mfa
+		WHERE user_id = :user_id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &MFA{UserID: validID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrMFANotEnrolled
+	}
+
+	m := MFA{}
+	err = rows.StructScan(&m)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.MFA{
+		UserID:      m.UserID.String(),
+		TOTPSecret:  m.TOTPSecret,
+		ConfirmedAt: m.ConfirmedAt,
+		CreatedAt:   m.CreatedAt,
+
+		LastTOTPStep: m.LastTOTPStep,
+	}, nil
+}
+
+// SaveTOTPSecret starts or restarts an enrolment, a confirmed enrolment is
+// never overwritten.
+func (r *Repository) SaveTOTPSecret(userID string, encryptedSecret string) error {
+	q := `
+		INSERT INTO user_mfa (user_id, totp_secret)
+		VALUES (:user_id, :totp_secret)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = EXCLUDED.totp_secret, created_at = NOW()
+		WHERE user_mfa.confirmed_at IS NULL
+		RETURNING user_id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+
+	arg := MFA{
+		UserID:     validID,
+		TOTPSecret: encryptedSecret,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// ConfirmTOTP enables the enrolment and replaces the recovery codes, which
+// are stored hashed like passwords.
+func (r *Repository) ConfirmTOTP(userID string, recoveryCodes []string) error {
+	q := `
+		WITH confirmed AS (
+			UPDATE user_mfa SET confirmed_at = NOW()
+			WHERE user_id = :user_id AND confirmed_at IS NULL
+			RETURNING user_id
+		), cleared AS (
+			DELETE FROM user_recovery_codes
+			WHERE user_id IN (SELECT user_id FROM confirmed)
+		)
+		INSERT INTO user_recovery_codes (user_id, code_hash)
+		SELECT confirmed.user_id, crypt(code, gen_salt('bf', 10))
+		FROM confirmed, unnest(CAST(:codes AS TEXT[])) AS code
+		RETURNING user_id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+
+	arg := RecoveryCodesArg{
+		UserID: validID,
+		Codes:  recoveryCodes,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrMFANotEnrolled
+	}
+
+	return nil
+}
+
+func (r *Repository) UseRecoveryCode(userID string, code string) (bool, error) {
+	q := `
+		UPDATE user_recovery_codes SET used_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_recovery_codes
+			WHERE user_id = :user_id
+			AND used_at IS NULL
+			AND code_hash = crypt(:code, code_hash)
+			LIMIT 1
+			FOR UPDATE
+		)
+		RETURNING id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, err
+	}
+
+	arg := RecoveryCodeArg{
+		UserID: validID,
+		Code:   code,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	used := rows.Next()
+	return used, rows.Err()
+}
+
+func (r *Repository) UseTOTPStep(userID string, step int64) (bool, error) {
+	q := `
+		UPDATE user_mfa SET last_totp_step = :step
+		WHERE user_id = :user_id AND last_totp_step < :step;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &TOTPStepArg{UserID: validID, Step: step})
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *Repository) CountMFAAttempt(challengeID string, userID string, expiresAt time.Time) (int, error) {
+	q := `
+		INSERT INTO mfa_challenges (id, user_id, attempts, expires_at)
+		VALUES (:id, :user_id, 1, :expires_at)
+		ON CONFLICT (id) DO UPDATE
+		SET attempts = mfa_challenges.attempts + 1
+		RETURNING attempts;
+	`
+
+	validID, err := uuid.Parse(challengeID)
+	if err != nil {
+		return 0, err
+	}
+
+	validUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	arg := MFAChallenge{
+		ID:        validID,
+		UserID:    validUserID,
+		ExpiresAt: expiresAt,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	attempts := 0
+	if rows.Next() {
+		err = rows.Scan(&attempts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return attempts, rows.Err()
+}
+
+func (r *Repository) UseMFAChallenge(challengeID string) (bool, error) {
+	q := `
+		UPDATE mfa_challenges SET used_at = NOW()
+		WHERE id = :id AND used_at IS NULL;
+	`
+
+	validID, err := uuid.Parse(challengeID)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &MFAChallenge{ID: validID})
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *Repository) DeleteMFAChallengesExpiredBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM mfa_challenges
+		WHERE expires_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &MFAChallengePruneArg{Before: before})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
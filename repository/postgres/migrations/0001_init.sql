@@ -0,0 +1,134 @@
+/**
+  Schema of database.sql up to the idempotency keys. Databases created from
+  database.sql before migrations existed have some or all of it already, the
+  statements skip what exists so they are adopted by running every migration.
+  */
+
+CREATE EXTENSION IF NOT EXISTS "pgcrypto";
+
+CREATE TABLE IF NOT EXISTS users (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	full_name VARCHAR (60) NOT NULL,
+	phone_number VARCHAR (20) UNIQUE NOT NULL,
+	login_count INT DEFAULT 0,
+	password TEXT NOT NULL,
+	is_active BOOLEAN DEFAULT TRUE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_changed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+ALTER TABLE users ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR (20) NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS user_password_history (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_password_history_user_id_created_at_idx ON user_password_history (user_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS user_mfa (
+	user_id uuid PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	totp_secret TEXT NOT NULL,
+	confirmed_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS user_recovery_codes (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
+
+CREATE TABLE IF NOT EXISTS user_sessions (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address INET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	last_seen_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	revoked_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS user_sessions_user_id_idx ON user_sessions (user_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS login_events (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NULL REFERENCES users (id) ON DELETE CASCADE,
+	phone_number TEXT NOT NULL DEFAULT '',
+	success BOOLEAN NOT NULL,
+	reason VARCHAR (30) NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address INET NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS login_events_user_id_created_at_idx ON login_events (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS login_events_created_at_idx ON login_events (created_at);
+
+-- append-only, rows are written in the same statement as the mutation
+CREATE TABLE IF NOT EXISTS user_audit_log (
+	id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id uuid NOT NULL REFERENCES users (id),
+	actor_id uuid NOT NULL REFERENCES users (id),
+	action VARCHAR (40) NOT NULL,
+	changes JSONB NOT NULL DEFAULT '[]',
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS user_audit_log_user_id_created_at_idx ON user_audit_log (user_id, created_at DESC);
+
+-- responses replayed for retries carrying the same Idempotency-Key,
+-- status_code is NULL while the first request is in progress
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	key VARCHAR (255) PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status_code INT NULL,
+	content_type TEXT NOT NULL DEFAULT '',
+	response_body BYTEA NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- function for update updated_at
+CREATE OR REPLACE FUNCTION update_updated_at_column() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    NEW.updated_at = NOW();
+    RETURN NEW;
+END;
+$$;
+
+-- function for keeping audit log append-only
+CREATE OR REPLACE FUNCTION reject_audit_log_change() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+    RAISE EXCEPTION 'user_audit_log is append-only';
+END;
+$$;
+
+DROP TRIGGER IF EXISTS users_updated_at ON users;
+CREATE TRIGGER users_updated_at BEFORE
+UPDATE
+    ON
+    users FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS user_audit_log_append_only ON user_audit_log;
+CREATE TRIGGER user_audit_log_append_only BEFORE
+UPDATE OR DELETE
+    ON
+    user_audit_log FOR EACH ROW EXECUTE FUNCTION reject_audit_log_change();
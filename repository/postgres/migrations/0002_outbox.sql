@@ -0,0 +1,16 @@
+-- outbox holds domain events until the relay publishes them, seq keeps them
+-- in the order they were stored
+CREATE TABLE IF NOT EXISTS outbox (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	seq BIGSERIAL NOT NULL,
+	event_type VARCHAR (64) NOT NULL,
+	aggregate_id UUID NOT NULL,
+	payload JSONB NOT NULL,
+	occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMP WITH TIME ZONE NULL,
+	published_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
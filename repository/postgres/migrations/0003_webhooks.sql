@@ -0,0 +1,38 @@
+-- webhooks are partner endpoints subscribed to domain events, the secret is
+-- encrypted by the service
+CREATE TABLE IF NOT EXISTS webhooks (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	url TEXT NOT NULL,
+	event_types TEXT[] NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- webhook_deliveries queue the events sent to each webhook and keep the
+-- outcome as the delivery log, an event is queued once per webhook
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	webhook_id UUID NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event_id UUID NOT NULL,
+	event_type VARCHAR (64) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR (16) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	last_status_code INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+	locked_until TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	delivered_at TIMESTAMP WITH TIME ZONE NULL,
+	UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
+
+DROP TRIGGER IF EXISTS webhooks_updated_at ON webhooks;
+CREATE TRIGGER webhooks_updated_at BEFORE
+UPDATE
+    ON
+    webhooks FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,29 @@
+-- oauth_clients are the applications signing users in with the authorization
+-- code flow, the secret of confidential clients is hashed
+CREATE TABLE IF NOT EXISTS oauth_clients (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR (60) NOT NULL,
+	redirect_uris TEXT[] NOT NULL,
+	confidential BOOLEAN NOT NULL DEFAULT FALSE,
+	secret TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- oauth_codes are the issued authorization codes, only their hash is stored
+-- and used_at makes sure each is exchanged once
+CREATE TABLE IF NOT EXISTS oauth_codes (
+	code_hash TEXT PRIMARY KEY,
+	client_id UUID NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	session_id UUID NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL DEFAULT '',
+	nonce TEXT NOT NULL DEFAULT '',
+	code_challenge TEXT NOT NULL,
+	auth_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS oauth_codes_expires_at_idx ON oauth_codes (expires_at);
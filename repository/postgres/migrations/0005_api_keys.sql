@@ -0,0 +1,14 @@
+-- api_keys authorize batch jobs on behalf of their owner, only the hash of
+-- a key is stored and the prefix identifies it
+CREATE TABLE IF NOT EXISTS api_keys (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	name VARCHAR (60) NOT NULL,
+	user_id UUID NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	prefix TEXT UNIQUE NOT NULL,
+	key_hash TEXT NOT NULL,
+	scopes TEXT[] NOT NULL,
+	expires_at TIMESTAMP WITH TIME ZONE NULL,
+	last_used_at TIMESTAMP WITH TIME ZONE NULL,
+	revoked_at TIMESTAMP WITH TIME ZONE NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
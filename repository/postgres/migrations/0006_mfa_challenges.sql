@@ -0,0 +1,15 @@
+-- last_totp_step is the time step of the last accepted TOTP code, codes of
+-- this step or an earlier one are replays
+ALTER TABLE user_mfa ADD COLUMN IF NOT EXISTS last_totp_step BIGINT NOT NULL DEFAULT 0;
+
+-- mfa_challenges count the codes submitted with an MFA challenge token, id
+-- is the jti of the token and used_at makes sure it is exchanged once
+CREATE TABLE IF NOT EXISTS mfa_challenges (
+	id uuid PRIMARY KEY,
+	user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	attempts INT NOT NULL DEFAULT 0,
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	used_at TIMESTAMP WITH TIME ZONE NULL
+);
+
+CREATE INDEX IF NOT EXISTS mfa_challenges_expires_at_idx ON mfa_challenges (expires_at);
@@ -0,0 +1,18 @@
+-- idempotency keys are scoped to the caller so callers can not collide with
+-- or probe the keys of another. Stored responses only live for the
+-- idempotency ttl, they are dropped.
+DROP TABLE IF EXISTS idempotency_keys;
+
+CREATE TABLE idempotency_keys (
+	caller TEXT NOT NULL,
+	key VARCHAR (255) NOT NULL,
+	fingerprint TEXT NOT NULL,
+	status_code INT NULL,
+	content_type TEXT NOT NULL DEFAULT '',
+	response_body BYTEA NULL,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+	expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	PRIMARY KEY (caller, key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
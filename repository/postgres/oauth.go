@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.OAuthRepo = (*Repository)(nil)
+
+const oauthClientColumns = `id, name, redirect_uris, confidential, secret, created_at`
+
+const oauthCodeColumns = `code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce,
+	code_challenge, auth_time, expires_at, created_at`
+
+func (r *Repository) CreateOAuthClient(data *domain.OAuthClient) (*domain.OAuthClient, error) {
+	q := `
+		INSERT INTO oauth_clients (name, redirect_uris, confidential, secret)
+		VALUES (:name, :redirect_uris, :confidential, :secret)
+		RETURNING ` + oauthClientColumns + `;
+	`
+
+	arg := OAuthClient{
+		Name:         data.Name,
+		RedirectURIs: data.RedirectURIs,
+		Confidential: data.Confidential,
+		Secret:       data.Secret,
+	}
+
+	return r.getOAuthClient(q, &arg)
+}
+
+func (r *Repository) GetOAuthClient(id string) (*domain.OAuthClient, error) {
+	q := `
+		SELECT ` + oauthClientColumns + `
+		FROM oauth_clients
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, cons.ErrOAuthClientNotFound
+	}
+
+	return r.getOAuthClient(q, &OAuthClient{ID: validID})
+}
+
+func (r *Repository) ListOAuthClients() ([]domain.OAuthClient, error) {
+	q := `
+		SELECT ` + oauthClientColumns + `
+		FROM oauth_clients
+		ORDER BY created_at, id;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &OAuthClient{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.OAuthClient{}
+	for rows.Next() {
+		c := OAuthClient{}
+		err = rows.StructScan(&c)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *toDomainOAuthClient(c))
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) DeleteOAuthClient(id string) error {
+	q := `
+		DELETE FROM oauth_clients
+		WHERE id = :id
+		RETURNING ` + oauthClientColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrOAuthClientNotFound
+	}
+
+	_, err = r.getOAuthClient(q, &OAuthClient{ID: validID})
+	return err
+}
+
+// getOAuthClient runs q returning one client, no row is
+// cons.ErrOAuthClientNotFound.
+func (r *Repository) getOAuthClient(q string, arg *OAuthClient) (*domain.OAuthClient, error) {
+	rows, err := r.sawitDB.NamedQuery(q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrOAuthClientNotFound
+	}
+
+	c := OAuthClient{}
+	err = rows.StructScan(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainOAuthClient(c), nil
+}
+
+func toDomainOAuthClient(c OAuthClient) *domain.OAuthClient {
+	return &domain.OAuthClient{
+		ID:           c.ID.String(),
+		Name:         c.Name,
+		RedirectURIs: []string(c.RedirectURIs),
+		Confidential: c.Confidential,
+		Secret:       c.Secret,
+		CreatedAt:    c.CreatedAt,
+	}
+}
+
+func (r *Repository) CreateOAuthCode(data *domain.OAuthCode) error {
+	q := `
+		INSERT INTO oauth_codes (code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce,
+			code_challenge, auth_time, expires_at)
+		VALUES (:code_hash, :client_id, :user_id, :session_id, :redirect_uri, :scope, :nonce,
+			:code_challenge, :auth_time, :expires_at);
+	`
+
+	clientID, err := uuid.Parse(data.ClientID)
+	if err != nil {
+		return cons.ErrOAuthClientNotFound
+	}
+
+	userID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		return cons.ErrUserNotFound
+	}
+
+	sessionID, err := uuid.Parse(data.SessionID)
+	if err != nil {
+		return cons.ErrSessionRevoked
+	}
+
+	arg := OAuthCode{
+		CodeHash:      data.CodeHash,
+		ClientID:      clientID,
+		UserID:        userID,
+		SessionID:     sessionID,
+		RedirectURI:   data.RedirectURI,
+		Scope:         data.Scope,
+		Nonce:         data.Nonce,
+		CodeChallenge: data.CodeChallenge,
+		AuthTime:      data.AuthTime,
+		ExpiresAt:     data.ExpiresAt,
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+// ConsumeOAuthCode marks the code as used in the same statement that reads
+// it, so concurrent exchanges of one code can not both succeed.
+func (r *Repository) ConsumeOAuthCode(codeHash string) (*domain.OAuthCode, error) {
+	q := `
+		UPDATE oauth_codes SET used_at = NOW()
+		WHERE code_hash = :code_hash
+		AND used_at IS NULL
+		AND expires_at > NOW()
+		RETURNING ` + oauthCodeColumns + `;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &OAuthCode{CodeHash: codeHash})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrInvalidGrant
+	}
+
+	c := OAuthCode{}
+	err = rows.StructScan(&c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.OAuthCode{
+		CodeHash:      c.CodeHash,
+		ClientID:      c.ClientID.String(),
+		UserID:        c.UserID.String(),
+		SessionID:     c.SessionID.String(),
+		RedirectURI:   c.RedirectURI,
+		Scope:         c.Scope,
+		Nonce:         c.Nonce,
+		CodeChallenge: c.CodeChallenge,
+		AuthTime:      c.AuthTime,
+		ExpiresAt:     c.ExpiresAt,
+		CreatedAt:     c.CreatedAt,
+	}, nil
+}
+
+func (r *Repository) DeleteOAuthCodesExpiredBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM oauth_codes
+		WHERE expires_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &OAuthCodePruneArg{Before: before})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,164 @@
+package postgres
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.OutboxRepo = (*Repository)(nil)
+
+func (r *Repository) CreateOutboxEvent(event domain.EventPayload) error {
+	q := `
+		INSERT INTO outbox (event_type, aggregate_id, payload)
+		VALUES (:event_type, :aggregate_id, :payload);
+	`
+
+	aggregateID, err := uuid.Parse(event.AggregateID())
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	arg := OutboxEvent{
+		EventType:   event.EventType(),
+		AggregateID: aggregateID,
+		Payload:     payload,
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+// ClaimOutboxEvents locks the rows only for the statement, the lease in
+// locked_until keeps other relays off them until they are marked.
+func (r *Repository) ClaimOutboxEvents(limit int, lease time.Duration) ([]domain.Event, error) {
+	q := `
+		WITH claimable AS (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND dead_lettered_at IS NULL
+			AND (locked_until IS NULL OR locked_until <= NOW())
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox earlier
+				WHERE earlier.aggregate_id = outbox.aggregate_id
+				AND earlier.seq < outbox.seq
+				AND earlier.published_at IS NULL AND earlier.dead_lettered_at IS NULL
+				AND earlier.locked_until > NOW()
+			)
+			ORDER BY seq
+			LIMIT :limit
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox SET locked_until = NOW() + CAST(:lease_ms AS BIGINT) * INTERVAL '1 millisecond'
+		FROM claimable
+		WHERE outbox.id = claimable.id
+		RETURNING outbox.id, outbox.seq, outbox.event_type, outbox.aggregate_id,
+			outbox.payload, outbox.occurred_at, outbox.attempts, outbox.last_error;
+	`
+
+	arg := OutboxClaimArg{
+		Limit:   limit,
+		LeaseMS: lease.Milliseconds(),
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := []OutboxEvent{}
+	for rows.Next() {
+		e := OutboxEvent{}
+		err = rows.StructScan(&e)
+		if err != nil {
+			return nil, err
+		}
+
+		claimed = append(claimed, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the claimable rows
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].Seq < claimed[j].Seq })
+
+	res := make([]domain.Event, 0, len(claimed))
+	for _, e := range claimed {
+		res = append(res, domain.Event{
+			ID:          e.ID.String(),
+			Type:        e.EventType,
+			AggregateID: e.AggregateID.String(),
+			Payload:     e.Payload,
+			OccurredAt:  e.OccurredAt,
+			Attempts:    e.Attempts,
+		})
+	}
+
+	return res, nil
+}
+
+func (r *Repository) MarkOutboxEventPublished(id string) error {
+	q := `
+		UPDATE outbox SET published_at = NOW(), locked_until = NULL
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &OutboxEvent{ID: validID})
+	return err
+}
+
+// RecordOutboxEventFailure keeps the event leased until its retry, a
+// dead-lettered event is released as it is never claimed again.
+func (r *Repository) RecordOutboxEventFailure(failure domain.OutboxFailure) error {
+	q := `
+		UPDATE outbox SET attempts = attempts + 1, last_error = :last_error,
+			locked_until = CASE WHEN :dead_letter THEN NULL ELSE CAST(:retry_at AS TIMESTAMP WITH TIME ZONE) END,
+			dead_lettered_at = CASE WHEN :dead_letter THEN NOW() END
+		WHERE id = :id AND published_at IS NULL;
+	`
+
+	validID, err := uuid.Parse(failure.EventID)
+	if err != nil {
+		return err
+	}
+
+	arg := OutboxFailureArg{
+		ID:         validID,
+		LastError:  failure.Error,
+		RetryAt:    failure.RetryAt,
+		DeadLetter: failure.DeadLetter,
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) DeleteOutboxEventsPublishedBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM outbox
+		WHERE published_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &OutboxPruneArg{Before: before})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
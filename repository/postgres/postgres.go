@@ -19,7 +19,10 @@ import (
 var _ port.UserRepo = (*Repository)(nil)
 
 type Repository struct {
-	sawitDB *sqlx.DB
+	db *sqlx.DB
+	// sawitDB runs the queries, it is the transaction inside WithTx
+	sawitDB queryer
+	inTx    bool
 }
 
 type NewRepoOptions struct {
@@ -58,33 +61,57 @@ func New(ctx context.Context, opts NewRepoOptions) (*Repository, error) {
 	pg.SetMaxOpenConns(opts.MaxOpenConn)
 	pg.SetConnMaxIdleTime(opts.MaxIdleTime)
 
+	if err = migrate(ctx, pg); err != nil {
+		_ = pg.Close()
+		return nil, err
+	}
+
 	return &Repository{
+		db:      pg,
 		sawitDB: pg,
 	}, nil
 }
 
-func (r *Repository) CreateUser(data *domain.User) (*domain.User, error) {
+func (r *Repository) CreateUser(actor domain.Actor, data *domain.User) (*domain.User, error) {
 	q := `
-		INSERT INTO users (full_name, phone_number, password) 
-		VALUES (:full_name, :phone_number, crypt(:password, gen_salt('bf')))
-		RETURNING id, full_name, phone_number;
+		WITH created AS (
+			INSERT INTO users (full_name, phone_number, password)
+			VALUES (:full_name, :phone_number, crypt(:password, gen_salt('bf', 10)))
+			RETURNING id, full_name, phone_number, version
+		), audit AS (
+			INSERT INTO user_audit_log (user_id, actor_id, action, changes, request_id)
+			SELECT id, COALESCE(CAST(:actor_id AS UUID), id), :action,
+				jsonb_build_array(
+					jsonb_build_object('field', 'full_name', 'old', NULL, 'new', full_name),
+					jsonb_build_object('field', 'phone_number', 'old', NULL, 'new', phone_number)
+				),
+				:request_id
+			FROM created
+		)
+		SELECT id, full_name, phone_number, version FROM created;
 	`
 
-	arg := User{
+	audit, err := newAuditArg(actor, cons.AuditActionCreateUser)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := UserCreateArg{
 		FullName:    data.FullName,
 		PhoneNumber: data.PhoneNumber,
 		Password:    data.Password,
+		AuditArg:    audit,
 	}
 
-	nstmt, err := r.sawitDB.PrepareNamed(q)
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
 	if err != nil {
-		return nil, err
-	}
+		if isUniqueViolation(err) {
+			return nil, cons.ErrDataConflict
+		}
 
-	rows, err := nstmt.Queryx(&arg)
-	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	u := User{}
 	for rows.Next() {
@@ -94,16 +121,53 @@ func (r *Repository) CreateUser(data *domain.User) (*domain.User, error) {
 		}
 	}
 
+	if err = rows.Err(); err != nil {
+		if isUniqueViolation(err) {
+			return nil, cons.ErrDataConflict
+		}
+
+		return nil, err
+	}
+
 	return &domain.User{
 		ID:          u.ID.String(),
 		FullName:    u.FullName,
 		PhoneNumber: u.PhoneNumber,
+		Version:     u.Version,
 	}, nil
 }
 
+func (r *Repository) GetUserIDByPhone(phone string) (string, error) {
+	q := `
+		SELECT id FROM users
+		WHERE phone_number = :phone_number;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &User{PhoneNumber: phone})
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return "", err
+		}
+
+		return "", cons.ErrUserNotFound
+	}
+
+	u := User{}
+	if err = rows.StructScan(&u); err != nil {
+		return "", err
+	}
+
+	return u.ID.String(), nil
+}
+
 func (r *Repository) GetUserByID(id string) (*domain.User, error) {
 	q := `
-		SELECT id, full_name, phone_number, created_at, updated_at 
+		SELECT id, full_name, phone_number, role, version, created_at, updated_at 
 		FROM users 
 		WHERE id = :id AND is_active = TRUE;
 	`
@@ -116,38 +180,69 @@ func (r *Repository) GetUserByID(id string) (*domain.User, error) {
 	arg := User{ID: validID}
 	resQuery := User{}
 
-	nstmt, err := r.sawitDB.PrepareNamed(q)
-	if err != nil {
-		return nil, err
-	}
-
-	rows, err := nstmt.Queryx(&arg)
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	for rows.Next() {
-		err = rows.StructScan(&resQuery)
-		if err != nil {
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
 			return nil, err
 		}
+
+		return nil, cons.ErrUserNotFound
+	}
+
+	err = rows.StructScan(&resQuery)
+	if err != nil {
+		return nil, err
 	}
 
 	res := &domain.User{
 		ID:          resQuery.ID.String(),
 		FullName:    resQuery.FullName,
 		PhoneNumber: resQuery.PhoneNumber,
+		Role:        resQuery.Role,
+		Version:     resQuery.Version,
 	}
 
 	return res, nil
 }
 
-func (r *Repository) PatchUserByID(id string, data *domain.User) (*domain.User, error) {
+func (r *Repository) PatchUserByID(actor domain.Actor, id string, data *domain.User) (*domain.User, error) {
+	// the previous values are locked and diffed against the new ones in the
+	// same statement, so the audit entry can not miss a concurrent update.
+	// A non zero data.Version is the version the caller last read, a stale
+	// one leaves nothing to update, just like a missing user.
 	qt := `
-		UPDATE users SET %s 
-		WHERE id = :id
-		AND is_active = true
-		RETURNING id, full_name, phone_number;
+		WITH old AS (
+			SELECT id, full_name, phone_number FROM users
+			WHERE id = :id
+			AND is_active = true
+			AND (CAST(:version AS INT) = 0 OR version = :version)
+			FOR UPDATE
+		), updated AS (
+			UPDATE users SET %s
+			FROM old
+			WHERE users.id = old.id
+			RETURNING users.id, users.full_name, users.phone_number, users.version,
+				old.full_name AS old_full_name, old.phone_number AS old_phone_number
+		), audit AS (
+			INSERT INTO user_audit_log (user_id, actor_id, action, changes, request_id)
+			SELECT id, COALESCE(CAST(:actor_id AS UUID), id), :action,
+				(
+					SELECT COALESCE(jsonb_agg(jsonb_build_object('field', c.field, 'old', c.old, 'new', c.new)), '[]')
+					FROM (VALUES
+						('full_name', old_full_name, full_name),
+						('phone_number', old_phone_number, phone_number)
+					) AS c (field, old, new)
+					WHERE c.old IS DISTINCT FROM c.new
+				),
+				:request_id
+			FROM updated
+		)
+		SELECT id, full_name, phone_number, version FROM updated;
 	`
 
 	validID, err := uuid.Parse(id)
@@ -155,7 +250,7 @@ func (r *Repository) PatchUserByID(id string, data *domain.User) (*domain.User,
 		return nil, err
 	}
 
-	params := []string{}
+	params := []string{"version = users.version + 1"}
 	if data.FullName != "" {
 		params = append(params, "full_name= :full_name")
 	}
@@ -167,41 +262,106 @@ func (r *Repository) PatchUserByID(id string, data *domain.User) (*domain.User,
 	paramsStr := strings.Join(params, ",")
 	q := fmt.Sprintf(qt, paramsStr)
 
+	audit, err := newAuditArg(actor, cons.AuditActionUpdateProfile)
+	if err != nil {
+		return nil, err
+	}
+
 	queryArg := UserPatchArg{
 		ID:          validID,
 		FullName:    data.FullName,
 		PhoneNumber: data.PhoneNumber,
+		Version:     data.Version,
+		AuditArg:    audit,
 	}
 	rows, err := r.sawitDB.NamedQuery(q, &queryArg)
 	if err != nil {
-		var pgErr *pq.Error
-		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		if isUniqueViolation(err) {
 			return nil, cons.ErrDataConflict
 		}
 
 		return nil, err
 	}
+	defer rows.Close()
 
-	u := User{}
-	for rows.Next() {
-		err = rows.StructScan(&u)
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			if isUniqueViolation(err) {
+				return nil, cons.ErrDataConflict
+			}
+
+			return nil, err
+		}
+
+		// the connection is free for the existence check once rows is
+		// closed, it may be the one of a transaction
+		_ = rows.Close()
+		if data.Version == 0 {
+			return nil, cons.ErrUserNotFound
+		}
+
+		exists, err := r.activeUserExists(validID)
 		if err != nil {
 			return nil, err
 		}
+
+		if !exists {
+			return nil, cons.ErrUserNotFound
+		}
+
+		return nil, cons.ErrVersionMismatch
+	}
+
+	u := User{}
+	err = rows.StructScan(&u)
+	if err != nil {
+		return nil, err
 	}
 
 	res := &domain.User{
 		ID:          u.ID.String(),
 		FullName:    u.FullName,
 		PhoneNumber: u.PhoneNumber,
+		Version:     u.Version,
 	}
 
 	return res, nil
 }
 
+// activeUserExists tells a missing or deactivated user apart from a stale
+// version when a conditional update matched nothing.
+func (r *Repository) activeUserExists(id uuid.UUID) (bool, error) {
+	q := `
+		SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE id = :id AND is_active = TRUE
+		);
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &User{ID: id})
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var exists bool
+	for rows.Next() {
+		if err = rows.Scan(&exists); err != nil {
+			return false, err
+		}
+	}
+
+	return exists, rows.Err()
+}
+
 func (r *Repository) Login(phone string, pass string) (*domain.User, error) {
 	q := `
-		SELECT id, full_name, phone_number, login_count
+		SELECT id, full_name, phone_number, login_count, role,
+			must_change_password, password_changed_at,
+			EXISTS (
+				SELECT 1 FROM user_mfa
+				WHERE user_mfa.user_id = users.id AND confirmed_at IS NOT NULL
+			) AS mfa_enabled
 		FROM users
 		WHERE phone_number = :phone_number
 		AND password = crypt(:password, password)
@@ -213,49 +373,348 @@ func (r *Repository) Login(phone string, pass string) (*domain.User, error) {
 		Password:    pass,
 	}
 
-	nstmt, err := r.sawitDB.PrepareNamed(q)
+	rows, err := r.sawitDB.NamedQuery(q, arg)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrLoginNotMatch
+	}
 
-	rows, err := nstmt.Queryx(&arg)
+	u := User{}
+	err = rows.StructScan(&u)
 	if err != nil {
 		return nil, err
 	}
 
-	u := User{}
+	res := &domain.User{
+		ID:                 u.ID.String(),
+		FullName:           u.FullName,
+		PhoneNumber:        u.PhoneNumber,
+		LoginCount:         u.LoginCount,
+		Role:               u.Role,
+		MustChangePassword: u.MustChangePassword,
+		PasswordChangedAt:  u.PasswordChangedAt,
+		MFAEnabled:         u.MFAEnabled,
+	}
+	return res, nil
+}
+
+func (r *Repository) IncrementLoginCount(id string) error {
+	q := `
+		UPDATE users SET login_count = login_count + 1
+		WHERE id = :id AND is_active = true
+		RETURNING id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &User{ID: validID})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) UpdatePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error {
+	// the replaced hash is moved into the history in the same statement
+	q := `
+		WITH old AS (
+			SELECT id, password FROM users
+			WHERE id = :id
+			AND password = crypt(:old_password, password)
+			AND is_active = true
+			FOR UPDATE
+		), history AS (
+			INSERT INTO user_password_history (user_id, password)
+			SELECT id, password FROM old
+		), audit AS (
+			INSERT INTO user_audit_log (user_id, actor_id, action, changes, request_id)
+			SELECT id, COALESCE(CAST(:actor_id AS UUID), id), :action,
+				jsonb_build_array(jsonb_build_object('field', 'password', 'old', NULL, 'new', NULL)),
+				:request_id
+			FROM old
+		)
+		UPDATE users SET password = crypt(:new_password, gen_salt('bf', 10)),
+			password_changed_at = NOW(),
+			must_change_password = false
+		FROM old
+		WHERE users.id = old.id
+		RETURNING users.id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	audit, err := newAuditArg(actor, cons.AuditActionChangePassword)
+	if err != nil {
+		return err
+	}
+
+	arg := UserPasswordArg{
+		ID:          validID,
+		OldPassword: oldPassword,
+		NewPassword: newPassword,
+		AuditArg:    audit,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrPasswordNotMatch
+	}
+
+	return nil
+}
+
+func (r *Repository) CheckPassword(id string, password string) error {
+	q := `
+		SELECT id FROM users
+		WHERE id = :id
+		AND password = crypt(:password, password)
+		AND is_active = true;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &PasswordHistoryArg{UserID: validID, Password: password})
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrPasswordNotMatch
+	}
+
+	return nil
+}
+
+func (r *Repository) IsPasswordReused(id string, password string, depth int) (bool, error) {
+	q := `
+		SELECT EXISTS (
+			SELECT 1 FROM users
+			WHERE id = :id AND password = crypt(:password, password)
+			UNION ALL
+			SELECT 1 FROM (
+				SELECT password FROM user_password_history
+				WHERE user_id = :id
+				ORDER BY created_at DESC
+				LIMIT :depth
+			) recent
+			WHERE recent.password = crypt(:password, recent.password)
+		);
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return false, err
+	}
+
+	if depth < 1 {
+		return false, nil
+	}
+
+	// the current password takes the first place of the window
+	arg := PasswordHistoryArg{
+		UserID:   validID,
+		Password: password,
+		Depth:    depth - 1,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	var reused bool
 	for rows.Next() {
-		err = rows.StructScan(&u)
+		err = rows.Scan(&reused)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
 	}
 
-	if u.ID == uuid.Nil {
-		return nil, errors.New("phone and password do not match")
+	return reused, rows.Err()
+}
+
+func (r *Repository) PrunePasswordHistory(id string, depth int) (int64, error) {
+	q := `
+		DELETE FROM user_password_history
+		WHERE user_id = :id
+		AND id NOT IN (
+			SELECT id FROM user_password_history
+			WHERE user_id = :id
+			ORDER BY created_at DESC
+			LIMIT :depth
+		);
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &PasswordHistoryArg{UserID: validID, Depth: depth})
+	if err != nil {
+		return 0, err
 	}
 
-	q = `
-		UPDATE users SET login_count = login_count + 1
-		WHERE id = :id;
+	return res.RowsAffected()
+}
+
+func (r *Repository) SetMustChangePassword(actor domain.Actor, id string, mustChange bool) error {
+	q := `
+		WITH old AS (
+			SELECT id, must_change_password FROM users
+			WHERE id = :id
+			AND is_active = true
+			FOR UPDATE
+		), audit AS (
+			INSERT INTO user_audit_log (user_id, actor_id, action, changes, request_id)
+			SELECT id, COALESCE(CAST(:actor_id AS UUID), id), :action,
+				jsonb_build_array(jsonb_build_object(
+					'field', 'must_change_password',
+					'old', CAST(must_change_password AS TEXT),
+					'new', CAST(CAST(:must_change_password AS BOOLEAN) AS TEXT)
+				)),
+				:request_id
+			FROM old
+		)
+		UPDATE users SET must_change_password = :must_change_password
+		FROM old
+		WHERE users.id = old.id
+		RETURNING users.id;
 	`
 
-	nstmt, err = r.sawitDB.PrepareNamed(q)
+	validID, err := uuid.Parse(id)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	rows, err = nstmt.Queryx(&u)
+	audit, err := newAuditArg(actor, cons.AuditActionForcePasswordChange)
+	if err != nil {
+		return err
+	}
 
+	arg := UserMustChangeArg{
+		ID:         validID,
+		MustChange: mustChange,
+		AuditArg:   audit,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	defer rows.Close()
 
-	res := &domain.User{
-		ID:          u.ID.String(),
-		FullName:    u.FullName,
-		PhoneNumber: u.PhoneNumber,
-		LoginCount:  u.LoginCount,
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrUserNotFound
 	}
-	return res, nil
+
+	return nil
+}
+
+func (r *Repository) SetUserRole(actor domain.Actor, id string, role string) error {
+	q := `
+		WITH old AS (
+			SELECT id, role FROM users
+			WHERE id = :id
+			AND is_active = true
+			FOR UPDATE
+		), audit AS (
+			INSERT INTO user_audit_log (user_id, actor_id, action, changes, request_id)
+			SELECT id, COALESCE(CAST(:actor_id AS UUID), id), :action,
+				jsonb_build_array(jsonb_build_object(
+					'field', 'role',
+					'old', role,
+					'new', CAST(:role AS TEXT)
+				)),
+				:request_id
+			FROM old
+		)
+		UPDATE users SET role = :role
+		FROM old
+		WHERE users.id = old.id
+		RETURNING users.id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	audit, err := newAuditArg(actor, cons.AuditActionSetRole)
+	if err != nil {
+		return err
+	}
+
+	arg := UserRoleArg{
+		ID:       validID,
+		Role:     role,
+		AuditArg: audit,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrUserNotFound
+	}
+
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pq.Error
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
 }
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+func (r *Repository) CreateSession(data *domain.Session) (*domain.Session, error) {
+	q := `
+		INSERT INTO user_sessions (user_id, user_agent, ip_address, expires_at)
+		VALUES (:user_id, :user_agent, CAST(NULLIF(:ip_address, '') AS INET), :expires_at)
+		RETURNING id, user_id, user_agent, COALESCE(host(ip_address), '') AS ip_address,
+			created_at, last_seen_at, expires_at;
+	`
+
+	validID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := Session{
+		UserID:    validID,
+		UserAgent: data.UserAgent,
+		IPAddress: data.IPAddress,
+		ExpiresAt: data.ExpiresAt,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrUserNotFound
+	}
+
+	s := Session{}
+	err = rows.StructScan(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainSession(s), nil
+}
+
+// TouchSession bumps last_seen_at of an active session and fails with
+// cons.ErrSessionRevoked when it is revoked or expired.
+func (r *Repository) TouchSession(userID string, sessionID string) error {
+	q := `
+		UPDATE user_sessions SET last_seen_at = NOW()
+		WHERE id = :id
+		AND user_id = :user_id
+		AND revoked_at IS NULL
+		AND expires_at > NOW()
+		RETURNING id;
+	`
+
+	arg, err := sessionArg(userID, sessionID)
+	if err != nil {
+		return cons.ErrSessionRevoked
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func (r *Repository) ListSessions(userID string) ([]domain.Session, error) {
+	q := `
+		SELECT id, user_id, user_agent, COALESCE(host(ip_address), '') AS ip_address,
+			created_at, last_seen_at, expires_at
+		FROM user_sessions
+		WHERE user_id = :user_id
+		AND revoked_at IS NULL
+		AND expires_at > NOW()
+		ORDER BY last_seen_at DESC;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &Session{UserID: validID})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.Session{}
+	for rows.Next() {
+		s := Session{}
+		err = rows.StructScan(&s)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *toDomainSession(s))
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) RevokeSession(userID string, sessionID string) error {
+	q := `
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE id = :id
+		AND user_id = :user_id
+		AND revoked_at IS NULL
+		RETURNING id;
+	`
+
+	arg, err := sessionArg(userID, sessionID)
+	if err != nil {
+		return cons.ErrSessionNotFound
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func sessionArg(userID string, sessionID string) (Session, error) {
+	validUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return Session{}, err
+	}
+
+	validID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return Session{ID: validID, UserID: validUserID}, nil
+}
+
+func toDomainSession(s Session) *domain.Session {
+	return &domain.Session{
+		ID:         s.ID.String(),
+		UserID:     s.UserID.String(),
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
+
+// RevokeUserSessions revokes every active session of the user except
+// exceptSessionID, which may be empty to revoke all of them.
+func (r *Repository) RevokeUserSessions(userID string, exceptSessionID string) (int64, error) {
+	q := `
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE user_id = :user_id
+		AND revoked_at IS NULL
+		AND id IS DISTINCT FROM CAST(:id AS UUID);
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	arg := SessionRevokeArg{UserID: validID}
+	if exceptSessionID != "" {
+		exceptID, err := uuid.Parse(exceptSessionID)
+		if err != nil {
+			return 0, err
+		}
+
+		arg.ExceptID = &exceptID
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &arg)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
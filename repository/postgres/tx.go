@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/jmoiron/sqlx"
+)
+
+// queryer is implemented by both *sqlx.DB and *sqlx.Tx, so every repository
+// method runs the same way inside and outside a transaction.
+type queryer interface {
+	NamedQuery(query string, arg interface{}) (*sqlx.Rows, error)
+	NamedExec(query string, arg interface{}) (sql.Result, error)
+	PrepareNamed(query string) (*sqlx.NamedStmt, error)
+}
+
+// WithTx runs fn with a repository bound to a single transaction, it is
+// committed when fn returns nil and rolled back otherwise. Calling WithTx on
+// a repository that is already in a transaction joins it.
+func (r *Repository) WithTx(ctx context.Context, fn func(repo port.UserRepo) error) (err error) {
+	if r.inTx {
+		return fn(r)
+	}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("%w: rollback: %v", err, rbErr)
+			}
+
+			return
+		}
+
+		err = tx.Commit()
+	}()
+
+	txRepo := &Repository{
+		db:      r.db,
+		sawitDB: tx,
+		inTx:    true,
+	}
+
+	return fn(txRepo)
+}
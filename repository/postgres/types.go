@@ -4,25 +4,278 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 type User struct {
-	ID          uuid.UUID  `db:"id" sql:",type:uuid"`
-	FullName    string     `db:"full_name"`
-	PhoneNumber string     `db:"phone_number"`
-	Password    string     `db:"password"`
-	LoginCount  int        `db:"login_count"`
-	CreatedAt   *time.Time `db:"created_at"`
-	UpdatedAt   *time.Time `db:"updated_at"`
+	ID                 uuid.UUID  `db:"id" sql:",type:uuid"`
+	FullName           string     `db:"full_name"`
+	PhoneNumber        string     `db:"phone_number"`
+	Password           string     `db:"password"`
+	LoginCount         int        `db:"login_count"`
+	Role               string     `db:"role"`
+	MustChangePassword bool       `db:"must_change_password"`
+	PasswordChangedAt  *time.Time `db:"password_changed_at"`
+	MFAEnabled         bool       `db:"mfa_enabled"`
+	Version            int        `db:"version"`
+	CreatedAt          *time.Time `db:"created_at"`
+	UpdatedAt          *time.Time `db:"updated_at"`
+}
+
+// AuditArg is embedded into the arguments of mutations that write an
+// audit entry in the same statement.
+type AuditArg struct {
+	ActorID   *uuid.UUID `db:"actor_id" sql:",type:uuid"`
+	RequestID string     `db:"request_id"`
+	Action    string     `db:"action"`
+}
+
+type UserCreateArg struct {
+	FullName    string `db:"full_name"`
+	PhoneNumber string `db:"phone_number"`
+	Password    string `db:"password"`
+	AuditArg
 }
 
 type UserPatchArg struct {
 	ID          uuid.UUID `db:"id" sql:",type:uuid"`
 	FullName    string    `db:"full_name"`
 	PhoneNumber string    `db:"phone_number"`
+	Version     int       `db:"version"`
+	AuditArg
 }
 
 type UserLoginArg struct {
 	PhoneNumber string `db:"phone_number"`
 	Password    string `db:"password"`
 }
+
+type UserPasswordArg struct {
+	ID          uuid.UUID `db:"id" sql:",type:uuid"`
+	OldPassword string    `db:"old_password"`
+	NewPassword string    `db:"new_password"`
+	AuditArg
+}
+
+type PasswordHistoryArg struct {
+	UserID   uuid.UUID `db:"id" sql:",type:uuid"`
+	Password string    `db:"password"`
+	Depth    int       `db:"depth"`
+}
+
+type UserMustChangeArg struct {
+	ID         uuid.UUID `db:"id" sql:",type:uuid"`
+	MustChange bool      `db:"must_change_password"`
+	AuditArg
+}
+
+type UserRoleArg struct {
+	ID   uuid.UUID `db:"id" sql:",type:uuid"`
+	Role string    `db:"role"`
+	AuditArg
+}
+
+type MFA struct {
+	UserID       uuid.UUID  `db:"user_id" sql:",type:uuid"`
+	TOTPSecret   string     `db:"totp_secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	CreatedAt    *time.Time `db:"created_at"`
+	LastTOTPStep int64      `db:"last_totp_step"`
+}
+
+type TOTPStepArg struct {
+	UserID uuid.UUID `db:"user_id" sql:",type:uuid"`
+	Step   int64     `db:"step"`
+}
+
+type MFAChallenge struct {
+	ID        uuid.UUID `db:"id" sql:",type:uuid"`
+	UserID    uuid.UUID `db:"user_id" sql:",type:uuid"`
+	Attempts  int       `db:"attempts"`
+	ExpiresAt time.Time `db:"expires_at"`
+}
+
+type MFAChallengePruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type RecoveryCodesArg struct {
+	UserID uuid.UUID      `db:"user_id" sql:",type:uuid"`
+	Codes  pq.StringArray `db:"codes"`
+}
+
+type RecoveryCodeArg struct {
+	UserID uuid.UUID `db:"user_id" sql:",type:uuid"`
+	Code   string    `db:"code"`
+}
+
+type Session struct {
+	ID         uuid.UUID  `db:"id" sql:",type:uuid"`
+	UserID     uuid.UUID  `db:"user_id" sql:",type:uuid"`
+	UserAgent  string     `db:"user_agent"`
+	IPAddress  string     `db:"ip_address"`
+	CreatedAt  *time.Time `db:"created_at"`
+	LastSeenAt *time.Time `db:"last_seen_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+}
+
+type LoginEvent struct {
+	ID          uuid.UUID  `db:"id" sql:",type:uuid"`
+	UserID      *uuid.UUID `db:"user_id" sql:",type:uuid"`
+	PhoneNumber string     `db:"phone_number"`
+	Success     bool       `db:"success"`
+	Reason      string     `db:"reason"`
+	UserAgent   string     `db:"user_agent"`
+	IPAddress   string     `db:"ip_address"`
+	CreatedAt   *time.Time `db:"created_at"`
+}
+
+type LoginEventListArg struct {
+	UserID uuid.UUID `db:"user_id" sql:",type:uuid"`
+	Limit  int       `db:"limit"`
+	Offset int       `db:"offset"`
+}
+
+type LoginEventPruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type AuditEntry struct {
+	ID        uuid.UUID  `db:"id" sql:",type:uuid"`
+	UserID    uuid.UUID  `db:"user_id" sql:",type:uuid"`
+	ActorID   uuid.UUID  `db:"actor_id" sql:",type:uuid"`
+	Action    string     `db:"action"`
+	Changes   []byte     `db:"changes"`
+	RequestID string     `db:"request_id"`
+	CreatedAt *time.Time `db:"created_at"`
+}
+
+type AuditListArg struct {
+	UserID uuid.UUID  `db:"user_id" sql:",type:uuid"`
+	From   *time.Time `db:"from"`
+	To     *time.Time `db:"to"`
+	Limit  int        `db:"limit"`
+	Offset int        `db:"offset"`
+}
+
+type SessionRevokeArg struct {
+	UserID   uuid.UUID  `db:"user_id" sql:",type:uuid"`
+	ExceptID *uuid.UUID `db:"id" sql:",type:uuid"`
+}
+
+type IdempotencyKey struct {
+	Caller       string    `db:"caller"`
+	Key          string    `db:"key"`
+	Fingerprint  string    `db:"fingerprint"`
+	StatusCode   int       `db:"status_code"`
+	ContentType  string    `db:"content_type"`
+	ResponseBody []byte    `db:"response_body"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	Reserved     bool      `db:"reserved"`
+}
+
+type IdempotencyPruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type OutboxEvent struct {
+	ID          uuid.UUID `db:"id" sql:",type:uuid"`
+	Seq         int64     `db:"seq"`
+	EventType   string    `db:"event_type"`
+	AggregateID uuid.UUID `db:"aggregate_id" sql:",type:uuid"`
+	Payload     []byte    `db:"payload"`
+	OccurredAt  time.Time `db:"occurred_at"`
+	Attempts    int       `db:"attempts"`
+	LastError   string    `db:"last_error"`
+}
+
+type OutboxClaimArg struct {
+	Limit   int   `db:"limit"`
+	LeaseMS int64 `db:"lease_ms"`
+}
+
+type OutboxFailureArg struct {
+	ID         uuid.UUID `db:"id" sql:",type:uuid"`
+	LastError  string    `db:"last_error"`
+	RetryAt    time.Time `db:"retry_at"`
+	DeadLetter bool      `db:"dead_letter"`
+}
+
+type OutboxPruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type Webhook struct {
+	ID         uuid.UUID      `db:"id" sql:",type:uuid"`
+	URL        string         `db:"url"`
+	EventTypes pq.StringArray `db:"event_types"`
+	Secret     string         `db:"secret"`
+	CreatedAt  *time.Time     `db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `db:"id" sql:",type:uuid"`
+	WebhookID      uuid.UUID  `db:"webhook_id" sql:",type:uuid"`
+	EventID        uuid.UUID  `db:"event_id" sql:",type:uuid"`
+	EventType      string     `db:"event_type"`
+	Payload        string     `db:"payload"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	LastStatusCode int        `db:"last_status_code"`
+	LastError      string     `db:"last_error"`
+	NextAttemptAt  *time.Time `db:"next_attempt_at"`
+	CreatedAt      *time.Time `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+}
+
+type WebhookClaimArg struct {
+	Status  string `db:"status"`
+	Limit   int    `db:"limit"`
+	LeaseMS int64  `db:"lease_ms"`
+}
+
+type WebhookDeliveryListArg struct {
+	WebhookID uuid.UUID `db:"webhook_id" sql:",type:uuid"`
+	Limit     int       `db:"limit"`
+	Offset    int       `db:"offset"`
+}
+
+type OAuthClient struct {
+	ID           uuid.UUID      `db:"id" sql:",type:uuid"`
+	Name         string         `db:"name"`
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+	Confidential bool           `db:"confidential"`
+	Secret       string         `db:"secret"`
+	CreatedAt    *time.Time     `db:"created_at"`
+}
+
+type OAuthCode struct {
+	CodeHash      string     `db:"code_hash"`
+	ClientID      uuid.UUID  `db:"client_id" sql:",type:uuid"`
+	UserID        uuid.UUID  `db:"user_id" sql:",type:uuid"`
+	SessionID     uuid.UUID  `db:"session_id" sql:",type:uuid"`
+	RedirectURI   string     `db:"redirect_uri"`
+	Scope         string     `db:"scope"`
+	Nonce         string     `db:"nonce"`
+	CodeChallenge string     `db:"code_challenge"`
+	AuthTime      time.Time  `db:"auth_time"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	CreatedAt     *time.Time `db:"created_at"`
+}
+
+type OAuthCodePruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type APIKey struct {
+	ID         uuid.UUID      `db:"id" sql:",type:uuid"`
+	Name       string         `db:"name"`
+	UserID     uuid.UUID      `db:"user_id" sql:",type:uuid"`
+	Prefix     string         `db:"prefix"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	ExpiresAt  *time.Time     `db:"expires_at"`
+	LastUsedAt *time.Time     `db:"last_used_at"`
+	RevokedAt  *time.Time     `db:"revoked_at"`
+	CreatedAt  *time.Time     `db:"created_at"`
+}
@@ -0,0 +1,336 @@
+package postgres
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.WebhookRepo = (*Repository)(nil)
+
+const webhookColumns = `id, url, event_types, secret, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	last_status_code, last_error, next_attempt_at, created_at, delivered_at`
+
+func (r *Repository) CreateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	q := `
+		INSERT INTO webhooks (url, event_types, secret)
+		VALUES (:url, :event_types, :secret)
+		RETURNING ` + webhookColumns + `;
+	`
+
+	arg := Webhook{
+		URL:        data.URL,
+		EventTypes: data.EventTypes,
+		Secret:     data.Secret,
+	}
+
+	return r.getWebhook(q, &arg)
+}
+
+func (r *Repository) ListWebhooks() ([]domain.Webhook, error) {
+	q := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		ORDER BY created_at, id;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &Webhook{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.Webhook{}
+	for rows.Next() {
+		w := Webhook{}
+		err = rows.StructScan(&w)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *toDomainWebhook(w))
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) GetWebhook(id string) (*domain.Webhook, error) {
+	q := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, cons.ErrWebhookNotFound
+	}
+
+	return r.getWebhook(q, &Webhook{ID: validID})
+}
+
+func (r *Repository) UpdateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	q := `
+		UPDATE webhooks SET
+			url = :url,
+			event_types = :event_types,
+			secret = COALESCE(NULLIF(:secret, ''), secret)
+		WHERE id = :id
+		RETURNING ` + webhookColumns + `;
+	`
+
+	validID, err := uuid.Parse(data.ID)
+	if err != nil {
+		return nil, cons.ErrWebhookNotFound
+	}
+
+	arg := Webhook{
+		ID:         validID,
+		URL:        data.URL,
+		EventTypes: data.EventTypes,
+		Secret:     data.Secret,
+	}
+
+	return r.getWebhook(q, &arg)
+}
+
+func (r *Repository) DeleteWebhook(id string) error {
+	q := `
+		DELETE FROM webhooks
+		WHERE id = :id
+		RETURNING ` + webhookColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrWebhookNotFound
+	}
+
+	_, err = r.getWebhook(q, &Webhook{ID: validID})
+	return err
+}
+
+// getWebhook runs q returning one webhook, no row is cons.ErrWebhookNotFound.
+func (r *Repository) getWebhook(q string, arg *Webhook) (*domain.Webhook, error) {
+	rows, err := r.sawitDB.NamedQuery(q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrWebhookNotFound
+	}
+
+	w := Webhook{}
+	err = rows.StructScan(&w)
+	if err != nil {
+		return nil, err
+	}
+
+	return toDomainWebhook(w), nil
+}
+
+func toDomainWebhook(w Webhook) *domain.Webhook {
+	return &domain.Webhook{
+		ID:         w.ID.String(),
+		URL:        w.URL,
+		EventTypes: []string(w.EventTypes),
+		Secret:     w.Secret,
+		CreatedAt:  w.CreatedAt,
+	}
+}
+
+func (r *Repository) EnqueueWebhookDeliveries(event *domain.Event) (int64, error) {
+	q := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT id, CAST(:event_id AS UUID), CAST(:event_type AS VARCHAR), CAST(:payload AS TEXT)
+		FROM webhooks
+		WHERE CAST(:event_type AS TEXT) = ANY (event_types)
+		ON CONFLICT (webhook_id, event_id) DO NOTHING;
+	`
+
+	eventID, err := uuid.Parse(event.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	arg := WebhookDelivery{
+		EventID:   eventID,
+		EventType: event.Type,
+		Payload:   string(payload),
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &arg)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *Repository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	q := `
+		WITH claimable AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = :status
+			AND next_attempt_at <= NOW()
+			AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY next_attempt_at
+			LIMIT :limit
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET locked_until = NOW() + CAST(:lease_ms AS BIGINT) * INTERVAL '1 millisecond'
+		FROM claimable
+		WHERE d.id = claimable.id
+		RETURNING d.id, d.webhook_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+			d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.delivered_at;
+	`
+
+	arg := WebhookClaimArg{
+		Status:  cons.WebhookStatusPending,
+		Limit:   limit,
+		LeaseMS: lease.Milliseconds(),
+	}
+
+	res, err := r.listWebhookDeliveries(q, &arg)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the claimable rows
+	sort.SliceStable(res, func(i, j int) bool { return res[i].NextAttemptAt.Before(*res[j].NextAttemptAt) })
+	return res, nil
+}
+
+func (r *Repository) RecordWebhookAttempt(attempt domain.WebhookAttempt) error {
+	q := `
+		UPDATE webhook_deliveries SET
+			status = :status,
+			attempts = attempts + 1,
+			last_status_code = :last_status_code,
+			last_error = :last_error,
+			next_attempt_at = :next_attempt_at,
+			delivered_at = :delivered_at,
+			locked_until = NULL
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(attempt.DeliveryID)
+	if err != nil {
+		return err
+	}
+
+	arg := WebhookDelivery{
+		ID:             validID,
+		Status:         attempt.Status,
+		LastStatusCode: attempt.StatusCode,
+		LastError:      attempt.Error,
+		NextAttemptAt:  &attempt.NextAttemptAt,
+	}
+	if attempt.Status == cons.WebhookStatusSucceeded {
+		now := time.Now()
+		arg.DeliveredAt = &now
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) ListWebhookDeliveries(webhookID string, limit int, offset int) ([]domain.WebhookDelivery, int, error) {
+	q := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = :webhook_id
+		ORDER BY created_at DESC, id
+		LIMIT :limit OFFSET :offset;
+	`
+
+	validID, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, 0, cons.ErrWebhookNotFound
+	}
+
+	arg := WebhookDeliveryListArg{
+		WebhookID: validID,
+		Limit:     limit,
+		Offset:    offset,
+	}
+
+	res, err := r.listWebhookDeliveries(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q = `
+		SELECT COUNT(*) FROM webhook_deliveries
+		WHERE webhook_id = :webhook_id;
+	`
+
+	countRows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer countRows.Close()
+
+	total := 0
+	if countRows.Next() {
+		err = countRows.Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return res, total, countRows.Err()
+}
+
+func (r *Repository) listWebhookDeliveries(q string, arg interface{}) ([]domain.WebhookDelivery, error) {
+	rows, err := r.sawitDB.NamedQuery(q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.WebhookDelivery{}
+	for rows.Next() {
+		d := WebhookDelivery{}
+		err = rows.StructScan(&d)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, domain.WebhookDelivery{
+			ID:             d.ID.String(),
+			WebhookID:      d.WebhookID.String(),
+			EventID:        d.EventID.String(),
+			EventType:      d.EventType,
+			Payload:        []byte(d.Payload),
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+			NextAttemptAt:  d.NextAttemptAt,
+			CreatedAt:      d.CreatedAt,
+			DeliveredAt:    d.DeliveredAt,
+		})
+	}
+
+	return res, rows.Err()
+}
@@ -0,0 +1,1051 @@
+// Package repotest is the conformance suite every port.UserRepo
+// implementation has to pass, so the services behave the same on each of
+// them.
+package repotest
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+	. "github.com/onsi/gomega"
+)
+
+const testPassword = "Passw0rd!"
+
+// NewRepo returns the repository under test. The suite only creates users
+// with random phone numbers, so a shared database works, but it does prune
+// login history.
+type NewRepo func(t *testing.T) port.UserRepo
+
+// RunUserRepo runs the conformance suite against the repository of newRepo.
+func RunUserRepo(t *testing.T, newRepo NewRepo) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, repo port.UserRepo)
+	}{
+		{"CreateUser", testCreateUser},
+		{"Login", testLogin},
+		{"LoginConcurrent", testLoginConcurrent},
+		{"PatchUserByID", testPatchUserByID},
+		{"UpdatePassword", testUpdatePassword},
+		{"PasswordReuseWindow", testPasswordReuseWindow},
+		{"SetMustChangePassword", testSetMustChangePassword},
+		{"SetUserRole", testSetUserRole},
+		{"MFA", testMFA},
+		{"MFAChallenges", testMFAChallenges},
+		{"Sessions", testSessions},
+		{"LoginEvents", testLoginEvents},
+		{"AuditEntries", testAuditEntries},
+		{"WithTx", testWithTx},
+		{"Outbox", testOutbox},
+		{"Webhooks", testWebhooks},
+		{"OAuth", testOAuth},
+		{"APIKeys", testAPIKeys},
+		{"IdempotencyKeys", testIdempotencyKeys},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			Default = NewGomegaWithT(t)
+			tc.run(t, newRepo(t))
+		})
+	}
+}
+
+// randomPhone keeps reruns against the same database from colliding.
+func randomPhone() string {
+	id := uuid.New()
+	return fmt.Sprintf("+62812%08d", binary.BigEndian.Uint32(id[:4])%100000000)
+}
+
+func createUser(t *testing.T, repo port.UserRepo) *domain.User {
+	u, err := repo.CreateUser(domain.Actor{RequestID: "req-create"}, &domain.User{
+		FullName:    "Edison Tantra",
+		PhoneNumber: randomPhone(),
+		Password:    testPassword,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u
+}
+
+func testCreateUser(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	Expect(uuid.Parse(u.ID)).ToNot(BeZero())
+	Expect(u.Version).To(Equal(1))
+
+	got, err := repo.GetUserByID(u.ID)
+	Expect(err).To(BeNil())
+	Expect(got.ID).To(Equal(u.ID))
+	Expect(got.FullName).To(Equal("Edison Tantra"))
+	Expect(got.PhoneNumber).To(Equal(u.PhoneNumber))
+	Expect(got.Password).To(BeEmpty())
+	Expect(got.Role).To(Equal(cons.RoleUser))
+	Expect(got.Version).To(Equal(1))
+
+	_, err = repo.CreateUser(domain.Actor{}, &domain.User{
+		FullName:    "Other User",
+		PhoneNumber: u.PhoneNumber,
+		Password:    testPassword,
+	})
+	Expect(err).To(MatchError(cons.ErrDataConflict))
+
+	_, err = repo.GetUserByID(uuid.NewString())
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+
+	id, err := repo.GetUserIDByPhone(u.PhoneNumber)
+	Expect(err).To(BeNil())
+	Expect(id).To(Equal(u.ID))
+
+	_, err = repo.GetUserIDByPhone(randomPhone())
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+}
+
+func testLogin(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+
+	_, err := repo.Login(u.PhoneNumber, "wrong")
+	Expect(err).To(MatchError(cons.ErrLoginNotMatch))
+
+	_, err = repo.Login(randomPhone(), testPassword)
+	Expect(err).To(MatchError(cons.ErrLoginNotMatch))
+
+	first, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(first.ID).To(Equal(u.ID))
+	Expect(first.Role).To(Equal(cons.RoleUser))
+	Expect(first.LoginCount).To(Equal(0))
+	Expect(first.MustChangePassword).To(BeFalse())
+	Expect(first.MFAEnabled).To(BeFalse())
+	Expect(first.PasswordChangedAt).ToNot(BeNil())
+
+	// verifying the password does not count the login
+	second, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(second.LoginCount).To(Equal(0))
+
+	Expect(repo.IncrementLoginCount(u.ID)).To(Succeed())
+	third, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(third.LoginCount).To(Equal(1))
+
+	Expect(repo.IncrementLoginCount(uuid.NewString())).To(MatchError(cons.ErrUserNotFound))
+}
+
+func testLoginConcurrent(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+
+	const logins = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, logins)
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- repo.IncrementLoginCount(u.ID)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		Expect(err).To(BeNil())
+	}
+
+	last, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(last.LoginCount).To(Equal(logins))
+}
+
+func testPatchUserByID(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	other := createUser(t, repo)
+	actor := domain.Actor{UserID: u.ID, RequestID: "req-patch"}
+
+	patched, err := repo.PatchUserByID(actor, u.ID, &domain.User{FullName: "Edison", Version: 1})
+	Expect(err).To(BeNil())
+	Expect(patched.FullName).To(Equal("Edison"))
+	Expect(patched.PhoneNumber).To(Equal(u.PhoneNumber))
+	Expect(patched.Version).To(Equal(2))
+
+	_, err = repo.PatchUserByID(actor, u.ID, &domain.User{FullName: "Stale", Version: 1})
+	Expect(err).To(MatchError(cons.ErrVersionMismatch))
+
+	_, err = repo.PatchUserByID(actor, u.ID, &domain.User{PhoneNumber: other.PhoneNumber})
+	Expect(err).To(MatchError(cons.ErrDataConflict))
+
+	phone := randomPhone()
+	patched, err = repo.PatchUserByID(actor, u.ID, &domain.User{PhoneNumber: phone})
+	Expect(err).To(BeNil())
+	Expect(patched.PhoneNumber).To(Equal(phone))
+	Expect(patched.Version).To(Equal(3))
+
+	_, err = repo.Login(phone, testPassword)
+	Expect(err).To(BeNil())
+
+	_, err = repo.PatchUserByID(actor, uuid.NewString(), &domain.User{FullName: "Nobody"})
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+
+	// a missing user is not found whatever version the caller sent
+	_, err = repo.PatchUserByID(actor, uuid.NewString(), &domain.User{FullName: "Nobody", Version: 1})
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+}
+
+func testUpdatePassword(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	actor := domain.Actor{UserID: u.ID}
+	const newPassword = "N3wPassw0rd!"
+
+	err := repo.UpdatePassword(actor, u.ID, "wrong", newPassword)
+	Expect(err).To(MatchError(cons.ErrPasswordNotMatch))
+
+	Expect(repo.IsPasswordReused(u.ID, testPassword, 1)).To(BeTrue())
+	Expect(repo.IsPasswordReused(u.ID, testPassword, 0)).To(BeFalse())
+	Expect(repo.IsPasswordReused(u.ID, newPassword, 5)).To(BeFalse())
+
+	err = repo.UpdatePassword(actor, u.ID, testPassword, newPassword)
+	Expect(err).To(BeNil())
+
+	_, err = repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(MatchError(cons.ErrLoginNotMatch))
+
+	_, err = repo.Login(u.PhoneNumber, newPassword)
+	Expect(err).To(BeNil())
+
+	Expect(repo.IsPasswordReused(u.ID, newPassword, 1)).To(BeTrue())
+	Expect(repo.IsPasswordReused(u.ID, testPassword, 2)).To(BeTrue())
+	Expect(repo.IsPasswordReused(u.ID, testPassword, 1)).To(BeFalse())
+
+	Expect(repo.CheckPassword(u.ID, testPassword)).To(MatchError(cons.ErrPasswordNotMatch))
+	Expect(repo.CheckPassword(u.ID, newPassword)).To(Succeed())
+
+	// the oldest password is pruned beyond the depth
+	Expect(repo.UpdatePassword(actor, u.ID, newPassword, "Th1rdPassw0rd!")).To(Succeed())
+	Expect(repo.PrunePasswordHistory(u.ID, 1)).To(Equal(int64(1)))
+	Expect(repo.IsPasswordReused(u.ID, newPassword, 5)).To(BeTrue())
+	Expect(repo.IsPasswordReused(u.ID, testPassword, 5)).To(BeFalse())
+	Expect(repo.PrunePasswordHistory(u.ID, 1)).To(BeZero())
+}
+
+func testPasswordReuseWindow(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	actor := domain.Actor{UserID: u.ID}
+	const depth = 3
+
+	passwords := []string{testPassword, "Passw0rd!1", "Passw0rd!2", "Passw0rd!3"}
+	for i := 1; i < len(passwords); i++ {
+		Expect(repo.UpdatePassword(actor, u.ID, passwords[i-1], passwords[i])).To(Succeed())
+	}
+
+	// after exactly depth changes the first password left the window of
+	// depth passwords, the current one included
+	Expect(repo.IsPasswordReused(u.ID, passwords[0], depth)).To(BeFalse())
+	for _, password := range passwords[1:] {
+		Expect(repo.IsPasswordReused(u.ID, password, depth)).To(BeTrue())
+	}
+}
+
+func testSetMustChangePassword(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+
+	err := repo.SetMustChangePassword(domain.Actor{}, u.ID, true)
+	Expect(err).To(BeNil())
+
+	logged, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(logged.MustChangePassword).To(BeTrue())
+
+	err = repo.UpdatePassword(domain.Actor{}, u.ID, testPassword, "N3wPassw0rd!")
+	Expect(err).To(BeNil())
+
+	logged, err = repo.Login(u.PhoneNumber, "N3wPassw0rd!")
+	Expect(err).To(BeNil())
+	Expect(logged.MustChangePassword).To(BeFalse())
+
+	err = repo.SetMustChangePassword(domain.Actor{}, uuid.NewString(), true)
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+}
+
+func testSetUserRole(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+
+	err := repo.SetUserRole(domain.Actor{RequestID: "req-promote"}, u.ID, cons.RoleAdmin)
+	Expect(err).To(BeNil())
+
+	logged, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(logged.Role).To(Equal(cons.RoleAdmin))
+
+	got, err := repo.GetUserByID(u.ID)
+	Expect(err).To(BeNil())
+	Expect(got.Role).To(Equal(cons.RoleAdmin))
+
+	entries, _, err := repo.ListAuditEntries(domain.AuditFilter{UserID: u.ID}, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(entries[0].Action).To(Equal(cons.AuditActionSetRole))
+	Expect(entries[0].ActorID).To(Equal(u.ID))
+	Expect(*entries[0].Changes[0].Old).To(Equal(cons.RoleUser))
+	Expect(*entries[0].Changes[0].New).To(Equal(cons.RoleAdmin))
+
+	err = repo.SetUserRole(domain.Actor{}, uuid.NewString(), cons.RoleAdmin)
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+}
+
+func testMFA(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+
+	_, err := repo.GetMFA(u.ID)
+	Expect(err).To(MatchError(cons.ErrMFANotEnrolled))
+
+	err = repo.ConfirmTOTP(u.ID, []string{"abcdefghij"})
+	Expect(err).To(MatchError(cons.ErrMFANotEnrolled))
+
+	Expect(repo.SaveTOTPSecret(u.ID, "first")).To(Succeed())
+	Expect(repo.SaveTOTPSecret(u.ID, "second")).To(Succeed())
+
+	m, err := repo.GetMFA(u.ID)
+	Expect(err).To(BeNil())
+	Expect(m.UserID).To(Equal(u.ID))
+	Expect(m.TOTPSecret).To(Equal("second"))
+	Expect(m.ConfirmedAt).To(BeNil())
+
+	Expect(repo.ConfirmTOTP(u.ID, []string{"abcdefghij", "klmnopqrst"})).To(Succeed())
+	Expect(repo.SaveTOTPSecret(u.ID, "third")).To(MatchError(cons.ErrMFAAlreadyEnabled))
+
+	m, err = repo.GetMFA(u.ID)
+	Expect(err).To(BeNil())
+	Expect(m.TOTPSecret).To(Equal("second"))
+	Expect(m.ConfirmedAt).ToNot(BeNil())
+
+	logged, err := repo.Login(u.PhoneNumber, testPassword)
+	Expect(err).To(BeNil())
+	Expect(logged.MFAEnabled).To(BeTrue())
+
+	Expect(repo.UseRecoveryCode(u.ID, "zzzzzzzzzz")).To(BeFalse())
+	Expect(repo.UseRecoveryCode(u.ID, "abcdefghij")).To(BeTrue())
+	Expect(repo.UseRecoveryCode(u.ID, "abcdefghij")).To(BeFalse())
+	Expect(repo.UseRecoveryCode(u.ID, "klmnopqrst")).To(BeTrue())
+
+	Expect(repo.UseTOTPStep(u.ID, 100)).To(BeTrue())
+	Expect(repo.UseTOTPStep(u.ID, 100)).To(BeFalse())
+	Expect(repo.UseTOTPStep(u.ID, 99)).To(BeFalse())
+	Expect(repo.UseTOTPStep(u.ID, 101)).To(BeTrue())
+
+	m, err = repo.GetMFA(u.ID)
+	Expect(err).To(BeNil())
+	Expect(m.LastTOTPStep).To(Equal(int64(101)))
+}
+
+func testMFAChallenges(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	id := uuid.NewString()
+	expiresAt := time.Now().Add(time.Minute)
+
+	Expect(repo.CountMFAAttempt(id, u.ID, expiresAt)).To(Equal(1))
+	Expect(repo.CountMFAAttempt(id, u.ID, expiresAt)).To(Equal(2))
+	Expect(repo.UseMFAChallenge(id)).To(BeTrue())
+	Expect(repo.UseMFAChallenge(id)).To(BeFalse())
+	Expect(repo.UseMFAChallenge(uuid.NewString())).To(BeFalse())
+
+	expired := uuid.NewString()
+	Expect(repo.CountMFAAttempt(expired, u.ID, time.Now().Add(-time.Minute))).To(Equal(1))
+
+	deleted, err := repo.DeleteMFAChallengesExpiredBefore(time.Now())
+	Expect(err).To(BeNil())
+	Expect(deleted).To(BeNumerically(">=", 1))
+	Expect(repo.CountMFAAttempt(expired, u.ID, expiresAt)).To(Equal(1))
+	Expect(repo.CountMFAAttempt(id, u.ID, expiresAt)).To(Equal(3))
+}
+
+func testSessions(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	expiresAt := time.Now().Add(time.Hour)
+
+	first, err := repo.CreateSession(&domain.Session{
+		UserID:    u.ID,
+		UserAgent: "curl/8.0",
+		IPAddress: "203.0.113.7",
+		ExpiresAt: &expiresAt,
+	})
+	Expect(err).To(BeNil())
+	Expect(first.UserID).To(Equal(u.ID))
+	Expect(first.UserAgent).To(Equal("curl/8.0"))
+	Expect(first.IPAddress).To(Equal("203.0.113.7"))
+	Expect(first.CreatedAt).ToNot(BeNil())
+	Expect(first.LastSeenAt).ToNot(BeNil())
+
+	second, err := repo.CreateSession(&domain.Session{UserID: u.ID, ExpiresAt: &expiresAt})
+	Expect(err).To(BeNil())
+	Expect(second.IPAddress).To(BeEmpty())
+
+	expiredAt := time.Now().Add(-time.Minute)
+	expired, err := repo.CreateSession(&domain.Session{UserID: u.ID, ExpiresAt: &expiredAt})
+	Expect(err).To(BeNil())
+
+	Expect(repo.TouchSession(u.ID, first.ID)).To(Succeed())
+	Expect(repo.TouchSession(u.ID, expired.ID)).To(MatchError(cons.ErrSessionRevoked))
+	Expect(repo.TouchSession(uuid.NewString(), first.ID)).To(MatchError(cons.ErrSessionRevoked))
+	Expect(repo.TouchSession(u.ID, "not-a-uuid")).To(MatchError(cons.ErrSessionRevoked))
+
+	sessions, err := repo.ListSessions(u.ID)
+	Expect(err).To(BeNil())
+	Expect(sessions).To(HaveLen(2))
+	Expect(sessions[0].ID).To(Equal(first.ID))
+
+	Expect(repo.RevokeSession(u.ID, second.ID)).To(Succeed())
+	Expect(repo.RevokeSession(u.ID, second.ID)).To(MatchError(cons.ErrSessionNotFound))
+	Expect(repo.RevokeSession(u.ID, uuid.NewString())).To(MatchError(cons.ErrSessionNotFound))
+	Expect(repo.TouchSession(u.ID, second.ID)).To(MatchError(cons.ErrSessionRevoked))
+
+	third, err := repo.CreateSession(&domain.Session{UserID: u.ID, ExpiresAt: &expiresAt})
+	Expect(err).To(BeNil())
+
+	n, err := repo.RevokeUserSessions(u.ID, first.ID)
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(int64(2)))
+	Expect(repo.TouchSession(u.ID, third.ID)).To(MatchError(cons.ErrSessionRevoked))
+	Expect(repo.TouchSession(u.ID, first.ID)).To(Succeed())
+
+	n, err = repo.RevokeUserSessions(u.ID, "")
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(int64(1)))
+
+	sessions, err = repo.ListSessions(u.ID)
+	Expect(err).To(BeNil())
+	Expect(sessions).To(BeEmpty())
+}
+
+func testLoginEvents(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+
+	Expect(repo.CreateLoginEvent(&domain.LoginEvent{
+		PhoneNumber: u.PhoneNumber,
+		Reason:      cons.LoginReasonInvalidCredentials,
+		IPAddress:   "203.0.113.7",
+	})).To(Succeed())
+	Expect(repo.CreateLoginEvent(&domain.LoginEvent{
+		UserID:      u.ID,
+		PhoneNumber: u.PhoneNumber,
+		Success:     true,
+		UserAgent:   "curl/8.0",
+	})).To(Succeed())
+	Expect(repo.CreateLoginEvent(&domain.LoginEvent{
+		PhoneNumber: randomPhone(),
+		Reason:      cons.LoginReasonInvalidPhone,
+	})).To(Succeed())
+
+	events, total, err := repo.ListLoginEvents(u.ID, 1, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(2))
+	Expect(events).To(HaveLen(1))
+	Expect(events[0].Success).To(BeTrue())
+	Expect(events[0].UserAgent).To(Equal("curl/8.0"))
+
+	events, total, err = repo.ListLoginEvents(u.ID, 1, 1)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(2))
+	Expect(events).To(HaveLen(1))
+	Expect(events[0].UserID).To(Equal(u.ID))
+	Expect(events[0].Reason).To(Equal(cons.LoginReasonInvalidCredentials))
+	Expect(events[0].IPAddress).To(Equal("203.0.113.7"))
+
+	events, _, err = repo.ListLoginEvents(u.ID, 10, 5)
+	Expect(err).To(BeNil())
+	Expect(events).To(BeEmpty())
+
+	n, err := repo.DeleteLoginEventsBefore(time.Now().Add(-time.Hour))
+	Expect(err).To(BeNil())
+	Expect(n).To(BeNumerically(">=", 0))
+
+	_, total, err = repo.ListLoginEvents(u.ID, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(2))
+
+	n, err = repo.DeleteLoginEventsBefore(time.Now().Add(time.Second))
+	Expect(err).To(BeNil())
+	Expect(n).To(BeNumerically(">=", 3))
+
+	_, total, err = repo.ListLoginEvents(u.ID, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(BeZero())
+}
+
+func testAuditEntries(t *testing.T, repo port.UserRepo) {
+	u := createUser(t, repo)
+	admin := createUser(t, repo)
+
+	_, err := repo.PatchUserByID(domain.Actor{UserID: u.ID, RequestID: "req-patch"}, u.ID, &domain.User{FullName: "Edison"})
+	Expect(err).To(BeNil())
+
+	err = repo.SetMustChangePassword(domain.Actor{UserID: admin.ID, RequestID: "req-force"}, u.ID, true)
+	Expect(err).To(BeNil())
+
+	entries, total, err := repo.ListAuditEntries(domain.AuditFilter{UserID: u.ID}, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(3))
+	Expect(entries).To(HaveLen(3))
+
+	Expect(entries[0].Action).To(Equal(cons.AuditActionForcePasswordChange))
+	Expect(entries[0].ActorID).To(Equal(admin.ID))
+	Expect(entries[0].RequestID).To(Equal("req-force"))
+	Expect(entries[0].Changes).To(HaveLen(1))
+	Expect(*entries[0].Changes[0].Old).To(Equal("false"))
+	Expect(*entries[0].Changes[0].New).To(Equal("true"))
+
+	Expect(entries[1].Action).To(Equal(cons.AuditActionUpdateProfile))
+	Expect(entries[1].ActorID).To(Equal(u.ID))
+	Expect(entries[1].Changes).To(HaveLen(1))
+	Expect(entries[1].Changes[0].Field).To(Equal("full_name"))
+	Expect(*entries[1].Changes[0].Old).To(Equal("Edison Tantra"))
+	Expect(*entries[1].Changes[0].New).To(Equal("Edison"))
+
+	Expect(entries[2].Action).To(Equal(cons.AuditActionCreateUser))
+	Expect(entries[2].ActorID).To(Equal(u.ID))
+	Expect(entries[2].RequestID).To(Equal("req-create"))
+	Expect(entries[2].Changes).To(HaveLen(2))
+	Expect(entries[2].Changes[0].Old).To(BeNil())
+
+	entries, total, err = repo.ListAuditEntries(domain.AuditFilter{UserID: u.ID}, 1, 1)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(3))
+	Expect(entries).To(HaveLen(1))
+	Expect(entries[0].Action).To(Equal(cons.AuditActionUpdateProfile))
+
+	future := time.Now().Add(time.Hour)
+	_, total, err = repo.ListAuditEntries(domain.AuditFilter{UserID: u.ID, From: &future}, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(BeZero())
+
+	past := time.Now().Add(-time.Hour)
+	_, total, err = repo.ListAuditEntries(domain.AuditFilter{UserID: u.ID, To: &past}, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(BeZero())
+}
+
+func testWithTx(t *testing.T, repo port.UserRepo) {
+	errRollback := errors.New("rollback")
+	phone := randomPhone()
+
+	err := repo.WithTx(context.Background(), func(tx port.UserRepo) error {
+		_, err := tx.CreateUser(domain.Actor{}, &domain.User{
+			FullName:    "Edison Tantra",
+			PhoneNumber: phone,
+			Password:    testPassword,
+		})
+		if err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	Expect(err).To(MatchError(errRollback))
+
+	_, err = repo.Login(phone, testPassword)
+	Expect(err).To(MatchError(cons.ErrLoginNotMatch))
+
+	var created *domain.User
+	err = repo.WithTx(context.Background(), func(tx port.UserRepo) error {
+		return tx.WithTx(context.Background(), func(nested port.UserRepo) error {
+			var err error
+			created, err = nested.CreateUser(domain.Actor{}, &domain.User{
+				FullName:    "Edison Tantra",
+				PhoneNumber: phone,
+				Password:    testPassword,
+			})
+			return err
+		})
+	})
+	Expect(err).To(BeNil())
+
+	logged, err := repo.Login(phone, testPassword)
+	Expect(err).To(BeNil())
+	Expect(logged.ID).To(Equal(created.ID))
+}
+
+// claimOwn claims pending events and keeps those of aggregateID, a shared
+// database may hold events of earlier runs.
+func claimOwn(ob port.OutboxRepo, aggregateID string, lease time.Duration) []domain.Event {
+	events, err := ob.ClaimOutboxEvents(1000, lease)
+	Expect(err).To(BeNil())
+
+	res := []domain.Event{}
+	for _, e := range events {
+		if e.AggregateID == aggregateID {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+func testOutbox(t *testing.T, repo port.UserRepo) {
+	ob, ok := repo.(port.OutboxRepo)
+	if !ok {
+		t.Skip("repository does not implement port.OutboxRepo")
+	}
+
+	errRollback := errors.New("rollback")
+	u := createUser(t, repo)
+
+	err := repo.WithTx(context.Background(), func(tx port.UserRepo) error {
+		err := tx.CreateOutboxEvent(domain.UserPhoneChanged{UserID: u.ID, PhoneNumber: u.PhoneNumber})
+		if err != nil {
+			return err
+		}
+
+		return errRollback
+	})
+	Expect(err).To(MatchError(errRollback))
+
+	sessionID := uuid.NewString()
+	err = repo.WithTx(context.Background(), func(tx port.UserRepo) error {
+		err := tx.CreateOutboxEvent(domain.UserRegistered{UserID: u.ID, FullName: u.FullName, PhoneNumber: u.PhoneNumber})
+		if err != nil {
+			return err
+		}
+
+		return tx.CreateOutboxEvent(domain.UserLoggedIn{UserID: u.ID, SessionID: sessionID})
+	})
+	Expect(err).To(BeNil())
+
+	events := claimOwn(ob, u.ID, time.Minute)
+	Expect(events).To(HaveLen(2))
+	Expect(events[0].Type).To(Equal(cons.EventUserRegistered))
+	Expect(events[1].Type).To(Equal(cons.EventUserLoggedIn))
+	Expect(uuid.Parse(events[0].ID)).ToNot(BeZero())
+	Expect(events[0].OccurredAt).To(BeTemporally("~", time.Now(), time.Minute))
+
+	loggedIn := domain.UserLoggedIn{}
+	Expect(json.Unmarshal(events[1].Payload, &loggedIn)).To(Succeed())
+	Expect(loggedIn).To(Equal(domain.UserLoggedIn{UserID: u.ID, SessionID: sessionID}))
+
+	// leased events are not claimed again
+	Expect(claimOwn(ob, u.ID, time.Minute)).To(BeEmpty())
+
+	// a failure is retried when it is due
+	Expect(ob.RecordOutboxEventFailure(domain.OutboxFailure{EventID: events[0].ID, Error: "broker unavailable", RetryAt: time.Now()})).To(Succeed())
+	retried := claimOwn(ob, u.ID, time.Millisecond)
+	Expect(retried).To(HaveLen(1))
+	Expect(retried[0].ID).To(Equal(events[0].ID))
+	Expect(retried[0].Attempts).To(Equal(1))
+
+	// so is an event whose lease expired
+	time.Sleep(10 * time.Millisecond)
+	Expect(claimOwn(ob, u.ID, time.Minute)).To(HaveLen(1))
+
+	// an event waiting for its retry holds back the later events
+	Expect(ob.RecordOutboxEventFailure(domain.OutboxFailure{EventID: events[0].ID, Error: "broker unavailable", RetryAt: time.Now().Add(time.Minute)})).To(Succeed())
+	Expect(ob.RecordOutboxEventFailure(domain.OutboxFailure{EventID: events[1].ID, Error: "broker unavailable", RetryAt: time.Now()})).To(Succeed())
+	Expect(claimOwn(ob, u.ID, time.Minute)).To(BeEmpty())
+
+	// a dead-lettered event is never claimed and releases them
+	Expect(ob.RecordOutboxEventFailure(domain.OutboxFailure{EventID: events[0].ID, Error: "broker unavailable", DeadLetter: true})).To(Succeed())
+	retried = claimOwn(ob, u.ID, time.Minute)
+	Expect(retried).To(HaveLen(1))
+	Expect(retried[0].ID).To(Equal(events[1].ID))
+
+	Expect(ob.MarkOutboxEventPublished(events[1].ID)).To(Succeed())
+	Expect(ob.RecordOutboxEventFailure(domain.OutboxFailure{EventID: events[1].ID, Error: "late failure", RetryAt: time.Now()})).To(Succeed())
+
+	time.Sleep(10 * time.Millisecond)
+	Expect(claimOwn(ob, u.ID, time.Millisecond)).To(BeEmpty())
+
+	n, err := ob.DeleteOutboxEventsPublishedBefore(time.Now().Add(time.Minute))
+	Expect(err).To(BeNil())
+	Expect(n).To(BeNumerically(">=", 1))
+}
+
+func testWebhooks(t *testing.T, repo port.UserRepo) {
+	wr, ok := repo.(port.WebhookRepo)
+	if !ok {
+		t.Skip("repository does not implement port.WebhookRepo")
+	}
+
+	// a random event type keeps webhooks of earlier runs from matching
+	eventType := "test." + uuid.NewString()
+
+	created, err := wr.CreateWebhook(&domain.Webhook{
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []string{cons.EventUserRegistered, eventType},
+		Secret:     "encrypted-secret",
+	})
+	Expect(err).To(BeNil())
+	Expect(uuid.Parse(created.ID)).ToNot(BeZero())
+	Expect(created.EventTypes).To(Equal([]string{cons.EventUserRegistered, eventType}))
+	Expect(created.CreatedAt).ToNot(BeNil())
+	t.Cleanup(func() {
+		_ = wr.DeleteWebhook(created.ID)
+	})
+
+	other, err := wr.CreateWebhook(&domain.Webhook{
+		URL:        "https://other.example.com/hooks",
+		EventTypes: []string{cons.EventUserLoggedIn},
+		Secret:     "encrypted-secret",
+	})
+	Expect(err).To(BeNil())
+	t.Cleanup(func() {
+		_ = wr.DeleteWebhook(other.ID)
+	})
+
+	got, err := wr.GetWebhook(created.ID)
+	Expect(err).To(BeNil())
+	Expect(got.URL).To(Equal("https://partner.example.com/hooks"))
+	Expect(got.Secret).To(Equal("encrypted-secret"))
+
+	list, err := wr.ListWebhooks()
+	Expect(err).To(BeNil())
+	ids := []string{}
+	for _, w := range list {
+		ids = append(ids, w.ID)
+	}
+	Expect(ids).To(ContainElements(created.ID, other.ID))
+
+	// an empty secret keeps the stored one
+	updated, err := wr.UpdateWebhook(&domain.Webhook{
+		ID:         created.ID,
+		URL:        "https://partner.example.com/v2/hooks",
+		EventTypes: []string{eventType},
+	})
+	Expect(err).To(BeNil())
+	Expect(updated.URL).To(Equal("https://partner.example.com/v2/hooks"))
+	Expect(updated.EventTypes).To(Equal([]string{eventType}))
+	Expect(updated.Secret).To(Equal("encrypted-secret"))
+
+	updated, err = wr.UpdateWebhook(&domain.Webhook{
+		ID:         created.ID,
+		URL:        updated.URL,
+		EventTypes: updated.EventTypes,
+		Secret:     "rotated-secret",
+	})
+	Expect(err).To(BeNil())
+	Expect(updated.Secret).To(Equal("rotated-secret"))
+
+	unknownID := uuid.NewString()
+	_, err = wr.GetWebhook(unknownID)
+	Expect(err).To(MatchError(cons.ErrWebhookNotFound))
+	_, err = wr.UpdateWebhook(&domain.Webhook{ID: unknownID, URL: "https://x.example.com", EventTypes: []string{eventType}})
+	Expect(err).To(MatchError(cons.ErrWebhookNotFound))
+	Expect(wr.DeleteWebhook(unknownID)).To(MatchError(cons.ErrWebhookNotFound))
+
+	// only the subscribed webhook gets a delivery, once
+	event := &domain.Event{
+		ID:          uuid.NewString(),
+		Type:        eventType,
+		AggregateID: uuid.NewString(),
+		Payload:     json.RawMessage(`{"user_id":"1"}`),
+		OccurredAt:  time.Now().UTC().Truncate(time.Second),
+	}
+	n, err := wr.EnqueueWebhookDeliveries(event)
+	Expect(err).To(BeNil())
+	Expect(n).To(Equal(int64(1)))
+
+	n, err = wr.EnqueueWebhookDeliveries(event)
+	Expect(err).To(BeNil())
+	Expect(n).To(BeZero())
+
+	claimOwn := func(lease time.Duration) []domain.WebhookDelivery {
+		deliveries, err := wr.ClaimWebhookDeliveries(1000, lease)
+		Expect(err).To(BeNil())
+
+		res := []domain.WebhookDelivery{}
+		for _, d := range deliveries {
+			if d.WebhookID == created.ID {
+				res = append(res, d)
+			}
+		}
+
+		return res
+	}
+
+	claimed := claimOwn(time.Minute)
+	Expect(claimed).To(HaveLen(1))
+	Expect(uuid.Parse(claimed[0].ID)).ToNot(BeZero())
+	Expect(claimed[0].EventID).To(Equal(event.ID))
+	Expect(claimed[0].EventType).To(Equal(eventType))
+	Expect(claimed[0].Status).To(Equal(cons.WebhookStatusPending))
+
+	payload := domain.Event{}
+	Expect(json.Unmarshal(claimed[0].Payload, &payload)).To(Succeed())
+	Expect(payload.ID).To(Equal(event.ID))
+	Expect(payload.Payload).To(MatchJSON(`{"user_id":"1"}`))
+
+	// leased deliveries are not claimed again
+	Expect(claimOwn(time.Minute)).To(BeEmpty())
+
+	// a retry is not due before its next attempt
+	Expect(wr.RecordWebhookAttempt(domain.WebhookAttempt{
+		DeliveryID:    claimed[0].ID,
+		Status:        cons.WebhookStatusPending,
+		StatusCode:    503,
+		Error:         "unexpected status 503",
+		NextAttemptAt: time.Now().Add(time.Hour),
+	})).To(Succeed())
+	Expect(claimOwn(time.Minute)).To(BeEmpty())
+
+	Expect(wr.RecordWebhookAttempt(domain.WebhookAttempt{
+		DeliveryID:    claimed[0].ID,
+		Status:        cons.WebhookStatusPending,
+		Error:         "connection refused",
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})).To(Succeed())
+	retried := claimOwn(time.Minute)
+	Expect(retried).To(HaveLen(1))
+	Expect(retried[0].Attempts).To(Equal(2))
+	Expect(retried[0].LastError).To(Equal("connection refused"))
+
+	Expect(wr.RecordWebhookAttempt(domain.WebhookAttempt{
+		DeliveryID:    claimed[0].ID,
+		Status:        cons.WebhookStatusSucceeded,
+		StatusCode:    204,
+		NextAttemptAt: time.Now(),
+	})).To(Succeed())
+	Expect(claimOwn(time.Millisecond)).To(BeEmpty())
+
+	deliveries, total, err := wr.ListWebhookDeliveries(created.ID, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(Equal(1))
+	Expect(deliveries).To(HaveLen(1))
+	Expect(deliveries[0].Status).To(Equal(cons.WebhookStatusSucceeded))
+	Expect(deliveries[0].Attempts).To(Equal(3))
+	Expect(deliveries[0].LastStatusCode).To(Equal(204))
+	Expect(deliveries[0].DeliveredAt).ToNot(BeNil())
+
+	_, total, err = wr.ListWebhookDeliveries(other.ID, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(BeZero())
+
+	// deleting a webhook deletes its delivery log
+	Expect(wr.DeleteWebhook(created.ID)).To(Succeed())
+	_, err = wr.GetWebhook(created.ID)
+	Expect(err).To(MatchError(cons.ErrWebhookNotFound))
+
+	_, total, err = wr.ListWebhookDeliveries(created.ID, 10, 0)
+	Expect(err).To(BeNil())
+	Expect(total).To(BeZero())
+}
+
+func testOAuth(t *testing.T, repo port.UserRepo) {
+	or, ok := repo.(port.OAuthRepo)
+	if !ok {
+		t.Skip("repository does not implement port.OAuthRepo")
+	}
+
+	u := createUser(t, repo)
+	expiresAt := time.Now().Add(time.Hour)
+	session, err := repo.CreateSession(&domain.Session{UserID: u.ID, ExpiresAt: &expiresAt})
+	Expect(err).To(BeNil())
+
+	client, err := or.CreateOAuthClient(&domain.OAuthClient{
+		Name:         "web",
+		RedirectURIs: []string{"https://app.example.com/callback", "http://localhost:3000/callback"},
+		Confidential: true,
+		Secret:       "hashed-secret",
+	})
+	Expect(err).To(BeNil())
+	Expect(uuid.Parse(client.ID)).ToNot(BeZero())
+	Expect(client.CreatedAt).ToNot(BeNil())
+	t.Cleanup(func() {
+		_ = or.DeleteOAuthClient(client.ID)
+	})
+
+	got, err := or.GetOAuthClient(client.ID)
+	Expect(err).To(BeNil())
+	Expect(got.Name).To(Equal("web"))
+	Expect(got.RedirectURIs).To(Equal([]string{"https://app.example.com/callback", "http://localhost:3000/callback"}))
+	Expect(got.Confidential).To(BeTrue())
+	Expect(got.Secret).To(Equal("hashed-secret"))
+
+	list, err := or.ListOAuthClients()
+	Expect(err).To(BeNil())
+	ids := []string{}
+	for _, c := range list {
+		ids = append(ids, c.ID)
+	}
+	Expect(ids).To(ContainElement(client.ID))
+
+	_, err = or.GetOAuthClient(uuid.NewString())
+	Expect(err).To(MatchError(cons.ErrOAuthClientNotFound))
+	_, err = or.GetOAuthClient("not-a-uuid")
+	Expect(err).To(MatchError(cons.ErrOAuthClientNotFound))
+	Expect(or.DeleteOAuthClient(uuid.NewString())).To(MatchError(cons.ErrOAuthClientNotFound))
+
+	authTime := time.Now().UTC().Truncate(time.Second)
+	newCode := func(expiresAt time.Time) *domain.OAuthCode {
+		code := &domain.OAuthCode{
+			CodeHash:      uuid.NewString(),
+			ClientID:      client.ID,
+			UserID:        u.ID,
+			SessionID:     session.ID,
+			RedirectURI:   "https://app.example.com/callback",
+			Scope:         "openid profile",
+			Nonce:         "n-0S6_WzA2Mj",
+			CodeChallenge: "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+			AuthTime:      authTime,
+			ExpiresAt:     expiresAt,
+		}
+		Expect(or.CreateOAuthCode(code)).To(Succeed())
+
+		return code
+	}
+
+	// a code is exchanged once
+	code := newCode(time.Now().Add(time.Minute))
+	consumed, err := or.ConsumeOAuthCode(code.CodeHash)
+	Expect(err).To(BeNil())
+	Expect(consumed.ClientID).To(Equal(client.ID))
+	Expect(consumed.UserID).To(Equal(u.ID))
+	Expect(consumed.SessionID).To(Equal(session.ID))
+	Expect(consumed.RedirectURI).To(Equal(code.RedirectURI))
+	Expect(consumed.Scope).To(Equal("openid profile"))
+	Expect(consumed.Nonce).To(Equal(code.Nonce))
+	Expect(consumed.CodeChallenge).To(Equal(code.CodeChallenge))
+	Expect(consumed.AuthTime.Equal(authTime)).To(BeTrue())
+
+	_, err = or.ConsumeOAuthCode(code.CodeHash)
+	Expect(err).To(MatchError(cons.ErrInvalidGrant))
+	_, err = or.ConsumeOAuthCode(uuid.NewString())
+	Expect(err).To(MatchError(cons.ErrInvalidGrant))
+
+	expired := newCode(time.Now().Add(-time.Minute))
+	_, err = or.ConsumeOAuthCode(expired.CodeHash)
+	Expect(err).To(MatchError(cons.ErrInvalidGrant))
+
+	n, err := or.DeleteOAuthCodesExpiredBefore(time.Now())
+	Expect(err).To(BeNil())
+	Expect(n).To(BeNumerically(">=", 1))
+
+	// deleting the client deletes its codes
+	unused := newCode(time.Now().Add(time.Minute))
+	Expect(or.DeleteOAuthClient(client.ID)).To(Succeed())
+	_, err = or.GetOAuthClient(client.ID)
+	Expect(err).To(MatchError(cons.ErrOAuthClientNotFound))
+	_, err = or.ConsumeOAuthCode(unused.CodeHash)
+	Expect(err).To(MatchError(cons.ErrInvalidGrant))
+}
+
+func testAPIKeys(t *testing.T, repo port.UserRepo) {
+	kr, ok := repo.(port.APIKeyRepo)
+	if !ok {
+		t.Skip("repository does not implement port.APIKeyRepo")
+	}
+
+	u := createUser(t, repo)
+	prefix := strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	key, err := kr.CreateAPIKey(&domain.APIKey{
+		Name:      "billing export",
+		UserID:    u.ID,
+		Prefix:    prefix,
+		KeyHash:   "hashed-key",
+		Scopes:    []string{cons.ScopeAdmin},
+		ExpiresAt: &expiresAt,
+	})
+	Expect(err).To(BeNil())
+	Expect(uuid.Parse(key.ID)).ToNot(BeZero())
+	Expect(key.CreatedAt).ToNot(BeNil())
+	Expect(key.LastUsedAt).To(BeNil())
+	Expect(key.RevokedAt).To(BeNil())
+
+	got, err := kr.GetAPIKeyByPrefix(prefix)
+	Expect(err).To(BeNil())
+	Expect(got.ID).To(Equal(key.ID))
+	Expect(got.Name).To(Equal("billing export"))
+	Expect(got.UserID).To(Equal(u.ID))
+	Expect(got.KeyHash).To(Equal("hashed-key"))
+	Expect(got.Scopes).To(Equal([]string{cons.ScopeAdmin}))
+	Expect(got.ExpiresAt.Equal(expiresAt)).To(BeTrue())
+
+	_, err = kr.GetAPIKeyByPrefix("unknown")
+	Expect(err).To(MatchError(cons.ErrAPIKeyNotFound))
+
+	_, err = kr.CreateAPIKey(&domain.APIKey{
+		Name:    "orphan",
+		UserID:  uuid.NewString(),
+		Prefix:  prefix + "x",
+		KeyHash: "hashed-key",
+		Scopes:  []string{cons.ScopeAdmin},
+	})
+	Expect(err).To(MatchError(cons.ErrUserNotFound))
+
+	Expect(kr.TouchAPIKey(key.ID)).To(Succeed())
+	got, err = kr.GetAPIKeyByPrefix(prefix)
+	Expect(err).To(BeNil())
+	Expect(got.LastUsedAt).ToNot(BeNil())
+
+	list, err := kr.ListAPIKeys()
+	Expect(err).To(BeNil())
+	ids := []string{}
+	for _, k := range list {
+		ids = append(ids, k.ID)
+	}
+	Expect(ids).To(ContainElement(key.ID))
+
+	// revoking again keeps the first revocation time
+	Expect(kr.RevokeAPIKey(key.ID)).To(Succeed())
+	got, err = kr.GetAPIKeyByPrefix(prefix)
+	Expect(err).To(BeNil())
+	Expect(got.RevokedAt).ToNot(BeNil())
+	revokedAt := *got.RevokedAt
+
+	Expect(kr.RevokeAPIKey(key.ID)).To(Succeed())
+	got, err = kr.GetAPIKeyByPrefix(prefix)
+	Expect(err).To(BeNil())
+	Expect(got.RevokedAt.Equal(revokedAt)).To(BeTrue())
+
+	Expect(kr.RevokeAPIKey(uuid.NewString())).To(MatchError(cons.ErrAPIKeyNotFound))
+	Expect(kr.RevokeAPIKey("not-a-uuid")).To(MatchError(cons.ErrAPIKeyNotFound))
+	Expect(kr.TouchAPIKey(uuid.NewString())).To(MatchError(cons.ErrAPIKeyNotFound))
+}
+
+func testIdempotencyKeys(t *testing.T, repo port.UserRepo) {
+	ir, ok := repo.(port.IdempotencyRepo)
+	if !ok {
+		t.Skip("repository does not implement port.IdempotencyRepo")
+	}
+
+	key := uuid.NewString()
+	expiresAt := time.Now().Add(time.Hour)
+	first := &domain.IdempotencyRecord{Caller: "caller-a", Key: key, Fingerprint: "abc", ExpiresAt: expiresAt}
+	Expect(ir.ReserveIdempotencyKey(first)).To(BeNil())
+
+	existing, err := ir.ReserveIdempotencyKey(first)
+	Expect(err).To(BeNil())
+	Expect(existing.StatusCode).To(BeZero())
+
+	// the same key of another caller is a key of its own
+	other := &domain.IdempotencyRecord{Caller: "caller-b", Key: key, Fingerprint: "def", ExpiresAt: expiresAt}
+	Expect(ir.ReserveIdempotencyKey(other)).To(BeNil())
+
+	first.StatusCode = 201
+	first.ContentType = "application/json"
+	first.Body = []byte(`{}`)
+	Expect(ir.CompleteIdempotencyKey(first)).To(Succeed())
+
+	existing, err = ir.ReserveIdempotencyKey(first)
+	Expect(err).To(BeNil())
+	Expect(existing.Caller).To(Equal("caller-a"))
+	Expect(existing.Fingerprint).To(Equal("abc"))
+	Expect(existing.StatusCode).To(Equal(201))
+	Expect(existing.Body).To(Equal([]byte(`{}`)))
+
+	Expect(ir.DeleteIdempotencyKey(other)).To(Succeed())
+	Expect(ir.ReserveIdempotencyKey(other)).To(BeNil())
+
+	existing, err = ir.ReserveIdempotencyKey(first)
+	Expect(err).To(BeNil())
+	Expect(existing.StatusCode).To(Equal(201))
+}
@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"encoding/json"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.APIKeyRepo = (*Repository)(nil)
+
+const apiKeyColumns = `id, name, user_id, prefix, key_hash, scopes, expires_at, last_used_at, revoked_at, created_at`
+
+// CreateAPIKey inserts the key only when its owner is active, no row is
+// cons.ErrUserNotFound.
+func (r *Repository) CreateAPIKey(data *domain.APIKey) (*domain.APIKey, error) {
+	q := `
+		INSERT INTO api_keys (id, name, user_id, prefix, key_hash, scopes, expires_at, created_at)
+		SELECT :id, :name, id, :prefix, :key_hash, :scopes, :expires_at, :now
+		FROM users
+		WHERE id = :user_id AND is_active = TRUE
+		RETURNING ` + apiKeyColumns + `;
+	`
+
+	userID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		return nil, cons.ErrUserNotFound
+	}
+
+	scopes, err := json.Marshal(data.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := APIKey{
+		ID:        uuid.NewString(),
+		Name:      data.Name,
+		UserID:    userID.String(),
+		Prefix:    data.Prefix,
+		KeyHash:   data.KeyHash,
+		Scopes:    string(scopes),
+		ExpiresAt: utc(data.ExpiresAt),
+		Now:       now(),
+	}
+
+	k := APIKey{}
+	found, err := r.get(q, &arg, &k)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrUserNotFound
+	}
+
+	return toDomainAPIKey(k)
+}
+
+func (r *Repository) GetAPIKeyByPrefix(prefix string) (*domain.APIKey, error) {
+	q := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE prefix = :prefix;
+	`
+
+	return r.getAPIKey(q, &APIKey{Prefix: prefix})
+}
+
+func (r *Repository) ListAPIKeys() ([]domain.APIKey, error) {
+	q := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		ORDER BY created_at, rowid;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &APIKey{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.APIKey{}
+	for rows.Next() {
+		k := APIKey{}
+		err = rows.StructScan(&k)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := toDomainAPIKey(k)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *key)
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) RevokeAPIKey(id string) error {
+	q := `
+		UPDATE api_keys SET revoked_at = COALESCE(revoked_at, :now)
+		WHERE id = :id
+		RETURNING ` + apiKeyColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrAPIKeyNotFound
+	}
+
+	_, err = r.getAPIKey(q, &APIKey{ID: validID.String(), Now: now()})
+	return err
+}
+
+func (r *Repository) TouchAPIKey(id string) error {
+	q := `
+		UPDATE api_keys SET last_used_at = :now
+		WHERE id = :id
+		RETURNING ` + apiKeyColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrAPIKeyNotFound
+	}
+
+	_, err = r.getAPIKey(q, &APIKey{ID: validID.String(), Now: now()})
+	return err
+}
+
+// getAPIKey runs q returning one key, no row is cons.ErrAPIKeyNotFound.
+func (r *Repository) getAPIKey(q string, arg *APIKey) (*domain.APIKey, error) {
+	k := APIKey{}
+	found, err := r.get(q, arg, &k)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrAPIKeyNotFound
+	}
+
+	return toDomainAPIKey(k)
+}
+
+func toDomainAPIKey(k APIKey) (*domain.APIKey, error) {
+	scopes := []string{}
+	if err := json.Unmarshal([]byte(k.Scopes), &scopes); err != nil {
+		return nil, err
+	}
+
+	return &domain.APIKey{
+		ID:         k.ID,
+		Name:       k.Name,
+		UserID:     k.UserID,
+		Prefix:     k.Prefix,
+		KeyHash:    k.KeyHash,
+		Scopes:     scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		RevokedAt:  k.RevokedAt,
+		CreatedAt:  k.CreatedAt,
+	}, nil
+}
@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"encoding/json"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+// audit writes the entry of a mutation, it has to run in the transaction of
+// the mutation. An actor without user id is the user itself.
+func (r *Repository) audit(actor domain.Actor, userID string, action string, changes []domain.AuditChange) error {
+	q := `
+		INSERT INTO user_audit_log (id, user_id, actor_id, action, changes, request_id)
+		VALUES (:id, :user_id, COALESCE(:actor_id, :user_id), :action, :changes, :request_id);
+	`
+
+	arg := AuditEntryArg{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Action:    action,
+		RequestID: actor.RequestID,
+	}
+
+	if actor.UserID != "" {
+		actorID, err := uuid.Parse(actor.UserID)
+		if err != nil {
+			return err
+		}
+
+		id := actorID.String()
+		arg.ActorID = &id
+	}
+
+	if changes == nil {
+		changes = []domain.AuditChange{}
+	}
+
+	encoded, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	arg.Changes = string(encoded)
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) ListAuditEntries(filter domain.AuditFilter, limit int, offset int) ([]domain.AuditEntry, int, error) {
+	const where = `
+		WHERE user_id = :user_id
+		AND (:from IS NULL OR created_at >= :from)
+		AND (:to IS NULL OR created_at < :to)
+	`
+
+	q := `
+		SELECT id, user_id, actor_id, action, changes, request_id, created_at
+		FROM user_audit_log
+	` + where + `
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT :limit OFFSET :offset;
+	`
+
+	validID, err := uuid.Parse(filter.UserID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	arg := AuditListArg{
+		UserID: validID.String(),
+		From:   utc(filter.From),
+		To:     utc(filter.To),
+		Limit:  limit,
+		Offset: offset,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := []domain.AuditEntry{}
+	for rows.Next() {
+		e := AuditEntry{}
+		err = rows.StructScan(&e)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		changes := []domain.AuditChange{}
+		err = json.Unmarshal(e.Changes, &changes)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		res = append(res, domain.AuditEntry{
+			ID:        e.ID,
+			UserID:    e.UserID,
+			ActorID:   e.ActorID,
+			Action:    e.Action,
+			Changes:   changes,
+			RequestID: e.RequestID,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	q = `SELECT COUNT(*) FROM user_audit_log ` + where + `;`
+
+	countRows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer countRows.Close()
+
+	total := 0
+	if countRows.Next() {
+		err = countRows.Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return res, total, countRows.Err()
+}
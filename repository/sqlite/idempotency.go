@@ -0,0 +1,128 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+)
+
+var _ port.IdempotencyRepo = (*Repository)(nil)
+
+// ReserveIdempotencyKey inserts rec, or takes over an expired record with
+// the same caller and key. When neither happens the live record is returned.
+func (r *Repository) ReserveIdempotencyKey(rec *domain.IdempotencyRecord) (*domain.IdempotencyRecord, error) {
+	q := `
+		INSERT INTO idempotency_keys (caller, key, fingerprint, expires_at)
+		VALUES (:caller, :key, :fingerprint, :expires_at)
+		ON CONFLICT (caller, key) DO UPDATE SET
+			fingerprint = excluded.fingerprint,
+			status_code = NULL,
+			content_type = '',
+			response_body = NULL,
+			created_at = :now,
+			expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= :now
+		RETURNING key;
+	`
+
+	arg := IdempotencyKey{
+		Caller:      rec.Caller,
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+		ExpiresAt:   rec.ExpiresAt.UTC(),
+		Now:         now(),
+	}
+
+	var res *domain.IdempotencyRecord
+	err := r.withTx(func(tx *Repository) error {
+		reserved, err := tx.get(q, &arg, &IdempotencyKey{})
+		if err != nil || reserved {
+			return err
+		}
+
+		existing := IdempotencyKey{}
+		found, err := tx.get(`
+			SELECT key, fingerprint, COALESCE(status_code, 0) AS status_code,
+				content_type, response_body, expires_at
+			FROM idempotency_keys
+			WHERE caller = :caller AND key = :key;
+		`, &arg, &existing)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return cons.ErrIdempotencyInProgress
+		}
+
+		res = &domain.IdempotencyRecord{
+			Caller:      rec.Caller,
+			Key:         existing.Key,
+			Fingerprint: existing.Fingerprint,
+			StatusCode:  existing.StatusCode,
+			ContentType: existing.ContentType,
+			Body:        existing.ResponseBody,
+			ExpiresAt:   existing.ExpiresAt,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (r *Repository) CompleteIdempotencyKey(rec *domain.IdempotencyRecord) error {
+	q := `
+		UPDATE idempotency_keys
+		SET status_code = :status_code, content_type = :content_type, response_body = :response_body
+		WHERE caller = :caller AND key = :key AND fingerprint = :fingerprint AND status_code IS NULL;
+	`
+
+	arg := IdempotencyKey{
+		Caller:       rec.Caller,
+		Key:          rec.Key,
+		Fingerprint:  rec.Fingerprint,
+		StatusCode:   rec.StatusCode,
+		ContentType:  rec.ContentType,
+		ResponseBody: rec.Body,
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+// DeleteIdempotencyKey drops a reservation that never completed, so the
+// request can be retried with the same key.
+func (r *Repository) DeleteIdempotencyKey(rec *domain.IdempotencyRecord) error {
+	q := `
+		DELETE FROM idempotency_keys
+		WHERE caller = :caller AND key = :key AND fingerprint = :fingerprint AND status_code IS NULL;
+	`
+
+	arg := IdempotencyKey{
+		Caller:      rec.Caller,
+		Key:         rec.Key,
+		Fingerprint: rec.Fingerprint,
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM idempotency_keys
+		WHERE expires_at <= :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &IdempotencyPruneArg{Before: before.UTC()})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
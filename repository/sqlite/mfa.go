@@ -0,0 +1,280 @@
+package sqlite
+
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+func (r *Repository) GetMFA(userID string) (*domain.MFA, error) {
+	q := `
+		SELECT user_id, totp_secret, confirmed_at, created_at, last_totp_step
+		FROM user_mfa
+		WHERE user_id = :user_id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := MFA{}
+	found, err := r.get(q, &MFA{UserID: validID.String()}, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrMFANotEnrolled
+	}
+
+	return &domain.MFA{
+		UserID:      m.UserID,
+		TOTPSecret:  m.TOTPSecret,
+		ConfirmedAt: m.ConfirmedAt,
+		CreatedAt:   m.CreatedAt,
+
+		LastTOTPStep: m.LastTOTPStep,
+	}, nil
+}
+
+// SaveTOTPSecret starts or restarts an enrolment, a confirmed enrolment is
+// never overwritten.
+func (r *Repository) SaveTOTPSecret(userID string, encryptedSecret string) error {
+	q := `
+		INSERT INTO user_mfa (user_id, totp_secret)
+		VALUES (:user_id, :totp_secret)
+		ON CONFLICT (user_id) DO UPDATE
+		SET totp_secret = excluded.totp_secret, created_at = :now
+		WHERE user_mfa.confirmed_at IS NULL
+		RETURNING user_id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+
+	arg := MFA{
+		UserID:     validID.String(),
+		TOTPSecret: encryptedSecret,
+		Now:        now(),
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrMFAAlreadyEnabled
+	}
+
+	return nil
+}
+
+// ConfirmTOTP enables the enrolment and replaces the recovery codes, which
+// are stored hashed like passwords.
+func (r *Repository) ConfirmTOTP(userID string, recoveryCodes []string) error {
+	q := `
+		UPDATE user_mfa SET confirmed_at = :now
+		WHERE user_id = :user_id AND confirmed_at IS NULL
+		RETURNING user_id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return err
+	}
+
+	codes := make([]RecoveryCode, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hash, err := hashSecret(code)
+		if err != nil {
+			return err
+		}
+
+		codes = append(codes, RecoveryCode{
+			ID:       uuid.NewString(),
+			UserID:   validID.String(),
+			CodeHash: hash,
+		})
+	}
+
+	return r.withTx(func(tx *Repository) error {
+		m := MFA{}
+		found, err := tx.get(q, &MFA{UserID: validID.String(), Now: now()}, &m)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return cons.ErrMFANotEnrolled
+		}
+
+		_, err = tx.sawitDB.NamedExec(`
+			DELETE FROM user_recovery_codes
+			WHERE user_id = :user_id;
+		`, &m)
+		if err != nil {
+			return err
+		}
+
+		for i := range codes {
+			_, err = tx.sawitDB.NamedExec(`
+				INSERT INTO user_recovery_codes (id, user_id, code_hash)
+				VALUES (:id, :user_id, :code_hash);
+			`, &codes[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) UseRecoveryCode(userID string, code string) (bool, error) {
+	q := `
+		SELECT id, user_id, code_hash FROM user_recovery_codes
+		WHERE user_id = :user_id
+		AND used_at IS NULL;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, err
+	}
+
+	used := false
+	err = r.withTx(func(tx *Repository) error {
+		rows, err := tx.sawitDB.NamedQuery(q, &RecoveryCode{UserID: validID.String()})
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		var match *RecoveryCode
+		for rows.Next() && match == nil {
+			c := RecoveryCode{}
+			err = rows.StructScan(&c)
+			if err != nil {
+				return err
+			}
+
+			if matchSecret(c.CodeHash, code) {
+				match = &c
+			}
+		}
+
+		if err = rows.Err(); err != nil || match == nil {
+			return err
+		}
+		rows.Close()
+
+		match.Now = now()
+		_, err = tx.sawitDB.NamedExec(`
+			UPDATE user_recovery_codes SET used_at = :now
+			WHERE id = :id;
+		`, match)
+		used = err == nil
+		return err
+	})
+
+	return used, err
+}
+
+func (r *Repository) UseTOTPStep(userID string, step int64) (bool, error) {
+	q := `
+		UPDATE user_mfa SET last_totp_step = :step
+		WHERE user_id = :user_id AND last_totp_step < :step;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &TOTPStepArg{UserID: validID.String(), Step: step})
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *Repository) CountMFAAttempt(challengeID string, userID string, expiresAt time.Time) (int, error) {
+	q := `
+		INSERT INTO mfa_challenges (id, user_id, attempts, expires_at)
+		VALUES (:id, :user_id, 1, :expires_at)
+		ON CONFLICT (id) DO UPDATE
+		SET attempts = mfa_challenges.attempts + 1
+		RETURNING id, user_id, attempts, expires_at;
+	`
+
+	validID, err := uuid.Parse(challengeID)
+	if err != nil {
+		return 0, err
+	}
+
+	validUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	arg := MFAChallenge{
+		ID:        validID.String(),
+		UserID:    validUserID.String(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+
+	c := MFAChallenge{}
+	_, err = r.get(q, &arg, &c)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.Attempts, nil
+}
+
+func (r *Repository) UseMFAChallenge(challengeID string) (bool, error) {
+	q := `
+		UPDATE mfa_challenges SET used_at = :now
+		WHERE id = :id AND used_at IS NULL;
+	`
+
+	validID, err := uuid.Parse(challengeID)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &MFAChallenge{ID: validID.String(), Now: now()})
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := res.RowsAffected()
+	return updated > 0, err
+}
+
+func (r *Repository) DeleteMFAChallengesExpiredBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM mfa_challenges
+		WHERE expires_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &MFAChallengePruneArg{Before: before.UTC()})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
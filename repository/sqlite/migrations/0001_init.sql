@@ -0,0 +1,124 @@
+/**
+  SQLite version of database.sql. There is no pgcrypto, ids are generated and
+  passwords and recovery codes are hashed by the repository. Timestamps are
+  UTC text in the format written by the driver so they compare in order.
+  */
+
+CREATE TABLE users (
+	id TEXT PRIMARY KEY,
+	full_name VARCHAR (60) NOT NULL,
+	phone_number VARCHAR (20) UNIQUE NOT NULL,
+	login_count INT DEFAULT 0,
+	password TEXT NOT NULL,
+	password_changed_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	must_change_password BOOLEAN DEFAULT FALSE,
+	role VARCHAR (20) NOT NULL DEFAULT 'user',
+	is_active BOOLEAN DEFAULT TRUE,
+	version INT NOT NULL DEFAULT 1,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	updated_at TIMESTAMP NULL
+);
+
+CREATE TABLE user_password_history (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	password TEXT NOT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX user_password_history_user_id_created_at_idx ON user_password_history (user_id, created_at DESC);
+
+CREATE TABLE user_mfa (
+	user_id TEXT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+	totp_secret TEXT NOT NULL,
+	confirmed_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE TABLE user_recovery_codes (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	used_at TIMESTAMP NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX user_recovery_codes_user_id_idx ON user_recovery_codes (user_id);
+
+CREATE TABLE user_sessions (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address TEXT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	last_seen_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP NULL
+);
+
+CREATE INDEX user_sessions_user_id_idx ON user_sessions (user_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE login_events (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NULL REFERENCES users (id) ON DELETE CASCADE,
+	phone_number TEXT NOT NULL DEFAULT '',
+	success BOOLEAN NOT NULL,
+	reason VARCHAR (30) NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip_address TEXT NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX login_events_user_id_created_at_idx ON login_events (user_id, created_at DESC);
+CREATE INDEX login_events_created_at_idx ON login_events (created_at);
+
+-- append-only, rows are written in the same transaction as the mutation
+CREATE TABLE user_audit_log (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id),
+	actor_id TEXT NOT NULL REFERENCES users (id),
+	action VARCHAR (40) NOT NULL,
+	changes TEXT NOT NULL DEFAULT '[]',
+	request_id TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'))
+);
+
+CREATE INDEX user_audit_log_user_id_created_at_idx ON user_audit_log (user_id, created_at DESC);
+
+-- responses replayed for retries carrying the same Idempotency-Key,
+-- status_code is NULL while the first request is in progress
+CREATE TABLE idempotency_keys (
+	key VARCHAR (255) PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	status_code INT NULL,
+	content_type TEXT NOT NULL DEFAULT '',
+	response_body BLOB NULL,
+	created_at TIMESTAMP DEFAULT (strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')),
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+
+-- update updated_at, recursive triggers are off so the inner update does not
+-- fire it again
+CREATE TRIGGER users_updated_at AFTER UPDATE ON users FOR EACH ROW
+BEGIN
+	UPDATE users SET updated_at = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+	WHERE id = NEW.id;
+END;
+
+-- keep audit log append-only
+CREATE TRIGGER user_audit_log_no_update BEFORE UPDATE ON user_audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'user_audit_log is append-only');
+END;
+
+CREATE TRIGGER user_audit_log_no_delete BEFORE DELETE ON user_audit_log
+BEGIN
+	SELECT RAISE(ABORT, 'user_audit_log is append-only');
+END;
+
+-- bcrypt hashes of test1, test2 and Adm1n!
+INSERT INTO users (id, full_name, phone_number, password) VALUES ('4e0b6f2c-8a51-4d3e-9c7a-1f2d3b4c5a61', 'john doe', '+62812345677', '$2a$06$WwAA.IS0.CerG397xkoZeOKxVJI4KSg5Xo2.sos6Y75WQapUdUcHK');
+INSERT INTO users (id, full_name, phone_number, password) VALUES ('9a7c3e15-2b64-4f08-8d91-6e5f4a3b2c17', 'mamang doe', '+62812345678', '$2a$06$JgZf2uyz.3KEHTNimhvScuYjzN/nAs6WWNpE.PpriCunCfUsmnKea');
+INSERT INTO users (id, full_name, phone_number, password, role) VALUES ('c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73', 'admin doe', '+62812345679', '$2a$06$66o1bDkuplYHqIH3..lBveH2ttRBf48Uj2366eqpODvAOX.J.tsLq', 'admin');
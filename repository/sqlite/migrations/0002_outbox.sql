@@ -0,0 +1,17 @@
+-- outbox holds domain events until the relay publishes them, seq keeps them
+-- in the order they were stored
+CREATE TABLE outbox (
+	seq INTEGER PRIMARY KEY AUTOINCREMENT,
+	id TEXT NOT NULL UNIQUE,
+	event_type VARCHAR (64) NOT NULL,
+	aggregate_id TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	occurred_at TIMESTAMP NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	locked_until TIMESTAMP NULL,
+	published_at TIMESTAMP NULL
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (seq) WHERE published_at IS NULL;
+CREATE INDEX outbox_published_at_idx ON outbox (published_at) WHERE published_at IS NOT NULL;
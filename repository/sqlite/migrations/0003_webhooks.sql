@@ -0,0 +1,32 @@
+-- webhooks are partner endpoints subscribed to domain events, event_types is
+-- a JSON array and the secret is encrypted by the service
+CREATE TABLE webhooks (
+	id TEXT PRIMARY KEY,
+	url TEXT NOT NULL,
+	event_types TEXT NOT NULL,
+	secret TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+);
+
+-- webhook_deliveries queue the events sent to each webhook and keep the
+-- outcome as the delivery log, an event is queued once per webhook
+CREATE TABLE webhook_deliveries (
+	id TEXT PRIMARY KEY,
+	webhook_id TEXT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+	event_id TEXT NOT NULL,
+	event_type VARCHAR (64) NOT NULL,
+	payload TEXT NOT NULL,
+	status VARCHAR (16) NOT NULL DEFAULT 'pending',
+	attempts INT NOT NULL DEFAULT 0,
+	last_status_code INT NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	next_attempt_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP NULL,
+	created_at TIMESTAMP NOT NULL,
+	delivered_at TIMESTAMP NULL,
+	UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at);
@@ -0,0 +1,30 @@
+-- oauth_clients are the applications signing users in with the authorization
+-- code flow, redirect_uris is a JSON array and the secret of confidential
+-- clients is hashed
+CREATE TABLE oauth_clients (
+	id TEXT PRIMARY KEY,
+	name VARCHAR (60) NOT NULL,
+	redirect_uris TEXT NOT NULL,
+	confidential BOOLEAN NOT NULL DEFAULT FALSE,
+	secret TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+-- oauth_codes are the issued authorization codes, only their hash is stored
+-- and used_at makes sure each is exchanged once
+CREATE TABLE oauth_codes (
+	code_hash TEXT PRIMARY KEY,
+	client_id TEXT NOT NULL REFERENCES oauth_clients (id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	session_id TEXT NOT NULL REFERENCES user_sessions (id) ON DELETE CASCADE,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL DEFAULT '',
+	nonce TEXT NOT NULL DEFAULT '',
+	code_challenge TEXT NOT NULL,
+	auth_time TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL,
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX oauth_codes_expires_at_idx ON oauth_codes (expires_at);
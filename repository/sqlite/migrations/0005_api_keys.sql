@@ -0,0 +1,14 @@
+-- api_keys authorize batch jobs on behalf of their owner, scopes is a JSON
+-- array, only the hash of a key is stored and the prefix identifies it
+CREATE TABLE api_keys (
+	id TEXT PRIMARY KEY,
+	name VARCHAR (60) NOT NULL,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	prefix TEXT UNIQUE NOT NULL,
+	key_hash TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	expires_at TIMESTAMP NULL,
+	last_used_at TIMESTAMP NULL,
+	revoked_at TIMESTAMP NULL,
+	created_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,15 @@
+-- last_totp_step is the time step of the last accepted TOTP code, codes of
+-- this step or an earlier one are replays
+ALTER TABLE user_mfa ADD COLUMN last_totp_step INTEGER NOT NULL DEFAULT 0;
+
+-- mfa_challenges count the codes submitted with an MFA challenge token, id
+-- is the jti of the token and used_at makes sure it is exchanged once
+CREATE TABLE mfa_challenges (
+	id TEXT PRIMARY KEY,
+	user_id TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP NULL
+);
+
+CREATE INDEX mfa_challenges_expires_at_idx ON mfa_challenges (expires_at);
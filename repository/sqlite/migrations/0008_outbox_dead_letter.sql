@@ -0,0 +1,7 @@
+-- a failed event is retried with backoff and dead-lettered after
+-- outbox.maxAttempts, it is never published then
+ALTER TABLE outbox ADD COLUMN dead_lettered_at TIMESTAMP NULL;
+
+DROP INDEX outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (seq) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
+CREATE INDEX outbox_pending_aggregate_idx ON outbox (aggregate_id, seq) WHERE published_at IS NULL AND dead_lettered_at IS NULL;
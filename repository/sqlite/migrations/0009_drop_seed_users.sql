@@ -0,0 +1,21 @@
+-- 0001_init.sql seeded users with published passwords, an admin among them.
+-- The ones still signing in with them are removed, or deactivated when the
+-- append-only audit log references them.
+CREATE TEMP TABLE seed_users (id TEXT PRIMARY KEY, password TEXT NOT NULL);
+
+INSERT INTO seed_users (id, password) VALUES
+	('4e0b6f2c-8a51-4d3e-9c7a-1f2d3b4c5a61', '$2a$06$WwAA.IS0.CerG397xkoZeOKxVJI4KSg5Xo2.sos6Y75WQapUdUcHK'),
+	('9a7c3e15-2b64-4f08-8d91-6e5f4a3b2c17', '$2a$06$JgZf2uyz.3KEHTNimhvScuYjzN/nAs6WWNpE.PpriCunCfUsmnKea'),
+	('c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73', '$2a$06$66o1bDkuplYHqIH3..lBveH2ttRBf48Uj2366eqpODvAOX.J.tsLq');
+
+DELETE FROM users
+WHERE id IN (SELECT id FROM seed_users WHERE seed_users.password = users.password)
+AND NOT EXISTS (
+	SELECT 1 FROM user_audit_log
+	WHERE user_audit_log.user_id = users.id OR user_audit_log.actor_id = users.id
+);
+
+UPDATE users SET is_active = FALSE, role = 'user'
+WHERE id IN (SELECT id FROM seed_users WHERE seed_users.password = users.password);
+
+DROP TABLE seed_users;
@@ -0,0 +1,213 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.OAuthRepo = (*Repository)(nil)
+
+const oauthClientColumns = `id, name, redirect_uris, confidential, secret, created_at`
+
+const oauthCodeColumns = `code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce,
+	code_challenge, auth_time, expires_at, created_at`
+
+func (r *Repository) CreateOAuthClient(data *domain.OAuthClient) (*domain.OAuthClient, error) {
+	q := `
+		INSERT INTO oauth_clients (id, name, redirect_uris, confidential, secret, created_at)
+		VALUES (:id, :name, :redirect_uris, :confidential, :secret, :now)
+		RETURNING ` + oauthClientColumns + `;
+	`
+
+	redirectURIs, err := json.Marshal(data.RedirectURIs)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := OAuthClient{
+		ID:           uuid.NewString(),
+		Name:         data.Name,
+		RedirectURIs: string(redirectURIs),
+		Confidential: data.Confidential,
+		Secret:       data.Secret,
+		Now:          now(),
+	}
+
+	return r.getOAuthClient(q, &arg)
+}
+
+func (r *Repository) GetOAuthClient(id string) (*domain.OAuthClient, error) {
+	q := `
+		SELECT ` + oauthClientColumns + `
+		FROM oauth_clients
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, cons.ErrOAuthClientNotFound
+	}
+
+	return r.getOAuthClient(q, &OAuthClient{ID: validID.String()})
+}
+
+func (r *Repository) ListOAuthClients() ([]domain.OAuthClient, error) {
+	q := `
+		SELECT ` + oauthClientColumns + `
+		FROM oauth_clients
+		ORDER BY created_at, rowid;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &OAuthClient{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.OAuthClient{}
+	for rows.Next() {
+		c := OAuthClient{}
+		err = rows.StructScan(&c)
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := toDomainOAuthClient(c)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *client)
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) DeleteOAuthClient(id string) error {
+	q := `
+		DELETE FROM oauth_clients
+		WHERE id = :id
+		RETURNING ` + oauthClientColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrOAuthClientNotFound
+	}
+
+	_, err = r.getOAuthClient(q, &OAuthClient{ID: validID.String()})
+	return err
+}
+
+// getOAuthClient runs q returning one client, no row is
+// cons.ErrOAuthClientNotFound.
+func (r *Repository) getOAuthClient(q string, arg *OAuthClient) (*domain.OAuthClient, error) {
+	c := OAuthClient{}
+	found, err := r.get(q, arg, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrOAuthClientNotFound
+	}
+
+	return toDomainOAuthClient(c)
+}
+
+func toDomainOAuthClient(c OAuthClient) (*domain.OAuthClient, error) {
+	redirectURIs := []string{}
+	if err := json.Unmarshal([]byte(c.RedirectURIs), &redirectURIs); err != nil {
+		return nil, err
+	}
+
+	return &domain.OAuthClient{
+		ID:           c.ID,
+		Name:         c.Name,
+		RedirectURIs: redirectURIs,
+		Confidential: c.Confidential,
+		Secret:       c.Secret,
+		CreatedAt:    c.CreatedAt,
+	}, nil
+}
+
+func (r *Repository) CreateOAuthCode(data *domain.OAuthCode) error {
+	q := `
+		INSERT INTO oauth_codes (code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce,
+			code_challenge, auth_time, expires_at, created_at)
+		VALUES (:code_hash, :client_id, :user_id, :session_id, :redirect_uri, :scope, :nonce,
+			:code_challenge, :auth_time, :expires_at, :now);
+	`
+
+	arg := OAuthCode{
+		CodeHash:      data.CodeHash,
+		ClientID:      data.ClientID,
+		UserID:        data.UserID,
+		SessionID:     data.SessionID,
+		RedirectURI:   data.RedirectURI,
+		Scope:         data.Scope,
+		Nonce:         data.Nonce,
+		CodeChallenge: data.CodeChallenge,
+		AuthTime:      data.AuthTime.UTC(),
+		ExpiresAt:     data.ExpiresAt.UTC(),
+		Now:           now(),
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+// ConsumeOAuthCode marks the code as used in the same statement that reads
+// it, so concurrent exchanges of one code can not both succeed.
+func (r *Repository) ConsumeOAuthCode(codeHash string) (*domain.OAuthCode, error) {
+	q := `
+		UPDATE oauth_codes SET used_at = :now
+		WHERE code_hash = :code_hash
+		AND used_at IS NULL
+		AND expires_at > :now
+		RETURNING ` + oauthCodeColumns + `;
+	`
+
+	c := OAuthCode{}
+	found, err := r.get(q, &OAuthCode{CodeHash: codeHash, Now: now()}, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrInvalidGrant
+	}
+
+	return &domain.OAuthCode{
+		CodeHash:      c.CodeHash,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		SessionID:     c.SessionID,
+		RedirectURI:   c.RedirectURI,
+		Scope:         c.Scope,
+		Nonce:         c.Nonce,
+		CodeChallenge: c.CodeChallenge,
+		AuthTime:      c.AuthTime,
+		ExpiresAt:     c.ExpiresAt,
+		CreatedAt:     c.CreatedAt,
+	}, nil
+}
+
+func (r *Repository) DeleteOAuthCodesExpiredBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM oauth_codes
+		WHERE expires_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &OAuthCodePruneArg{Before: before.UTC()})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
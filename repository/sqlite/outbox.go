@@ -0,0 +1,168 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.OutboxRepo = (*Repository)(nil)
+
+func (r *Repository) CreateOutboxEvent(event domain.EventPayload) error {
+	q := `
+		INSERT INTO outbox (id, event_type, aggregate_id, payload, occurred_at)
+		VALUES (:id, :event_type, :aggregate_id, :payload, :now);
+	`
+
+	aggregateID, err := uuid.Parse(event.AggregateID())
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	arg := OutboxEvent{
+		ID:          uuid.NewString(),
+		EventType:   event.EventType(),
+		AggregateID: aggregateID.String(),
+		Payload:     string(payload),
+		Now:         now(),
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+// ClaimOutboxEvents leases the events in one statement, writes are
+// serialised so concurrent relays never claim the same event.
+func (r *Repository) ClaimOutboxEvents(limit int, lease time.Duration) ([]domain.Event, error) {
+	q := `
+		UPDATE outbox SET locked_until = :locked_until
+		WHERE seq IN (
+			SELECT seq FROM outbox
+			WHERE published_at IS NULL AND dead_lettered_at IS NULL
+			AND (locked_until IS NULL OR locked_until <= :now)
+			AND NOT EXISTS (
+				SELECT 1 FROM outbox earlier
+				WHERE earlier.aggregate_id = outbox.aggregate_id
+				AND earlier.seq < outbox.seq
+				AND earlier.published_at IS NULL AND earlier.dead_lettered_at IS NULL
+				AND earlier.locked_until > :now
+			)
+			ORDER BY seq
+			LIMIT :limit
+		)
+		RETURNING seq, id, event_type, aggregate_id, payload, occurred_at, attempts, last_error;
+	`
+
+	t := now()
+	arg := OutboxClaimArg{
+		Limit:       limit,
+		LockedUntil: t.Add(lease),
+		Now:         t,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	claimed := []OutboxEvent{}
+	for rows.Next() {
+		e := OutboxEvent{}
+		err = rows.StructScan(&e)
+		if err != nil {
+			return nil, err
+		}
+
+		claimed = append(claimed, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].Seq < claimed[j].Seq })
+
+	res := make([]domain.Event, 0, len(claimed))
+	for _, e := range claimed {
+		res = append(res, domain.Event{
+			ID:          e.ID,
+			Type:        e.EventType,
+			AggregateID: e.AggregateID,
+			Payload:     json.RawMessage(e.Payload),
+			OccurredAt:  e.OccurredAt,
+			Attempts:    e.Attempts,
+		})
+	}
+
+	return res, nil
+}
+
+func (r *Repository) MarkOutboxEventPublished(id string) error {
+	q := `
+		UPDATE outbox SET published_at = :now, locked_until = NULL
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &OutboxEvent{ID: validID.String(), Now: now()})
+	return err
+}
+
+// RecordOutboxEventFailure keeps the event leased until its retry, a
+// dead-lettered event is released as it is never claimed again.
+func (r *Repository) RecordOutboxEventFailure(failure domain.OutboxFailure) error {
+	q := `
+		UPDATE outbox SET attempts = attempts + 1, last_error = :last_error,
+			locked_until = :locked_until, dead_lettered_at = :dead_lettered_at
+		WHERE id = :id AND published_at IS NULL;
+	`
+
+	validID, err := uuid.Parse(failure.EventID)
+	if err != nil {
+		return err
+	}
+
+	arg := OutboxFailureArg{
+		ID:        validID.String(),
+		LastError: failure.Error,
+	}
+	if failure.DeadLetter {
+		t := now()
+		arg.DeadLetteredAt = &t
+	} else {
+		retryAt := failure.RetryAt.UTC()
+		arg.LockedUntil = &retryAt
+	}
+
+	_, err = r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) DeleteOutboxEventsPublishedBefore(before time.Time) (int64, error) {
+	q := `
+		DELETE FROM outbox
+		WHERE published_at < :before;
+	`
+
+	res, err := r.sawitDB.NamedExec(q, &OutboxPruneArg{Before: before.UTC()})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,207 @@
+package sqlite
+
+import (
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/google/uuid"
+)
+
+func (r *Repository) CreateSession(data *domain.Session) (*domain.Session, error) {
+	q := `
+		INSERT INTO user_sessions (id, user_id, user_agent, ip_address, expires_at)
+		VALUES (:id, :user_id, :user_agent, NULLIF(:ip_address, ''), :expires_at)
+		RETURNING id, user_id, user_agent, COALESCE(ip_address, '') AS ip_address,
+			created_at, last_seen_at, expires_at;
+	`
+
+	validID, err := uuid.Parse(data.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ip, err := normalizeIP(data.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := Session{
+		ID:        uuid.NewString(),
+		UserID:    validID.String(),
+		UserAgent: data.UserAgent,
+		IPAddress: ip,
+		ExpiresAt: utc(data.ExpiresAt),
+	}
+
+	s := Session{}
+	found, err := r.get(q, &arg, &s)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrUserNotFound
+	}
+
+	return toDomainSession(s), nil
+}
+
+// TouchSession bumps last_seen_at of an active session and fails with
+// cons.ErrSessionRevoked when it is revoked or expired.
+func (r *Repository) TouchSession(userID string, sessionID string) error {
+	q := `
+		UPDATE user_sessions SET last_seen_at = :now
+		WHERE id = :id
+		AND user_id = :user_id
+		AND revoked_at IS NULL
+		AND expires_at > :now
+		RETURNING id;
+	`
+
+	arg, err := sessionArg(userID, sessionID)
+	if err != nil {
+		return cons.ErrSessionRevoked
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func (r *Repository) ListSessions(userID string) ([]domain.Session, error) {
+	q := `
+		SELECT id, user_id, user_agent, COALESCE(ip_address, '') AS ip_address,
+			created_at, last_seen_at, expires_at
+		FROM user_sessions
+		WHERE user_id = :user_id
+		AND revoked_at IS NULL
+		AND expires_at > :now
+		ORDER BY last_seen_at DESC, rowid DESC;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &Session{UserID: validID.String(), Now: now()})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.Session{}
+	for rows.Next() {
+		s := Session{}
+		err = rows.StructScan(&s)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *toDomainSession(s))
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) RevokeSession(userID string, sessionID string) error {
+	q := `
+		UPDATE user_sessions SET revoked_at = :now
+		WHERE id = :id
+		AND user_id = :user_id
+		AND revoked_at IS NULL
+		RETURNING id;
+	`
+
+	arg, err := sessionArg(userID, sessionID)
+	if err != nil {
+		return cons.ErrSessionNotFound
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		return cons.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func sessionArg(userID string, sessionID string) (Session, error) {
+	validUserID, err := uuid.Parse(userID)
+	if err != nil {
+		return Session{}, err
+	}
+
+	validID, err := uuid.Parse(sessionID)
+	if err != nil {
+		return Session{}, err
+	}
+
+	return Session{ID: validID.String(), UserID: validUserID.String(), Now: now()}, nil
+}
+
+func toDomainSession(s Session) *domain.Session {
+	return &domain.Session{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		UserAgent:  s.UserAgent,
+		IPAddress:  s.IPAddress,
+		CreatedAt:  s.CreatedAt,
+		LastSeenAt: s.LastSeenAt,
+		ExpiresAt:  s.ExpiresAt,
+	}
+}
+
+// RevokeUserSessions revokes every active session of the user except
+// exceptSessionID, which may be empty to revoke all of them.
+func (r *Repository) RevokeUserSessions(userID string, exceptSessionID string) (int64, error) {
+	q := `
+		UPDATE user_sessions SET revoked_at = :now
+		WHERE user_id = :user_id
+		AND revoked_at IS NULL
+		AND id IS NOT :id;
+	`
+
+	validID, err := uuid.Parse(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	arg := SessionRevokeArg{UserID: validID.String(), Now: now()}
+	if exceptSessionID != "" {
+		exceptID, err := uuid.Parse(exceptSessionID)
+		if err != nil {
+			return 0, err
+		}
+
+		id := exceptID.String()
+		arg.ExceptID = &id
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &arg)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,644 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/bcrypt"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var _ port.UserRepo = (*Repository)(nil)
+
+// hashCost matches gen_salt('bf', 10) of the postgres repository.
+const hashCost = bcrypt.DefaultCost
+
+func init() {
+	sqlx.BindDriver("sqlite", sqlx.QUESTION)
+}
+
+// Repository stores everything in a single SQLite file. SQLite has no
+// pgcrypto and no data-modifying CTEs, so secrets are hashed here and
+// mutations that span several statements run in a transaction.
+type Repository struct {
+	db *sqlx.DB
+	// sawitDB runs the queries, it is the transaction inside WithTx
+	sawitDB queryer
+	inTx    bool
+}
+
+type NewRepoOptions struct {
+	Path        string
+	MaxOpenConn int
+}
+
+// New opens the database file at opts.Path, creating it when it does not
+// exist, and applies the pending migrations.
+func New(ctx context.Context, opts NewRepoOptions) (*Repository, error) {
+	// WAL lets readers run next to the single writer, immediate transactions
+	// take the write lock up front so they wait on busy_timeout instead of
+	// failing when they upgrade from a read
+	dsn := fmt.Sprintf(
+		"file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite&_txlock=immediate",
+		opts.Path,
+	)
+
+	if err := os.MkdirAll(filepath.Dir(opts.Path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sqlx.ConnectContext(ctx, "sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConn)
+
+	if err = migrate(ctx, db); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Repository{
+		db:      db,
+		sawitDB: db,
+	}, nil
+}
+
+func (r *Repository) CreateUser(actor domain.Actor, data *domain.User) (*domain.User, error) {
+	q := `
+		INSERT INTO users (id, full_name, phone_number, password)
+		VALUES (:id, :full_name, :phone_number, :password)
+		RETURNING id, full_name, phone_number, version;
+	`
+
+	hash, err := hashSecret(data.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := UserCreateArg{
+		ID:          uuid.NewString(),
+		FullName:    data.FullName,
+		PhoneNumber: data.PhoneNumber,
+		Password:    hash,
+	}
+
+	u := User{}
+	err = r.withTx(func(tx *Repository) error {
+		if _, err := tx.get(q, &arg, &u); err != nil {
+			return err
+		}
+
+		return tx.audit(actor, u.ID, cons.AuditActionCreateUser, []domain.AuditChange{
+			{Field: "full_name", New: &u.FullName},
+			{Field: "phone_number", New: &u.PhoneNumber},
+		})
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, cons.ErrDataConflict
+		}
+
+		return nil, err
+	}
+
+	return &domain.User{
+		ID:          u.ID,
+		FullName:    u.FullName,
+		PhoneNumber: u.PhoneNumber,
+		Version:     u.Version,
+	}, nil
+}
+
+func (r *Repository) GetUserIDByPhone(phone string) (string, error) {
+	q := `
+		SELECT id FROM users
+		WHERE phone_number = :phone_number;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &User{PhoneNumber: phone})
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return "", err
+		}
+
+		return "", cons.ErrUserNotFound
+	}
+
+	u := User{}
+	if err = rows.StructScan(&u); err != nil {
+		return "", err
+	}
+
+	return u.ID, nil
+}
+
+func (r *Repository) GetUserByID(id string) (*domain.User, error) {
+	q := `
+		SELECT id, full_name, phone_number, role, version, created_at, updated_at
+		FROM users
+		WHERE id = :id AND is_active = TRUE;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &User{ID: validID.String()})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, cons.ErrUserNotFound
+	}
+
+	u := User{}
+	err = rows.StructScan(&u)
+	if err != nil {
+		return nil, err
+	}
+
+	return &domain.User{
+		ID:          u.ID,
+		FullName:    u.FullName,
+		PhoneNumber: u.PhoneNumber,
+		Role:        u.Role,
+		Version:     u.Version,
+	}, nil
+}
+
+// PatchUserByID updates the non empty fields, a non zero data.Version must
+// match the current version of the user.
+func (r *Repository) PatchUserByID(actor domain.Actor, id string, data *domain.User) (*domain.User, error) {
+	q := `
+		SELECT id, full_name, phone_number, version FROM users
+		WHERE id = :id
+		AND is_active = TRUE;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	params := []string{"version = version + 1"}
+	if data.FullName != "" {
+		params = append(params, "full_name = :full_name")
+	}
+
+	if data.PhoneNumber != "" {
+		params = append(params, "phone_number = :phone_number")
+	}
+
+	update := fmt.Sprintf(`
+		UPDATE users SET %s
+		WHERE id = :id
+		RETURNING id, full_name, phone_number, version;
+	`, strings.Join(params, ","))
+
+	arg := UserPatchArg{
+		ID:          validID.String(),
+		FullName:    data.FullName,
+		PhoneNumber: data.PhoneNumber,
+		Version:     data.Version,
+	}
+
+	u := User{}
+	err = r.withTx(func(tx *Repository) error {
+		old := User{}
+		found, err := tx.get(q, &arg, &old)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return cons.ErrUserNotFound
+		}
+
+		// a non zero data.Version is the version the caller last read
+		if data.Version != 0 && old.Version != data.Version {
+			return cons.ErrVersionMismatch
+		}
+
+		if _, err = tx.get(update, &arg, &u); err != nil {
+			return err
+		}
+
+		changes := []domain.AuditChange{}
+		if old.FullName != u.FullName {
+			changes = append(changes, domain.AuditChange{Field: "full_name", Old: &old.FullName, New: &u.FullName})
+		}
+		if old.PhoneNumber != u.PhoneNumber {
+			changes = append(changes, domain.AuditChange{Field: "phone_number", Old: &old.PhoneNumber, New: &u.PhoneNumber})
+		}
+
+		return tx.audit(actor, u.ID, cons.AuditActionUpdateProfile, changes)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, cons.ErrDataConflict
+		}
+
+		return nil, err
+	}
+
+	return &domain.User{
+		ID:          u.ID,
+		FullName:    u.FullName,
+		PhoneNumber: u.PhoneNumber,
+		Version:     u.Version,
+	}, nil
+}
+
+func (r *Repository) Login(phone string, pass string) (*domain.User, error) {
+	q := `
+		SELECT id, full_name, phone_number, password, login_count, role,
+			must_change_password, password_changed_at,
+			EXISTS (
+				SELECT 1 FROM user_mfa
+				WHERE user_mfa.user_id = users.id AND confirmed_at IS NOT NULL
+			) AS mfa_enabled
+		FROM users
+		WHERE phone_number = :phone_number
+		AND is_active = TRUE;
+	`
+
+	u := User{}
+	found, err := r.get(q, &User{PhoneNumber: phone}, &u)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found || !matchSecret(u.Password, pass) {
+		return nil, cons.ErrLoginNotMatch
+	}
+
+	return &domain.User{
+		ID:                 u.ID,
+		FullName:           u.FullName,
+		PhoneNumber:        u.PhoneNumber,
+		LoginCount:         u.LoginCount,
+		Role:               u.Role,
+		MustChangePassword: u.MustChangePassword,
+		PasswordChangedAt:  u.PasswordChangedAt,
+		MFAEnabled:         u.MFAEnabled,
+	}, nil
+}
+
+func (r *Repository) IncrementLoginCount(id string) error {
+	q := `
+		UPDATE users SET login_count = login_count + 1
+		WHERE id = :id AND is_active = TRUE
+		RETURNING id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	found, err := r.get(q, &User{ID: validID.String()}, &User{})
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return cons.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// UpdatePassword moves the replaced hash into the password history.
+func (r *Repository) UpdatePassword(actor domain.Actor, id string, oldPassword string, newPassword string) error {
+	q := `
+		SELECT id, password FROM users
+		WHERE id = :id AND is_active = TRUE;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashSecret(newPassword)
+	if err != nil {
+		return err
+	}
+
+	return r.withTx(func(tx *Repository) error {
+		u := User{}
+		found, err := tx.get(q, &User{ID: validID.String()}, &u)
+		if err != nil {
+			return err
+		}
+
+		if !found || !matchSecret(u.Password, oldPassword) {
+			return cons.ErrPasswordNotMatch
+		}
+
+		_, err = tx.sawitDB.NamedExec(`
+			INSERT INTO user_password_history (id, user_id, password)
+			VALUES (:id, :user_id, :password);
+		`, &PasswordHistoryArg{
+			ID:       uuid.NewString(),
+			UserID:   u.ID,
+			Password: u.Password,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = tx.audit(actor, u.ID, cons.AuditActionChangePassword, []domain.AuditChange{
+			{Field: "password"},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.sawitDB.NamedExec(`
+			UPDATE users SET password = :password,
+				password_changed_at = :now,
+				must_change_password = FALSE
+			WHERE id = :id;
+		`, &UserPasswordArg{
+			ID:       u.ID,
+			Password: hash,
+			Now:      now(),
+		})
+		return err
+	})
+}
+
+func (r *Repository) CheckPassword(id string, password string) error {
+	q := `
+		SELECT id, password FROM users
+		WHERE id = :id AND is_active = TRUE;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	u := User{}
+	found, err := r.get(q, &User{ID: validID.String()}, &u)
+	if err != nil {
+		return err
+	}
+
+	if !found || !matchSecret(u.Password, password) {
+		return cons.ErrPasswordNotMatch
+	}
+
+	return nil
+}
+
+func (r *Repository) IsPasswordReused(id string, password string, depth int) (bool, error) {
+	q := `
+		SELECT password FROM users
+		WHERE id = :user_id
+		UNION ALL
+		SELECT password FROM (
+			SELECT password FROM user_password_history
+			WHERE user_id = :user_id
+			ORDER BY created_at DESC, rowid DESC
+			LIMIT :depth
+		);
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return false, err
+	}
+
+	if depth < 1 {
+		return false, nil
+	}
+
+	// the current password takes the first place of the window
+	arg := PasswordHistoryArg{
+		UserID: validID.String(),
+		Depth:  depth - 1,
+	}
+
+	rows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			return false, err
+		}
+
+		if matchSecret(hash, password) {
+			return true, nil
+		}
+	}
+
+	return false, rows.Err()
+}
+
+func (r *Repository) PrunePasswordHistory(id string, depth int) (int64, error) {
+	q := `
+		DELETE FROM user_password_history
+		WHERE user_id = :user_id
+		AND id NOT IN (
+			SELECT id FROM user_password_history
+			WHERE user_id = :user_id
+			ORDER BY created_at DESC, rowid DESC
+			LIMIT :depth
+		);
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return 0, err
+	}
+
+	res, err := r.sawitDB.NamedExec(q, &PasswordHistoryArg{UserID: validID.String(), Depth: depth})
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *Repository) SetMustChangePassword(actor domain.Actor, id string, mustChange bool) error {
+	q := `
+		SELECT id, must_change_password FROM users
+		WHERE id = :id AND is_active = TRUE;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	return r.withTx(func(tx *Repository) error {
+		u := User{}
+		found, err := tx.get(q, &User{ID: validID.String()}, &u)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return cons.ErrUserNotFound
+		}
+
+		old := strconv.FormatBool(u.MustChangePassword)
+		updated := strconv.FormatBool(mustChange)
+		err = tx.audit(actor, u.ID, cons.AuditActionForcePasswordChange, []domain.AuditChange{
+			{Field: "must_change_password", Old: &old, New: &updated},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.sawitDB.NamedExec(`
+			UPDATE users SET must_change_password = :must_change_password
+			WHERE id = :id;
+		`, &UserMustChangeArg{
+			ID:         u.ID,
+			MustChange: mustChange,
+		})
+		return err
+	})
+}
+
+// get scans the first row of q into dest and reports whether there was one.
+func (r *Repository) get(q string, arg interface{}, dest interface{}) (bool, error) {
+	rows, err := r.sawitDB.NamedQuery(q, arg)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return false, rows.Err()
+	}
+
+	if err = rows.StructScan(dest); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlitedriver.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func (r *Repository) SetUserRole(actor domain.Actor, id string, role string) error {
+	q := `
+		SELECT id, role FROM users
+		WHERE id = :id AND is_active = TRUE;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return err
+	}
+
+	return r.withTx(func(tx *Repository) error {
+		u := User{}
+		found, err := tx.get(q, &User{ID: validID.String()}, &u)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			return cons.ErrUserNotFound
+		}
+
+		err = tx.audit(actor, u.ID, cons.AuditActionSetRole, []domain.AuditChange{
+			{Field: "role", Old: &u.Role, New: &role},
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.sawitDB.NamedExec(`
+			UPDATE users SET role = :role
+			WHERE id = :id;
+		`, &User{ID: u.ID, Role: role})
+		return err
+	})
+}
+
+func hashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), hashCost)
+	if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+		return "", cons.ErrInvalidPasswordLength
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+func matchSecret(hash string, secret string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret)) == nil
+}
+
+// now is the current time in UTC, timestamps are stored as text and only
+// compare in order when they share a zone.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+// utc converts an optional time for comparing it against stored timestamps.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	u := t.UTC()
+	return &u
+}
+
+// normalizeIP stores addresses the way the INET columns of postgres do, an
+// empty address stays empty.
+func normalizeIP(addr string) (string, error) {
+	if addr == "" {
+		return "", nil
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return "", fmt.Errorf("invalid ip address %q", addr)
+	}
+
+	return ip.String(), nil
+}
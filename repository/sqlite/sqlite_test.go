@@ -0,0 +1,74 @@
+package sqlite_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/SawitProRecruitment/UserService/repository/repotest"
+	"github.com/SawitProRecruitment/UserService/repository/sqlite"
+	. "github.com/onsi/gomega"
+)
+
+func TestRepository_Conformance(t *testing.T) {
+	repo, err := sqlite.New(context.Background(), sqlite.NewRepoOptions{
+		Path: filepath.Join(t.TempDir(), "service.db"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.RunUserRepo(t, func(t *testing.T) port.UserRepo {
+		return repo
+	})
+}
+
+func TestNew_NoSeedUsers(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	repo, err := sqlite.New(context.Background(), sqlite.NewRepoOptions{
+		Path: filepath.Join(t.TempDir(), "service.db"),
+	})
+	Expect(err).To(BeNil())
+
+	seeded := []string{
+		"4e0b6f2c-8a51-4d3e-9c7a-1f2d3b4c5a61",
+		"9a7c3e15-2b64-4f08-8d91-6e5f4a3b2c17",
+		"c3d2e1f0-7b6a-4958-a4b3-2c1d0e9f8a73",
+	}
+	for _, id := range seeded {
+		_, err = repo.GetUserByID(id)
+		Expect(err).To(MatchError(cons.ErrUserNotFound))
+	}
+
+	_, err = repo.Login("+62812345679", "Adm1n!")
+	Expect(err).To(HaveOccurred())
+}
+
+func TestNew_Reopen(t *testing.T) {
+	Default = NewGomegaWithT(t)
+
+	path := filepath.Join(t.TempDir(), "service.db")
+	for i := 0; i < 2; i++ {
+		repo, err := sqlite.New(context.Background(), sqlite.NewRepoOptions{Path: path})
+		Expect(err).To(BeNil())
+
+		if i == 0 {
+			_, err = repo.CreateUser(domain.Actor{}, &domain.User{
+				FullName:    "john doe",
+				PhoneNumber: "+62812345677",
+				Password:    "test1",
+			})
+			Expect(err).To(BeNil())
+		}
+
+		user, err := repo.Login("+62812345677", "test1")
+		Expect(err).To(BeNil())
+		Expect(user.Role).To(Equal(cons.RoleUser))
+		Expect(user.LoginCount).To(Equal(i))
+		Expect(repo.IncrementLoginCount(user.ID)).To(Succeed())
+	}
+}
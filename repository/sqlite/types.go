@@ -0,0 +1,277 @@
+package sqlite
+
+import (
+	"time"
+)
+
+type User struct {
+	ID                 string     `db:"id"`
+	FullName           string     `db:"full_name"`
+	PhoneNumber        string     `db:"phone_number"`
+	Password           string     `db:"password"`
+	LoginCount         int        `db:"login_count"`
+	Role               string     `db:"role"`
+	MustChangePassword bool       `db:"must_change_password"`
+	PasswordChangedAt  *time.Time `db:"password_changed_at"`
+	MFAEnabled         bool       `db:"mfa_enabled"`
+	Version            int        `db:"version"`
+	CreatedAt          *time.Time `db:"created_at"`
+	UpdatedAt          *time.Time `db:"updated_at"`
+}
+
+type UserCreateArg struct {
+	ID          string `db:"id"`
+	FullName    string `db:"full_name"`
+	PhoneNumber string `db:"phone_number"`
+	Password    string `db:"password"`
+}
+
+type UserPatchArg struct {
+	ID          string `db:"id"`
+	FullName    string `db:"full_name"`
+	PhoneNumber string `db:"phone_number"`
+	Version     int    `db:"version"`
+}
+
+type UserPasswordArg struct {
+	ID       string    `db:"id"`
+	Password string    `db:"password"`
+	Now      time.Time `db:"now"`
+}
+
+type PasswordHistoryArg struct {
+	ID       string `db:"id"`
+	UserID   string `db:"user_id"`
+	Password string `db:"password"`
+	Depth    int    `db:"depth"`
+}
+
+type UserMustChangeArg struct {
+	ID         string `db:"id"`
+	MustChange bool   `db:"must_change_password"`
+}
+
+// AuditEntryArg is written in the same transaction as the mutation it
+// records.
+type AuditEntryArg struct {
+	ID        string  `db:"id"`
+	UserID    string  `db:"user_id"`
+	ActorID   *string `db:"actor_id"`
+	Action    string  `db:"action"`
+	Changes   string  `db:"changes"`
+	RequestID string  `db:"request_id"`
+}
+
+type MFA struct {
+	UserID       string     `db:"user_id"`
+	TOTPSecret   string     `db:"totp_secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	CreatedAt    *time.Time `db:"created_at"`
+	LastTOTPStep int64      `db:"last_totp_step"`
+	Now          time.Time  `db:"now"`
+}
+
+type TOTPStepArg struct {
+	UserID string `db:"user_id"`
+	Step   int64  `db:"step"`
+}
+
+type MFAChallenge struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Attempts  int       `db:"attempts"`
+	ExpiresAt time.Time `db:"expires_at"`
+	Now       time.Time `db:"now"`
+}
+
+type MFAChallengePruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type RecoveryCode struct {
+	ID       string    `db:"id"`
+	UserID   string    `db:"user_id"`
+	CodeHash string    `db:"code_hash"`
+	Now      time.Time `db:"now"`
+}
+
+type Session struct {
+	ID         string     `db:"id"`
+	UserID     string     `db:"user_id"`
+	UserAgent  string     `db:"user_agent"`
+	IPAddress  string     `db:"ip_address"`
+	CreatedAt  *time.Time `db:"created_at"`
+	LastSeenAt *time.Time `db:"last_seen_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	Now        time.Time  `db:"now"`
+}
+
+type SessionRevokeArg struct {
+	UserID   string    `db:"user_id"`
+	ExceptID *string   `db:"id"`
+	Now      time.Time `db:"now"`
+}
+
+type LoginEvent struct {
+	ID          string     `db:"id"`
+	UserID      *string    `db:"user_id"`
+	PhoneNumber string     `db:"phone_number"`
+	Success     bool       `db:"success"`
+	Reason      string     `db:"reason"`
+	UserAgent   string     `db:"user_agent"`
+	IPAddress   string     `db:"ip_address"`
+	CreatedAt   *time.Time `db:"created_at"`
+}
+
+type LoginEventListArg struct {
+	UserID string `db:"user_id"`
+	Limit  int    `db:"limit"`
+	Offset int    `db:"offset"`
+}
+
+type LoginEventPruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type AuditEntry struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	ActorID   string     `db:"actor_id"`
+	Action    string     `db:"action"`
+	Changes   []byte     `db:"changes"`
+	RequestID string     `db:"request_id"`
+	CreatedAt *time.Time `db:"created_at"`
+}
+
+type AuditListArg struct {
+	UserID string     `db:"user_id"`
+	From   *time.Time `db:"from"`
+	To     *time.Time `db:"to"`
+	Limit  int        `db:"limit"`
+	Offset int        `db:"offset"`
+}
+
+type IdempotencyKey struct {
+	Caller       string    `db:"caller"`
+	Key          string    `db:"key"`
+	Fingerprint  string    `db:"fingerprint"`
+	StatusCode   int       `db:"status_code"`
+	ContentType  string    `db:"content_type"`
+	ResponseBody []byte    `db:"response_body"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	Now          time.Time `db:"now"`
+}
+
+type IdempotencyPruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type OutboxEvent struct {
+	Seq         int64     `db:"seq"`
+	ID          string    `db:"id"`
+	EventType   string    `db:"event_type"`
+	AggregateID string    `db:"aggregate_id"`
+	Payload     string    `db:"payload"`
+	OccurredAt  time.Time `db:"occurred_at"`
+	Attempts    int       `db:"attempts"`
+	LastError   string    `db:"last_error"`
+	Now         time.Time `db:"now"`
+}
+
+type OutboxClaimArg struct {
+	Limit       int       `db:"limit"`
+	LockedUntil time.Time `db:"locked_until"`
+	Now         time.Time `db:"now"`
+}
+
+type OutboxFailureArg struct {
+	ID             string     `db:"id"`
+	LastError      string     `db:"last_error"`
+	LockedUntil    *time.Time `db:"locked_until"`
+	DeadLetteredAt *time.Time `db:"dead_lettered_at"`
+}
+
+type OutboxPruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type Webhook struct {
+	ID         string     `db:"id"`
+	URL        string     `db:"url"`
+	EventTypes string     `db:"event_types"`
+	Secret     string     `db:"secret"`
+	CreatedAt  *time.Time `db:"created_at"`
+	Now        time.Time  `db:"now"`
+}
+
+type WebhookDelivery struct {
+	ID             string     `db:"id"`
+	WebhookID      string     `db:"webhook_id"`
+	EventID        string     `db:"event_id"`
+	EventType      string     `db:"event_type"`
+	Payload        string     `db:"payload"`
+	Status         string     `db:"status"`
+	Attempts       int        `db:"attempts"`
+	LastStatusCode int        `db:"last_status_code"`
+	LastError      string     `db:"last_error"`
+	NextAttemptAt  *time.Time `db:"next_attempt_at"`
+	CreatedAt      *time.Time `db:"created_at"`
+	DeliveredAt    *time.Time `db:"delivered_at"`
+	Now            time.Time  `db:"now"`
+}
+
+type WebhookClaimArg struct {
+	Status      string    `db:"status"`
+	Limit       int       `db:"limit"`
+	LockedUntil time.Time `db:"locked_until"`
+	Now         time.Time `db:"now"`
+}
+
+type WebhookDeliveryListArg struct {
+	WebhookID string `db:"webhook_id"`
+	Limit     int    `db:"limit"`
+	Offset    int    `db:"offset"`
+}
+
+type OAuthClient struct {
+	ID           string     `db:"id"`
+	Name         string     `db:"name"`
+	RedirectURIs string     `db:"redirect_uris"`
+	Confidential bool       `db:"confidential"`
+	Secret       string     `db:"secret"`
+	CreatedAt    *time.Time `db:"created_at"`
+	Now          time.Time  `db:"now"`
+}
+
+type OAuthCode struct {
+	CodeHash      string     `db:"code_hash"`
+	ClientID      string     `db:"client_id"`
+	UserID        string     `db:"user_id"`
+	SessionID     string     `db:"session_id"`
+	RedirectURI   string     `db:"redirect_uri"`
+	Scope         string     `db:"scope"`
+	Nonce         string     `db:"nonce"`
+	CodeChallenge string     `db:"code_challenge"`
+	AuthTime      time.Time  `db:"auth_time"`
+	ExpiresAt     time.Time  `db:"expires_at"`
+	CreatedAt     *time.Time `db:"created_at"`
+	Now           time.Time  `db:"now"`
+}
+
+type OAuthCodePruneArg struct {
+	Before time.Time `db:"before"`
+}
+
+type APIKey struct {
+	ID         string     `db:"id"`
+	Name       string     `db:"name"`
+	UserID     string     `db:"user_id"`
+	Prefix     string     `db:"prefix"`
+	KeyHash    string     `db:"key_hash"`
+	Scopes     string     `db:"scopes"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	RevokedAt  *time.Time `db:"revoked_at"`
+	CreatedAt  *time.Time `db:"created_at"`
+	Now        time.Time  `db:"now"`
+}
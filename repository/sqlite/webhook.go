@@ -0,0 +1,377 @@
+package sqlite
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/cons"
+	"github.com/SawitProRecruitment/UserService/core/domain"
+	"github.com/SawitProRecruitment/UserService/core/port"
+	"github.com/google/uuid"
+)
+
+var _ port.WebhookRepo = (*Repository)(nil)
+
+const webhookColumns = `id, url, event_types, secret, created_at`
+
+const webhookDeliveryColumns = `id, webhook_id, event_id, event_type, payload, status, attempts,
+	last_status_code, last_error, next_attempt_at, created_at, delivered_at`
+
+func (r *Repository) CreateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	q := `
+		INSERT INTO webhooks (id, url, event_types, secret, created_at, updated_at)
+		VALUES (:id, :url, :event_types, :secret, :now, :now)
+		RETURNING ` + webhookColumns + `;
+	`
+
+	eventTypes, err := json.Marshal(data.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := Webhook{
+		ID:         uuid.NewString(),
+		URL:        data.URL,
+		EventTypes: string(eventTypes),
+		Secret:     data.Secret,
+		Now:        now(),
+	}
+
+	return r.getWebhook(q, &arg)
+}
+
+func (r *Repository) ListWebhooks() ([]domain.Webhook, error) {
+	q := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		ORDER BY created_at, rowid;
+	`
+
+	rows, err := r.sawitDB.NamedQuery(q, &Webhook{})
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.Webhook{}
+	for rows.Next() {
+		w := Webhook{}
+		err = rows.StructScan(&w)
+		if err != nil {
+			return nil, err
+		}
+
+		webhook, err := toDomainWebhook(w)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, *webhook)
+	}
+
+	return res, rows.Err()
+}
+
+func (r *Repository) GetWebhook(id string) (*domain.Webhook, error) {
+	q := `
+		SELECT ` + webhookColumns + `
+		FROM webhooks
+		WHERE id = :id;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, cons.ErrWebhookNotFound
+	}
+
+	return r.getWebhook(q, &Webhook{ID: validID.String()})
+}
+
+func (r *Repository) UpdateWebhook(data *domain.Webhook) (*domain.Webhook, error) {
+	q := `
+		UPDATE webhooks SET
+			url = :url,
+			event_types = :event_types,
+			secret = COALESCE(NULLIF(:secret, ''), secret),
+			updated_at = :now
+		WHERE id = :id
+		RETURNING ` + webhookColumns + `;
+	`
+
+	validID, err := uuid.Parse(data.ID)
+	if err != nil {
+		return nil, cons.ErrWebhookNotFound
+	}
+
+	eventTypes, err := json.Marshal(data.EventTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	arg := Webhook{
+		ID:         validID.String(),
+		URL:        data.URL,
+		EventTypes: string(eventTypes),
+		Secret:     data.Secret,
+		Now:        now(),
+	}
+
+	return r.getWebhook(q, &arg)
+}
+
+func (r *Repository) DeleteWebhook(id string) error {
+	q := `
+		DELETE FROM webhooks
+		WHERE id = :id
+		RETURNING ` + webhookColumns + `;
+	`
+
+	validID, err := uuid.Parse(id)
+	if err != nil {
+		return cons.ErrWebhookNotFound
+	}
+
+	_, err = r.getWebhook(q, &Webhook{ID: validID.String()})
+	return err
+}
+
+// getWebhook runs q returning one webhook, no row is cons.ErrWebhookNotFound.
+func (r *Repository) getWebhook(q string, arg *Webhook) (*domain.Webhook, error) {
+	w := Webhook{}
+	found, err := r.get(q, arg, &w)
+	if err != nil {
+		return nil, err
+	}
+
+	if !found {
+		return nil, cons.ErrWebhookNotFound
+	}
+
+	return toDomainWebhook(w)
+}
+
+func toDomainWebhook(w Webhook) (*domain.Webhook, error) {
+	eventTypes := []string{}
+	if err := json.Unmarshal([]byte(w.EventTypes), &eventTypes); err != nil {
+		return nil, err
+	}
+
+	return &domain.Webhook{
+		ID:         w.ID,
+		URL:        w.URL,
+		EventTypes: eventTypes,
+		Secret:     w.Secret,
+		CreatedAt:  w.CreatedAt,
+	}, nil
+}
+
+func (r *Repository) EnqueueWebhookDeliveries(event *domain.Event) (int64, error) {
+	eventID, err := uuid.Parse(event.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return 0, err
+	}
+
+	arg := WebhookDelivery{
+		EventID:   eventID.String(),
+		EventType: event.Type,
+		Payload:   string(payload),
+		Now:       now(),
+	}
+
+	var n int64
+	err = r.withTx(func(tx *Repository) error {
+		rows, err := tx.sawitDB.NamedQuery(`
+			SELECT id FROM webhooks
+			WHERE EXISTS (SELECT 1 FROM json_each(webhooks.event_types) WHERE value = :event_type);
+		`, &arg)
+		if err != nil {
+			return err
+		}
+
+		webhookIDs := []string{}
+		for rows.Next() {
+			id := ""
+			if err = rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+
+			webhookIDs = append(webhookIDs, id)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return err
+		}
+
+		for _, id := range webhookIDs {
+			arg.ID = uuid.NewString()
+			arg.WebhookID = id
+
+			res, err := tx.sawitDB.NamedExec(`
+				INSERT INTO webhook_deliveries (id, webhook_id, event_id, event_type, payload, next_attempt_at, created_at)
+				VALUES (:id, :webhook_id, :event_id, :event_type, :payload, :now, :now)
+				ON CONFLICT (webhook_id, event_id) DO NOTHING;
+			`, &arg)
+			if err != nil {
+				return err
+			}
+
+			inserted, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			n += inserted
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+func (r *Repository) ClaimWebhookDeliveries(limit int, lease time.Duration) ([]domain.WebhookDelivery, error) {
+	q := `
+		UPDATE webhook_deliveries SET locked_until = :locked_until
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = :status
+			AND next_attempt_at <= :now
+			AND (locked_until IS NULL OR locked_until <= :now)
+			ORDER BY next_attempt_at
+			LIMIT :limit
+		)
+		RETURNING ` + webhookDeliveryColumns + `;
+	`
+
+	t := now()
+	arg := WebhookClaimArg{
+		Status:      cons.WebhookStatusPending,
+		Limit:       limit,
+		LockedUntil: t.Add(lease),
+		Now:         t,
+	}
+
+	res, err := r.listWebhookDeliveries(q, &arg)
+	if err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the subquery
+	sort.SliceStable(res, func(i, j int) bool { return res[i].NextAttemptAt.Before(*res[j].NextAttemptAt) })
+	return res, nil
+}
+
+func (r *Repository) RecordWebhookAttempt(attempt domain.WebhookAttempt) error {
+	q := `
+		UPDATE webhook_deliveries SET
+			status = :status,
+			attempts = attempts + 1,
+			last_status_code = :last_status_code,
+			last_error = :last_error,
+			next_attempt_at = :next_attempt_at,
+			delivered_at = :delivered_at,
+			locked_until = NULL
+		WHERE id = :id;
+	`
+
+	arg := WebhookDelivery{
+		ID:             attempt.DeliveryID,
+		Status:         attempt.Status,
+		LastStatusCode: attempt.StatusCode,
+		LastError:      attempt.Error,
+		NextAttemptAt:  utc(&attempt.NextAttemptAt),
+	}
+	if attempt.Status == cons.WebhookStatusSucceeded {
+		t := now()
+		arg.DeliveredAt = &t
+	}
+
+	_, err := r.sawitDB.NamedExec(q, &arg)
+	return err
+}
+
+func (r *Repository) ListWebhookDeliveries(webhookID string, limit int, offset int) ([]domain.WebhookDelivery, int, error) {
+	q := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = :webhook_id
+		ORDER BY created_at DESC, rowid DESC
+		LIMIT :limit OFFSET :offset;
+	`
+
+	validID, err := uuid.Parse(webhookID)
+	if err != nil {
+		return nil, 0, cons.ErrWebhookNotFound
+	}
+
+	arg := WebhookDeliveryListArg{
+		WebhookID: validID.String(),
+		Limit:     limit,
+		Offset:    offset,
+	}
+
+	res, err := r.listWebhookDeliveries(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	q = `
+		SELECT COUNT(*) FROM webhook_deliveries
+		WHERE webhook_id = :webhook_id;
+	`
+
+	countRows, err := r.sawitDB.NamedQuery(q, &arg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer countRows.Close()
+
+	total := 0
+	if countRows.Next() {
+		err = countRows.Scan(&total)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return res, total, countRows.Err()
+}
+
+func (r *Repository) listWebhookDeliveries(q string, arg interface{}) ([]domain.WebhookDelivery, error) {
+	rows, err := r.sawitDB.NamedQuery(q, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []domain.WebhookDelivery{}
+	for rows.Next() {
+		d := WebhookDelivery{}
+		err = rows.StructScan(&d)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, domain.WebhookDelivery{
+			ID:             d.ID,
+			WebhookID:      d.WebhookID,
+			EventID:        d.EventID,
+			EventType:      d.EventType,
+			Payload:        []byte(d.Payload),
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			LastStatusCode: d.LastStatusCode,
+			LastError:      d.LastError,
+			NextAttemptAt:  d.NextAttemptAt,
+			CreatedAt:      d.CreatedAt,
+			DeliveredAt:    d.DeliveredAt,
+		})
+	}
+
+	return res, rows.Err()
+}